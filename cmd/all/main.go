@@ -10,6 +10,8 @@ import (
 
 	"github.com/adeilh/agentic_go_signals/internal/config"
 	"github.com/adeilh/agentic_go_signals/internal/db"
+	"github.com/adeilh/agentic_go_signals/internal/notifications"
+	"github.com/adeilh/agentic_go_signals/internal/predictor"
 	"github.com/adeilh/agentic_go_signals/internal/svc"
 	"github.com/adeilh/agentic_go_signals/internal/worker"
 	"golang.org/x/sync/errgroup"
@@ -50,7 +52,14 @@ func main() {
 	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer stop()
 	g, ctx := errgroup.WithContext(ctx)
-	g.Go(func() error { return worker.Start(ctx, svc.DB) })
+	manager := worker.NewManager(svc.DB, svc.KClient, svc.App.MarketData(), svc.Broker, svc.Exchange)
+	manager.SetNotifier(notifications.NewNotifier(cfg))
+	svc.App.SetBotHalter(manager)
+	g.Go(func() error { return manager.Run(ctx) })
+	if svc.UserData != nil {
+		g.Go(func() error { return svc.UserData.Run(ctx) })
+	}
+	g.Go(func() error { return predictor.RunLabeler(ctx, svc.DB, cfg.LabelHorizon) })
 	g.Go(func() error { return svc.App.Listen(":3333") })
 	if err := g.Wait(); err != nil {
 		panic(err)
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/adeilh/agentic_go_signals/internal/backfill"
+	"github.com/adeilh/agentic_go_signals/internal/config"
+	"github.com/adeilh/agentic_go_signals/internal/db"
+	"github.com/adeilh/agentic_go_signals/internal/exchange"
+)
+
+func main() {
+	symbols := flag.String("symbol", "BTCUSDT", "Symbols to backfill, comma separated")
+	interval := flag.String("interval", "1m", "Kline interval")
+	from := flag.String("from", "", "Start of the range (RFC3339 or YYYY-MM-DD, default 7 days ago)")
+	to := flag.String("to", "", "End of the range (RFC3339 or YYYY-MM-DD, default now)")
+	weight := flag.Int("weight", backfill.DefaultWeightPerMinute, "Request weight to use per minute")
+	flag.Parse()
+
+	end := time.Now()
+	if *to != "" {
+		t, err := parseTime(*to)
+		if err != nil {
+			log.Fatalf("Invalid -to: %v", err)
+		}
+		end = t
+	}
+	start := end.Add(-7 * 24 * time.Hour)
+	if *from != "" {
+		t, err := parseTime(*from)
+		if err != nil {
+			log.Fatalf("Invalid -from: %v", err)
+		}
+		start = t
+	}
+
+	database, err := db.Open(config.LoadDSN())
+	if err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+	defer database.GetConn().Close()
+
+	// Klines are public market data, so no API keys are needed
+	market, err := exchange.New(exchange.BinanceName, exchange.Config{Production: true})
+	if err != nil {
+		log.Fatalf("Failed to create exchange: %v", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	backfiller := backfill.New(market, database, backfill.NewLimiter(*weight))
+	failed := false
+	for _, symbol := range strings.Split(*symbols, ",") {
+		result, err := backfiller.Run(ctx, backfill.Request{
+			Symbol:   symbol,
+			Interval: *interval,
+			From:     start,
+			To:       end,
+		})
+		if result != nil {
+			printResult(result)
+		}
+		if err != nil {
+			log.Printf("Backfill of %s failed: %v", symbol, err)
+			failed = true
+			if ctx.Err() != nil {
+				break
+			}
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// parseTime accepts RFC3339 timestamps or plain dates
+func parseTime(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.Parse("2006-01-02", value)
+}
+
+func printResult(r *backfill.Result) {
+	fmt.Printf("%s %s %s - %s\n", r.Symbol, r.Interval, r.From.Format(time.RFC3339), r.To.Format(time.RFC3339))
+	fmt.Printf("  Klines:   %d expected, %d missing in %d gaps\n", r.Expected, r.Missing, len(r.Gaps))
+	fmt.Printf("  Stored:   %d (%d unavailable from the exchange)\n", r.Stored, r.Unfilled)
+	fmt.Printf("  Requests: %d\n", r.Requests)
+}
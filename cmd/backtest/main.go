@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/adeilh/agentic_go_signals/internal/backtest"
+	"github.com/adeilh/agentic_go_signals/internal/config"
+	"github.com/adeilh/agentic_go_signals/internal/db"
+	"github.com/adeilh/agentic_go_signals/internal/exchange"
+	"github.com/adeilh/agentic_go_signals/internal/risk"
+	"github.com/adeilh/agentic_go_signals/internal/strategy"
+)
+
+func main() {
+	symbol := flag.String("symbol", "BTCUSDT", "Symbol to backtest")
+	interval := flag.String("interval", "1m", "Kline interval")
+	from := flag.String("from", "", "Start of the range (RFC3339 or YYYY-MM-DD, default 7 days ago)")
+	to := flag.String("to", "", "End of the range (RFC3339 or YYYY-MM-DD, default now)")
+	strategyName := flag.String("strategy", strategy.NameSMACross, "Strategy: sma_cross, rules or predictions")
+	botID := flag.String("bot", "", "Bot whose predictions are replayed by the predictions strategy")
+	source := flag.String("source", backtest.SourceDB, "Kline source: db or binance")
+	feeRate := flag.Float64("fee", 0.001, "Fee rate per fill, e.g. 0.001 for 0.1%")
+	slippage := flag.Float64("slippage", 5, "Slippage per fill in basis points")
+	balance := flag.Float64("balance", risk.DefaultParams().AccountBalance, "Starting equity")
+	trades := flag.Bool("trades", false, "Print the trade list")
+	jsonOut := flag.Bool("json", false, "Print the full result as JSON")
+	flag.Parse()
+
+	end := time.Now()
+	if *to != "" {
+		t, err := parseTime(*to)
+		if err != nil {
+			log.Fatalf("Invalid -to: %v", err)
+		}
+		end = t
+	}
+	start := end.Add(-7 * 24 * time.Hour)
+	if *from != "" {
+		t, err := parseTime(*from)
+		if err != nil {
+			log.Fatalf("Invalid -from: %v", err)
+		}
+		start = t
+	}
+
+	// The database is only needed for stored klines and predictions
+	var database *db.DB
+	if *source == backtest.SourceDB || *strategyName == backtest.StrategyPredictions {
+		var err error
+		database, err = db.Open(config.LoadDSN())
+		if err != nil {
+			log.Fatalf("Failed to connect to database: %v", err)
+		}
+		defer database.GetConn().Close()
+	}
+
+	// Klines are public market data, so no API keys are needed
+	market, err := exchange.New(exchange.BinanceName, exchange.Config{Production: true})
+	if err != nil {
+		log.Fatalf("Failed to create exchange: %v", err)
+	}
+
+	sym := strings.ToUpper(*symbol)
+	bars, err := backtest.LoadBars(database, market, *source, sym, *interval, start, end)
+	if err != nil {
+		log.Fatalf("Failed to load klines: %v", err)
+	}
+
+	strat, err := backtest.NewStrategy(*strategyName, database, *botID, sym, start, end)
+	if err != nil {
+		log.Fatalf("Failed to create strategy: %v", err)
+	}
+
+	params := risk.DefaultParams()
+	params.AccountBalance = *balance
+	result, err := backtest.Run(context.Background(), bars, strat, backtest.Config{
+		Symbol:      sym,
+		Interval:    *interval,
+		RiskParams:  params,
+		FeeRate:     *feeRate,
+		SlippageBps: *slippage,
+	})
+	if err != nil {
+		log.Fatalf("Backtest failed: %v", err)
+	}
+
+	if *jsonOut {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(result); err != nil {
+			log.Fatalf("Failed to encode result: %v", err)
+		}
+		return
+	}
+
+	printSummary(result, start, end)
+	if *trades {
+		printTrades(result.Trades)
+	}
+}
+
+// parseTime accepts RFC3339 timestamps or plain dates
+func parseTime(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.Parse("2006-01-02", value)
+}
+
+func printSummary(r *backtest.Result, start, end time.Time) {
+	fmt.Printf("Strategy:      %s\n", r.Strategy)
+	fmt.Printf("Symbol:        %s %s\n", r.Symbol, r.Interval)
+	fmt.Printf("Range:         %s - %s (%d bars)\n", start.Format(time.RFC3339), end.Format(time.RFC3339), r.Bars)
+	fmt.Printf("Equity:        %.2f -> %.2f\n", r.StartEquity, r.FinalEquity)
+	fmt.Printf("Total return:  %.2f%%\n", r.TotalReturn*100)
+	fmt.Printf("Max drawdown:  %.2f%%\n", r.MaxDrawdown*100)
+	fmt.Printf("Sharpe:        %.2f\n", r.Sharpe)
+	fmt.Printf("Trades:        %d (win rate %.1f%%)\n", len(r.Trades), r.WinRate*100)
+	fmt.Printf("Fees:          %.2f\n", r.TotalFees)
+}
+
+func printTrades(trades []backtest.Trade) {
+	fmt.Println()
+	for _, t := range trades {
+		fmt.Printf("%-5s %s %.2f -> %s %.2f qty=%.5f pnl=%.2f (%s)\n",
+			t.Side, t.EntryTime.Format(time.RFC3339), t.EntryPrice,
+			t.ExitTime.Format(time.RFC3339), t.ExitPrice, t.Qty, t.PnL, t.ExitReason)
+	}
+}
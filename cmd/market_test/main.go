@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/adeilh/agentic_go_signals/internal/config"
+	"github.com/adeilh/agentic_go_signals/internal/exchange"
 	"github.com/adeilh/agentic_go_signals/internal/services"
 	"github.com/adeilh/agentic_go_signals/internal/trader"
 )
@@ -113,9 +114,7 @@ func testMarketDataMethods(client *trader.Client) {
 		log.Printf("  ⚠️  Exchange info test failed: %v", err)
 	} else {
 		log.Printf("  ✅ Exchange info retrieved successfully")
-		if symbols, ok := info["symbols"].([]interface{}); ok {
-			log.Printf("  📈 Available symbols: %d", len(symbols))
-		}
+		log.Printf("  📈 Available symbols: %d", len(info.Symbols))
 	}
 
 	log.Println("  ✅ Market data methods test completed")
@@ -147,7 +146,7 @@ func testMarketDataService(client *trader.Client) {
 	hub := trader.NewWSHub()
 	go hub.Run()
 
-	service := services.NewMarketDataService(client, hub, nil) // Pass nil for DB in test
+	service := services.NewMarketDataService(exchange.NewBinance(client), hub, nil) // Pass nil for DB in test
 
 	// Test service status
 	log.Printf("  📊 Service running: %v", service.IsRunning())
@@ -160,7 +159,7 @@ func testMarketDataService(client *trader.Client) {
 	// Test getting market summary
 	log.Println("  📈 Testing market summary...")
 	summary := service.GetMarketSummary()
-	log.Printf("  📊 Market summary: %d symbols", summary["total_symbols"])
+	log.Printf("  📊 Market summary: %d symbols", summary.TotalSymbols)
 
 	// Test getting all prices (initially empty)
 	prices := service.GetAllPrices()
@@ -0,0 +1,39 @@
+package api
+
+import (
+	"github.com/adeilh/agentic_go_signals/internal/services"
+	"github.com/adeilh/agentic_go_signals/internal/trader"
+	"github.com/gofiber/fiber/v2"
+)
+
+// SetUserData exposes the exchange account followed by userData through
+// the API
+func (a *App) SetUserData(userData *services.UserDataService) {
+	a.userData = userData
+}
+
+// WSHub returns the hub that market and account updates are broadcast on
+func (a *App) WSHub() *trader.WSHub {
+	return a.wsHub
+}
+
+// getAccountBalances returns the exchange account's balances as last
+// reported by the user data stream
+func (a *App) getAccountBalances(c *fiber.Ctx) error {
+	if a.userData == nil {
+		return c.Status(404).JSON(fiber.Map{
+			"status": "error",
+			"error":  "Live trading is disabled",
+		})
+	}
+
+	balances, updatedAt := a.userData.Balances()
+	return c.JSON(fiber.Map{
+		"status": "success",
+		"data": fiber.Map{
+			"balances":   balances,
+			"updated_at": updatedAt,
+			"streaming":  a.userData.IsRunning(),
+		},
+	})
+}
@@ -6,11 +6,19 @@ import (
 	"fmt"
 	"log"
 	"strconv"
+	"strings"
 	"time"
 
+	"github.com/adeilh/agentic_go_signals/internal/backfill"
 	"github.com/adeilh/agentic_go_signals/internal/db"
+	"github.com/adeilh/agentic_go_signals/internal/exchange"
+	"github.com/adeilh/agentic_go_signals/internal/indicators"
 	"github.com/adeilh/agentic_go_signals/internal/kimi"
+	"github.com/adeilh/agentic_go_signals/internal/paper"
+	"github.com/adeilh/agentic_go_signals/internal/predictor"
+	"github.com/adeilh/agentic_go_signals/internal/risk"
 	"github.com/adeilh/agentic_go_signals/internal/services"
+	"github.com/adeilh/agentic_go_signals/internal/strategy"
 	"github.com/adeilh/agentic_go_signals/internal/trader"
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
@@ -24,8 +32,12 @@ type App struct {
 	wsHub             *trader.WSHub
 	hub               *Hub // Legacy hub for backward compatibility
 	marketDataService *services.MarketDataService
-	binanceClient     *trader.Client
+	exchange          exchange.Exchange
 	kimiClient        *kimi.Client
+	paperBroker       *paper.Broker
+	userData          *services.UserDataService
+	backfills         *backfill.Jobs
+	halter            BotHalter
 }
 
 // Legacy Hub struct for backward compatibility with existing WebSocket implementation
@@ -70,7 +82,7 @@ func (h *Hub) run() {
 	}
 }
 
-func New(database *db.DB, binanceClient *trader.Client, kimiClient *kimi.Client) *App {
+func New(database *db.DB, ex exchange.Exchange, kimiClient *kimi.Client) *App {
 	app := fiber.New(fiber.Config{
 		AppName:      "SigForge API v1.0",
 		ServerHeader: "SigForge",
@@ -85,7 +97,7 @@ func New(database *db.DB, binanceClient *trader.Client, kimiClient *kimi.Client)
 	go wsHub.Run()
 
 	// Create market data service
-	marketDataService := services.NewMarketDataService(binanceClient, wsHub, database)
+	marketDataService := services.NewMarketDataService(ex, wsHub, database)
 
 	// Legacy hub for backward compatibility
 	legacyHub := newHub()
@@ -100,8 +112,9 @@ func New(database *db.DB, binanceClient *trader.Client, kimiClient *kimi.Client)
 		wsHub:             wsHub,
 		hub:               legacyHub,
 		marketDataService: marketDataService,
-		binanceClient:     binanceClient,
+		exchange:          ex,
 		kimiClient:        kimiClient,
+		backfills:         backfill.NewJobs(backfill.New(ex, database, backfill.NewLimiter(backfill.DefaultWeightPerMinute))),
 	}
 
 	apiApp.setupRoutes()
@@ -118,7 +131,14 @@ func (a *App) setupRoutes() {
 
 	// Bot management
 	a.app.Post("/bot/create", a.createBot)
+	a.app.Get("/bots", a.listBots)
 	a.app.Get("/bot/:botId", a.getBotInfo)
+	a.app.Put("/bot/:botId", a.updateBot)
+	a.app.Delete("/bot/:botId", a.deleteBot)
+	a.app.Get("/bot/:botId/paper", a.getPaperAccount)
+
+	// Live exchange account
+	a.app.Get("/account/balances", a.getAccountBalances)
 
 	// Ingestion
 	a.app.Post("/ingest/manual", a.manualIngest)
@@ -130,18 +150,34 @@ func (a *App) setupRoutes() {
 	// Predictions
 	a.app.Get("/predictions/latest", a.getLatestPrediction)
 	a.app.Get("/predictions/history", a.getPredictionHistory)
+	a.app.Get("/predictions/scorecard", a.getPredictionScorecard)
 
 	// Trades
 	a.app.Get("/trades/latest", a.getLatestTrades)
 
+	// Positions and PnL
+	a.app.Get("/positions", a.getPositions)
+	a.app.Get("/pnl/daily", a.getDailyPnL)
+	a.app.Get("/pnl/equity-curve", a.getEquityCurve)
+
+	// Risk controls
+	a.app.Post("/risk/kill-switch", a.killSwitch)
+	a.app.Get("/risk/guards", a.listRiskGuards)
+	a.app.Get("/risk/audit", a.listOrderAudit)
+
+	// Backtesting
+	a.app.Post("/backtest", a.runBacktest)
+
 	// Market Data Endpoints
 	a.app.Get("/market/prices", a.getAllPrices)
 	a.app.Get("/market/prices/:symbol", a.getSymbolPrice)
 	a.app.Get("/market/ticker/:symbol", a.getSymbolTicker)
 	a.app.Get("/market/orderbook/:symbol", a.getOrderBook)
+	a.app.Get("/market/book/:symbol", a.getLocalOrderBook)
 	a.app.Get("/market/trades/:symbol", a.getRecentTrades)
 	a.app.Get("/market/klines/:symbol", a.getKlines)
 	a.app.Get("/market/summary", a.getMarketSummary)
+	a.app.Get("/market/indicators/:symbol", a.getIndicators)
 
 	// Market Data Service Control
 	a.app.Post("/market/start", a.startMarketData)
@@ -149,16 +185,26 @@ func (a *App) setupRoutes() {
 	a.app.Get("/market/status", a.getMarketDataStatus)
 	a.app.Post("/market/symbols", a.addSymbol)
 
+	// Kline backfill jobs
+	a.app.Post("/market/backfill", a.startBackfill)
+	a.app.Get("/market/backfill", a.listBackfills)
+	a.app.Get("/market/backfill/:id", a.getBackfill)
+
 	// TiDB-backed market data endpoints
 	a.app.Get("/market/tidb/prices", a.getTiDBPrices)
 	a.app.Get("/market/tidb/signals/:symbol", a.getTradingSignals)
 	a.app.Get("/market/tidb/history/:symbol", a.getPriceHistory)
 	a.app.Get("/market/tidb/volume/:symbol", a.getVolumeAnalysis)
+	a.app.Get("/market/tidb/klines/:symbol", a.getStoredKlines)
 
 	// Kimi AI signals endpoint
 	a.app.Get("/kimi/signals/:symbol", a.getKimiSignals)
 	a.app.Get("/kimi/enhanced/:symbol", a.getKimiSignals)
 
+	// Strategy registry
+	a.app.Get("/strategies", a.listStrategies)
+	a.app.Get("/strategies/:name/signal/:symbol", a.getStrategySignal)
+
 	// Advanced TiDB Analytics endpoints
 	a.app.Get("/tidb/advanced/:symbol", a.getAdvancedAnalytics)
 	a.app.Get("/tidb/realtime/:symbol", a.getRealTimeState)
@@ -195,27 +241,6 @@ func (a *App) healthCheck(c *fiber.Ctx) error {
 	})
 }
 
-func (a *App) createBot(c *fiber.Ctx) error {
-	botID := "bot_" + strconv.FormatInt(time.Now().UnixNano(), 36)
-
-	return c.JSON(fiber.Map{
-		"bot_id":     botID,
-		"created_at": time.Now().Unix(),
-		"status":     "active",
-	})
-}
-
-func (a *App) getBotInfo(c *fiber.Ctx) error {
-	botID := c.Params("botId")
-
-	return c.JSON(fiber.Map{
-		"bot_id": botID,
-		"status": "active",
-		"trades": 0,
-		"pnl":    0.0,
-	})
-}
-
 func (a *App) manualIngest(c *fiber.Ctx) error {
 	botID := c.Query("bot_id", "default")
 
@@ -236,78 +261,262 @@ func (a *App) manualIngest(c *fiber.Ctx) error {
 }
 
 func (a *App) getCurrentSignal(c *fiber.Ctx) error {
-	botID := c.Query("bot_id", "default")
+	filter, err := parseHistoryFilter(c)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"status": "error",
+			"error":  err.Error(),
+		})
+	}
+	filter.Limit, filter.Offset = 1, 0
 
-	return c.JSON(fiber.Map{
-		"bot_id":    botID,
-		"symbol":    "BTC",
-		"side":      "LONG",
-		"conv":      85,
-		"timestamp": time.Now().Unix(),
-		"logic":     "Strong bullish momentum with high volume",
-	})
+	signals, err := predictor.List(a.db, filter, true)
+	if err != nil {
+		log.Printf("Failed to get current signal: %v", err)
+		return c.Status(500).JSON(fiber.Map{
+			"status": "error",
+			"error":  "Failed to get current signal",
+		})
+	}
+	if len(signals) == 0 {
+		return c.Status(404).JSON(fiber.Map{
+			"status": "error",
+			"error":  "No signal found",
+		})
+	}
+
+	return c.JSON(signalResponse(signals[0]))
 }
 
 func (a *App) getSignalHistory(c *fiber.Ctx) error {
-	botID := c.Query("bot_id", "default")
+	filter, err := parseHistoryFilter(c)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"status": "error",
+			"error":  err.Error(),
+		})
+	}
+
+	predictions, err := predictor.List(a.db, filter, true)
+	if err != nil {
+		log.Printf("Failed to get signal history: %v", err)
+		return c.Status(500).JSON(fiber.Map{
+			"status": "error",
+			"error":  "Failed to get signal history",
+		})
+	}
+
+	signals := make([]fiber.Map, 0, len(predictions))
+	for _, prediction := range predictions {
+		signals = append(signals, signalResponse(prediction))
+	}
 
 	return c.JSON(fiber.Map{
-		"bot_id": botID,
-		"signals": []fiber.Map{
-			{
-				"symbol":    "BTC",
-				"side":      "LONG",
-				"conv":      85,
-				"timestamp": time.Now().Unix() - 3600,
-			},
-			{
-				"symbol":    "ETH",
-				"side":      "SHORT",
-				"conv":      72,
-				"timestamp": time.Now().Unix() - 7200,
-			},
-		},
+		"bot_id":  filter.BotID,
+		"signals": signals,
+		"count":   len(signals),
+		"limit":   filter.Limit,
+		"offset":  filter.Offset,
 	})
 }
 
 func (a *App) getLatestPrediction(c *fiber.Ctx) error {
+	filter, err := parseHistoryFilter(c)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"status": "error",
+			"error":  err.Error(),
+		})
+	}
+	filter.Limit, filter.Offset = 1, 0
+
+	predictions, err := predictor.List(a.db, filter, false)
+	if err != nil {
+		log.Printf("Failed to get latest prediction: %v", err)
+		return c.Status(500).JSON(fiber.Map{
+			"status": "error",
+			"error":  "Failed to get latest prediction",
+		})
+	}
+	if len(predictions) == 0 {
+		return c.Status(404).JSON(fiber.Map{
+			"status": "error",
+			"error":  "No prediction found",
+		})
+	}
+
+	return c.JSON(predictionResponse(predictions[0]))
+}
+
+func (a *App) getPredictionHistory(c *fiber.Ctx) error {
+	filter, err := parseHistoryFilter(c)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"status": "error",
+			"error":  err.Error(),
+		})
+	}
+
+	predictions, err := predictor.List(a.db, filter, false)
+	if err != nil {
+		log.Printf("Failed to get prediction history: %v", err)
+		return c.Status(500).JSON(fiber.Map{
+			"status": "error",
+			"error":  "Failed to get prediction history",
+		})
+	}
+
+	results := make([]fiber.Map, 0, len(predictions))
+	for _, prediction := range predictions {
+		results = append(results, predictionResponse(prediction))
+	}
+
 	return c.JSON(fiber.Map{
-		"symbol":    "BTC",
-		"dir":       "LONG",
-		"conv":      85,
-		"logic":     "Strong institutional adoption and technical breakout",
-		"timestamp": time.Now().Unix(),
+		"predictions": results,
+		"count":       len(results),
+		"limit":       filter.Limit,
+		"offset":      filter.Offset,
 	})
 }
 
-func (a *App) getPredictionHistory(c *fiber.Ctx) error {
+// getPredictionScorecard reports hit rate, conviction deciles, calibration
+// and Brier score for labeled predictions, per bot and symbol
+func (a *App) getPredictionScorecard(c *fiber.Ctx) error {
+	filter, err := parseHistoryFilter(c)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"status": "error",
+			"error":  err.Error(),
+		})
+	}
+
+	scorecards, err := predictor.GetScorecards(a.db, filter)
+	if err != nil {
+		log.Printf("Failed to compute prediction scorecard: %v", err)
+		return c.Status(500).JSON(fiber.Map{
+			"status": "error",
+			"error":  "Failed to compute prediction scorecard",
+		})
+	}
+
 	return c.JSON(fiber.Map{
-		"predictions": []fiber.Map{
-			{
-				"symbol":    "BTC",
-				"dir":       "LONG",
-				"conv":      85,
-				"timestamp": time.Now().Unix() - 3600,
-			},
-		},
+		"status":    "success",
+		"data":      scorecards,
+		"count":     len(scorecards),
+		"timestamp": time.Now(),
+		"source":    "TiDB Prediction Analytics",
 	})
 }
 
 func (a *App) getLatestTrades(c *fiber.Ctx) error {
+	filter, err := parseHistoryFilter(c)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"status": "error",
+			"error":  err.Error(),
+		})
+	}
+
+	trades, err := db.NewTradeStore(a.db).List(filter)
+	if err != nil {
+		log.Printf("Failed to get trades: %v", err)
+		return c.Status(500).JSON(fiber.Map{
+			"status": "error",
+			"error":  "Failed to get trades",
+		})
+	}
+
+	results := make([]fiber.Map, 0, len(trades))
+	for _, trade := range trades {
+		results = append(results, fiber.Map{
+			"id":        trade.ID,
+			"bot_id":    trade.BotID,
+			"symbol":    trade.Symbol,
+			"side":      trade.Side,
+			"qty":       trade.Qty,
+			"price":     trade.Price,
+			"fee":       trade.Fee,
+			"base_fee":  trade.BaseFee,
+			"status":    trade.Status,
+			"timestamp": trade.Ts.Unix(),
+		})
+	}
+
 	return c.JSON(fiber.Map{
-		"trades": []fiber.Map{
-			{
-				"symbol":    "BTCUSDT",
-				"side":      "BUY",
-				"qty":       0.001,
-				"price":     45000.00,
-				"status":    "FILLED",
-				"timestamp": time.Now().Unix(),
-			},
-		},
+		"trades": results,
+		"count":  len(results),
+		"limit":  filter.Limit,
+		"offset": filter.Offset,
 	})
 }
 
+// parseHistoryFilter reads the bot_id, symbol, from, to, limit and offset
+// query parameters shared by the history endpoints
+func parseHistoryFilter(c *fiber.Ctx) (db.QueryFilter, error) {
+	filter := db.QueryFilter{
+		BotID:  c.Query("bot_id"),
+		Symbol: strings.ToUpper(c.Query("symbol")),
+		Limit:  c.QueryInt("limit", 50),
+		Offset: c.QueryInt("offset", 0),
+	}
+
+	if filter.Limit <= 0 || filter.Limit > 500 {
+		return filter, fmt.Errorf("limit must be between 1 and 500")
+	}
+	if filter.Offset < 0 {
+		return filter, fmt.Errorf("offset must not be negative")
+	}
+
+	var err error
+	if filter.From, err = parseTimeParam(c.Query("from")); err != nil {
+		return filter, fmt.Errorf("invalid from: %w", err)
+	}
+	if filter.To, err = parseTimeParam(c.Query("to")); err != nil {
+		return filter, fmt.Errorf("invalid to: %w", err)
+	}
+	if !filter.From.IsZero() && !filter.To.IsZero() && filter.To.Before(filter.From) {
+		return filter, fmt.Errorf("to must not be before from")
+	}
+
+	return filter, nil
+}
+
+// parseTimeParam accepts a unix timestamp in seconds or an RFC3339 time
+func parseTimeParam(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(seconds, 0), nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
+func signalResponse(prediction db.Prediction) fiber.Map {
+	return fiber.Map{
+		"id":        prediction.ID,
+		"bot_id":    prediction.BotID,
+		"symbol":    prediction.Symbol,
+		"side":      prediction.Dir,
+		"conv":      prediction.Conv,
+		"timestamp": prediction.Ts.Unix(),
+		"logic":     prediction.Logic,
+	}
+}
+
+func predictionResponse(prediction db.Prediction) fiber.Map {
+	return fiber.Map{
+		"id":        prediction.ID,
+		"bot_id":    prediction.BotID,
+		"symbol":    prediction.Symbol,
+		"dir":       prediction.Dir,
+		"conv":      prediction.Conv,
+		"logic":     prediction.Logic,
+		"fwd_ret":   prediction.FwdRet,
+		"timestamp": prediction.Ts.Unix(),
+	}
+}
+
 func (a *App) handleLegacyWebSocket(c *websocket.Conn) {
 	defer c.Close()
 
@@ -353,7 +562,7 @@ func (a *App) getSymbolPrice(c *fiber.Ctx) error {
 func (a *App) getSymbolTicker(c *fiber.Ctx) error {
 	symbol := c.Params("symbol")
 
-	tickers, err := a.binanceClient.GetTicker24hr(symbol)
+	tickers, err := a.exchange.GetTicker24hr(symbol)
 	if err != nil {
 		return c.Status(500).JSON(fiber.Map{
 			"status": "error",
@@ -378,7 +587,7 @@ func (a *App) getOrderBook(c *fiber.Ctx) error {
 	symbol := c.Params("symbol")
 	limit := c.QueryInt("limit", 100)
 
-	orderBook, err := a.binanceClient.GetOrderBook(symbol, limit)
+	orderBook, err := a.exchange.GetOrderBook(symbol, limit)
 	if err != nil {
 		return c.Status(500).JSON(fiber.Map{
 			"status": "error",
@@ -396,7 +605,7 @@ func (a *App) getRecentTrades(c *fiber.Ctx) error {
 	symbol := c.Params("symbol")
 	limit := c.QueryInt("limit", 100)
 
-	trades, err := a.binanceClient.GetRecentTrades(symbol, limit)
+	trades, err := a.exchange.GetRecentTrades(symbol, limit)
 	if err != nil {
 		return c.Status(500).JSON(fiber.Map{
 			"status": "error",
@@ -418,7 +627,7 @@ func (a *App) getKlines(c *fiber.Ctx) error {
 	startTime := c.QueryInt("startTime", 0)
 	endTime := c.QueryInt("endTime", 0)
 
-	klines, err := a.binanceClient.GetKlines(symbol, interval, limit, int64(startTime), int64(endTime))
+	klines, err := a.exchange.GetKlines(symbol, interval, limit, int64(startTime), int64(endTime))
 	if err != nil {
 		return c.Status(500).JSON(fiber.Map{
 			"status": "error",
@@ -505,6 +714,7 @@ func (a *App) getMarketDataStatus(c *fiber.Ctx) error {
 		"data": fiber.Map{
 			"running":     a.marketDataService.IsRunning(),
 			"price_count": len(a.marketDataService.GetAllPrices()),
+			"stream":      a.marketDataService.StreamStats(),
 			"timestamp":   time.Now().Unix(),
 		},
 	})
@@ -539,6 +749,12 @@ func (a *App) addSymbol(c *fiber.Ctx) error {
 }
 
 func (a *App) getOpenAPI(c *fiber.Ctx) error {
+	botIDParam := fiber.Map{
+		"name":     "bot_id",
+		"in":       "query",
+		"required": true,
+		"schema":   fiber.Map{"type": "string"},
+	}
 	spec := fiber.Map{
 		"openapi": "3.0.0",
 		"info": fiber.Map{
@@ -558,6 +774,61 @@ func (a *App) getOpenAPI(c *fiber.Ctx) error {
 					},
 				},
 			},
+			"/bots": fiber.Map{
+				"get": fiber.Map{
+					"summary": "List registered bots",
+				},
+			},
+			"/bot/{botId}": fiber.Map{
+				"get":    fiber.Map{"summary": "Get bot configuration, trade count and PnL"},
+				"put":    fiber.Map{"summary": "Update bot configuration"},
+				"delete": fiber.Map{"summary": "Delete a bot"},
+			},
+			"/bot/{botId}/paper": fiber.Map{
+				"get": fiber.Map{"summary": "Get a bot's paper trading balances and open orders"},
+			},
+			"/market/book/{symbol}": fiber.Map{
+				"get": fiber.Map{
+					"summary": "Get the local order book kept from the diff-depth stream, with imbalance, VWAP to fill qty and depth at price",
+					"parameters": []fiber.Map{
+						{"name": "levels", "in": "query", "schema": fiber.Map{"type": "integer"}},
+						{"name": "qty", "in": "query", "schema": fiber.Map{"type": "number"}},
+						{"name": "price", "in": "query", "schema": fiber.Map{"type": "number"}},
+					},
+				},
+			},
+			"/market/tidb/klines/{symbol}": fiber.Map{
+				"get": fiber.Map{
+					"summary": "Stored klines of any interval; intervals above 1m are rolled up from the 1m klines",
+					"parameters": []fiber.Map{
+						{"name": "interval", "in": "query", "schema": fiber.Map{"type": "string"}},
+						{"name": "limit", "in": "query", "schema": fiber.Map{"type": "integer"}},
+						{"name": "from", "in": "query", "schema": fiber.Map{"type": "string"}},
+						{"name": "to", "in": "query", "schema": fiber.Map{"type": "string"}},
+					},
+				},
+			},
+			"/market/indicators/{symbol}": fiber.Map{
+				"get": fiber.Map{
+					"summary": "Compute EMA, RSI, MACD, Bollinger Bands, ATR, VWAP, OBV and Stochastic over stored klines",
+					"parameters": []fiber.Map{
+						{"name": "interval", "in": "query", "schema": fiber.Map{"type": "string"}},
+						{"name": "names", "in": "query", "schema": fiber.Map{"type": "string"}, "description": "Comma separated: ema, rsi, macd, bollinger, atr, vwap, obv, stoch"},
+						{"name": "limit", "in": "query", "schema": fiber.Map{"type": "integer"}},
+						{"name": "series", "in": "query", "schema": fiber.Map{"type": "boolean"}},
+					},
+				},
+			},
+			"/market/backfill": fiber.Map{
+				"post": fiber.Map{"summary": "Start a job filling the klines missing from market_klines for a symbol, interval and range"},
+				"get":  fiber.Map{"summary": "List kline backfill jobs"},
+			},
+			"/market/backfill/{id}": fiber.Map{
+				"get": fiber.Map{"summary": "Get a kline backfill job's progress or result"},
+			},
+			"/account/balances": fiber.Map{
+				"get": fiber.Map{"summary": "Get the live exchange account's balances from the user data stream"},
+			},
 			"/ingest/manual": fiber.Map{
 				"post": fiber.Map{
 					"summary": "Trigger manual data ingestion",
@@ -575,6 +846,86 @@ func (a *App) getOpenAPI(c *fiber.Ctx) error {
 					"summary": "Get current trading signal",
 				},
 			},
+			"/strategies": fiber.Map{
+				"get": fiber.Map{"summary": "List available strategies"},
+			},
+			"/strategies/{name}/signal/{symbol}": fiber.Map{
+				"get": fiber.Map{
+					"summary": "Evaluate a strategy against live TiDB analytics",
+					"parameters": []fiber.Map{
+						{
+							"name":        "sizing_mode",
+							"in":          "query",
+							"description": "Mode the returned position_size is sized in: " + strings.Join(risk.SizingModes(), ", "),
+							"schema":      fiber.Map{"type": "string"},
+						},
+					},
+				},
+			},
+			"/backtest": fiber.Map{
+				"post": fiber.Map{
+					"summary": "Backtest a strategy over stored or exchange klines",
+				},
+			},
+			"/positions": fiber.Map{
+				"get": fiber.Map{
+					"summary": "List a bot's positions with average entry price and realized and unrealized PnL",
+					"parameters": []fiber.Map{
+						botIDParam,
+						{
+							"name":        "all",
+							"in":          "query",
+							"description": "Include closed positions",
+							"schema":      fiber.Map{"type": "boolean"},
+						},
+					},
+				},
+			},
+			"/pnl/daily": fiber.Map{
+				"get": fiber.Map{
+					"summary": "Get a bot's realized PnL and fees per UTC day",
+					"parameters": []fiber.Map{
+						botIDParam,
+						{
+							"name":   "days",
+							"in":     "query",
+							"schema": fiber.Map{"type": "integer", "default": 30},
+						},
+					},
+				},
+			},
+			"/pnl/equity-curve": fiber.Map{
+				"get": fiber.Map{
+					"summary": "Get a bot's equity after each trade and now",
+					"parameters": []fiber.Map{
+						botIDParam,
+						{
+							"name":   "from",
+							"in":     "query",
+							"schema": fiber.Map{"type": "string"},
+						},
+					},
+				},
+			},
+			"/risk/kill-switch": fiber.Map{
+				"post": fiber.Map{"summary": "Disable and stop every bot at once, optionally closing their positions"},
+			},
+			"/risk/guards": fiber.Map{
+				"get": fiber.Map{"summary": "List each bot's daily loss, drawdown and losing streak circuit breaker state"},
+			},
+			"/risk/audit": fiber.Map{
+				"get": fiber.Map{
+					"summary": "List the pre-trade checks' decision on each order",
+					"parameters": []fiber.Map{
+						{
+							"name":        "rejected",
+							"in":          "query",
+							"description": "Only return orders the checks rejected",
+							"schema":      fiber.Map{"type": "boolean"},
+						},
+					},
+				},
+			},
 			"/ws": fiber.Map{
 				"get": fiber.Map{
 					"summary": "WebSocket endpoint for real-time updates",
@@ -610,7 +961,13 @@ func (a *App) getTradingSignals(c *fiber.Ctx) error {
 		})
 	}
 
-	signals := a.marketDataService.GetTradingSignalsFromTiDB(symbol)
+	signals, err := a.marketDataService.GetTradingSignalsFromTiDB(symbol)
+	if err != nil {
+		log.Printf("Error getting trading signals for %s: %v", symbol, err)
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to get trading signals",
+		})
+	}
 	return c.JSON(fiber.Map{
 		"success": true,
 		"data":    signals,
@@ -732,7 +1089,11 @@ func (a *App) handleMarketDataWebSocket(c *websocket.Conn) {
 					switch requestType {
 					case "get_signals":
 						if symbol, ok := request["symbol"].(string); ok {
-							signals := a.marketDataService.GetTradingSignalsFromTiDB(symbol)
+							signals, err := a.marketDataService.GetTradingSignalsFromTiDB(symbol)
+							if err != nil {
+								log.Printf("Error getting signals for %s: %v", symbol, err)
+								continue
+							}
 							if err := c.WriteJSON(fiber.Map{
 								"type":   "signals",
 								"symbol": symbol,
@@ -759,7 +1120,11 @@ func (a *App) handleMarketDataWebSocket(c *websocket.Conn) {
 								defer ticker.Stop()
 
 								for range ticker.C {
-									signals := a.marketDataService.GetTradingSignalsFromTiDB(symbol)
+									signals, err := a.marketDataService.GetTradingSignalsFromTiDB(symbol)
+									if err != nil {
+										log.Printf("Error getting signals for %s: %v", symbol, err)
+										continue
+									}
 									if err := c.WriteJSON(fiber.Map{
 										"type":   "live_update",
 										"symbol": symbol,
@@ -779,240 +1144,36 @@ func (a *App) handleMarketDataWebSocket(c *websocket.Conn) {
 
 // getKimiSignals handles requests for enhanced Kimi AI trading signals using TiDB analytics
 func (a *App) getKimiSignals(c *fiber.Ctx) error {
-	symbol := c.Params("symbol")
-	if symbol == "" {
-		return c.Status(400).JSON(fiber.Map{
-			"status": "error",
-			"error":  "symbol parameter is required",
-		})
-	}
+	return a.strategySignal(c, strategy.NameKimi, "Kimi AI + TiDB Analytics")
+}
 
-	// Get advanced TiDB analytics for comprehensive analysis
-	advancedSignals, err := a.marketDataService.GetAdvancedTiDBSignals(symbol)
-	if err != nil {
-		log.Printf("Error getting advanced TiDB signals for %s: %v", symbol, err)
-		return c.Status(500).JSON(fiber.Map{
-			"status": "error",
-			"error":  "Failed to get market analytics",
-		})
-	}
+// strategyBars is how many 1m klines strategies evaluated through the API
+// see, enough for every indicator to warm up
+const strategyBars = 300
 
-	// Get real-time market state
-	realTimeState, err := a.marketDataService.GetRealTimeMarketState(symbol)
-	if err != nil {
-		log.Printf("Error getting real-time state for %s: %v", symbol, err)
-		realTimeState = make(map[string]interface{})
-	}
-
-	// Create enhanced prompt for Kimi AI with TiDB analytics
-	prompt := fmt.Sprintf(`REAL-TIME CRYPTO TRADING ANALYSIS FOR %s
-
-TECHNICAL INDICATORS:
-- Current Price: $%.2f
-- SMA10: $%.2f | SMA20: $%.2f 
-- Price vs SMA10: %.2f%% | Price vs SMA20: %.2f%%
-- Golden Cross: %t (SMA10 > SMA20)
-- Volatility: %.4f
-
-MOMENTUM ANALYSIS:
-- 1min: %.3f%% | 5min: %.3f%% | 15min: %.3f%%
-- Price Trend: %s
-
-VOLUME DYNAMICS:
-- Buy/Sell Ratio: %.2f (>0.6=Bullish, <0.4=Bearish)
-- Volume Surge: %.2fx vs previous period
-- Trade Frequency: %.0f trades/30min
-- Buy Pressure: %.2f%%
-
-SUPPORT/RESISTANCE:
-- Support: $%.2f (%.2f%% away)
-- Resistance: $%.2f (%.2f%% away)
-- Risk Zone: %s
-
-REAL-TIME SIGNALS:
-- Volume Spike: %t
-- Volatility Spike: %.2fx
-- Order Flow: %s
-
-Provide a JSON response with:
-{
-  "action": "BUY|SELL|HOLD",
-  "confidence": 1-100,
-  "timeframe": "1-5min|5-15min|15-60min",
-  "entry_price": suggested_price,
-  "stop_loss": suggested_stop,
-  "take_profit": suggested_target,
-  "reasoning": "detailed analysis",
-  "risk_level": "LOW|MEDIUM|HIGH",
-  "signals": ["signal1", "signal2", "signal3"]
-}`,
-		symbol,
-		getFloat(advancedSignals, "current_price"),
-		getFloat(advancedSignals, "sma_10"),
-		getFloat(advancedSignals, "sma_20"),
-		getFloat(advancedSignals, "price_vs_sma10"),
-		getFloat(advancedSignals, "price_vs_sma20"),
-		getBool(advancedSignals, "sma_cross"),
-		getFloat(advancedSignals, "volatility"),
-		getFloat(advancedSignals, "momentum_1min"),
-		getFloat(advancedSignals, "momentum_5min"),
-		getFloat(advancedSignals, "momentum_15min"),
-		determineTrend(advancedSignals),
-		getFloat(advancedSignals, "volume_ratio"),
-		getFloat(realTimeState, "volume_surge"),
-		getFloat(advancedSignals, "trade_frequency"),
-		getFloat(realTimeState, "buy_pressure")*100,
-		getFloat(advancedSignals, "support_level"),
-		getFloat(advancedSignals, "support_distance"),
-		getFloat(advancedSignals, "resistance_level"),
-		getFloat(advancedSignals, "resistance_distance"),
-		determineRiskZone(advancedSignals),
-		getFloat(realTimeState, "volume_surge") > 1.5,
-		getFloat(realTimeState, "volatility_spike"),
-		determineOrderFlow(realTimeState),
-	)
-
-	// Call Kimi AI with enhanced analytics
-	ctx := context.Background()
-	prediction, err := a.kimiClient.Ask(ctx,
-		"You are an expert cryptocurrency trader with access to real-time TiDB analytics. Analyze the comprehensive market data and provide precise, actionable trading recommendations with specific entry/exit points.",
-		prompt)
-	if err != nil {
-		log.Printf("Error getting Kimi AI response for %s: %v", symbol, err)
-		return c.Status(500).JSON(fiber.Map{
+// getStrategySignal evaluates any registered strategy against the live analytics
+func (a *App) getStrategySignal(c *fiber.Ctx) error {
+	name := c.Params("name")
+	if !strategy.Valid(name) {
+		return c.Status(404).JSON(fiber.Map{
 			"status": "error",
-			"error":  "Failed to get AI analysis",
+			"error":  fmt.Sprintf("unknown strategy %q", name),
 		})
 	}
+	return a.strategySignal(c, name, "Strategy "+name+" + TiDB Analytics")
+}
 
-	// Parse Kimi response for enhanced data
-	enhancedData := parseKimiResponse(prediction, advancedSignals, realTimeState)
-
+// listStrategies reports the strategies bots, backtests and signals can use
+func (a *App) listStrategies(c *fiber.Ctx) error {
 	return c.JSON(fiber.Map{
 		"status": "success",
-		"data": map[string]interface{}{
-			"symbol":         symbol,
-			"recommendation": prediction.Dir,
-			"confidence":     prediction.Conv,
-			"reasoning":      prediction.Logic,
-			"enhanced_data":  enhancedData,
-			"tidb_analytics": advancedSignals,
-			"realtime_state": realTimeState,
-			"timestamp":      time.Now(),
-			"source":         "Kimi AI + TiDB Analytics",
-		},
+		"data":   strategy.Names(),
 	})
 }
 
-func (a *App) Listen(addr string) error {
-	return a.app.Listen(addr)
-}
-
-// Helper functions for enhanced Kimi AI analysis
-func getFloat(data map[string]interface{}, key string) float64 {
-	if val, exists := data[key]; exists {
-		switch v := val.(type) {
-		case float64:
-			return v
-		case float32:
-			return float64(v)
-		case int:
-			return float64(v)
-		case int64:
-			return float64(v)
-		}
-	}
-	return 0.0
-}
-
-func getBool(data map[string]interface{}, key string) bool {
-	if val, exists := data[key]; exists {
-		if b, ok := val.(bool); ok {
-			return b
-		}
-	}
-	return false
-}
-
-func determineTrend(signals map[string]interface{}) string {
-	momentum1 := getFloat(signals, "momentum_1min")
-	momentum5 := getFloat(signals, "momentum_5min")
-	smaCross := getBool(signals, "sma_cross")
-
-	if momentum1 > 0.5 && momentum5 > 1.0 && smaCross {
-		return "STRONG_BULLISH"
-	} else if momentum1 > 0.2 && momentum5 > 0.5 {
-		return "BULLISH"
-	} else if momentum1 < -0.5 && momentum5 < -1.0 && !smaCross {
-		return "STRONG_BEARISH"
-	} else if momentum1 < -0.2 && momentum5 < -0.5 {
-		return "BEARISH"
-	}
-	return "SIDEWAYS"
-}
-
-func determineRiskZone(signals map[string]interface{}) string {
-	supportDist := getFloat(signals, "support_distance")
-	resistanceDist := getFloat(signals, "resistance_distance")
-	volatility := getFloat(signals, "volatility")
-
-	if supportDist < 2.0 || resistanceDist < 2.0 {
-		return "HIGH_RISK" // Near support/resistance
-	} else if volatility > 0.05 {
-		return "HIGH_VOLATILITY"
-	} else if supportDist > 5.0 && resistanceDist > 5.0 {
-		return "SAFE_ZONE"
-	}
-	return "MODERATE"
-}
-
-func determineOrderFlow(realTimeState map[string]interface{}) string {
-	buyPressure := getFloat(realTimeState, "buy_pressure")
-	volumeSurge := getFloat(realTimeState, "volume_surge")
-
-	if buyPressure > 0.7 && volumeSurge > 1.5 {
-		return "STRONG_BUY_FLOW"
-	} else if buyPressure > 0.6 {
-		return "BUY_FLOW"
-	} else if buyPressure < 0.3 && volumeSurge > 1.5 {
-		return "STRONG_SELL_FLOW"
-	} else if buyPressure < 0.4 {
-		return "SELL_FLOW"
-	}
-	return "BALANCED"
-}
-
-func parseKimiResponse(prediction kimi.Prediction, signals, realTimeState map[string]interface{}) map[string]interface{} {
-	enhanced := make(map[string]interface{})
-
-	// Calculate risk-adjusted position size
-	volatility := getFloat(signals, "volatility")
-	if volatility > 0 {
-		enhanced["position_size_multiplier"] = 1.0 / (1.0 + volatility*10) // Lower size for higher volatility
-	}
-
-	// Calculate entry timing score
-	momentum := getFloat(signals, "momentum_5min")
-	volumeRatio := getFloat(signals, "volume_ratio")
-	buyPressure := getFloat(realTimeState, "buy_pressure")
-
-	timingScore := (momentum + (volumeRatio-0.5)*2 + (buyPressure-0.5)*2) / 3
-	enhanced["entry_timing_score"] = timingScore
-
-	// Determine urgency level
-	if getFloat(realTimeState, "volume_surge") > 2.0 {
-		enhanced["urgency"] = "HIGH"
-	} else if getFloat(realTimeState, "volume_surge") > 1.5 {
-		enhanced["urgency"] = "MEDIUM"
-	} else {
-		enhanced["urgency"] = "LOW"
-	}
-
-	return enhanced
-}
-
-// getEnhancedKimiSignals provides advanced Kimi AI analysis with comprehensive TiDB data
-func (a *App) getEnhancedKimiSignals(c *fiber.Ctx) error {
+// strategySignal builds a live snapshot for the symbol in the path and
+// returns the named strategy's decision alongside the analytics it saw
+func (a *App) strategySignal(c *fiber.Ctx, name, source string) error {
 	symbol := c.Params("symbol")
 	if symbol == "" {
 		return c.Status(400).JSON(fiber.Map{
@@ -1021,7 +1182,26 @@ func (a *App) getEnhancedKimiSignals(c *fiber.Ctx) error {
 		})
 	}
 
-	// Get comprehensive TiDB analytics
+	sizingParams := risk.DefaultParams()
+	sizingParams.SizingMode = c.Query("sizing_mode")
+	calc, err := risk.NewCalculator(sizingParams)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"status": "error",
+			"error":  err.Error(),
+		})
+	}
+
+	strat, err := strategy.New(name, a.kimiClient)
+	if err != nil {
+		log.Printf("Failed to create strategy %s: %v", name, err)
+		return c.Status(500).JSON(fiber.Map{
+			"status": "error",
+			"error":  "Strategy unavailable",
+		})
+	}
+
+	// Get advanced TiDB analytics for comprehensive analysis
 	advancedSignals, err := a.marketDataService.GetAdvancedTiDBSignals(symbol)
 	if err != nil {
 		log.Printf("Error getting advanced TiDB signals for %s: %v", symbol, err)
@@ -1035,138 +1215,81 @@ func (a *App) getEnhancedKimiSignals(c *fiber.Ctx) error {
 	realTimeState, err := a.marketDataService.GetRealTimeMarketState(symbol)
 	if err != nil {
 		log.Printf("Error getting real-time state for %s: %v", symbol, err)
-		realTimeState = make(map[string]interface{})
-	}
-
-	// Enhanced prompt with more sophisticated analysis
-	prompt := fmt.Sprintf(`ADVANCED CRYPTO TRADING ANALYSIS FOR %s
-
-TECHNICAL ANALYSIS:
-- Current Price: $%.2f
-- SMA Cross Signal: %t (Golden: %t, Death: %t)
-- Volume Ratio: %.3f (vs 20-period average)
-- Momentum Indicators:
-  * 5min: %.3f%% 
-  * 15min: %.3f%%
-  * Trend: %s
-
-REAL-TIME MARKET STATE:
-- Volume Surge: %.2fx normal levels
-- Buy Pressure: %.1f%% (>60%%=Bullish, <40%%=Bearish)
-- Volatility Spike: %t
-- Order Flow: %s
-
-RISK ASSESSMENT:
-- Support: $%.2f (%.2f%% away)
-- Resistance: $%.2f (%.2f%% away)
-- Risk Zone: %s
-- Volatility: %.4f
-
-Provide a sophisticated trading recommendation with:
-1. Clear BUY/SELL/HOLD decision with confidence 1-100%%
-2. Specific entry price and timing
-3. Stop loss and take profit levels
-4. Risk assessment and position sizing
-5. Key technical reasons supporting the decision
-
-Format as JSON:
-{
-  "recommendation": "BUY|SELL|HOLD",
-  "confidence": "XX%%",
-  "reasoning": "comprehensive analysis",
-  "entry_price": price,
-  "stop_loss": price,
-  "take_profit": price,
-  "risk_level": "LOW|MEDIUM|HIGH",
-  "position_size": "percentage",
-  "timeframe": "minutes",
-  "key_signals": ["signal1", "signal2"]
-}`,
-		symbol,
-		getFloat(advancedSignals, "current_price"),
-		getBool(advancedSignals, "sma_cross"),
-		getBool(advancedSignals, "sma_cross"),
-		!getBool(advancedSignals, "sma_cross"),
-		getFloat(advancedSignals, "volume_ratio"),
-		getFloat(advancedSignals, "momentum_5min"),
-		getFloat(advancedSignals, "momentum_15min"),
-		determineTrend(advancedSignals),
-		getFloat(realTimeState, "volume_surge"),
-		getFloat(realTimeState, "buy_pressure")*100,
-		getFloat(realTimeState, "volatility_spike") > 1.5,
-		determineOrderFlow(realTimeState),
-		getFloat(advancedSignals, "support_level"),
-		getFloat(advancedSignals, "support_distance"),
-		getFloat(advancedSignals, "resistance_level"),
-		getFloat(advancedSignals, "resistance_distance"),
-		determineRiskZone(advancedSignals),
-		getFloat(advancedSignals, "volatility"),
-	)
-
-	// Call Kimi AI with enhanced analytics
-	ctx := context.Background()
-	prediction, err := a.kimiClient.Ask(ctx,
-		"You are a professional cryptocurrency trader and technical analyst with access to real-time TiDB market analytics. Provide precise, actionable trading recommendations with specific entry/exit points and risk management.",
-		prompt)
+	}
+
+	snap := strategy.Snapshot{
+		Symbol:   symbol,
+		Time:     time.Now(),
+		Signals:  advancedSignals,
+		RealTime: realTimeState,
+	}
+	if bars, err := a.marketDataService.GetRecentKlines(symbol, "1m", strategyBars); err == nil {
+		snap.Bars = bars
+		snap.Indicators = indicators.Latest(bars)
+	}
+
+	decision, err := strat.Decide(c.Context(), snap)
 	if err != nil {
-		log.Printf("Error getting enhanced Kimi AI response for %s: %v", symbol, err)
+		log.Printf("Error getting %s decision for %s: %v", name, symbol, err)
 		return c.Status(500).JSON(fiber.Map{
 			"status": "error",
-			"error":  "Failed to get AI analysis",
+			"error":  "Failed to get strategy decision",
 		})
 	}
 
-	// Enhanced response parsing
-	enhancedData := parseKimiResponse(prediction, advancedSignals, realTimeState)
-
-	// Additional enhanced metrics
-	enhancedData["market_sentiment"] = determineTrend(advancedSignals)
-	enhancedData["volatility_adjusted_confidence"] = calculateVolatilityAdjustedConfidence(float64(prediction.Conv), getFloat(advancedSignals, "volatility"))
-	enhancedData["optimal_position_size"] = calculateOptimalPositionSize(advancedSignals, realTimeState)
-
 	return c.JSON(fiber.Map{
-		"success": true,
+		"status": "success",
 		"data": map[string]interface{}{
 			"symbol":         symbol,
-			"recommendation": prediction.Dir,
-			"confidence":     fmt.Sprintf("%.0f%%", float64(prediction.Conv)),
-			"reasoning":      prediction.Logic,
-			"enhanced_data":  enhancedData,
+			"strategy":       strat.Name(),
+			"recommendation": decision.Action,
+			"confidence":     decision.Confidence,
+			"reasoning":      decision.Reasoning,
+			"enhanced_data":  decision.Analysis,
+			"position_size":  signalPositionSize(calc, decision, snap),
 			"tidb_analytics": advancedSignals,
 			"realtime_state": realTimeState,
 			"timestamp":      time.Now(),
-			"source":         "Enhanced Kimi AI + Advanced TiDB Analytics",
+			"source":         source,
 		},
 	})
 }
 
-// Helper functions for enhanced analysis
-func calculateVolatilityAdjustedConfidence(baseConfidence, volatility float64) float64 {
-	// Reduce confidence in high volatility environments
-	volatilityFactor := 1.0 - (volatility * 2)
-	if volatilityFactor < 0.5 {
-		volatilityFactor = 0.5
+// signalPositionSize sizes a decision with the calculator for the default
+// risk params, or returns nil when the decision is flat or its sizing mode
+// lacks the readings it needs
+func signalPositionSize(calc *risk.Calculator, decision strategy.Decision, snap strategy.Snapshot) *risk.PositionSize {
+	direction := ""
+	switch decision.Action {
+	case strategy.Long:
+		direction = "long"
+	case strategy.Short:
+		direction = "short"
+	default:
+		return nil
+	}
+	if snap.Signals == nil || snap.Signals.CurrentPrice == nil {
+		return nil
 	}
-	return baseConfidence * volatilityFactor
-}
-
-func calculateOptimalPositionSize(signals, realTimeState map[string]interface{}) float64 {
-	// Base position size of 10%
-	baseSize := 0.10
 
-	// Adjust for volatility
-	volatility := getFloat(signals, "volatility")
-	volatilityAdjustment := 1.0 - (volatility * 5)
-	if volatilityAdjustment < 0.2 {
-		volatilityAdjustment = 0.2
+	in := risk.SizingInputs{Conviction: float64(decision.Confidence) / 100}
+	if snap.Indicators != nil && snap.Indicators.ATR14 != nil {
+		in.ATR = *snap.Indicators.ATR14
 	}
+	size, err := calc.Size(*snap.Signals.CurrentPrice, direction, 0, in)
+	if err != nil {
+		return nil
+	}
+	return size
+}
 
-	// Adjust for confidence and volume
-	volumeRatio := getFloat(signals, "volume_ratio")
-	buyPressure := getFloat(realTimeState, "buy_pressure")
-	confidenceBoost := (volumeRatio + buyPressure) / 2
+func (a *App) Listen(addr string) error {
+	return a.app.Listen(addr)
+}
 
-	return baseSize * volatilityAdjustment * (1 + confidenceBoost)
+// MarketData returns the market data service backing the API
+func (a *App) MarketData() *services.MarketDataService {
+	return a.marketDataService
 }
 
 // getAdvancedAnalytics provides TiDB-powered advanced market analytics
@@ -1228,7 +1351,7 @@ func (a *App) getAdvancedAnalyticsBatch(c *fiber.Ctx) error {
 	// Default symbols to analyze
 	symbols := []string{"BTCUSDT", "ETHUSDT", "BNBUSDT", "ADAUSDT", "SOLUSDT"}
 
-	var results []map[string]interface{}
+	var results []*db.AdvancedSignals
 
 	for _, symbol := range symbols {
 		analytics, err := a.marketDataService.GetAdvancedTiDBSignals(symbol)
@@ -1236,10 +1359,6 @@ func (a *App) getAdvancedAnalyticsBatch(c *fiber.Ctx) error {
 			log.Printf("Failed to get analytics for %s: %v", symbol, err)
 			continue
 		}
-
-		// Add symbol and timestamp to the analytics result
-		analytics["symbol"] = symbol
-		analytics["timestamp"] = time.Now()
 		results = append(results, analytics)
 	}
 
@@ -1256,7 +1375,7 @@ func (a *App) getRealTimeStateBatch(c *fiber.Ctx) error {
 	// Default symbols to analyze
 	symbols := []string{"BTCUSDT", "ETHUSDT", "BNBUSDT", "ADAUSDT", "SOLUSDT"}
 
-	var results []map[string]interface{}
+	var results []*db.RealTimeState
 
 	for _, symbol := range symbols {
 		state, err := a.marketDataService.GetRealTimeMarketState(symbol)
@@ -1264,10 +1383,6 @@ func (a *App) getRealTimeStateBatch(c *fiber.Ctx) error {
 			log.Printf("Failed to get real-time state for %s: %v", symbol, err)
 			continue
 		}
-
-		// Add symbol and timestamp to the state result
-		state["symbol"] = symbol
-		state["timestamp"] = time.Now()
 		results = append(results, state)
 	}
 
@@ -1,22 +1,25 @@
 package api
 
 import (
+	"errors"
 	"io"
 	"net/http/httptest"
 	"strings"
 	"testing"
 
 	"github.com/adeilh/agentic_go_signals/internal/db"
+	"github.com/adeilh/agentic_go_signals/internal/exchange"
 	"github.com/adeilh/agentic_go_signals/internal/kimi"
+	"github.com/adeilh/agentic_go_signals/internal/services"
 	"github.com/adeilh/agentic_go_signals/internal/trader"
 )
 
 func TestNew(t *testing.T) {
 	// Create test clients
-	binanceClient := trader.NewClient("", "") // Empty API keys for testing
-	kimiClient := kimi.NewClient("")          // Empty API key for testing
+	ex := exchange.NewFake()         // In-process exchange, no network access
+	kimiClient := kimi.NewClient("") // Empty API key for testing
 
-	app := New(&db.DB{}, binanceClient, kimiClient)
+	app := New(&db.DB{}, ex, kimiClient)
 	if app == nil {
 		t.Fatal("expected app to be non-nil")
 	}
@@ -24,10 +27,10 @@ func TestNew(t *testing.T) {
 
 func TestHealthCheck(t *testing.T) {
 	// Create test clients
-	binanceClient := trader.NewClient("", "") // Empty API keys for testing
-	kimiClient := kimi.NewClient("")          // Empty API key for testing
+	ex := exchange.NewFake()         // In-process exchange, no network access
+	kimiClient := kimi.NewClient("") // Empty API key for testing
 
-	app := New(&db.DB{}, binanceClient, kimiClient)
+	app := New(&db.DB{}, ex, kimiClient)
 
 	req := httptest.NewRequest("GET", "/healthz", nil)
 	resp, err := app.app.Test(req)
@@ -47,33 +50,110 @@ func TestHealthCheck(t *testing.T) {
 
 func TestCreateBot(t *testing.T) {
 	// Create test clients
-	binanceClient := trader.NewClient("", "") // Empty API keys for testing
-	kimiClient := kimi.NewClient("")          // Empty API key for testing
+	ex := exchange.NewFake()         // In-process exchange, no network access
+	kimiClient := kimi.NewClient("") // Empty API key for testing
 
-	app := New(&db.DB{}, binanceClient, kimiClient)
+	app := New(&db.DB{}, ex, kimiClient)
 
-	req := httptest.NewRequest("POST", "/bot/create", nil)
+	// Invalid risk params are rejected before touching storage
+	req := httptest.NewRequest("POST", "/bot/create", strings.NewReader(`{"risk_params":{"account_balance":-1}}`))
+	req.Header.Set("Content-Type", "application/json")
 	resp, err := app.app.Test(req)
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	if resp.StatusCode != 200 {
-		t.Fatalf("expected status 200, got %d", resp.StatusCode)
+	if resp.StatusCode != 400 {
+		t.Fatalf("expected status 400, got %d", resp.StatusCode)
 	}
 
-	body, _ := io.ReadAll(resp.Body)
-	if !strings.Contains(string(body), "bot_") {
-		t.Fatal("expected bot ID in response")
+	// Unknown strategies are rejected
+	req = httptest.NewRequest("POST", "/bot/create", strings.NewReader(`{"strategy":"martingale"}`))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err = app.app.Test(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.StatusCode != 400 {
+		t.Fatalf("expected status 400 for unknown strategy, got %d", resp.StatusCode)
+	}
+
+	// A valid bot cannot be persisted without a database connection
+	req = httptest.NewRequest("POST", "/bot/create", strings.NewReader(`{"name":"test","symbols":["btcusdt"]}`))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err = app.app.Test(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.StatusCode != 500 {
+		t.Fatalf("expected status 500, got %d", resp.StatusCode)
+	}
+}
+
+func TestGetBotInfo(t *testing.T) {
+	// Create test clients
+	ex := exchange.NewFake()         // In-process exchange, no network access
+	kimiClient := kimi.NewClient("") // Empty API key for testing
+
+	app := New(&db.DB{}, ex, kimiClient)
+
+	req := httptest.NewRequest("GET", "/bot/bot_test", nil)
+	resp, err := app.app.Test(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.StatusCode != 500 {
+		t.Fatalf("expected status 500 without a database, got %d", resp.StatusCode)
+	}
+}
+
+func TestBotRequestApply(t *testing.T) {
+	name := "momentum"
+	strat := "rules"
+	enabled := false
+	bot := defaultBot("bot_test")
+
+	botRequest{
+		Name:     &name,
+		Symbols:  []string{" solusdt "},
+		Strategy: &strat,
+		Enabled:  &enabled,
+	}.applyTo(&bot)
+
+	if bot.Name != "momentum" {
+		t.Errorf("expected name momentum, got %s", bot.Name)
+	}
+	if len(bot.Symbols) != 1 || bot.Symbols[0] != "SOLUSDT" {
+		t.Errorf("expected symbols [SOLUSDT], got %v", bot.Symbols)
+	}
+	if bot.Strategy != "rules" {
+		t.Errorf("expected strategy rules, got %s", bot.Strategy)
+	}
+	if bot.Enabled {
+		t.Error("expected bot to be disabled")
+	}
+	if bot.PredictIntervalSec != 600 {
+		t.Errorf("expected default predict interval 600, got %d", bot.PredictIntervalSec)
+	}
+	if err := validateBot(bot); err != nil {
+		t.Errorf("unexpected validation error: %v", err)
+	}
+
+	bot.ExecuteIntervalSec = 0
+	if err := validateBot(bot); err == nil {
+		t.Error("expected error for zero execute interval")
 	}
 }
 
 func TestManualIngest(t *testing.T) {
 	// Create test clients
-	binanceClient := trader.NewClient("", "") // Empty API keys for testing
-	kimiClient := kimi.NewClient("")          // Empty API key for testing
+	ex := exchange.NewFake()         // In-process exchange, no network access
+	kimiClient := kimi.NewClient("") // Empty API key for testing
 
-	app := New(&db.DB{}, binanceClient, kimiClient)
+	app := New(&db.DB{}, ex, kimiClient)
 
 	req := httptest.NewRequest("POST", "/ingest/manual?bot_id=test123", nil)
 	resp, err := app.app.Test(req)
@@ -85,3 +165,344 @@ func TestManualIngest(t *testing.T) {
 		t.Fatalf("expected status 200, got %d", resp.StatusCode)
 	}
 }
+
+func TestHistoryEndpoints(t *testing.T) {
+	// Create test clients
+	ex := exchange.NewFake()         // In-process exchange, no network access
+	kimiClient := kimi.NewClient("") // Empty API key for testing
+
+	app := New(&db.DB{}, ex, kimiClient)
+
+	cases := []struct {
+		url    string
+		status int
+	}{
+		{"/signals/current?bot_id=test&symbol=BTCUSDT", 500},
+		{"/signals/history?limit=0", 400},
+		{"/predictions/latest?from=yesterday", 400},
+		{"/predictions/history?from=1700000000&to=1600000000", 400},
+		{"/predictions/history?from=2024-01-01T00:00:00Z", 500},
+		{"/predictions/scorecard?bot_id=test", 500},
+		{"/trades/latest?offset=-1", 400},
+		{"/trades/latest?bot_id=test", 500},
+		{"/positions", 400},
+		{"/positions?bot_id=test", 500},
+		{"/pnl/daily?bot_id=test&days=0", 400},
+		{"/pnl/daily?bot_id=test", 500},
+		{"/pnl/equity-curve?bot_id=test&from=yesterday", 400},
+		{"/pnl/equity-curve?bot_id=test", 500},
+	}
+
+	for _, tc := range cases {
+		resp, err := app.app.Test(httptest.NewRequest("GET", tc.url, nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != tc.status {
+			t.Errorf("%s: expected status %d, got %d", tc.url, tc.status, resp.StatusCode)
+		}
+	}
+}
+
+type fakeHalter struct {
+	reason  string
+	flatten bool
+}
+
+func (h *fakeHalter) HaltAll(reason string, flatten bool) int {
+	h.reason, h.flatten = reason, flatten
+	return 2
+}
+
+func TestKillSwitch(t *testing.T) {
+	app := New(&db.DB{}, exchange.NewFake(), kimi.NewClient(""))
+	halter := &fakeHalter{}
+	app.SetBotHalter(halter)
+
+	req := httptest.NewRequest("POST", "/risk/kill-switch", strings.NewReader(`{"reason":"exchange outage","flatten":true}`))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := app.app.Test(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The registry cannot be updated without a database, but the running
+	// bots are stopped regardless
+	if resp.StatusCode != 500 {
+		t.Fatalf("expected status 500 without a database, got %d", resp.StatusCode)
+	}
+	if halter.reason != "exchange outage" || !halter.flatten {
+		t.Fatalf("expected the running bots to be halted and flattened, got %+v", halter)
+	}
+
+	req = httptest.NewRequest("POST", "/risk/kill-switch", strings.NewReader(`{"reason":`))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err = app.app.Test(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != 400 {
+		t.Fatalf("expected status 400 for a malformed body, got %d", resp.StatusCode)
+	}
+
+	resp, err = app.app.Test(httptest.NewRequest("GET", "/risk/guards", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != 500 {
+		t.Fatalf("expected status 500 without a database, got %d", resp.StatusCode)
+	}
+}
+
+func TestOrderAuditEndpoint(t *testing.T) {
+	app := New(&db.DB{}, exchange.NewFake(), kimi.NewClient(""))
+
+	resp, err := app.app.Test(httptest.NewRequest("GET", "/risk/audit?limit=0", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != 400 {
+		t.Fatalf("expected status 400 for an invalid limit, got %d", resp.StatusCode)
+	}
+
+	resp, err = app.app.Test(httptest.NewRequest("GET", "/risk/audit?rejected=true", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != 500 {
+		t.Fatalf("expected status 500 without a database, got %d", resp.StatusCode)
+	}
+}
+
+func TestBacktestEndpoint(t *testing.T) {
+	ex := exchange.NewFake()
+	kimiClient := kimi.NewClient("")
+
+	app := New(&db.DB{}, ex, kimiClient)
+
+	cases := []struct {
+		body   string
+		status int
+	}{
+		{`{"interval":"7m"}`, 400},
+		{`{"strategy":"martingale"}`, 400},
+		{`{"strategy":"predictions"}`, 400},
+		{`{"source":"csv"}`, 400},
+		{`{"from":"1700000000","to":"1600000000"}`, 400},
+		{`{"from":"2020-01-01T00:00:00Z","to":"2024-01-01T00:00:00Z"}`, 400},
+		{`{"symbol":"btcusdt","interval":"1h"}`, 500},
+		{`not json`, 400},
+	}
+
+	for _, tc := range cases {
+		req := httptest.NewRequest("POST", "/backtest", strings.NewReader(tc.body))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.app.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != tc.status {
+			t.Errorf("%s: expected status %d, got %d", tc.body, tc.status, resp.StatusCode)
+		}
+	}
+}
+
+func TestBackfillEndpoints(t *testing.T) {
+	app := New(&db.DB{}, exchange.NewFake(), kimi.NewClient(""))
+
+	cases := []struct {
+		body   string
+		status int
+	}{
+		{`{"interval":"1m"}`, 400},
+		{`{"symbol":"btcusdt","interval":"7m"}`, 400},
+		{`{"symbol":"btcusdt","from":"1700000000","to":"1600000000"}`, 400},
+		{`{"symbol":"btcusdt","from":"2000-01-01T00:00:00Z","to":"2024-01-01T00:00:00Z"}`, 400},
+		{`{"symbol":"btcusdt","interval":"1h"}`, 202},
+		{`not json`, 400},
+	}
+	for _, tc := range cases {
+		req := httptest.NewRequest("POST", "/market/backfill", strings.NewReader(tc.body))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.app.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != tc.status {
+			t.Errorf("%s: expected status %d, got %d", tc.body, tc.status, resp.StatusCode)
+		}
+	}
+
+	resp, err := app.app.Test(httptest.NewRequest("GET", "/market/backfill/backfill-1", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != 200 || !strings.Contains(string(body), `"symbol":"BTCUSDT"`) {
+		t.Fatalf("expected the started job, got %d %s", resp.StatusCode, body)
+	}
+
+	resp, err = app.app.Test(httptest.NewRequest("GET", "/market/backfill/backfill-9", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != 404 {
+		t.Fatalf("expected status 404 for an unknown job, got %d", resp.StatusCode)
+	}
+}
+
+func TestStrategyEndpoints(t *testing.T) {
+	ex := exchange.NewFake()
+	kimiClient := kimi.NewClient("")
+
+	app := New(&db.DB{}, ex, kimiClient)
+
+	resp, err := app.app.Test(httptest.NewRequest("GET", "/strategies", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != 200 {
+		t.Fatalf("expected status 200, got %d", resp.StatusCode)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	if !strings.Contains(string(body), "rules") {
+		t.Fatalf("expected rules strategy to be listed, got %s", body)
+	}
+
+	resp, err = app.app.Test(httptest.NewRequest("GET", "/strategies/martingale/signal/BTCUSDT", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != 404 {
+		t.Fatalf("expected status 404 for unknown strategy, got %d", resp.StatusCode)
+	}
+
+	resp, err = app.app.Test(httptest.NewRequest("GET", "/strategies/rules/signal/BTCUSDT?sizing_mode=martingale", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != 400 {
+		t.Fatalf("expected status 400 for unknown sizing mode, got %d", resp.StatusCode)
+	}
+}
+
+func TestMarketEndpoints(t *testing.T) {
+	ex := exchange.NewFake()
+	ex.SetPrice("BTCUSDT", 50000)
+	ex.SetOrderBook("BTCUSDT", trader.OrderBook{
+		Bids: [][]string{{"49999", "1"}, {"49998", "2"}},
+		Asks: [][]string{{"50001", "1"}, {"50002", "2"}},
+	})
+	kimiClient := kimi.NewClient("")
+
+	app := New(&db.DB{}, ex, kimiClient)
+
+	resp, err := app.app.Test(httptest.NewRequest("GET", "/market/ticker/BTCUSDT", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != 200 || !strings.Contains(string(body), `"lastPrice":"50000.00000000"`) {
+		t.Fatalf("expected the fake ticker, got %d %s", resp.StatusCode, body)
+	}
+
+	resp, err = app.app.Test(httptest.NewRequest("GET", "/market/ticker/ETHUSDT", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != 404 {
+		t.Fatalf("expected status 404 for unknown symbol, got %d", resp.StatusCode)
+	}
+
+	resp, err = app.app.Test(httptest.NewRequest("GET", "/market/orderbook/BTCUSDT?limit=1", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ = io.ReadAll(resp.Body)
+	if resp.StatusCode != 200 || strings.Contains(string(body), "49998") {
+		t.Fatalf("expected a single level per side, got %d %s", resp.StatusCode, body)
+	}
+
+	// No depth updates have arrived, so there is no local book yet
+	resp, err = app.app.Test(httptest.NewRequest("GET", "/market/book/BTCUSDT", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != 404 {
+		t.Fatalf("expected status 404 without a local order book, got %d", resp.StatusCode)
+	}
+
+	for _, query := range []string{"interval=7m", "names=rsi,ichimoku", "limit=0"} {
+		resp, err = app.app.Test(httptest.NewRequest("GET", "/market/indicators/BTCUSDT?"+query, nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != 400 {
+			t.Fatalf("expected status 400 for %s, got %d", query, resp.StatusCode)
+		}
+	}
+
+	for _, query := range []string{"interval=7m", "limit=9999", "from=yesterday", "from=1700000000"} {
+		resp, err = app.app.Test(httptest.NewRequest("GET", "/market/tidb/klines/BTCUSDT?"+query, nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != 400 {
+			t.Fatalf("expected status 400 for stored klines with %s, got %d", query, resp.StatusCode)
+		}
+	}
+
+	// The test database has no connection to load klines from
+	resp, err = app.app.Test(httptest.NewRequest("GET", "/market/tidb/klines/BTCUSDT?interval=4h", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != 500 {
+		t.Fatalf("expected status 500 without klines storage, got %d", resp.StatusCode)
+	}
+
+	resp, err = app.app.Test(httptest.NewRequest("GET", "/market/indicators/BTCUSDT?names=rsi", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != 500 {
+		t.Fatalf("expected status 500 without klines storage, got %d", resp.StatusCode)
+	}
+
+	ex.SetError(errors.New("venue down"))
+	resp, err = app.app.Test(httptest.NewRequest("GET", "/market/trades/BTCUSDT", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != 500 {
+		t.Fatalf("expected status 500 when the exchange fails, got %d", resp.StatusCode)
+	}
+}
+
+func TestAccountBalances(t *testing.T) {
+	ex := exchange.NewFake()
+	app := New(&db.DB{}, ex, kimi.NewClient(""))
+
+	req := httptest.NewRequest("GET", "/account/balances", nil)
+	resp, err := app.app.Test(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != 404 {
+		t.Fatalf("expected 404 without live trading, got %d", resp.StatusCode)
+	}
+
+	userData := services.NewUserDataService(ex, app.WSHub(), nil)
+	userData.HandleAccountPosition(trader.WSAccountPosition{
+		Balances: []trader.WSBalance{{Asset: "USDT", Free: "100", Locked: "0"}},
+	})
+	app.SetUserData(userData)
+
+	resp, err = app.app.Test(httptest.NewRequest("GET", "/account/balances", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != 200 || !strings.Contains(string(body), `"a":"USDT"`) {
+		t.Fatalf("expected the cached balances, got %d %s", resp.StatusCode, body)
+	}
+}
@@ -0,0 +1,120 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/adeilh/agentic_go_signals/internal/backfill"
+	"github.com/adeilh/agentic_go_signals/internal/trader"
+	"github.com/gofiber/fiber/v2"
+)
+
+// maxBackfillBars bounds the range a single backfill job may cover
+const maxBackfillBars = 1000000
+
+// backfillRequest is the body accepted by POST /market/backfill. Times are
+// unix seconds or RFC3339; to defaults to now and from to a week before.
+type backfillRequest struct {
+	Symbol   string `json:"symbol"`
+	Interval string `json:"interval"`
+	From     string `json:"from"`
+	To       string `json:"to"`
+}
+
+// parseBackfillRequest applies defaults and validates a backfill request
+func parseBackfillRequest(req backfillRequest) (backfill.Request, error) {
+	spec := backfill.Request{
+		Symbol:   strings.ToUpper(strings.TrimSpace(req.Symbol)),
+		Interval: req.Interval,
+	}
+	if spec.Symbol == "" {
+		return spec, fmt.Errorf("symbol is required")
+	}
+	if spec.Interval == "" {
+		spec.Interval = "1m"
+	}
+	barLength, err := trader.IntervalDuration(spec.Interval)
+	if err != nil {
+		return spec, err
+	}
+
+	if spec.To, err = parseTimeParam(req.To); err != nil {
+		return spec, fmt.Errorf("invalid to")
+	}
+	if spec.To.IsZero() {
+		spec.To = time.Now()
+	}
+	if spec.From, err = parseTimeParam(req.From); err != nil {
+		return spec, fmt.Errorf("invalid from")
+	}
+	if spec.From.IsZero() {
+		spec.From = spec.To.Add(-7 * 24 * time.Hour)
+	}
+	if !spec.From.Before(spec.To) {
+		return spec, fmt.Errorf("from must be before to")
+	}
+	if spec.To.Sub(spec.From)/barLength > maxBackfillBars {
+		return spec, fmt.Errorf("range exceeds %d %s bars", maxBackfillBars, spec.Interval)
+	}
+	return spec, nil
+}
+
+// startBackfill starts a background job filling the klines missing from
+// market_klines in a range
+func (a *App) startBackfill(c *fiber.Ctx) error {
+	var req backfillRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"status": "error",
+			"error":  "Invalid request body",
+		})
+	}
+
+	spec, err := parseBackfillRequest(req)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"status": "error",
+			"error":  err.Error(),
+		})
+	}
+
+	// The job outlives the request
+	job, err := a.backfills.Start(context.Background(), spec)
+	if err != nil {
+		return c.Status(409).JSON(fiber.Map{
+			"status": "error",
+			"error":  err.Error(),
+		})
+	}
+
+	return c.Status(202).JSON(fiber.Map{
+		"status": "success",
+		"data":   job,
+	})
+}
+
+// listBackfills returns every backfill job, newest first
+func (a *App) listBackfills(c *fiber.Ctx) error {
+	return c.JSON(fiber.Map{
+		"status": "success",
+		"data":   a.backfills.List(),
+	})
+}
+
+// getBackfill returns a backfill job's progress or outcome
+func (a *App) getBackfill(c *fiber.Ctx) error {
+	job, ok := a.backfills.Get(c.Params("id"))
+	if !ok {
+		return c.Status(404).JSON(fiber.Map{
+			"status": "error",
+			"error":  "Backfill job not found",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status": "success",
+		"data":   job,
+	})
+}
@@ -0,0 +1,179 @@
+package api
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/adeilh/agentic_go_signals/internal/backtest"
+	"github.com/adeilh/agentic_go_signals/internal/risk"
+	"github.com/adeilh/agentic_go_signals/internal/strategy"
+	"github.com/adeilh/agentic_go_signals/internal/trader"
+	"github.com/gofiber/fiber/v2"
+)
+
+// maxBacktestBars bounds the range a single request may replay
+const maxBacktestBars = 100000
+
+// backtestRequest is the body accepted by POST /backtest. Times are unix
+// seconds or RFC3339; omitted fields fall back to the defaults below.
+type backtestRequest struct {
+	Symbol      string           `json:"symbol"`
+	Interval    string           `json:"interval"`
+	From        string           `json:"from"`
+	To          string           `json:"to"`
+	Strategy    string           `json:"strategy"`
+	BotID       string           `json:"bot_id"`
+	Source      string           `json:"source"`
+	FeeRate     *float64         `json:"fee_rate"`
+	SlippageBps *float64         `json:"slippage_bps"`
+	RiskParams  *risk.RiskParams `json:"risk_params"`
+}
+
+// backtestSpec is a validated backtest request
+type backtestSpec struct {
+	from, to time.Time
+	strategy string
+	botID    string
+	source   string
+	config   backtest.Config
+}
+
+// parseBacktestRequest applies defaults and validates a backtest request
+func parseBacktestRequest(req backtestRequest) (backtestSpec, error) {
+	spec := backtestSpec{
+		strategy: req.Strategy,
+		botID:    req.BotID,
+		source:   req.Source,
+		config: backtest.Config{
+			Symbol:      strings.ToUpper(strings.TrimSpace(req.Symbol)),
+			Interval:    req.Interval,
+			RiskParams:  risk.DefaultParams(),
+			FeeRate:     0.001,
+			SlippageBps: 5,
+		},
+	}
+
+	if spec.config.Symbol == "" {
+		spec.config.Symbol = "BTCUSDT"
+	}
+	if spec.config.Interval == "" {
+		spec.config.Interval = "1m"
+	}
+	if spec.strategy == "" {
+		spec.strategy = strategy.NameSMACross
+	}
+	if spec.source == "" {
+		spec.source = backtest.SourceDB
+	}
+	if req.FeeRate != nil {
+		spec.config.FeeRate = *req.FeeRate
+	}
+	if req.SlippageBps != nil {
+		spec.config.SlippageBps = *req.SlippageBps
+	}
+	if req.RiskParams != nil {
+		spec.config.RiskParams = *req.RiskParams
+	}
+
+	barLength, err := trader.IntervalDuration(spec.config.Interval)
+	if err != nil {
+		return spec, err
+	}
+	if spec.source != backtest.SourceDB && spec.source != backtest.SourceBinance {
+		return spec, fmt.Errorf("source must be %q or %q", backtest.SourceDB, backtest.SourceBinance)
+	}
+	switch {
+	case spec.strategy == backtest.StrategyPredictions:
+		if spec.botID == "" {
+			return spec, fmt.Errorf("bot_id is required for the %s strategy", backtest.StrategyPredictions)
+		}
+	case spec.strategy == strategy.NameKimi:
+		return spec, fmt.Errorf("the %s strategy cannot be backtested, use %q to replay its predictions", strategy.NameKimi, backtest.StrategyPredictions)
+	case !strategy.Valid(spec.strategy):
+		return spec, fmt.Errorf("unknown strategy %q", spec.strategy)
+	}
+
+	if spec.to, err = parseTimeParam(req.To); err != nil {
+		return spec, fmt.Errorf("invalid to")
+	}
+	if spec.to.IsZero() {
+		spec.to = time.Now()
+	}
+	if spec.from, err = parseTimeParam(req.From); err != nil {
+		return spec, fmt.Errorf("invalid from")
+	}
+	if spec.from.IsZero() {
+		spec.from = spec.to.Add(-7 * 24 * time.Hour)
+	}
+	if !spec.from.Before(spec.to) {
+		return spec, fmt.Errorf("from must be before to")
+	}
+	if spec.to.Sub(spec.from)/barLength > maxBacktestBars {
+		return spec, fmt.Errorf("range exceeds %d %s bars", maxBacktestBars, spec.config.Interval)
+	}
+
+	return spec, nil
+}
+
+// runBacktest replays stored or exchange klines through a strategy and
+// returns the equity curve, drawdown, Sharpe, win rate and trade list
+func (a *App) runBacktest(c *fiber.Ctx) error {
+	var req backtestRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(400).JSON(fiber.Map{
+				"status": "error",
+				"error":  "Invalid request body",
+			})
+		}
+	}
+
+	spec, err := parseBacktestRequest(req)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"status": "error",
+			"error":  err.Error(),
+		})
+	}
+
+	bars, err := backtest.LoadBars(a.db, a.exchange, spec.source,
+		spec.config.Symbol, spec.config.Interval, spec.from, spec.to)
+	if err != nil {
+		log.Printf("Failed to load backtest klines for %s: %v", spec.config.Symbol, err)
+		return c.Status(500).JSON(fiber.Map{
+			"status": "error",
+			"error":  "Failed to load klines",
+		})
+	}
+	if len(bars) == 0 {
+		return c.Status(404).JSON(fiber.Map{
+			"status": "error",
+			"error":  "No klines found for the requested range",
+		})
+	}
+
+	strat, err := backtest.NewStrategy(spec.strategy, a.db, spec.botID, spec.config.Symbol, spec.from, spec.to)
+	if err != nil {
+		log.Printf("Failed to create backtest strategy %s: %v", spec.strategy, err)
+		return c.Status(500).JSON(fiber.Map{
+			"status": "error",
+			"error":  "Failed to load strategy",
+		})
+	}
+
+	result, err := backtest.Run(c.Context(), bars, strat, spec.config)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"status": "error",
+			"error":  err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status":    "success",
+		"data":      result,
+		"timestamp": time.Now(),
+	})
+}
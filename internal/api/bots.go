@@ -0,0 +1,290 @@
+package api
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/adeilh/agentic_go_signals/internal/db"
+	"github.com/adeilh/agentic_go_signals/internal/risk"
+	"github.com/adeilh/agentic_go_signals/internal/strategy"
+	"github.com/gofiber/fiber/v2"
+)
+
+// botRequest is the body accepted by the bot create and update endpoints.
+// Omitted fields keep their defaults on create and their stored value on update.
+type botRequest struct {
+	Name               *string          `json:"name"`
+	Symbols            []string         `json:"symbols"`
+	Strategy           *string          `json:"strategy"`
+	IngestIntervalSec  *int             `json:"ingest_interval_sec"`
+	PredictIntervalSec *int             `json:"predict_interval_sec"`
+	ExecuteIntervalSec *int             `json:"execute_interval_sec"`
+	RiskParams         *risk.RiskParams `json:"risk_params"`
+	Enabled            *bool            `json:"enabled"`
+}
+
+// defaultBot returns the configuration used for fields a create request omits
+func defaultBot(botID string) db.Bot {
+	return db.Bot{
+		ID:                 botID,
+		Symbols:            []string{"BTCUSDT", "ETHUSDT"},
+		Strategy:           strategy.NameKimi,
+		IngestIntervalSec:  300,
+		PredictIntervalSec: 600,
+		ExecuteIntervalSec: 60,
+		RiskParams:         risk.DefaultParams(),
+		Enabled:            true,
+	}
+}
+
+// applyTo copies the fields set in the request onto bot
+func (r botRequest) applyTo(bot *db.Bot) {
+	if r.Name != nil {
+		bot.Name = *r.Name
+	}
+	if len(r.Symbols) > 0 {
+		symbols := make([]string, 0, len(r.Symbols))
+		for _, symbol := range r.Symbols {
+			symbols = append(symbols, strings.ToUpper(strings.TrimSpace(symbol)))
+		}
+		bot.Symbols = symbols
+	}
+	if r.Strategy != nil {
+		bot.Strategy = strings.TrimSpace(*r.Strategy)
+	}
+	if r.IngestIntervalSec != nil {
+		bot.IngestIntervalSec = *r.IngestIntervalSec
+	}
+	if r.PredictIntervalSec != nil {
+		bot.PredictIntervalSec = *r.PredictIntervalSec
+	}
+	if r.ExecuteIntervalSec != nil {
+		bot.ExecuteIntervalSec = *r.ExecuteIntervalSec
+	}
+	if r.RiskParams != nil {
+		bot.RiskParams = *r.RiskParams
+	}
+	if r.Enabled != nil {
+		bot.Enabled = *r.Enabled
+	}
+}
+
+// validateBot checks a bot configuration before it is persisted
+func validateBot(bot db.Bot) error {
+	if len(bot.Name) > 64 {
+		return fmt.Errorf("name must be at most 64 characters")
+	}
+	for _, symbol := range bot.Symbols {
+		if symbol == "" || len(symbol) > 16 {
+			return fmt.Errorf("invalid symbol %q", symbol)
+		}
+	}
+	if !strategy.Valid(bot.Strategy) {
+		return fmt.Errorf("unknown strategy %q", bot.Strategy)
+	}
+	if bot.IngestIntervalSec <= 0 || bot.PredictIntervalSec <= 0 || bot.ExecuteIntervalSec <= 0 {
+		return fmt.Errorf("intervals must be positive")
+	}
+	if _, err := risk.NewCalculator(bot.RiskParams); err != nil {
+		return fmt.Errorf("invalid risk params: %w", err)
+	}
+	return nil
+}
+
+func (a *App) parseBotRequest(c *fiber.Ctx) (botRequest, error) {
+	var req botRequest
+	if len(c.Body()) == 0 {
+		return req, nil
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return req, err
+	}
+	return req, nil
+}
+
+func (a *App) createBot(c *fiber.Ctx) error {
+	req, err := a.parseBotRequest(c)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"status": "error",
+			"error":  "Invalid request body",
+		})
+	}
+
+	bot := defaultBot("bot_" + strconv.FormatInt(time.Now().UnixNano(), 36))
+	req.applyTo(&bot)
+
+	if err := validateBot(bot); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"status": "error",
+			"error":  err.Error(),
+		})
+	}
+
+	if err := db.NewBotStore(a.db).Create(bot); err != nil {
+		log.Printf("Failed to create bot %s: %v", bot.ID, err)
+		return c.Status(500).JSON(fiber.Map{
+			"status": "error",
+			"error":  "Failed to create bot",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"bot_id":     bot.ID,
+		"created_at": time.Now().Unix(),
+		"status":     botStatus(bot),
+		"bot":        bot,
+	})
+}
+
+func (a *App) listBots(c *fiber.Ctx) error {
+	bots, err := db.NewBotStore(a.db).List()
+	if err != nil {
+		log.Printf("Failed to list bots: %v", err)
+		return c.Status(500).JSON(fiber.Map{
+			"status": "error",
+			"error":  "Failed to list bots",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status": "success",
+		"data":   bots,
+		"count":  len(bots),
+	})
+}
+
+func (a *App) getBotInfo(c *fiber.Ctx) error {
+	botID := c.Params("botId")
+	store := db.NewBotStore(a.db)
+
+	bot, err := store.Get(botID)
+	if err != nil {
+		log.Printf("Failed to get bot %s: %v", botID, err)
+		return c.Status(500).JSON(fiber.Map{
+			"status": "error",
+			"error":  "Failed to get bot",
+		})
+	}
+	if bot == nil {
+		return c.Status(404).JSON(fiber.Map{
+			"status": "error",
+			"error":  "Bot not found",
+		})
+	}
+
+	positions, err := a.portfolio().Positions(botID)
+	if err != nil {
+		log.Printf("Failed to compute positions for bot %s: %v", botID, err)
+		return c.Status(500).JSON(fiber.Map{
+			"status": "error",
+			"error":  "Failed to compute bot stats",
+		})
+	}
+
+	trades := 0
+	var pnl float64
+	for _, position := range positions {
+		trades += position.Trades
+		pnl += position.RealizedPnL + position.UnrealizedPnL
+	}
+
+	return c.JSON(fiber.Map{
+		"bot_id": bot.ID,
+		"status": botStatus(*bot),
+		"trades": trades,
+		"pnl":    pnl,
+		"bot":    bot,
+	})
+}
+
+func (a *App) updateBot(c *fiber.Ctx) error {
+	botID := c.Params("botId")
+	store := db.NewBotStore(a.db)
+
+	req, err := a.parseBotRequest(c)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"status": "error",
+			"error":  "Invalid request body",
+		})
+	}
+
+	bot, err := store.Get(botID)
+	if err != nil {
+		log.Printf("Failed to get bot %s: %v", botID, err)
+		return c.Status(500).JSON(fiber.Map{
+			"status": "error",
+			"error":  "Failed to get bot",
+		})
+	}
+	if bot == nil {
+		return c.Status(404).JSON(fiber.Map{
+			"status": "error",
+			"error":  "Bot not found",
+		})
+	}
+
+	req.applyTo(bot)
+	if err := validateBot(*bot); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"status": "error",
+			"error":  err.Error(),
+		})
+	}
+
+	found, err := store.Update(*bot)
+	if err != nil {
+		log.Printf("Failed to update bot %s: %v", botID, err)
+		return c.Status(500).JSON(fiber.Map{
+			"status": "error",
+			"error":  "Failed to update bot",
+		})
+	}
+	if !found {
+		return c.Status(404).JSON(fiber.Map{
+			"status": "error",
+			"error":  "Bot not found",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status": "success",
+		"data":   bot,
+	})
+}
+
+func (a *App) deleteBot(c *fiber.Ctx) error {
+	botID := c.Params("botId")
+
+	found, err := db.NewBotStore(a.db).Delete(botID)
+	if err != nil {
+		log.Printf("Failed to delete bot %s: %v", botID, err)
+		return c.Status(500).JSON(fiber.Map{
+			"status": "error",
+			"error":  "Failed to delete bot",
+		})
+	}
+	if !found {
+		return c.Status(404).JSON(fiber.Map{
+			"status": "error",
+			"error":  "Bot not found",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Bot deleted",
+		"bot_id":  botID,
+	})
+}
+
+func botStatus(bot db.Bot) string {
+	if bot.Enabled {
+		return "active"
+	}
+	return "disabled"
+}
@@ -0,0 +1,80 @@
+package api
+
+import (
+	"log"
+	"strings"
+
+	"github.com/adeilh/agentic_go_signals/internal/indicators"
+	"github.com/adeilh/agentic_go_signals/internal/trader"
+	"github.com/gofiber/fiber/v2"
+)
+
+// maxIndicatorBars bounds the klines one indicators request computes over
+const maxIndicatorBars = 5000
+
+// getIndicators computes technical indicators over a symbol's stored
+// klines. names selects the indicators, every one by default; series=true
+// adds the reading at every bar to the latest one.
+func (a *App) getIndicators(c *fiber.Ctx) error {
+	symbol := strings.ToUpper(c.Params("symbol"))
+	interval := c.Query("interval", "1m")
+	if _, err := trader.IntervalDuration(interval); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"status": "error",
+			"error":  err.Error(),
+		})
+	}
+	names, err := indicators.ParseNames(c.Query("names"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"status": "error",
+			"error":  err.Error(),
+		})
+	}
+	limit := c.QueryInt("limit", 500)
+	if limit <= 0 || limit > maxIndicatorBars {
+		return c.Status(400).JSON(fiber.Map{
+			"status": "error",
+			"error":  "limit must be between 1 and 5000",
+		})
+	}
+
+	bars, err := a.marketDataService.GetRecentKlines(symbol, interval, limit)
+	if err != nil {
+		log.Printf("Failed to load %s klines for %s: %v", interval, symbol, err)
+		return c.Status(500).JSON(fiber.Map{
+			"status": "error",
+			"error":  "Failed to load klines",
+		})
+	}
+	if len(bars) == 0 {
+		return c.Status(404).JSON(fiber.Map{
+			"status": "error",
+			"error":  "No klines stored for symbol and interval",
+		})
+	}
+
+	readings, err := indicators.Compute(bars, names)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"status": "error",
+			"error":  err.Error(),
+		})
+	}
+
+	data := fiber.Map{
+		"symbol":     symbol,
+		"interval":   interval,
+		"indicators": names,
+		"bars":       len(bars),
+		"latest":     readings[len(readings)-1],
+	}
+	if c.QueryBool("series") {
+		data["series"] = readings
+	}
+
+	return c.JSON(fiber.Map{
+		"status": "success",
+		"data":   data,
+	})
+}
@@ -0,0 +1,82 @@
+package api
+
+import (
+	"log"
+	"strings"
+
+	"github.com/adeilh/agentic_go_signals/internal/db"
+	"github.com/adeilh/agentic_go_signals/internal/trader"
+	"github.com/gofiber/fiber/v2"
+)
+
+// maxStoredKlines bounds the klines one stored klines request returns
+const maxStoredKlines = 5000
+
+// getStoredKlines serves klines of any interval from TiDB, oldest first.
+// Longer intervals are rolled up from the streamed 1m klines, so every
+// timeframe agrees with the base data. from and to select a range, unix
+// seconds or RFC3339; otherwise the latest limit klines are returned.
+func (a *App) getStoredKlines(c *fiber.Ctx) error {
+	symbol := strings.ToUpper(c.Params("symbol"))
+	interval := c.Query("interval", db.BaseInterval)
+	if _, err := trader.IntervalDuration(interval); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"status": "error",
+			"error":  err.Error(),
+		})
+	}
+	from, err := parseTimeParam(c.Query("from"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"status": "error",
+			"error":  "invalid from",
+		})
+	}
+	to, err := parseTimeParam(c.Query("to"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"status": "error",
+			"error":  "invalid to",
+		})
+	}
+	limit := c.QueryInt("limit", 500)
+	if limit <= 0 || limit > maxStoredKlines {
+		return c.Status(400).JSON(fiber.Map{
+			"status": "error",
+			"error":  "limit must be between 1 and 5000",
+		})
+	}
+
+	var klines []db.MarketKline
+	if from.IsZero() && to.IsZero() {
+		klines, err = a.marketDataService.GetRecentKlines(symbol, interval, limit)
+	} else {
+		if from.IsZero() || to.IsZero() || !from.Before(to) {
+			return c.Status(400).JSON(fiber.Map{
+				"status": "error",
+				"error":  "from and to must both be set, from before to",
+			})
+		}
+		klines, err = db.NewMarketDataStore(a.db).GetKlineRange(symbol, interval, from, to)
+		if len(klines) > limit {
+			klines = klines[:limit]
+		}
+	}
+	if err != nil {
+		log.Printf("Failed to load %s klines for %s: %v", interval, symbol, err)
+		return c.Status(500).JSON(fiber.Map{
+			"status": "error",
+			"error":  "Failed to load klines",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status": "success",
+		"data": fiber.Map{
+			"symbol":   symbol,
+			"interval": interval,
+			"klines":   klines,
+			"count":    len(klines),
+		},
+	})
+}
@@ -0,0 +1,76 @@
+package api
+
+import (
+	"errors"
+
+	"github.com/adeilh/agentic_go_signals/internal/orderbook"
+	"github.com/adeilh/agentic_go_signals/internal/trader"
+	"github.com/gofiber/fiber/v2"
+)
+
+// getLocalOrderBook returns the top of the order book kept from the
+// diff-depth stream along with its imbalance. Optional qty and price query
+// parameters add the VWAP to fill qty and the depth available at price for
+// each side.
+func (a *App) getLocalOrderBook(c *fiber.Ctx) error {
+	syncer, ok := a.marketDataService.OrderBook(c.Params("symbol"))
+	if !ok {
+		return c.Status(404).JSON(fiber.Map{
+			"status": "error",
+			"error":  "No local order book for symbol",
+		})
+	}
+	book := syncer.Book()
+	levels := c.QueryInt("levels", 20)
+	bids, asks := book.Top(levels)
+
+	data := fiber.Map{
+		"symbol":    book.Symbol(),
+		"sync":      syncer.Stats(),
+		"bids":      bids,
+		"asks":      asks,
+		"imbalance": book.Imbalance(levels),
+		"timestamp": book.UpdatedAt(),
+	}
+	bestBid, hasBid := book.BestBid()
+	bestAsk, hasAsk := book.BestAsk()
+	if hasBid {
+		data["best_bid"] = bestBid
+	}
+	if hasAsk {
+		data["best_ask"] = bestAsk
+	}
+	if hasBid && hasAsk {
+		data["spread"] = bestAsk.Price - bestBid.Price
+	}
+
+	if qty := c.QueryFloat("qty"); qty > 0 {
+		vwap := fiber.Map{}
+		for _, side := range []string{trader.SideBuy, trader.SideSell} {
+			price, err := book.VWAP(side, qty)
+			if errors.Is(err, orderbook.ErrInsufficientDepth) {
+				vwap[side] = nil
+				continue
+			}
+			if err != nil {
+				return c.Status(400).JSON(fiber.Map{
+					"status": "error",
+					"error":  err.Error(),
+				})
+			}
+			vwap[side] = price
+		}
+		data["vwap"] = vwap
+	}
+	if price := c.QueryFloat("price"); price > 0 {
+		data["depth_at"] = fiber.Map{
+			trader.SideBuy:  book.DepthAt(trader.SideBuy, price),
+			trader.SideSell: book.DepthAt(trader.SideSell, price),
+		}
+	}
+
+	return c.JSON(fiber.Map{
+		"status": "success",
+		"data":   data,
+	})
+}
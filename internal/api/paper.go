@@ -0,0 +1,50 @@
+package api
+
+import (
+	"log"
+
+	"github.com/adeilh/agentic_go_signals/internal/db"
+	"github.com/adeilh/agentic_go_signals/internal/paper"
+	"github.com/gofiber/fiber/v2"
+)
+
+// SetPaperBroker exposes the paper broker's accounts through the API
+func (a *App) SetPaperBroker(broker *paper.Broker) {
+	a.paperBroker = broker
+}
+
+// getPaperAccount returns a bot's simulated balances and open orders
+func (a *App) getPaperAccount(c *fiber.Ctx) error {
+	if a.paperBroker == nil {
+		return c.Status(404).JSON(fiber.Map{
+			"status": "error",
+			"error":  "Paper trading is disabled",
+		})
+	}
+
+	botID := c.Params("botId")
+	bot, err := db.NewBotStore(a.db).Get(botID)
+	if err != nil {
+		log.Printf("Failed to get bot %s: %v", botID, err)
+		return c.Status(500).JSON(fiber.Map{
+			"status": "error",
+			"error":  "Failed to get bot",
+		})
+	}
+	if bot == nil {
+		return c.Status(404).JSON(fiber.Map{
+			"status": "error",
+			"error":  "Bot not found",
+		})
+	}
+
+	account := a.paperBroker.Account(botID)
+	return c.JSON(fiber.Map{
+		"status": "success",
+		"data": fiber.Map{
+			"bot_id":      botID,
+			"balances":    account.Balances(),
+			"open_orders": account.OpenOrders(),
+		},
+	})
+}
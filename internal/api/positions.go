@@ -0,0 +1,140 @@
+package api
+
+import (
+	"log"
+	"time"
+
+	"github.com/adeilh/agentic_go_signals/internal/db"
+	"github.com/adeilh/agentic_go_signals/internal/portfolio"
+	"github.com/gofiber/fiber/v2"
+)
+
+// portfolio replays bots' trades into positions marked at the market data
+// service's cached prices
+func (a *App) portfolio() *portfolio.Portfolio {
+	return portfolio.New(db.NewTradeStore(a.db), a.markPrice)
+}
+
+func (a *App) markPrice(symbol string) (float64, bool) {
+	if a.marketDataService == nil {
+		return 0, false
+	}
+	price, ok := a.marketDataService.GetPriceData(symbol)
+	return price.Price, ok
+}
+
+// getPositions reports the bot's position in every symbol it has traded.
+// Closed positions are left out unless all=true.
+func (a *App) getPositions(c *fiber.Ctx) error {
+	botID := c.Query("bot_id")
+	if botID == "" {
+		return c.Status(400).JSON(fiber.Map{
+			"status": "error",
+			"error":  "bot_id is required",
+		})
+	}
+
+	positions, err := a.portfolio().Positions(botID)
+	if err != nil {
+		log.Printf("Failed to get positions of bot %s: %v", botID, err)
+		return c.Status(500).JSON(fiber.Map{
+			"status": "error",
+			"error":  "Failed to get positions",
+		})
+	}
+
+	results := make([]portfolio.Position, 0, len(positions))
+	for _, position := range positions {
+		if position.Open() || c.QueryBool("all") {
+			results = append(results, position)
+		}
+	}
+
+	return c.JSON(fiber.Map{
+		"status": "success",
+		"data":   results,
+		"count":  len(results),
+	})
+}
+
+// getDailyPnL reports what the bot realized on each day it traded within
+// the last days UTC days
+func (a *App) getDailyPnL(c *fiber.Ctx) error {
+	botID := c.Query("bot_id")
+	if botID == "" {
+		return c.Status(400).JSON(fiber.Map{
+			"status": "error",
+			"error":  "bot_id is required",
+		})
+	}
+	days := c.QueryInt("days", 30)
+	if days <= 0 || days > 365 {
+		return c.Status(400).JSON(fiber.Map{
+			"status": "error",
+			"error":  "days must be between 1 and 365",
+		})
+	}
+
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	daily, err := a.portfolio().Daily(botID, today.AddDate(0, 0, 1-days))
+	if err != nil {
+		log.Printf("Failed to get daily PnL of bot %s: %v", botID, err)
+		return c.Status(500).JSON(fiber.Map{
+			"status": "error",
+			"error":  "Failed to get daily PnL",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status": "success",
+		"data":   daily,
+	})
+}
+
+// getEquityCurve reports the bot's equity, from its account balance, after
+// each trade since from and now
+func (a *App) getEquityCurve(c *fiber.Ctx) error {
+	botID := c.Query("bot_id")
+	if botID == "" {
+		return c.Status(400).JSON(fiber.Map{
+			"status": "error",
+			"error":  "bot_id is required",
+		})
+	}
+	from, err := parseTimeParam(c.Query("from"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"status": "error",
+			"error":  "invalid from: " + err.Error(),
+		})
+	}
+
+	bot, err := db.NewBotStore(a.db).Get(botID)
+	if err != nil {
+		log.Printf("Failed to get bot %s: %v", botID, err)
+		return c.Status(500).JSON(fiber.Map{
+			"status": "error",
+			"error":  "Failed to get bot",
+		})
+	}
+	if bot == nil {
+		return c.Status(404).JSON(fiber.Map{
+			"status": "error",
+			"error":  "Bot not found",
+		})
+	}
+
+	curve, err := a.portfolio().EquityCurve(botID, bot.RiskParams.AccountBalance, from)
+	if err != nil {
+		log.Printf("Failed to get equity curve of bot %s: %v", botID, err)
+		return c.Status(500).JSON(fiber.Map{
+			"status": "error",
+			"error":  "Failed to get equity curve",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status": "success",
+		"data":   curve,
+	})
+}
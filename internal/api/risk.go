@@ -0,0 +1,114 @@
+package api
+
+import (
+	"log"
+	"strings"
+
+	"github.com/adeilh/agentic_go_signals/internal/db"
+	"github.com/gofiber/fiber/v2"
+)
+
+// BotHalter stops every running bot at once; worker.Manager implements it
+type BotHalter interface {
+	HaltAll(reason string, flatten bool) int
+}
+
+// SetBotHalter lets the kill switch stop the bots running in this process
+// immediately instead of at the worker's next registry sync
+func (a *App) SetBotHalter(halter BotHalter) {
+	a.halter = halter
+}
+
+// killSwitchRequest is the body accepted by the kill switch
+type killSwitchRequest struct {
+	Reason  string `json:"reason"`
+	Flatten bool   `json:"flatten"` // Close every bot's positions as it stops
+}
+
+// killSwitch halts every bot: all bots are disabled in the registry, so
+// none restarts until it is re-enabled, and the running ones are stopped
+func (a *App) killSwitch(c *fiber.Ctx) error {
+	var req killSwitchRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(400).JSON(fiber.Map{
+				"status": "error",
+				"error":  "Invalid request body",
+			})
+		}
+	}
+	if req.Reason = strings.TrimSpace(req.Reason); req.Reason == "" {
+		req.Reason = "kill switch"
+	}
+
+	disabled, dbErr := db.NewBotStore(a.db).DisableAll()
+	if dbErr != nil {
+		log.Printf("Kill switch failed to disable bots: %v", dbErr)
+	}
+
+	// Running bots are stopped even if the registry could not be updated
+	halted := 0
+	if a.halter != nil {
+		halted = a.halter.HaltAll(req.Reason, req.Flatten)
+	}
+
+	if dbErr != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status": "error",
+			"error":  "Failed to disable bots; running bots were stopped but may restart",
+			"data":   fiber.Map{"halted": halted},
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status": "success",
+		"data": fiber.Map{
+			"disabled": disabled,
+			"halted":   halted,
+			"reason":   req.Reason,
+		},
+	})
+}
+
+// listRiskGuards reports every bot's circuit breaker state
+func (a *App) listRiskGuards(c *fiber.Ctx) error {
+	states, err := db.NewRiskGuardStore(a.db).List()
+	if err != nil {
+		log.Printf("Failed to list risk guards: %v", err)
+		return c.Status(500).JSON(fiber.Map{
+			"status": "error",
+			"error":  "Failed to list risk guards",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status": "success",
+		"data":   states,
+	})
+}
+
+// listOrderAudit reports the pre-trade checks' decisions, newest first.
+// Only rejected orders are returned with rejected=true.
+func (a *App) listOrderAudit(c *fiber.Ctx) error {
+	filter, err := parseHistoryFilter(c)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"status": "error",
+			"error":  err.Error(),
+		})
+	}
+
+	entries, err := db.NewOrderAuditStore(a.db).List(filter, c.QueryBool("rejected"))
+	if err != nil {
+		log.Printf("Failed to list order audit: %v", err)
+		return c.Status(500).JSON(fiber.Map{
+			"status": "error",
+			"error":  "Failed to list order audit",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status": "success",
+		"data":   entries,
+	})
+}
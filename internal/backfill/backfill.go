@@ -0,0 +1,277 @@
+// Package backfill fills market_klines from the exchange klines endpoint,
+// repairing the gaps the live kline stream leaves whenever the service is
+// down.
+package backfill
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/adeilh/agentic_go_signals/internal/db"
+	"github.com/adeilh/agentic_go_signals/internal/exchange"
+	"github.com/adeilh/agentic_go_signals/internal/trader"
+)
+
+const (
+	// PageSize is the maximum number of klines Binance returns per request
+	PageSize = 1000
+	// DefaultWeightPerMinute is a fifth of Binance's 6000 per-minute IP
+	// limit, leaving the rest to the live service sharing the IP
+	DefaultWeightPerMinute = 1200
+	// klinesWeight is the request weight of GET /api/v3/klines
+	klinesWeight = 2
+	// maxRateLimitRetries bounds how often one request is retried after a
+	// rate limit response
+	maxRateLimitRetries = 5
+	// defaultRetryAfter is waited when a rate limit response does not say
+	// how long to back off; Binance weights reset every minute
+	defaultRetryAfter = time.Minute
+)
+
+// KlineStore is the part of db.MarketDataStore the backfiller needs
+type KlineStore interface {
+	GetKlineOpenTimes(symbol, interval string, from, to time.Time) ([]time.Time, error)
+	StoreKlines(klines []db.MarketKline) error
+	MaterializeRollups(symbol string, from, to time.Time) error
+}
+
+// Request selects the klines to backfill: those of Symbol and Interval
+// opening in [From, To)
+type Request struct {
+	Symbol   string    `json:"symbol"`
+	Interval string    `json:"interval"`
+	From     time.Time `json:"from"`
+	To       time.Time `json:"to"`
+}
+
+// Gap is a run of consecutive missing klines
+type Gap struct {
+	From time.Time `json:"from"` // Open time of the first missing kline
+	To   time.Time `json:"to"`   // Open time after the last missing kline
+	Bars int       `json:"bars"`
+}
+
+// Result reports what a backfill found and stored
+type Result struct {
+	Request
+	Expected int   `json:"expected"` // Closed klines in the range
+	Missing  int   `json:"missing"`  // Klines absent before the backfill
+	Gaps     []Gap `json:"gaps"`
+	Stored   int   `json:"stored"`
+	Unfilled int   `json:"unfilled"` // Missing klines the exchange has no data for either
+	Requests int   `json:"requests"`
+}
+
+// Backfiller fetches missing klines from the exchange into a KlineStore
+type Backfiller struct {
+	market  exchange.MarketData
+	store   KlineStore
+	limiter *Limiter
+	now     func() time.Time
+}
+
+// New creates a backfiller storing into database. limiter may be shared
+// with other backfillers so that together they stay within one budget.
+func New(market exchange.MarketData, database *db.DB, limiter *Limiter) *Backfiller {
+	return &Backfiller{
+		market:  market,
+		store:   db.NewMarketDataStore(database),
+		limiter: limiter,
+		now:     time.Now,
+	}
+}
+
+// Run detects the klines missing from the store in the request's range and
+// fetches them. Only closed klines are backfilled, so the range ends at the
+// current kline's open time at the latest. Storing relies on the
+// unique_kline key, so running the same backfill twice is harmless.
+func (b *Backfiller) Run(ctx context.Context, req Request) (*Result, error) {
+	req.Symbol = strings.ToUpper(strings.TrimSpace(req.Symbol))
+	if req.Symbol == "" {
+		return nil, fmt.Errorf("symbol is required")
+	}
+	step, err := trader.IntervalDuration(req.Interval)
+	if err != nil {
+		return nil, err
+	}
+	// time.Truncate aligns on multiples of step since year 1, which matches
+	// Binance's kline boundaries up to 1d, and for 1w since year 1 began on
+	// a Monday
+	req.From = req.From.Truncate(step)
+	if lastClosed := b.now().Truncate(step); req.To.After(lastClosed) {
+		req.To = lastClosed
+	}
+	if !req.From.Before(req.To) {
+		return nil, fmt.Errorf("range contains no closed %s klines", req.Interval)
+	}
+
+	existing, err := b.store.GetKlineOpenTimes(req.Symbol, req.Interval, req.From, req.To)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load stored klines: %w", err)
+	}
+
+	result := &Result{Request: req}
+	result.Expected, result.Gaps = FindGaps(existing, req.From, req.To, step)
+	for _, gap := range result.Gaps {
+		result.Missing += gap.Bars
+	}
+
+	for _, gap := range result.Gaps {
+		requests, err := fetchPages(ctx, b.market, b.limiter, req.Symbol, req.Interval, gap.From, gap.To, func(bars []db.MarketKline) error {
+			if err := b.store.StoreKlines(bars); err != nil {
+				return err
+			}
+			result.Stored += len(bars)
+			return nil
+		})
+		result.Requests += requests
+		if err != nil {
+			return result, fmt.Errorf("failed to backfill %s %s from %s: %w", req.Symbol, req.Interval, gap.From.Format(time.RFC3339), err)
+		}
+	}
+	result.Unfilled = result.Missing - result.Stored
+
+	// Longer intervals are rolled up from the base klines, so refresh them
+	// over the repaired range
+	if req.Interval == db.BaseInterval && result.Stored > 0 {
+		if err := b.store.MaterializeRollups(req.Symbol, req.From, req.To); err != nil {
+			return result, fmt.Errorf("failed to roll up backfilled %s klines: %w", req.Symbol, err)
+		}
+	}
+
+	log.Printf("Backfilled %s %s: %d of %d klines missing in %d gaps, %d stored, %d unavailable",
+		req.Symbol, req.Interval, result.Missing, result.Expected, len(result.Gaps), result.Stored, result.Unfilled)
+	return result, nil
+}
+
+// FindGaps walks the kline slots opening in [from, to) and returns how many
+// there are and the runs of them missing from existing, which must be
+// sorted
+func FindGaps(existing []time.Time, from, to time.Time, step time.Duration) (slots int, gaps []Gap) {
+	i := 0
+	var gap *Gap
+	for slot := from; slot.Before(to); slot = slot.Add(step) {
+		slots++
+		for i < len(existing) && existing[i].Before(slot) {
+			i++
+		}
+		if i < len(existing) && existing[i].Equal(slot) {
+			gap = nil
+			continue
+		}
+
+		if gap == nil {
+			gaps = append(gaps, Gap{From: slot})
+			gap = &gaps[len(gaps)-1]
+		}
+		gap.To = slot.Add(step)
+		gap.Bars++
+	}
+	return slots, gaps
+}
+
+// Fetch returns the klines of symbol opening in [from, to), oldest first,
+// paging through GetKlines. limiter may be nil.
+func Fetch(ctx context.Context, market exchange.MarketData, limiter *Limiter, symbol, interval string, from, to time.Time) ([]db.MarketKline, error) {
+	var bars []db.MarketKline
+	_, err := fetchPages(ctx, market, limiter, symbol, interval, from, to, func(page []db.MarketKline) error {
+		bars = append(bars, page...)
+		return nil
+	})
+	return bars, err
+}
+
+// fetchPages passes each page of klines opening in [from, to) to handle and
+// returns the number of requests made. Rate limit responses are waited out
+// and retried.
+func fetchPages(ctx context.Context, market exchange.MarketData, limiter *Limiter, symbol, interval string, from, to time.Time, handle func([]db.MarketKline) error) (int, error) {
+	start := from.UnixMilli()
+	end := to.UnixMilli() - 1
+	requests, retries := 0, 0
+
+	for start <= end {
+		if limiter != nil {
+			if err := limiter.Wait(ctx, klinesWeight); err != nil {
+				return requests, err
+			}
+		} else if err := ctx.Err(); err != nil {
+			return requests, err
+		}
+
+		rows, err := market.GetKlines(symbol, interval, PageSize, start, end)
+		requests++
+		var limited *trader.RateLimitError
+		if errors.As(err, &limited) && retries < maxRateLimitRetries {
+			retries++
+			wait := limited.RetryAfter
+			if wait <= 0 {
+				wait = defaultRetryAfter
+			}
+			log.Printf("Kline requests for %s rate limited, retrying in %s", symbol, wait)
+			if limiter != nil {
+				limiter.Pause(wait)
+			} else if err := sleep(ctx, wait); err != nil {
+				return requests, err
+			}
+			continue
+		}
+		if err != nil {
+			return requests, fmt.Errorf("failed to fetch klines: %w", err)
+		}
+		retries = 0
+
+		page := make([]db.MarketKline, 0, len(rows))
+		for _, row := range rows {
+			kline, err := trader.ParseKlineRow(row)
+			if err != nil {
+				return requests, err
+			}
+			bar, err := KlineBar(symbol, interval, kline)
+			if err != nil {
+				return requests, err
+			}
+			page = append(page, bar)
+			start = kline.OpenTime + 1
+		}
+		if len(page) > 0 {
+			if err := handle(page); err != nil {
+				return requests, err
+			}
+		}
+
+		if len(rows) < PageSize {
+			break
+		}
+	}
+
+	return requests, nil
+}
+
+// KlineBar converts an exchange kline to the stored kline representation
+func KlineBar(symbol, interval string, k trader.Kline) (db.MarketKline, error) {
+	open, high, low, closePrice, volume, quoteVolume, err := trader.KlineFloats(k)
+	if err != nil {
+		return db.MarketKline{}, err
+	}
+	trades := int(k.NumberOfTrades)
+
+	return db.MarketKline{
+		Symbol:       symbol,
+		IntervalType: interval,
+		OpenPrice:    open,
+		HighPrice:    high,
+		LowPrice:     low,
+		ClosePrice:   closePrice,
+		Volume:       volume,
+		QuoteVolume:  &quoteVolume,
+		OpenTime:     time.UnixMilli(k.OpenTime),
+		CloseTime:    time.UnixMilli(k.CloseTime),
+		IsClosed:     time.UnixMilli(k.CloseTime).Before(time.Now()),
+		TradeCount:   &trades,
+		Timestamp:    time.Now(),
+	}, nil
+}
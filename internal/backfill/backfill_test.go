@@ -0,0 +1,213 @@
+package backfill
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/adeilh/agentic_go_signals/internal/db"
+	"github.com/adeilh/agentic_go_signals/internal/exchange"
+	"github.com/adeilh/agentic_go_signals/internal/trader"
+)
+
+// memoryKlines is a KlineStore keyed like unique_kline
+type memoryKlines struct {
+	klines  map[int64]db.MarketKline
+	writes  int
+	rollups int
+}
+
+func newMemoryKlines() *memoryKlines {
+	return &memoryKlines{klines: make(map[int64]db.MarketKline)}
+}
+
+func (m *memoryKlines) GetKlineOpenTimes(symbol, interval string, from, to time.Time) ([]time.Time, error) {
+	var times []time.Time
+	for _, k := range m.klines {
+		if !k.OpenTime.Before(from) && k.OpenTime.Before(to) {
+			times = append(times, k.OpenTime)
+		}
+	}
+	sort.Slice(times, func(i, j int) bool { return times[i].Before(times[j]) })
+	return times, nil
+}
+
+func (m *memoryKlines) StoreKlines(klines []db.MarketKline) error {
+	m.writes++
+	for _, k := range klines {
+		m.klines[k.OpenTime.UnixMilli()] = k
+	}
+	return nil
+}
+
+func (m *memoryKlines) MaterializeRollups(symbol string, from, to time.Time) error {
+	m.rollups++
+	return nil
+}
+
+// rateLimited fails its first GetKlines call with a rate limit error
+type rateLimited struct {
+	*exchange.Fake
+	limited bool
+}
+
+func (r *rateLimited) GetKlines(symbol, interval string, limit int, startTime, endTime int64) ([][]interface{}, error) {
+	if !r.limited {
+		r.limited = true
+		return nil, &trader.RateLimitError{StatusCode: 429, RetryAfter: time.Millisecond}
+	}
+	return r.Fake.GetKlines(symbol, interval, limit, startTime, endTime)
+}
+
+var start = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// minuteKlines returns n one-minute klines from start, skipping the given
+// indexes
+func minuteKlines(n int, skip ...int) []trader.Kline {
+	skipped := make(map[int]bool)
+	for _, i := range skip {
+		skipped[i] = true
+	}
+	var klines []trader.Kline
+	for i := 0; i < n; i++ {
+		if skipped[i] {
+			continue
+		}
+		open := start.Add(time.Duration(i) * time.Minute)
+		price := strconv.Itoa(100 + i)
+		klines = append(klines, trader.Kline{
+			OpenTime:         open.UnixMilli(),
+			Open:             price,
+			High:             price,
+			Low:              price,
+			Close:            price,
+			Volume:           "1",
+			CloseTime:        open.Add(time.Minute).UnixMilli() - 1,
+			QuoteAssetVolume: price,
+			NumberOfTrades:   1,
+		})
+	}
+	return klines
+}
+
+func TestFindGaps(t *testing.T) {
+	minute := func(i int) time.Time { return start.Add(time.Duration(i) * time.Minute) }
+	existing := []time.Time{minute(0), minute(1), minute(4), minute(6)}
+
+	slots, gaps := FindGaps(existing, minute(0), minute(8), time.Minute)
+	if slots != 8 || len(gaps) != 3 {
+		t.Fatalf("expected 8 slots and 3 gaps, got %d and %+v", slots, gaps)
+	}
+	want := []Gap{{minute(2), minute(4), 2}, {minute(5), minute(6), 1}, {minute(7), minute(8), 1}}
+	for i, gap := range gaps {
+		if !gap.From.Equal(want[i].From) || !gap.To.Equal(want[i].To) || gap.Bars != want[i].Bars {
+			t.Fatalf("expected gaps %+v, got %+v", want, gaps)
+		}
+	}
+
+	if _, gaps := FindGaps(nil, minute(0), minute(3), time.Minute); len(gaps) != 1 || gaps[0].Bars != 3 {
+		t.Fatalf("expected the whole range missing, got %+v", gaps)
+	}
+}
+
+func TestBackfillerRun(t *testing.T) {
+	fake := exchange.NewFake()
+	// The exchange itself has no kline at minute 7
+	fake.SetKlines("BTCUSDT", "1m", minuteKlines(2500, 7))
+
+	store := newMemoryKlines()
+	for _, k := range minuteKlines(2500)[:5] {
+		bar, err := KlineBar("BTCUSDT", "1m", k)
+		if err != nil {
+			t.Fatal(err)
+		}
+		store.klines[k.OpenTime] = bar
+	}
+
+	backfiller := &Backfiller{
+		market: fake,
+		store:  store,
+		now:    func() time.Time { return start.Add(2400*time.Minute + 30*time.Second) },
+	}
+	// To is past now, so it is cut back to the last closed kline
+	result, err := backfiller.Run(context.Background(), Request{Symbol: "btcusdt", Interval: "1m", From: start.Add(10 * time.Second), To: start.Add(3000 * time.Minute)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result.Symbol != "BTCUSDT" || !result.From.Equal(start) || !result.To.Equal(start.Add(2400*time.Minute)) {
+		t.Fatalf("unexpected range %+v", result.Request)
+	}
+	if result.Expected != 2400 || result.Missing != 2395 || len(result.Gaps) != 1 {
+		t.Fatalf("unexpected gaps: %+v", result)
+	}
+	if result.Stored != 2394 || result.Unfilled != 1 || result.Requests != 3 {
+		t.Fatalf("unexpected result %+v", result)
+	}
+	if len(store.klines) != 2399 {
+		t.Fatalf("expected 2399 stored klines, got %d", len(store.klines))
+	}
+	if bar := store.klines[start.Add(2399*time.Minute).UnixMilli()]; bar.ClosePrice != 2499 || !bar.IsClosed {
+		t.Fatalf("unexpected last bar %+v", bar)
+	}
+
+	// A second run finds only the kline the exchange lacks
+	again, err := backfiller.Run(context.Background(), Request{Symbol: "BTCUSDT", Interval: "1m", From: start, To: start.Add(2400 * time.Minute)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.Missing != 1 || again.Stored != 0 || again.Unfilled != 1 {
+		t.Fatalf("expected an idempotent rerun, got %+v", again)
+	}
+	// Only the run that stored base klines rolls them up
+	if store.rollups != 1 {
+		t.Fatalf("expected one rollup, got %d", store.rollups)
+	}
+
+	if _, err := backfiller.Run(context.Background(), Request{Symbol: "BTCUSDT", Interval: "1M", From: start, To: start.Add(time.Hour)}); err == nil {
+		t.Fatal("expected an error for an unsupported interval")
+	}
+	if _, err := backfiller.Run(context.Background(), Request{Symbol: "BTCUSDT", Interval: "1m", From: start.Add(3000 * time.Minute), To: start.Add(4000 * time.Minute)}); err == nil {
+		t.Fatal("expected an error for a range with no closed klines")
+	}
+}
+
+func TestFetchRetriesRateLimits(t *testing.T) {
+	fake := exchange.NewFake()
+	fake.SetKlines("BTCUSDT", "1m", minuteKlines(10))
+	market := &rateLimited{Fake: fake}
+
+	bars, err := Fetch(context.Background(), market, NewLimiter(60000), "BTCUSDT", "1m", start, start.Add(10*time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !market.limited || len(bars) != 10 {
+		t.Fatalf("expected all 10 klines after the retry, got %d", len(bars))
+	}
+}
+
+func TestLimiter(t *testing.T) {
+	now := start
+	limiter := NewLimiter(60) // One unit of weight per second
+	limiter.now = func() time.Time { return now }
+
+	ctx, cancel := context.WithCancel(context.Background())
+	if err := limiter.Wait(ctx, 2); err != nil {
+		t.Fatal(err)
+	}
+	if !limiter.next.Equal(start.Add(2 * time.Second)) {
+		t.Fatalf("expected the next request 2s later, got %s", limiter.next.Sub(start))
+	}
+	limiter.Pause(time.Minute)
+	if !limiter.next.Equal(start.Add(time.Minute)) {
+		t.Fatalf("expected a pause to push the next request back, got %s", limiter.next.Sub(start))
+	}
+
+	// The next request would wait a minute; a canceled context ends it
+	cancel()
+	if err := limiter.Wait(ctx, 1); err == nil {
+		t.Fatal("expected the canceled context's error")
+	}
+}
@@ -0,0 +1,109 @@
+package backfill
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Job states
+const (
+	JobRunning = "running"
+	JobDone    = "done"
+	JobFailed  = "failed"
+)
+
+// Job is a backfill running in the background
+type Job struct {
+	ID         string     `json:"id"`
+	Request    Request    `json:"request"`
+	Status     string     `json:"status"`
+	Result     *Result    `json:"result,omitempty"` // Progress so far when failed
+	Error      string     `json:"error,omitempty"`
+	StartedAt  time.Time  `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
+
+// Jobs runs backfills in the background and keeps their outcomes. Only one
+// job per symbol and interval runs at a time. It is safe for concurrent
+// use.
+type Jobs struct {
+	backfiller *Backfiller
+
+	mu   sync.Mutex
+	jobs map[string]*Job
+	next int
+}
+
+// NewJobs creates a job runner for backfiller
+func NewJobs(backfiller *Backfiller) *Jobs {
+	return &Jobs{backfiller: backfiller, jobs: make(map[string]*Job)}
+}
+
+// Start launches a backfill of req, which runs until it finishes or ctx is
+// done
+func (j *Jobs) Start(ctx context.Context, req Request) (Job, error) {
+	req.Symbol = strings.ToUpper(strings.TrimSpace(req.Symbol))
+
+	j.mu.Lock()
+	for _, job := range j.jobs {
+		if job.Status == JobRunning && job.Request.Symbol == req.Symbol && job.Request.Interval == req.Interval {
+			j.mu.Unlock()
+			return Job{}, fmt.Errorf("backfill %s of %s %s is already running", job.ID, req.Symbol, req.Interval)
+		}
+	}
+	j.next++
+	job := &Job{
+		ID:        fmt.Sprintf("backfill-%d", j.next),
+		Request:   req,
+		Status:    JobRunning,
+		StartedAt: time.Now(),
+	}
+	j.jobs[job.ID] = job
+	snapshot := *job
+	j.mu.Unlock()
+
+	go func() {
+		result, err := j.backfiller.Run(ctx, req)
+
+		j.mu.Lock()
+		defer j.mu.Unlock()
+		finished := time.Now()
+		job.FinishedAt = &finished
+		job.Result = result
+		job.Status = JobDone
+		if err != nil {
+			job.Status = JobFailed
+			job.Error = err.Error()
+		}
+	}()
+
+	return snapshot, nil
+}
+
+// Get returns the job with id
+func (j *Jobs) Get(id string) (Job, bool) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	job, ok := j.jobs[id]
+	if !ok {
+		return Job{}, false
+	}
+	return *job, true
+}
+
+// List returns every job, newest first
+func (j *Jobs) List() []Job {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	jobs := make([]Job, 0, len(j.jobs))
+	for _, job := range j.jobs {
+		jobs = append(jobs, *job)
+	}
+	sort.Slice(jobs, func(a, b int) bool { return jobs[a].StartedAt.After(jobs[b].StartedAt) })
+	return jobs
+}
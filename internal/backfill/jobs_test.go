@@ -0,0 +1,62 @@
+package backfill
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/adeilh/agentic_go_signals/internal/exchange"
+)
+
+func TestJobs(t *testing.T) {
+	fake := exchange.NewFake()
+	fake.SetKlines("BTCUSDT", "1m", minuteKlines(60))
+	jobs := NewJobs(&Backfiller{
+		market: fake,
+		store:  newMemoryKlines(),
+		now:    func() time.Time { return start.Add(time.Hour) },
+	})
+
+	job, err := jobs.Start(context.Background(), Request{Symbol: "btcusdt", Interval: "1m", From: start, To: start.Add(time.Hour)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job.Status != JobRunning || job.Request.Symbol != "BTCUSDT" {
+		t.Fatalf("unexpected job %+v", job)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		job, _ = jobs.Get(job.ID)
+		if job.Status != JobRunning || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if job.Status != JobDone || job.Result == nil || job.Result.Stored != 60 || job.FinishedAt == nil {
+		t.Fatalf("unexpected finished job %+v", job)
+	}
+
+	failed, err := jobs.Start(context.Background(), Request{Symbol: "BTCUSDT", Interval: "bad"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	deadline = time.Now().Add(time.Second)
+	for {
+		failed, _ = jobs.Get(failed.ID)
+		if failed.Status != JobRunning || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if failed.Status != JobFailed || failed.Error == "" {
+		t.Fatalf("expected a failed job, got %+v", failed)
+	}
+
+	if list := jobs.List(); len(list) != 2 {
+		t.Fatalf("expected 2 jobs, got %d", len(list))
+	}
+	if _, ok := jobs.Get("missing"); ok {
+		t.Fatal("expected no job for an unknown id")
+	}
+}
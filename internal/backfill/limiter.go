@@ -0,0 +1,66 @@
+package backfill
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Limiter spaces requests evenly so that their total weight stays within a
+// per-minute budget. It is safe for concurrent use.
+type Limiter struct {
+	perWeight time.Duration // Time each unit of weight uses up
+
+	mu   sync.Mutex
+	next time.Time
+	now  func() time.Time
+}
+
+// NewLimiter creates a limiter allowing weightPerMinute of request weight
+// per minute
+func NewLimiter(weightPerMinute int) *Limiter {
+	return &Limiter{
+		perWeight: time.Minute / time.Duration(max(weightPerMinute, 1)),
+		now:       time.Now,
+	}
+}
+
+// Wait blocks until a request of the given weight may be sent, or ctx is
+// done
+func (l *Limiter) Wait(ctx context.Context, weight int) error {
+	l.mu.Lock()
+	now := l.now()
+	at := l.next
+	if at.Before(now) {
+		at = now
+	}
+	l.next = at.Add(time.Duration(weight) * l.perWeight)
+	l.mu.Unlock()
+
+	return sleep(ctx, at.Sub(now))
+}
+
+// Pause holds every request back for d, e.g. after the exchange asked us
+// to back off
+func (l *Limiter) Pause(d time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if until := l.now().Add(d); until.After(l.next) {
+		l.next = until
+	}
+}
+
+// sleep waits for d or until ctx is done
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
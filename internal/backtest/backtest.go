@@ -0,0 +1,390 @@
+package backtest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/adeilh/agentic_go_signals/internal/db"
+	"github.com/adeilh/agentic_go_signals/internal/indicators"
+	"github.com/adeilh/agentic_go_signals/internal/risk"
+	"github.com/adeilh/agentic_go_signals/internal/strategy"
+	"github.com/adeilh/agentic_go_signals/internal/trader"
+)
+
+// Exit reasons recorded on closed trades
+const (
+	ExitSignal   = "signal"
+	ExitStopLoss = "stop_loss"
+	ExitEnd      = "end"
+)
+
+// Config controls how a backtest simulates trading
+type Config struct {
+	Symbol      string          `json:"symbol"`
+	Interval    string          `json:"interval"`
+	RiskParams  risk.RiskParams `json:"risk_params"`  // AccountBalance is the starting equity
+	FeeRate     float64         `json:"fee_rate"`     // Fee charged on the notional of every fill, e.g. 0.001 for 0.1%
+	SlippageBps float64         `json:"slippage_bps"` // Adverse price move applied to every fill, in basis points
+}
+
+// Trade is a simulated round trip
+type Trade struct {
+	Side       string    `json:"side"`
+	EntryTime  time.Time `json:"entry_time"`
+	EntryPrice float64   `json:"entry_price"`
+	ExitTime   time.Time `json:"exit_time"`
+	ExitPrice  float64   `json:"exit_price"`
+	Qty        float64   `json:"qty"`
+	PnL        float64   `json:"pnl"` // Net of fees
+	Fees       float64   `json:"fees"`
+	ExitReason string    `json:"exit_reason"`
+}
+
+// EquityPoint is the marked-to-market equity at a bar close
+type EquityPoint struct {
+	Time     time.Time `json:"time"`
+	Equity   float64   `json:"equity"`
+	Drawdown float64   `json:"drawdown"` // Fraction below the running peak
+}
+
+// Result summarises a backtest run
+type Result struct {
+	Strategy    string        `json:"strategy"`
+	Symbol      string        `json:"symbol"`
+	Interval    string        `json:"interval"`
+	Bars        int           `json:"bars"`
+	StartEquity float64       `json:"start_equity"`
+	FinalEquity float64       `json:"final_equity"`
+	TotalReturn float64       `json:"total_return"`
+	MaxDrawdown float64       `json:"max_drawdown"`
+	Sharpe      float64       `json:"sharpe"` // Annualized from per-bar returns
+	WinRate     float64       `json:"win_rate"`
+	TotalFees   float64       `json:"total_fees"`
+	Trades      []Trade       `json:"trades"`
+	EquityCurve []EquityPoint `json:"equity_curve"`
+}
+
+// position is the open simulated position
+type position struct {
+	side       string
+	qty        float64
+	entryPrice float64
+	entryTime  time.Time
+	entryFee   float64
+	stopLoss   float64
+}
+
+// unrealized returns the gross PnL of the position at price
+func (p *position) unrealized(price float64) float64 {
+	if p.side == strategy.Long {
+		return (price - p.entryPrice) * p.qty
+	}
+	return (p.entryPrice - price) * p.qty
+}
+
+// simulator holds the state of a single run
+type simulator struct {
+	cfg    Config
+	cash   float64
+	peak   float64
+	pos    *position
+	result *Result
+}
+
+// Run replays bars oldest first, asking strat for a decision after each bar
+// closes with a snapshot holding every bar so far. A decision taken at a
+// bar's close is filled at the next bar's open, so a strategy never trades on
+// a price it could not have seen. Open positions are stopped out intrabar at
+// the stop-loss from risk.Calculator, and any position left at the end is
+// closed at the last close.
+func Run(ctx context.Context, bars []db.MarketKline, strat strategy.Strategy, cfg Config) (*Result, error) {
+	if strat == nil {
+		return nil, errors.New("strategy is required")
+	}
+	if len(bars) == 0 {
+		return nil, errors.New("no bars to backtest")
+	}
+	barLength, err := trader.IntervalDuration(cfg.Interval)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.FeeRate < 0 || cfg.FeeRate >= 1 {
+		return nil, errors.New("fee rate must be between 0 and 1")
+	}
+	if cfg.SlippageBps < 0 || cfg.SlippageBps >= 10000 {
+		return nil, errors.New("slippage must be between 0 and 10000 bps")
+	}
+	if _, err := risk.NewCalculator(cfg.RiskParams); err != nil {
+		return nil, fmt.Errorf("invalid risk params: %w", err)
+	}
+
+	s := &simulator{
+		cfg:  cfg,
+		cash: cfg.RiskParams.AccountBalance,
+		peak: cfg.RiskParams.AccountBalance,
+		result: &Result{
+			Strategy:    strat.Name(),
+			Symbol:      cfg.Symbol,
+			Interval:    cfg.Interval,
+			Bars:        len(bars),
+			StartEquity: cfg.RiskParams.AccountBalance,
+			Trades:      []Trade{},
+			EquityCurve: make([]EquityPoint, 0, len(bars)),
+		},
+	}
+
+	// Each reading only depends on bars up to its own, so computing the
+	// whole series up front leaks nothing from the future
+	readings, err := indicators.Compute(bars, indicators.Names())
+	if err != nil {
+		return nil, err
+	}
+
+	decision := strategy.Flat
+	var sizing risk.SizingInputs
+	stoppedSide := ""
+	for i, bar := range bars {
+		if i > 0 {
+			if decision != stoppedSide {
+				stoppedSide = ""
+			}
+			if s.pos != nil && s.pos.side != decision {
+				s.close(bar.OpenPrice, bar.OpenTime, ExitSignal)
+			}
+			if s.pos == nil && decision != strategy.Flat && decision != stoppedSide {
+				if err := s.open(decision, bar.OpenPrice, bar.OpenTime, sizing); err != nil {
+					return nil, err
+				}
+			}
+		}
+
+		if s.pos != nil && s.stopHit(bar) {
+			stoppedSide = s.pos.side
+			s.close(s.stopFillPrice(bar), bar.CloseTime, ExitStopLoss)
+		}
+
+		s.mark(bar.ClosePrice, bar.CloseTime)
+
+		next, err := strat.Decide(ctx, strategy.Snapshot{
+			Symbol:     cfg.Symbol,
+			Time:       bar.CloseTime,
+			Bars:       bars[:i+1],
+			Indicators: &readings[i],
+		})
+		if err != nil {
+			return nil, fmt.Errorf("strategy %s failed at %s: %w", strat.Name(), bar.CloseTime.Format(time.RFC3339), err)
+		}
+		decision = next.Action
+		if decision != strategy.Long && decision != strategy.Short {
+			decision = strategy.Flat
+		}
+		sizing = s.sizingInputs(next, readings[i])
+	}
+
+	if s.pos != nil {
+		last := bars[len(bars)-1]
+		s.close(last.ClosePrice, last.CloseTime, ExitEnd)
+		s.result.EquityCurve = s.result.EquityCurve[:len(s.result.EquityCurve)-1]
+		s.mark(last.ClosePrice, last.CloseTime)
+	}
+
+	s.finish(barLength)
+	return s.result, nil
+}
+
+// fillPrice applies slippage against the trader: buys fill higher, sells lower
+func (s *simulator) fillPrice(price float64, buy bool) float64 {
+	slip := s.cfg.SlippageBps / 10000
+	if buy {
+		return price * (1 + slip)
+	}
+	return price * (1 - slip)
+}
+
+// equity returns cash plus the open position marked at price
+func (s *simulator) equity(price float64) float64 {
+	if s.pos == nil {
+		return s.cash
+	}
+	return s.cash + s.pos.unrealized(price)
+}
+
+// sizingInputs are what risk.Calculator sizes the position a decision opens
+// with: the ATR at the decision's bar, its confidence and the hit rate of
+// the round trips simulated so far
+func (s *simulator) sizingInputs(decision strategy.Decision, reading indicators.Readings) risk.SizingInputs {
+	in := risk.SizingInputs{
+		Conviction: float64(decision.Confidence) / 100,
+		Samples:    len(s.result.Trades),
+	}
+	if reading.ATR14 != nil {
+		in.ATR = *reading.ATR14
+	}
+	if in.Samples > 0 {
+		wins := 0
+		for _, trade := range s.result.Trades {
+			if trade.PnL > 0 {
+				wins++
+			}
+		}
+		in.HitRate = float64(wins) / float64(in.Samples)
+	}
+	return in
+}
+
+// open sizes a new position with risk.Calculator against current equity.
+// Decisions the sizing mode finds no edge in, or cannot size before the
+// ATR has warmed up, are not traded.
+func (s *simulator) open(side string, price float64, at time.Time, in risk.SizingInputs) error {
+	if s.cash <= 0 {
+		return nil
+	}
+
+	params := s.cfg.RiskParams
+	params.AccountBalance = s.cash
+	calc, err := risk.NewCalculator(params)
+	if err != nil {
+		return fmt.Errorf("failed to size position: %w", err)
+	}
+
+	fill := s.fillPrice(price, side == strategy.Long)
+	size, err := calc.Size(fill, tradeDirection(side), 0, in)
+	if errors.Is(err, risk.ErrNoEdge) || errors.Is(err, risk.ErrNoATR) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to size position: %w", err)
+	}
+	if size.Quantity <= 0 {
+		return nil
+	}
+
+	fee := size.Quantity * fill * s.cfg.FeeRate
+	s.cash -= fee
+	s.pos = &position{
+		side:       side,
+		qty:        size.Quantity,
+		entryPrice: fill,
+		entryTime:  at,
+		entryFee:   fee,
+		stopLoss:   size.StopLoss,
+	}
+	return nil
+}
+
+// close exits the open position at price and records the round trip
+func (s *simulator) close(price float64, at time.Time, reason string) {
+	p := s.pos
+	fill := s.fillPrice(price, p.side == strategy.Short)
+	fee := p.qty * fill * s.cfg.FeeRate
+	gross := p.unrealized(fill)
+
+	s.cash += gross - fee
+	s.pos = nil
+
+	s.result.TotalFees += p.entryFee + fee
+	s.result.Trades = append(s.result.Trades, Trade{
+		Side:       p.side,
+		EntryTime:  p.entryTime,
+		EntryPrice: p.entryPrice,
+		ExitTime:   at,
+		ExitPrice:  fill,
+		Qty:        p.qty,
+		PnL:        gross - p.entryFee - fee,
+		Fees:       p.entryFee + fee,
+		ExitReason: reason,
+	})
+}
+
+// stopHit reports whether the bar's range reached the open position's stop
+func (s *simulator) stopHit(bar db.MarketKline) bool {
+	if s.pos.side == strategy.Long {
+		return bar.LowPrice <= s.pos.stopLoss
+	}
+	return bar.HighPrice >= s.pos.stopLoss
+}
+
+// stopFillPrice is the stop price, or the open when the bar gapped through it
+func (s *simulator) stopFillPrice(bar db.MarketKline) float64 {
+	if s.pos.side == strategy.Long {
+		return math.Min(bar.OpenPrice, s.pos.stopLoss)
+	}
+	return math.Max(bar.OpenPrice, s.pos.stopLoss)
+}
+
+// mark appends the equity at a bar close to the curve
+func (s *simulator) mark(price float64, at time.Time) {
+	equity := s.equity(price)
+	s.peak = math.Max(s.peak, equity)
+
+	drawdown := 0.0
+	if s.peak > 0 {
+		drawdown = (s.peak - equity) / s.peak
+	}
+	s.result.EquityCurve = append(s.result.EquityCurve, EquityPoint{Time: at, Equity: equity, Drawdown: drawdown})
+}
+
+// finish computes the summary statistics from the equity curve and trades
+func (s *simulator) finish(barLength time.Duration) {
+	r := s.result
+	r.FinalEquity = s.cash
+	if r.StartEquity > 0 {
+		r.TotalReturn = r.FinalEquity/r.StartEquity - 1
+	}
+
+	returns := make([]float64, 0, len(r.EquityCurve))
+	prev := r.StartEquity
+	for _, point := range r.EquityCurve {
+		r.MaxDrawdown = math.Max(r.MaxDrawdown, point.Drawdown)
+		if prev > 0 {
+			returns = append(returns, point.Equity/prev-1)
+		}
+		prev = point.Equity
+	}
+	r.Sharpe = sharpe(returns, float64(365*24*time.Hour)/float64(barLength))
+
+	if len(r.Trades) > 0 {
+		wins := 0
+		for _, trade := range r.Trades {
+			if trade.PnL > 0 {
+				wins++
+			}
+		}
+		r.WinRate = float64(wins) / float64(len(r.Trades))
+	}
+}
+
+// sharpe annualizes the mean over the sample standard deviation of per-bar
+// returns, assuming a zero risk-free rate
+func sharpe(returns []float64, periodsPerYear float64) float64 {
+	if len(returns) < 2 {
+		return 0
+	}
+
+	mean := 0.0
+	for _, r := range returns {
+		mean += r
+	}
+	mean /= float64(len(returns))
+
+	variance := 0.0
+	for _, r := range returns {
+		variance += (r - mean) * (r - mean)
+	}
+	std := math.Sqrt(variance / float64(len(returns)-1))
+	if std == 0 {
+		return 0
+	}
+	return mean / std * math.Sqrt(periodsPerYear)
+}
+
+// tradeDirection maps a decision to the direction risk.Calculator expects
+func tradeDirection(decision string) string {
+	if decision == strategy.Short {
+		return "short"
+	}
+	return "long"
+}
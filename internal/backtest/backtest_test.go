@@ -0,0 +1,286 @@
+package backtest
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/adeilh/agentic_go_signals/internal/db"
+	"github.com/adeilh/agentic_go_signals/internal/exchange"
+	"github.com/adeilh/agentic_go_signals/internal/risk"
+	"github.com/adeilh/agentic_go_signals/internal/strategy"
+	"github.com/adeilh/agentic_go_signals/internal/trader"
+)
+
+// fixedStrategy always returns the same decision
+type fixedStrategy string
+
+func (f fixedStrategy) Name() string { return "fixed" }
+func (f fixedStrategy) Decide(ctx context.Context, snap strategy.Snapshot) (strategy.Decision, error) {
+	return strategy.Decision{Action: string(f)}, nil
+}
+
+// makeBars builds 1m bars opening at the previous close
+func makeBars(closes ...float64) []db.MarketKline {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	bars := make([]db.MarketKline, len(closes))
+	open := closes[0]
+	for i, c := range closes {
+		openTime := start.Add(time.Duration(i) * time.Minute)
+		bars[i] = db.MarketKline{
+			Symbol:       "BTCUSDT",
+			IntervalType: "1m",
+			OpenPrice:    open,
+			HighPrice:    math.Max(open, c),
+			LowPrice:     math.Min(open, c),
+			ClosePrice:   c,
+			OpenTime:     openTime,
+			CloseTime:    openTime.Add(time.Minute - time.Millisecond),
+		}
+		open = c
+	}
+	return bars
+}
+
+func testConfig() Config {
+	return Config{Symbol: "BTCUSDT", Interval: "1m", RiskParams: risk.DefaultParams()}
+}
+
+func TestRunLongTrend(t *testing.T) {
+	ctx := context.Background()
+	bars := makeBars(100, 101, 102, 103, 104)
+
+	result, err := Run(ctx, bars, fixedStrategy(strategy.Long), testConfig())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(result.Trades) != 1 {
+		t.Fatalf("expected 1 trade, got %d", len(result.Trades))
+	}
+
+	trade := result.Trades[0]
+	if trade.EntryPrice != 100 || trade.ExitPrice != 104 || trade.ExitReason != ExitEnd {
+		t.Fatalf("unexpected trade: %+v", trade)
+	}
+	// 10% max position of 10000 at 100 caps the size at 10 units
+	if trade.Qty != 10 {
+		t.Fatalf("expected qty 10, got %v", trade.Qty)
+	}
+	if math.Abs(result.FinalEquity-10040) > 1e-9 {
+		t.Fatalf("expected final equity 10040, got %v", result.FinalEquity)
+	}
+	if result.WinRate != 1 || result.MaxDrawdown != 0 {
+		t.Fatalf("unexpected stats: win rate %v, drawdown %v", result.WinRate, result.MaxDrawdown)
+	}
+	if result.Sharpe <= 0 {
+		t.Fatalf("expected positive sharpe, got %v", result.Sharpe)
+	}
+	if len(result.EquityCurve) != len(bars) {
+		t.Fatalf("expected %d equity points, got %d", len(bars), len(result.EquityCurve))
+	}
+	if last := result.EquityCurve[len(bars)-1]; last.Equity != result.FinalEquity {
+		t.Fatalf("last equity point %v does not match final equity %v", last.Equity, result.FinalEquity)
+	}
+}
+
+func TestRunFeesAndSlippage(t *testing.T) {
+	ctx := context.Background()
+	bars := makeBars(100, 101, 102, 103, 104)
+	cfg := testConfig()
+	cfg.FeeRate = 0.001
+	cfg.SlippageBps = 10
+
+	result, err := Run(ctx, bars, fixedStrategy(strategy.Long), cfg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	trade := result.Trades[0]
+	if math.Abs(trade.EntryPrice-100.1) > 1e-9 || math.Abs(trade.ExitPrice-103.896) > 1e-9 {
+		t.Fatalf("expected slipped fills, got %v -> %v", trade.EntryPrice, trade.ExitPrice)
+	}
+	if result.TotalFees <= 0 || trade.Fees != result.TotalFees {
+		t.Fatalf("expected fees to be charged, got %v", result.TotalFees)
+	}
+	if math.Abs(result.FinalEquity-(10000+trade.PnL)) > 1e-9 {
+		t.Fatalf("final equity %v does not match trade pnl %v", result.FinalEquity, trade.PnL)
+	}
+	if result.FinalEquity >= 10040 {
+		t.Fatalf("expected costs to reduce equity, got %v", result.FinalEquity)
+	}
+}
+
+func TestRunStopLoss(t *testing.T) {
+	ctx := context.Background()
+	bars := makeBars(100, 100, 90, 91, 92)
+
+	result, err := Run(ctx, bars, fixedStrategy(strategy.Long), testConfig())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(result.Trades) != 1 {
+		t.Fatalf("expected no re-entry after the stop, got %d trades", len(result.Trades))
+	}
+
+	trade := result.Trades[0]
+	if trade.ExitReason != ExitStopLoss || trade.ExitPrice != 95 {
+		t.Fatalf("expected stop at 95, got %+v", trade)
+	}
+	if result.WinRate != 0 || result.MaxDrawdown <= 0 {
+		t.Fatalf("unexpected stats: win rate %v, drawdown %v", result.WinRate, result.MaxDrawdown)
+	}
+}
+
+func TestRunShortAndReverse(t *testing.T) {
+	ctx := context.Background()
+	bars := makeBars(100, 99, 98, 99, 100)
+	decisions := []string{strategy.Short, strategy.Short, strategy.Long, strategy.Long, strategy.Long}
+	result, err := Run(ctx, bars, &scriptedStrategy{decisions: decisions}, testConfig())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(result.Trades) != 2 {
+		t.Fatalf("expected 2 trades, got %d", len(result.Trades))
+	}
+	if result.Trades[0].Side != strategy.Short || result.Trades[0].ExitReason != ExitSignal || result.Trades[0].PnL <= 0 {
+		t.Fatalf("unexpected short trade: %+v", result.Trades[0])
+	}
+	if result.Trades[1].Side != strategy.Long || result.Trades[1].ExitReason != ExitEnd {
+		t.Fatalf("unexpected long trade: %+v", result.Trades[1])
+	}
+}
+
+// scriptedStrategy returns the decision scripted for the latest bar
+type scriptedStrategy struct {
+	decisions []string
+}
+
+func (s *scriptedStrategy) Name() string { return "scripted" }
+func (s *scriptedStrategy) Decide(ctx context.Context, snap strategy.Snapshot) (strategy.Decision, error) {
+	return strategy.Decision{Action: s.decisions[len(snap.Bars)-1]}, nil
+}
+
+func TestRunValidation(t *testing.T) {
+	ctx := context.Background()
+	bars := makeBars(100, 101)
+
+	if _, err := Run(ctx, nil, fixedStrategy(strategy.Long), testConfig()); err == nil {
+		t.Fatal("expected error for no bars")
+	}
+	if _, err := Run(ctx, bars, nil, testConfig()); err == nil {
+		t.Fatal("expected error for nil strategy")
+	}
+
+	cfg := testConfig()
+	cfg.Interval = "7m"
+	if _, err := Run(ctx, bars, fixedStrategy(strategy.Long), cfg); err == nil {
+		t.Fatal("expected error for unsupported interval")
+	}
+
+	cfg = testConfig()
+	cfg.FeeRate = -0.1
+	if _, err := Run(ctx, bars, fixedStrategy(strategy.Long), cfg); err == nil {
+		t.Fatal("expected error for negative fee rate")
+	}
+
+	cfg = testConfig()
+	cfg.RiskParams.AccountBalance = 0
+	if _, err := Run(ctx, bars, fixedStrategy(strategy.Long), cfg); err == nil {
+		t.Fatal("expected error for invalid risk params")
+	}
+}
+
+func TestPredictionReplay(t *testing.T) {
+	bars := makeBars(100, 101, 102, 103)
+	predictions := []db.Prediction{
+		{Ts: bars[0].CloseTime, Dir: strategy.Long},
+		{Ts: bars[1].CloseTime.Add(time.Second), Dir: strategy.Short},
+	}
+	replay := NewPredictionReplay("bot_1", predictions, 90*time.Second)
+	ctx := context.Background()
+
+	expected := []string{strategy.Long, strategy.Long, strategy.Short, strategy.Flat}
+	for i, want := range expected {
+		decision, err := replay.Decide(ctx, strategy.Snapshot{Time: bars[i].CloseTime, Bars: bars[:i+1]})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if decision.Action != want {
+			t.Fatalf("bar %d: expected %s, got %s", i, want, decision.Action)
+		}
+	}
+
+	decision, _ := NewPredictionReplay("bot_1", nil, 0).Decide(ctx, strategy.Snapshot{Time: bars[3].CloseTime})
+	if decision.Action != strategy.Flat {
+		t.Fatalf("expected FLAT without predictions, got %s", decision.Action)
+	}
+}
+
+func TestNewStrategy(t *testing.T) {
+	from := time.Now().Add(-time.Hour)
+	to := time.Now()
+
+	strat, err := NewStrategy("", nil, "", "BTCUSDT", from, to)
+	if err != nil || strat.Name() != "sma_cross_10_30" {
+		t.Fatalf("expected default SMA strategy, got %v, %v", strat, err)
+	}
+	if _, err := NewStrategy(strategy.NameRules, nil, "", "BTCUSDT", from, to); err != nil {
+		t.Fatalf("unexpected error for rules strategy: %v", err)
+	}
+	if _, err := NewStrategy(strategy.NameKimi, nil, "", "BTCUSDT", from, to); err == nil {
+		t.Fatal("expected error for the kimi strategy")
+	}
+	if _, err := NewStrategy(StrategyPredictions, nil, "", "BTCUSDT", from, to); err == nil {
+		t.Fatal("expected error without bot_id")
+	}
+	if _, err := NewStrategy(StrategyPredictions, nil, "bot_1", "BTCUSDT", from, to); err == nil {
+		t.Fatal("expected error without database")
+	}
+	if _, err := NewStrategy("martingale", nil, "", "BTCUSDT", from, to); err == nil {
+		t.Fatal("expected error for unknown strategy")
+	}
+}
+
+func TestLoadBarsValidation(t *testing.T) {
+	now := time.Now()
+
+	if _, err := LoadBars(nil, nil, SourceDB, "BTCUSDT", "1m", now, now.Add(-time.Hour)); err == nil {
+		t.Fatal("expected error for inverted range")
+	}
+	if _, err := LoadBars(nil, nil, SourceDB, "BTCUSDT", "1m", now.Add(-time.Hour), now); err == nil {
+		t.Fatal("expected error without database")
+	}
+	if _, err := LoadBars(nil, nil, SourceBinance, "BTCUSDT", "1m", now.Add(-time.Hour), now); err == nil {
+		t.Fatal("expected error without an exchange")
+	}
+	if _, err := LoadBars(nil, nil, "csv", "BTCUSDT", "1m", now.Add(-time.Hour), now); err == nil {
+		t.Fatal("expected error for unknown source")
+	}
+}
+
+func TestLoadBarsFromExchange(t *testing.T) {
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	klines := make([]trader.Kline, 2500)
+	for i := range klines {
+		open := from.Add(time.Duration(i) * time.Minute).UnixMilli()
+		klines[i] = trader.Kline{
+			OpenTime: open, CloseTime: open + 59999,
+			Open: "1", High: "1", Low: "1", Close: "1", Volume: "1", QuoteAssetVolume: "1",
+		}
+	}
+	market := exchange.NewFake()
+	market.SetKlines("BTCUSDT", "1m", klines)
+
+	// The range spans three pages and excludes the bar opening at to
+	bars, err := LoadBars(nil, market, SourceBinance, "BTCUSDT", "1m", from, from.Add(2400*time.Minute))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(bars) != 2400 {
+		t.Fatalf("expected 2400 bars, got %d", len(bars))
+	}
+	if !bars[0].OpenTime.Equal(from) || !bars[2399].OpenTime.Equal(from.Add(2399*time.Minute)) {
+		t.Fatalf("unexpected range %v - %v", bars[0].OpenTime, bars[2399].OpenTime)
+	}
+}
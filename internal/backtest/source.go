@@ -0,0 +1,41 @@
+package backtest
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/adeilh/agentic_go_signals/internal/backfill"
+	"github.com/adeilh/agentic_go_signals/internal/db"
+	"github.com/adeilh/agentic_go_signals/internal/exchange"
+	"github.com/adeilh/agentic_go_signals/internal/trader"
+)
+
+// Bar sources accepted by LoadBars
+const (
+	SourceDB      = "db"
+	SourceBinance = "binance"
+)
+
+// LoadBars reads klines with open_time in [from, to), oldest first, either
+// from market_klines or directly from the exchange klines endpoint
+func LoadBars(database *db.DB, market exchange.MarketData, source, symbol, interval string, from, to time.Time) ([]db.MarketKline, error) {
+	if !from.Before(to) {
+		return nil, fmt.Errorf("from must be before to")
+	}
+	if _, err := trader.IntervalDuration(interval); err != nil {
+		return nil, err
+	}
+
+	switch source {
+	case "", SourceDB:
+		return db.NewMarketDataStore(database).GetKlineRange(symbol, interval, from, to)
+	case SourceBinance:
+		if market == nil {
+			return nil, fmt.Errorf("exchange is nil")
+		}
+		return backfill.Fetch(context.Background(), market, nil, symbol, interval, from, to)
+	default:
+		return nil, fmt.Errorf("unknown source %q", source)
+	}
+}
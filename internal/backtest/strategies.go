@@ -0,0 +1,85 @@
+package backtest
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/adeilh/agentic_go_signals/internal/db"
+	"github.com/adeilh/agentic_go_signals/internal/predictor"
+	"github.com/adeilh/agentic_go_signals/internal/strategy"
+)
+
+// StrategyPredictions replays a bot's stored predictions. Any other name is
+// built by strategy.New.
+const StrategyPredictions = "predictions"
+
+// predictionMaxAge matches how long the orchestrator acts on a prediction
+// with its default 10 minute prediction interval
+const predictionMaxAge = 20 * time.Minute
+
+// PredictionReplay follows the stored predictions of a bot, taking the
+// latest one made at or before each bar close. Predictions older than
+// MaxAge are ignored, as the orchestrator ignores stale predictions.
+type PredictionReplay struct {
+	BotID       string
+	MaxAge      time.Duration
+	predictions []db.Prediction // Sorted by ts, oldest first
+}
+
+// NewPredictionReplay creates a replay strategy from predictions sorted oldest first
+func NewPredictionReplay(botID string, predictions []db.Prediction, maxAge time.Duration) *PredictionReplay {
+	return &PredictionReplay{BotID: botID, MaxAge: maxAge, predictions: predictions}
+}
+
+// LoadPredictionReplay reads a bot's predictions for a symbol covering [from, to]
+func LoadPredictionReplay(database *db.DB, botID, symbol string, from, to time.Time) (*PredictionReplay, error) {
+	predictions, err := predictor.Range(database, botID, symbol, from.Add(-predictionMaxAge), to)
+	if err != nil {
+		return nil, err
+	}
+	return NewPredictionReplay(botID, predictions, predictionMaxAge), nil
+}
+
+func (p *PredictionReplay) Name() string {
+	return StrategyPredictions + ":" + p.BotID
+}
+
+func (p *PredictionReplay) Decide(ctx context.Context, snap strategy.Snapshot) (strategy.Decision, error) {
+	i := sort.Search(len(p.predictions), func(i int) bool {
+		return p.predictions[i].Ts.After(snap.Time)
+	})
+	if i == 0 {
+		return strategy.Decision{Action: strategy.Flat, Reasoning: "no prediction yet"}, nil
+	}
+
+	latest := p.predictions[i-1]
+	if p.MaxAge > 0 && snap.Time.Sub(latest.Ts) > p.MaxAge {
+		return strategy.Decision{Action: strategy.Flat, Reasoning: "latest prediction is stale"}, nil
+	}
+	return strategy.Decision{Action: latest.Dir, Confidence: latest.Conv, Reasoning: latest.Logic}, nil
+}
+
+// NewStrategy builds a strategy for a backtest. The predictions strategy
+// replays the stored calls of botID and needs a database; an empty name
+// selects the SMA cross. The Kimi strategy
+// is not offered: its live calls are neither reproducible nor free, and its
+// past calls are what the predictions strategy replays.
+func NewStrategy(name string, database *db.DB, botID, symbol string, from, to time.Time) (strategy.Strategy, error) {
+	if name == "" {
+		name = strategy.NameSMACross
+	}
+
+	switch name {
+	case StrategyPredictions:
+		if botID == "" {
+			return nil, fmt.Errorf("bot_id is required for the %s strategy", StrategyPredictions)
+		}
+		return LoadPredictionReplay(database, botID, symbol, from, to)
+	case strategy.NameKimi:
+		return nil, fmt.Errorf("the %s strategy cannot be backtested, replay its stored calls with %q", strategy.NameKimi, StrategyPredictions)
+	default:
+		return strategy.New(name, nil)
+	}
+}
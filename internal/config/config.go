@@ -2,7 +2,10 @@ package config
 
 import (
 	"errors"
+	"fmt"
 	"os"
+	"strconv"
+	"time"
 
 	"github.com/joho/godotenv"
 )
@@ -14,8 +17,15 @@ type Config struct {
 	DBDSN             string
 	SlackWebhook      string
 	BinanceProduction bool
+	Exchange          string        // Registry name of the exchange, e.g. "binance"
+	LabelHorizon      time.Duration // Forward-return horizon used to label predictions
+	PaperTrading      bool          // Fill bot orders with the paper broker instead of the exchange
+	PaperBalance      float64       // Starting quote balance of each paper account
 }
 
+// defaultDSN is used when TIDB_DSN is not set
+const defaultDSN = "root:@tcp(localhost:4000)/sigforge?charset=utf8mb4&parseTime=True&loc=Local"
+
 func Load() (*Config, error) {
 	_ = godotenv.Load()
 
@@ -23,20 +33,50 @@ func Load() (*Config, error) {
 		KimiKey:           os.Getenv("KIMI_API_KEY"),
 		BinanceKey:        os.Getenv("BINANCE_TEST_KEY"),
 		BinanceSecret:     os.Getenv("BINANCE_TEST_SECRET"),
-		DBDSN:             os.Getenv("TIDB_DSN"),
+		DBDSN:             LoadDSN(),
 		SlackWebhook:      os.Getenv("SLACK_WEBHOOK_URL"),
 		BinanceProduction: false, // Always use testnet for trading operations
+		Exchange:          os.Getenv("EXCHANGE"),
+	}
+	if c.Exchange == "" {
+		c.Exchange = "binance"
+	}
+
+	c.LabelHorizon = time.Hour
+	if horizon := os.Getenv("FWD_RET_HORIZON"); horizon != "" {
+		d, err := time.ParseDuration(horizon)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("invalid FWD_RET_HORIZON %q", horizon)
+		}
+		c.LabelHorizon = d
+	}
+
+	c.PaperTrading = true
+	if paper := os.Getenv("PAPER_TRADING"); paper != "" {
+		enabled, err := strconv.ParseBool(paper)
+		if err != nil {
+			return nil, fmt.Errorf("invalid PAPER_TRADING %q", paper)
+		}
+		c.PaperTrading = enabled
 	}
 
-	// Set defaults
-	if c.DBDSN == "" {
-		c.DBDSN = "root:@tcp(localhost:4000)/sigforge?charset=utf8mb4&parseTime=True&loc=Local"
+	c.PaperBalance = 10000
+	if balance := os.Getenv("PAPER_BALANCE"); balance != "" {
+		b, err := strconv.ParseFloat(balance, 64)
+		if err != nil || b <= 0 {
+			return nil, fmt.Errorf("invalid PAPER_BALANCE %q", balance)
+		}
+		c.PaperBalance = b
 	}
 
 	// Validate required fields
 	if c.KimiKey == "" {
 		return nil, errors.New("KIMI_API_KEY is required")
 	}
+	// Paper trading only reads public market data
+	if c.PaperTrading {
+		return c, nil
+	}
 	if c.BinanceKey == "" {
 		return nil, errors.New("BINANCE_TEST_KEY is required")
 	}
@@ -47,6 +87,16 @@ func Load() (*Config, error) {
 	return c, nil
 }
 
+// LoadDSN returns the TiDB DSN for tools that only need the database and
+// should not require the API keys Load validates
+func LoadDSN() string {
+	_ = godotenv.Load()
+	if dsn := os.Getenv("TIDB_DSN"); dsn != "" {
+		return dsn
+	}
+	return defaultDSN
+}
+
 // IsSlackEnabled returns true if Slack webhook URL is configured
 func (c *Config) IsSlackEnabled() bool {
 	return c.SlackWebhook != ""
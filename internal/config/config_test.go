@@ -3,6 +3,7 @@ package config
 import (
 	"os"
 	"testing"
+	"time"
 )
 
 func TestLoad(t *testing.T) {
@@ -24,6 +25,33 @@ func TestLoad(t *testing.T) {
 	if cfg.DBDSN == "" {
 		t.Fatal("expected DBDSN to have default value")
 	}
+	if cfg.LabelHorizon != time.Hour {
+		t.Fatal("expected LabelHorizon to default to 1h")
+	}
+	if cfg.Exchange != "binance" {
+		t.Fatal("expected Exchange to default to binance")
+	}
+}
+
+func TestLoadLabelHorizon(t *testing.T) {
+	os.Setenv("KIMI_API_KEY", "test-kimi-key")
+	os.Setenv("BINANCE_TEST_KEY", "test-binance-key")
+	os.Setenv("BINANCE_TEST_SECRET", "test-binance-secret")
+	defer os.Unsetenv("FWD_RET_HORIZON")
+
+	os.Setenv("FWD_RET_HORIZON", "15m")
+	cfg, err := Load()
+	if err != nil {
+		t.Fatal("expected no error, got:", err)
+	}
+	if cfg.LabelHorizon != 15*time.Minute {
+		t.Fatalf("expected LabelHorizon 15m, got %v", cfg.LabelHorizon)
+	}
+
+	os.Setenv("FWD_RET_HORIZON", "soon")
+	if _, err := Load(); err == nil {
+		t.Fatal("expected error for invalid FWD_RET_HORIZON")
+	}
 }
 
 func TestLoadMissingRequired(t *testing.T) {
@@ -38,6 +66,37 @@ func TestLoadMissingRequired(t *testing.T) {
 	}
 }
 
+func TestLoadPaperTrading(t *testing.T) {
+	os.Setenv("KIMI_API_KEY", "test-kimi-key")
+	os.Unsetenv("BINANCE_TEST_KEY")
+	os.Unsetenv("BINANCE_TEST_SECRET")
+	defer os.Unsetenv("PAPER_TRADING")
+	defer os.Unsetenv("PAPER_BALANCE")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatal("expected paper trading to need no Binance keys, got:", err)
+	}
+	if !cfg.PaperTrading || cfg.PaperBalance != 10000 {
+		t.Fatalf("expected paper trading with 10000 by default, got %v %v", cfg.PaperTrading, cfg.PaperBalance)
+	}
+
+	os.Setenv("PAPER_BALANCE", "500")
+	if cfg, err = Load(); err != nil || cfg.PaperBalance != 500 {
+		t.Fatalf("expected PAPER_BALANCE 500, got %v, %v", cfg, err)
+	}
+
+	os.Setenv("PAPER_TRADING", "false")
+	if _, err := Load(); err == nil {
+		t.Fatal("expected Binance keys to be required without paper trading")
+	}
+
+	os.Setenv("PAPER_TRADING", "maybe")
+	if _, err := Load(); err == nil {
+		t.Fatal("expected error for invalid PAPER_TRADING")
+	}
+}
+
 func TestIsSlackEnabled(t *testing.T) {
 	// Test with Slack webhook URL set
 	config := &Config{SlackWebhook: "https://hooks.slack.com/test"}
@@ -51,3 +110,17 @@ func TestIsSlackEnabled(t *testing.T) {
 		t.Fatal("expected Slack to be disabled when webhook URL is empty")
 	}
 }
+
+func TestLoadDSN(t *testing.T) {
+	defer os.Unsetenv("TIDB_DSN")
+
+	os.Unsetenv("TIDB_DSN")
+	if LoadDSN() != defaultDSN {
+		t.Fatal("expected default DSN when TIDB_DSN is unset")
+	}
+
+	os.Setenv("TIDB_DSN", "user:pass@tcp(db:4000)/test")
+	if LoadDSN() != "user:pass@tcp(db:4000)/test" {
+		t.Fatal("expected TIDB_DSN to be used")
+	}
+}
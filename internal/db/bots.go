@@ -0,0 +1,220 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/adeilh/agentic_go_signals/internal/risk"
+)
+
+// BotStore handles bot registry persistence in TiDB
+type BotStore struct {
+	db *DB
+}
+
+func NewBotStore(db *DB) *BotStore {
+	return &BotStore{db: db}
+}
+
+// Bot represents the bots table structure
+type Bot struct {
+	ID                 string          `json:"bot_id"`
+	Name               string          `json:"name"`
+	Symbols            []string        `json:"symbols"`
+	Strategy           string          `json:"strategy"`
+	IngestIntervalSec  int             `json:"ingest_interval_sec"`
+	PredictIntervalSec int             `json:"predict_interval_sec"`
+	ExecuteIntervalSec int             `json:"execute_interval_sec"`
+	RiskParams         risk.RiskParams `json:"risk_params"`
+	Enabled            bool            `json:"enabled"`
+	CreatedAt          time.Time       `json:"created_at"`
+	UpdatedAt          time.Time       `json:"updated_at"`
+}
+
+const botColumns = `id, name, symbols, strategy, ingest_interval_sec, predict_interval_sec,
+		execute_interval_sec, risk_params, enabled, created_at, updated_at`
+
+// Create inserts a new bot into the registry
+func (b *BotStore) Create(bot Bot) error {
+	if b.db == nil || b.db.conn == nil {
+		return fmt.Errorf("database connection is nil")
+	}
+
+	symbolsJSON, riskJSON, err := encodeBotJSON(bot)
+	if err != nil {
+		return err
+	}
+
+	query := `INSERT INTO bots (
+		id, name, symbols, strategy, ingest_interval_sec, predict_interval_sec,
+		execute_interval_sec, risk_params, enabled, created_at, updated_at
+	) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, NOW(), NOW())`
+
+	_, err = b.db.conn.Exec(query, bot.ID, bot.Name, symbolsJSON, bot.Strategy, bot.IngestIntervalSec,
+		bot.PredictIntervalSec, bot.ExecuteIntervalSec, riskJSON, bot.Enabled)
+	if err != nil {
+		return fmt.Errorf("failed to insert bot: %w", err)
+	}
+	return nil
+}
+
+// Get retrieves a bot by ID, returning nil if it does not exist
+func (b *BotStore) Get(botID string) (*Bot, error) {
+	if b.db == nil || b.db.conn == nil {
+		return nil, fmt.Errorf("database connection is nil")
+	}
+
+	row := b.db.conn.QueryRow(`SELECT `+botColumns+` FROM bots WHERE id = ?`, botID)
+	bot, err := scanBot(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get bot: %w", err)
+	}
+	return bot, nil
+}
+
+// List retrieves every registered bot
+func (b *BotStore) List() ([]Bot, error) {
+	return b.list(`SELECT ` + botColumns + ` FROM bots ORDER BY id`)
+}
+
+// Update overwrites a bot's configuration, returning false if it does not exist
+func (b *BotStore) Update(bot Bot) (bool, error) {
+	if b.db == nil || b.db.conn == nil {
+		return false, fmt.Errorf("database connection is nil")
+	}
+
+	symbolsJSON, riskJSON, err := encodeBotJSON(bot)
+	if err != nil {
+		return false, err
+	}
+
+	query := `UPDATE bots SET
+		name = ?, symbols = ?, strategy = ?, ingest_interval_sec = ?, predict_interval_sec = ?,
+		execute_interval_sec = ?, risk_params = ?, enabled = ?, updated_at = NOW()
+	WHERE id = ?`
+
+	result, err := b.db.conn.Exec(query, bot.Name, symbolsJSON, bot.Strategy, bot.IngestIntervalSec,
+		bot.PredictIntervalSec, bot.ExecuteIntervalSec, riskJSON, bot.Enabled, bot.ID)
+	if err != nil {
+		return false, fmt.Errorf("failed to update bot: %w", err)
+	}
+
+	affected, _ := result.RowsAffected()
+	return affected > 0, nil
+}
+
+// SetEnabled starts or stops a bot, returning false if it does not exist
+func (b *BotStore) SetEnabled(botID string, enabled bool) (bool, error) {
+	if b.db == nil || b.db.conn == nil {
+		return false, fmt.Errorf("database connection is nil")
+	}
+
+	result, err := b.db.conn.Exec(`UPDATE bots SET enabled = ?, updated_at = NOW() WHERE id = ?`, enabled, botID)
+	if err != nil {
+		return false, fmt.Errorf("failed to update bot: %w", err)
+	}
+
+	affected, _ := result.RowsAffected()
+	return affected > 0, nil
+}
+
+// DisableAll stops every enabled bot and returns how many were stopped
+func (b *BotStore) DisableAll() (int64, error) {
+	if b.db == nil || b.db.conn == nil {
+		return 0, fmt.Errorf("database connection is nil")
+	}
+
+	result, err := b.db.conn.Exec(`UPDATE bots SET enabled = FALSE, updated_at = NOW() WHERE enabled = TRUE`)
+	if err != nil {
+		return 0, fmt.Errorf("failed to disable bots: %w", err)
+	}
+	return result.RowsAffected()
+}
+
+// Delete removes a bot from the registry, returning false if it does not exist.
+// Its events, predictions and trades are kept for history.
+func (b *BotStore) Delete(botID string) (bool, error) {
+	if b.db == nil || b.db.conn == nil {
+		return false, fmt.Errorf("database connection is nil")
+	}
+
+	result, err := b.db.conn.Exec(`DELETE FROM bots WHERE id = ?`, botID)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete bot: %w", err)
+	}
+
+	affected, _ := result.RowsAffected()
+	return affected > 0, nil
+}
+
+// ListEnabled retrieves every bot that should currently be trading
+func (b *BotStore) ListEnabled() ([]Bot, error) {
+	return b.list(`SELECT ` + botColumns + ` FROM bots WHERE enabled = TRUE ORDER BY id`)
+}
+
+func (b *BotStore) list(query string) ([]Bot, error) {
+	if b.db == nil || b.db.conn == nil {
+		return nil, fmt.Errorf("database connection is nil")
+	}
+
+	rows, err := b.db.conn.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query bots: %w", err)
+	}
+	defer rows.Close()
+
+	var bots []Bot
+	for rows.Next() {
+		bot, err := scanBot(rows)
+		if err != nil {
+			return nil, err
+		}
+		bots = append(bots, *bot)
+	}
+	return bots, rows.Err()
+}
+
+// encodeBotJSON encodes the JSON columns of a bot
+func encodeBotJSON(bot Bot) ([]byte, []byte, error) {
+	symbolsJSON, err := json.Marshal(bot.Symbols)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to marshal symbols: %w", err)
+	}
+
+	riskJSON, err := json.Marshal(bot.RiskParams)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to marshal risk params: %w", err)
+	}
+
+	return symbolsJSON, riskJSON, nil
+}
+
+// scanBot decodes a bots row including its JSON columns
+func scanBot(row interface{ Scan(...interface{}) error }) (*Bot, error) {
+	var bot Bot
+	var name *string
+	var symbolsJSON, riskJSON []byte
+
+	err := row.Scan(&bot.ID, &name, &symbolsJSON, &bot.Strategy, &bot.IngestIntervalSec, &bot.PredictIntervalSec,
+		&bot.ExecuteIntervalSec, &riskJSON, &bot.Enabled, &bot.CreatedAt, &bot.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if name != nil {
+		bot.Name = *name
+	}
+	if err := json.Unmarshal(symbolsJSON, &bot.Symbols); err != nil {
+		return nil, fmt.Errorf("failed to decode symbols for bot %s: %w", bot.ID, err)
+	}
+	if err := json.Unmarshal(riskJSON, &bot.RiskParams); err != nil {
+		return nil, fmt.Errorf("failed to decode risk params for bot %s: %w", bot.ID, err)
+	}
+
+	return &bot, nil
+}
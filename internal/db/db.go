@@ -45,14 +45,17 @@ type Prediction struct {
 }
 
 type Trade struct {
-	ID     uint      `json:"id"`
-	BotID  string    `json:"bot_id"`
-	Ts     time.Time `json:"ts"`
-	Symbol string    `json:"symbol"`
-	Side   string    `json:"side"`
-	Qty    float64   `json:"qty"`
-	Price  float64   `json:"price"`
-	Status string    `json:"status"`
+	ID      uint      `json:"id"`
+	BotID   string    `json:"bot_id"`
+	Ts      time.Time `json:"ts"`
+	Symbol  string    `json:"symbol"`
+	Side    string    `json:"side"`
+	Qty     float64   `json:"qty"`
+	Price   float64   `json:"price"`
+	Status  string    `json:"status"`
+	OrderID string    `json:"order_id,omitempty"` // Exchange order ID, empty for simulated trades
+	Fee     float64   `json:"fee"`                // Commission in the quote asset
+	BaseFee float64   `json:"base_fee"`           // Part of Fee taken in the base asset, in base units
 }
 
 func Open(dsn string) (*DB, error) {
@@ -150,9 +153,64 @@ func AutoMigrate(db *DB) error {
 			price DOUBLE NOT NULL,
 			status VARCHAR(16) NOT NULL,
 			order_id VARCHAR(32),
+			fee DOUBLE NOT NULL DEFAULT 0,
+			base_fee DOUBLE NOT NULL DEFAULT 0,
 			PRIMARY KEY (bot_id, id)
 		)`,
 
+		// Bot registry
+		`CREATE TABLE IF NOT EXISTS bots (
+			id VARCHAR(32) NOT NULL,
+			name VARCHAR(64),
+			symbols JSON NOT NULL,
+			strategy VARCHAR(32) NOT NULL DEFAULT 'kimi',
+			ingest_interval_sec INT NOT NULL DEFAULT 300,
+			predict_interval_sec INT NOT NULL DEFAULT 600,
+			execute_interval_sec INT NOT NULL DEFAULT 60,
+			risk_params JSON NOT NULL,
+			enabled BOOLEAN NOT NULL DEFAULT TRUE,
+			created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+			PRIMARY KEY (id)
+		)`,
+
+		// Circuit breaker state per bot
+		`CREATE TABLE IF NOT EXISTS risk_guards (
+			bot_id VARCHAR(32) NOT NULL,
+			since DATETIME NOT NULL,
+			day DATETIME NOT NULL,
+			day_start_equity DOUBLE NOT NULL,
+			peak_equity DOUBLE NOT NULL,
+			tripped_at DATETIME,
+			reason VARCHAR(255),
+			updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+			PRIMARY KEY (bot_id)
+		)`,
+
+		// Pre-trade check decisions on every order
+		`CREATE TABLE IF NOT EXISTS order_audit (
+			id BIGINT AUTO_INCREMENT,
+			bot_id VARCHAR(32) NOT NULL,
+			ts DATETIME NOT NULL,
+			symbol VARCHAR(16) NOT NULL,
+			side VARCHAR(8) NOT NULL,
+			order_type VARCHAR(24) NOT NULL DEFAULT 'MARKET',
+			qty DOUBLE NOT NULL,
+			price DOUBLE NOT NULL,
+			stop_price DOUBLE NOT NULL DEFAULT 0,
+			notional DOUBLE NOT NULL,
+			reduce_only BOOLEAN NOT NULL DEFAULT FALSE,
+			accepted BOOLEAN NOT NULL,
+			check_name VARCHAR(32),
+			reason VARCHAR(255),
+			PRIMARY KEY (bot_id, id),
+			KEY idx_ts (ts)
+		)`,
+
+		// Columns added after the trades table was first released
+		`ALTER TABLE trades ADD COLUMN IF NOT EXISTS fee DOUBLE NOT NULL DEFAULT 0 AFTER order_id`,
+		`ALTER TABLE trades ADD COLUMN IF NOT EXISTS base_fee DOUBLE NOT NULL DEFAULT 0 AFTER fee`,
+
 		// Market data tables for TiDB storage and decision making
 
 		// Real-time price data with TTL for space efficiency
@@ -2,6 +2,7 @@ package db
 
 import (
 	"testing"
+	"time"
 )
 
 func TestOpen(t *testing.T) {
@@ -21,3 +22,85 @@ func TestAutoMigrate(t *testing.T) {
 	}
 	t.Log("AutoMigrate properly failed with nil connection:", err)
 }
+
+func TestBotStoreNilConnection(t *testing.T) {
+	store := NewBotStore(&DB{conn: nil})
+	if _, err := store.ListEnabled(); err == nil {
+		t.Fatal("expected error for nil connection")
+	}
+	if err := store.Create(Bot{ID: "bot_test"}); err == nil {
+		t.Fatal("expected error creating bot with nil connection")
+	}
+	if _, err := store.Get("bot_test"); err == nil {
+		t.Fatal("expected error getting bot with nil connection")
+	}
+}
+
+func TestQueryFilterWhere(t *testing.T) {
+	where, args := QueryFilter{}.Where("ts")
+	if where != "" || len(args) != 0 {
+		t.Fatalf("expected empty clause, got %q with %d args", where, len(args))
+	}
+
+	filter := QueryFilter{
+		BotID:  "bot_test",
+		Symbol: "BTCUSDT",
+		From:   time.Unix(1700000000, 0),
+	}
+	where, args = filter.Where("ts", "dir <> 'FLAT'")
+
+	expected := "WHERE bot_id = ? AND symbol = ? AND ts >= ? AND dir <> 'FLAT'"
+	if where != expected {
+		t.Errorf("expected %q, got %q", expected, where)
+	}
+	if len(args) != 3 {
+		t.Errorf("expected 3 args, got %d", len(args))
+	}
+
+	limit, offset := QueryFilter{Offset: -5}.Page(50)
+	if limit != 50 || offset != 0 {
+		t.Errorf("expected default page 50/0, got %d/%d", limit, offset)
+	}
+}
+
+func TestRollupWindow(t *testing.T) {
+	from := time.Date(2024, 1, 1, 10, 7, 0, 0, time.UTC)
+
+	step, start, end, err := rollupWindow("1h", from, from.Add(2*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if step != time.Hour || !start.Equal(from.Truncate(time.Hour)) || !end.Equal(time.Date(2024, 1, 1, 13, 0, 0, 0, time.UTC)) {
+		t.Fatalf("expected whole hour buckets, got %v %v %v", step, start, end)
+	}
+
+	// 4h and 1d buckets align on UTC midnight, like the exchange's
+	if _, start, end, _ := rollupWindow("1d", from, from.Add(time.Minute)); !start.Equal(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)) || !end.Equal(start.Add(24*time.Hour)) {
+		t.Fatalf("unexpected day bucket %v %v", start, end)
+	}
+	if _, start, _, _ := rollupWindow("4h", from, from.Add(time.Minute)); !start.Equal(time.Date(2024, 1, 1, 8, 0, 0, 0, time.UTC)) {
+		t.Fatalf("unexpected 4h bucket start %v", start)
+	}
+
+	for _, interval := range []string{"1m", "1s", "3d", "1w", "1M"} {
+		if _, _, _, err := rollupWindow(interval, from, from.Add(time.Hour)); err == nil {
+			t.Errorf("expected %s not to be rolled up", interval)
+		}
+	}
+}
+
+func TestAggregatedIntervals(t *testing.T) {
+	for interval, want := range map[string]bool{"1m": false, "5m": false, "1d": false, "30m": true, "2h": true, "1w": false} {
+		if got := aggregated(interval); got != want {
+			t.Errorf("aggregated(%q) = %t, want %t", interval, got, want)
+		}
+	}
+
+	store := NewMarketDataStore(&DB{conn: nil})
+	if _, err := store.MaterializeKlines("BTCUSDT", "1h", time.Now().Add(-time.Hour), time.Now()); err == nil {
+		t.Fatal("expected error for nil connection")
+	}
+	if _, err := store.GetKlineData("BTCUSDT", "30m", 10); err == nil {
+		t.Fatal("expected error for nil connection")
+	}
+}
@@ -0,0 +1,59 @@
+package db
+
+import (
+	"strings"
+	"time"
+)
+
+// QueryFilter narrows bot-scoped history queries such as predictions and trades
+type QueryFilter struct {
+	BotID  string
+	Symbol string
+	From   time.Time
+	To     time.Time
+	Limit  int
+	Offset int
+}
+
+// Where builds the WHERE clause and arguments for the filter's bot, symbol
+// and time range conditions against the given timestamp column
+func (f QueryFilter) Where(tsColumn string, extra ...string) (string, []interface{}) {
+	var conditions []string
+	var args []interface{}
+
+	if f.BotID != "" {
+		conditions = append(conditions, "bot_id = ?")
+		args = append(args, f.BotID)
+	}
+	if f.Symbol != "" {
+		conditions = append(conditions, "symbol = ?")
+		args = append(args, f.Symbol)
+	}
+	if !f.From.IsZero() {
+		conditions = append(conditions, tsColumn+" >= ?")
+		args = append(args, f.From)
+	}
+	if !f.To.IsZero() {
+		conditions = append(conditions, tsColumn+" <= ?")
+		args = append(args, f.To)
+	}
+	conditions = append(conditions, extra...)
+
+	if len(conditions) == 0 {
+		return "", args
+	}
+	return "WHERE " + strings.Join(conditions, " AND "), args
+}
+
+// Page returns the LIMIT/OFFSET clause arguments, applying a default limit
+func (f QueryFilter) Page(defaultLimit int) (int, int) {
+	limit := f.Limit
+	if limit <= 0 {
+		limit = defaultLimit
+	}
+	offset := f.Offset
+	if offset < 0 {
+		offset = 0
+	}
+	return limit, offset
+}
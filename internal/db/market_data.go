@@ -4,6 +4,7 @@ import (
 	"database/sql"
 	"encoding/json"
 	"fmt"
+	"strings"
 	"time"
 )
 
@@ -85,6 +86,69 @@ type MarketSummary struct {
 	Timestamp       time.Time `json:"timestamp"`
 }
 
+// AdvancedSignals holds the window-function analytics for a symbol. Raw
+// readings are nil when the query returned NULL (e.g. no prices in the
+// window); derived indicators are nil when their inputs were unavailable.
+type AdvancedSignals struct {
+	Symbol          string    `json:"symbol"`
+	CurrentPrice    *float64  `json:"current_price"`
+	PreviousPrice   *float64  `json:"previous_price"`
+	Price5MinAgo    *float64  `json:"price_5min_ago"`
+	Price15MinAgo   *float64  `json:"price_15min_ago"`
+	SMA10           *float64  `json:"sma_10"`
+	SMA20           *float64  `json:"sma_20"`
+	Volatility      *float64  `json:"volatility"`
+	BuyVolume       *float64  `json:"buy_volume"`
+	SellVolume      *float64  `json:"sell_volume"`
+	TradeFrequency  int64     `json:"trade_frequency"`
+	AvgTradePrice   *float64  `json:"avg_trade_price"`
+	SupportLevel    *float64  `json:"support_level"`
+	ResistanceLevel *float64  `json:"resistance_level"`
+	MidPoint        *float64  `json:"mid_point"`
+	Timestamp       time.Time `json:"timestamp"`
+
+	PriceVsSMA10       *float64 `json:"price_vs_sma10,omitempty"`
+	PriceVsSMA20       *float64 `json:"price_vs_sma20,omitempty"`
+	SMACross           *bool    `json:"sma_cross,omitempty"` // SMA10 above SMA20
+	VolumeRatio        *float64 `json:"volume_ratio,omitempty"`
+	SupportDistance    *float64 `json:"support_distance,omitempty"`
+	ResistanceDistance *float64 `json:"resistance_distance,omitempty"`
+	Momentum1Min       *float64 `json:"momentum_1min,omitempty"`
+	Momentum5Min       *float64 `json:"momentum_5min,omitempty"`
+	Momentum15Min      *float64 `json:"momentum_15min,omitempty"`
+}
+
+// RealTimeState holds the short-window price, volume and order flow readings
+// for a symbol. Derived indicators are nil when their inputs were unavailable.
+type RealTimeState struct {
+	Symbol          string    `json:"symbol"`
+	LatestPrice     *float64  `json:"latest_price"`
+	PreviousPrice   *float64  `json:"previous_price"`
+	VolumeLast5Min  float64   `json:"volume_last_5min"`
+	VolumePrev5Min  float64   `json:"volume_prev_5min"`
+	RecentBuys      int64     `json:"recent_buys"`
+	RecentSells     int64     `json:"recent_sells"`
+	Volatility15Min *float64  `json:"volatility_15min"`
+	Volatility1Hour *float64  `json:"volatility_1hour"`
+	Timestamp       time.Time `json:"timestamp"`
+
+	PriceMomentum   *float64 `json:"price_momentum,omitempty"`
+	VolumeSurge     *float64 `json:"volume_surge,omitempty"`
+	BuyPressure     *float64 `json:"buy_pressure,omitempty"`
+	VolatilitySpike *float64 `json:"volatility_spike,omitempty"`
+}
+
+// TradingVolume holds trade volume metrics over a lookback window.
+// VolumeRatio is the buy share of volume, nil when there were no trades.
+type TradingVolume struct {
+	TradeCount  int64    `json:"trade_count"`
+	TotalVolume float64  `json:"total_volume"`
+	AvgPrice    *float64 `json:"avg_price"`
+	BuyVolume   float64  `json:"buy_volume"`
+	SellVolume  float64  `json:"sell_volume"`
+	VolumeRatio *float64 `json:"volume_ratio,omitempty"`
+}
+
 // StorePrice stores real-time price data
 func (m *MarketDataStore) StorePrice(price MarketPrice) error {
 	query := `INSERT INTO market_prices (
@@ -149,6 +213,78 @@ func (m *MarketDataStore) StoreKline(kline MarketKline) error {
 	return err
 }
 
+// klineBatchSize bounds the rows of one StoreKlines insert statement
+const klineBatchSize = 500
+
+// StoreKlines upserts klines in batches. Rows are keyed by unique_kline, so
+// storing the same klines again only overwrites them.
+func (m *MarketDataStore) StoreKlines(klines []MarketKline) error {
+	if m.db == nil || m.db.conn == nil {
+		return fmt.Errorf("database connection is nil")
+	}
+
+	for start := 0; start < len(klines); start += klineBatchSize {
+		batch := klines[start:min(start+klineBatchSize, len(klines))]
+
+		placeholders := make([]string, len(batch))
+		args := make([]interface{}, 0, len(batch)*13)
+		for i, k := range batch {
+			placeholders[i] = "(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)"
+			args = append(args, k.Symbol, k.IntervalType, k.OpenPrice, k.HighPrice,
+				k.LowPrice, k.ClosePrice, k.Volume, k.QuoteVolume,
+				k.OpenTime, k.CloseTime, k.IsClosed, k.TradeCount, k.Timestamp)
+		}
+
+		query := `INSERT INTO market_klines (
+			symbol, interval_type, open_price, high_price, low_price, close_price,
+			volume, quote_volume, open_time, close_time, is_closed, trade_count, ts
+		) VALUES ` + strings.Join(placeholders, ", ") + `
+		ON DUPLICATE KEY UPDATE
+			open_price = VALUES(open_price),
+			high_price = VALUES(high_price),
+			low_price = VALUES(low_price),
+			close_price = VALUES(close_price),
+			volume = VALUES(volume),
+			quote_volume = VALUES(quote_volume),
+			close_time = VALUES(close_time),
+			is_closed = VALUES(is_closed),
+			trade_count = VALUES(trade_count),
+			ts = VALUES(ts)`
+		if _, err := m.db.conn.Exec(query, args...); err != nil {
+			return fmt.Errorf("failed to store klines: %w", err)
+		}
+	}
+	return nil
+}
+
+// GetKlineOpenTimes returns the open times of the stored klines with
+// open_time in [from, to), oldest first
+func (m *MarketDataStore) GetKlineOpenTimes(symbol, interval string, from, to time.Time) ([]time.Time, error) {
+	if m.db == nil || m.db.conn == nil {
+		return nil, fmt.Errorf("database connection is nil")
+	}
+
+	query := `SELECT open_time FROM market_klines
+	WHERE symbol = ? AND interval_type = ? AND open_time >= ? AND open_time < ?
+	ORDER BY open_time ASC`
+
+	rows, err := m.db.conn.Query(query, symbol, interval, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query kline open times: %w", err)
+	}
+	defer rows.Close()
+
+	var times []time.Time
+	for rows.Next() {
+		var t time.Time
+		if err := rows.Scan(&t); err != nil {
+			return nil, fmt.Errorf("failed to scan kline open time: %w", err)
+		}
+		times = append(times, t)
+	}
+	return times, rows.Err()
+}
+
 // StoreSummary stores market summary and analysis
 func (m *MarketDataStore) StoreSummary(summary MarketSummary) error {
 	query := `INSERT INTO market_summary (
@@ -271,8 +407,48 @@ func (m *MarketDataStore) GetRecentTrades(symbol string, limit int) ([]MarketTra
 	return trades, nil
 }
 
-// GetKlineData retrieves candlestick data for technical analysis
+// GetLatestOrderBook retrieves the most recent order book snapshot, or nil if none is stored
+func (m *MarketDataStore) GetLatestOrderBook(symbol string) (*MarketOrderBook, error) {
+	if m.db == nil || m.db.conn == nil {
+		return nil, fmt.Errorf("database connection is nil")
+	}
+
+	query := `SELECT id, symbol, bids, asks, depth_level, ts
+	FROM market_orderbook
+	WHERE symbol = ?
+	ORDER BY ts DESC
+	LIMIT 1`
+
+	var ob MarketOrderBook
+	var bidsJSON, asksJSON []byte
+	err := m.db.conn.QueryRow(query, symbol).Scan(&ob.ID, &ob.Symbol, &bidsJSON, &asksJSON, &ob.DepthLevel, &ob.Timestamp)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query order book: %w", err)
+	}
+
+	if err := json.Unmarshal(bidsJSON, &ob.Bids); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal bids: %w", err)
+	}
+	if err := json.Unmarshal(asksJSON, &ob.Asks); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal asks: %w", err)
+	}
+	return &ob, nil
+}
+
+// GetKlineData retrieves candlestick data for technical analysis, newest
+// first. Intervals that are neither stored nor materialized are aggregated
+// from the base klines.
 func (m *MarketDataStore) GetKlineData(symbol, interval string, limit int) ([]MarketKline, error) {
+	if m.db == nil || m.db.conn == nil {
+		return nil, fmt.Errorf("database connection is nil")
+	}
+	if aggregated(interval) {
+		return m.recentAggregatedKlines(symbol, interval, limit)
+	}
+
 	query := `SELECT symbol, interval_type, open_price, high_price, low_price, close_price,
 		volume, quote_volume, open_time, close_time, is_closed, trade_count, ts
 	FROM market_klines 
@@ -300,6 +476,53 @@ func (m *MarketDataStore) GetKlineData(symbol, interval string, limit int) ([]Ma
 	return klines, nil
 }
 
+// GetKlineRange returns klines with open_time in [from, to), oldest first.
+// Like GetKlineData it aggregates intervals that are not stored.
+func (m *MarketDataStore) GetKlineRange(symbol, interval string, from, to time.Time) ([]MarketKline, error) {
+	if m.db == nil || m.db.conn == nil {
+		return nil, fmt.Errorf("database connection is nil")
+	}
+	if aggregated(interval) {
+		klines, err := m.aggregateKlines(symbol, interval, from, to)
+		if err != nil {
+			return nil, err
+		}
+		// The aggregation covers whole buckets, so trim to the range
+		var inRange []MarketKline
+		for _, k := range klines {
+			if !k.OpenTime.Before(from) && k.OpenTime.Before(to) {
+				inRange = append(inRange, k)
+			}
+		}
+		return inRange, nil
+	}
+
+	query := `SELECT symbol, interval_type, open_price, high_price, low_price, close_price,
+		volume, quote_volume, open_time, close_time, is_closed, trade_count, ts
+	FROM market_klines
+	WHERE symbol = ? AND interval_type = ? AND open_time >= ? AND open_time < ?
+	ORDER BY open_time ASC`
+
+	rows, err := m.db.conn.Query(query, symbol, interval, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query klines: %w", err)
+	}
+	defer rows.Close()
+
+	var klines []MarketKline
+	for rows.Next() {
+		var k MarketKline
+		err := rows.Scan(&k.Symbol, &k.IntervalType, &k.OpenPrice, &k.HighPrice,
+			&k.LowPrice, &k.ClosePrice, &k.Volume, &k.QuoteVolume, &k.OpenTime,
+			&k.CloseTime, &k.IsClosed, &k.TradeCount, &k.Timestamp)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan kline: %w", err)
+		}
+		klines = append(klines, k)
+	}
+	return klines, rows.Err()
+}
+
 // GetMarketSummary retrieves latest market analysis
 func (m *MarketDataStore) GetMarketSummary(symbol string) (*MarketSummary, error) {
 	query := `SELECT symbol, avg_price, volume_24h, price_trend, volatility,
@@ -324,36 +547,32 @@ func (m *MarketDataStore) GetMarketSummary(symbol string) (*MarketSummary, error
 }
 
 // GetTradingVolume calculates volume metrics for decision making
-func (m *MarketDataStore) GetTradingVolume(symbol string, hours int) (map[string]float64, error) {
+func (m *MarketDataStore) GetTradingVolume(symbol string, hours int) (*TradingVolume, error) {
 	query := `SELECT 
 		COUNT(*) as trade_count,
-		SUM(quantity) as total_volume,
+		COALESCE(SUM(quantity), 0) as total_volume,
 		AVG(price) as avg_price,
-		SUM(CASE WHEN is_buyer_maker = 0 THEN quantity ELSE 0 END) as buy_volume,
-		SUM(CASE WHEN is_buyer_maker = 1 THEN quantity ELSE 0 END) as sell_volume
+		COALESCE(SUM(CASE WHEN is_buyer_maker = 0 THEN quantity ELSE 0 END), 0) as buy_volume,
+		COALESCE(SUM(CASE WHEN is_buyer_maker = 1 THEN quantity ELSE 0 END), 0) as sell_volume
 	FROM market_trades 
 	WHERE symbol = ? AND trade_time >= DATE_SUB(NOW(), INTERVAL ? HOUR)`
 
-	var tradeCount, totalVolume, avgPrice, buyVolume, sellVolume sql.NullFloat64
+	var v TradingVolume
 	err := m.db.conn.QueryRow(query, symbol, hours).Scan(
-		&tradeCount, &totalVolume, &avgPrice, &buyVolume, &sellVolume,
+		&v.TradeCount, &v.TotalVolume, &v.AvgPrice, &v.BuyVolume, &v.SellVolume,
 	)
 	if err != nil {
 		return nil, err
 	}
 
-	return map[string]float64{
-		"trade_count":  tradeCount.Float64,
-		"total_volume": totalVolume.Float64,
-		"avg_price":    avgPrice.Float64,
-		"buy_volume":   buyVolume.Float64,
-		"sell_volume":  sellVolume.Float64,
-		"volume_ratio": buyVolume.Float64 / (buyVolume.Float64 + sellVolume.Float64),
-	}, nil
+	if v.BuyVolume+v.SellVolume > 0 {
+		v.VolumeRatio = ratio(v.BuyVolume, v.BuyVolume+v.SellVolume)
+	}
+	return &v, nil
 }
 
 // GetAdvancedSignals uses TiDB's analytical capabilities for sophisticated trading signals
-func (m *MarketDataStore) GetAdvancedSignals(symbol string) (map[string]interface{}, error) {
+func (m *MarketDataStore) GetAdvancedSignals(symbol string) (*AdvancedSignals, error) {
 	// TiDB Time-Series Analysis with Window Functions
 	query := `
 	WITH price_analysis AS (
@@ -406,66 +625,53 @@ func (m *MarketDataStore) GetAdvancedSignals(symbol string) (map[string]interfac
 	FROM price_analysis p, volume_analysis v, support_resistance sr
 	WHERE p.rn = 1`
 
-	var currentPrice, prevPrice, price5min, price15min, sma10, sma20, volatility sql.NullFloat64
-	var buyVol, sellVol, tradeFreq, avgTradePrice, support, resistance, midPoint sql.NullFloat64
-
+	r := AdvancedSignals{Symbol: symbol, Timestamp: time.Now()}
+	var tradeFreq sql.NullInt64
 	err := m.db.conn.QueryRow(query, symbol, symbol, symbol).Scan(
-		&currentPrice, &prevPrice, &price5min, &price15min, &sma10, &sma20, &volatility,
-		&buyVol, &sellVol, &tradeFreq, &avgTradePrice, &support, &resistance, &midPoint,
+		&r.CurrentPrice, &r.PreviousPrice, &r.Price5MinAgo, &r.Price15MinAgo, &r.SMA10, &r.SMA20, &r.Volatility,
+		&r.BuyVolume, &r.SellVolume, &tradeFreq, &r.AvgTradePrice, &r.SupportLevel, &r.ResistanceLevel, &r.MidPoint,
 	)
 	if err != nil {
 		return nil, err
 	}
-
-	// Calculate advanced indicators
-	result := map[string]interface{}{
-		"current_price":    currentPrice.Float64,
-		"previous_price":   prevPrice.Float64,
-		"price_5min_ago":   price5min.Float64,
-		"price_15min_ago":  price15min.Float64,
-		"sma_10":           sma10.Float64,
-		"sma_20":           sma20.Float64,
-		"volatility":       volatility.Float64,
-		"buy_volume":       buyVol.Float64,
-		"sell_volume":      sellVol.Float64,
-		"trade_frequency":  tradeFreq.Float64,
-		"avg_trade_price":  avgTradePrice.Float64,
-		"support_level":    support.Float64,
-		"resistance_level": resistance.Float64,
-		"mid_point":        midPoint.Float64,
-	}
+	r.TradeFrequency = tradeFreq.Int64
 
 	// Calculate derived signals
-	if currentPrice.Float64 > 0 && sma10.Float64 > 0 && sma20.Float64 > 0 {
-		result["price_vs_sma10"] = (currentPrice.Float64 - sma10.Float64) / sma10.Float64 * 100
-		result["price_vs_sma20"] = (currentPrice.Float64 - sma20.Float64) / sma20.Float64 * 100
-		result["sma_cross"] = sma10.Float64 > sma20.Float64 // Golden cross indicator
+	current := value(r.CurrentPrice)
+	sma10, sma20 := value(r.SMA10), value(r.SMA20)
+	if current > 0 && sma10 > 0 && sma20 > 0 {
+		r.PriceVsSMA10 = percentChange(sma10, current)
+		r.PriceVsSMA20 = percentChange(sma20, current)
+		cross := sma10 > sma20 // Golden cross indicator
+		r.SMACross = &cross
 	}
 
-	if buyVol.Float64+sellVol.Float64 > 0 {
-		result["volume_ratio"] = buyVol.Float64 / (buyVol.Float64 + sellVol.Float64)
+	buyVol, sellVol := value(r.BuyVolume), value(r.SellVolume)
+	if buyVol+sellVol > 0 {
+		r.VolumeRatio = ratio(buyVol, buyVol+sellVol)
 	}
 
-	if currentPrice.Float64 > 0 && support.Float64 > 0 && resistance.Float64 > 0 {
-		result["support_distance"] = (currentPrice.Float64 - support.Float64) / support.Float64 * 100
-		result["resistance_distance"] = (resistance.Float64 - currentPrice.Float64) / currentPrice.Float64 * 100
+	support, resistance := value(r.SupportLevel), value(r.ResistanceLevel)
+	if current > 0 && support > 0 && resistance > 0 {
+		r.SupportDistance = percentChange(support, current)
+		r.ResistanceDistance = percentChange(current, resistance)
 	}
 
-	if prevPrice.Float64 > 0 {
-		result["momentum_1min"] = (currentPrice.Float64 - prevPrice.Float64) / prevPrice.Float64 * 100
+	if prev := value(r.PreviousPrice); prev > 0 {
+		r.Momentum1Min = percentChange(prev, current)
 	}
-	if price5min.Float64 > 0 {
-		result["momentum_5min"] = (currentPrice.Float64 - price5min.Float64) / price5min.Float64 * 100
+	if prev := value(r.Price5MinAgo); prev > 0 {
+		r.Momentum5Min = percentChange(prev, current)
 	}
-	if price15min.Float64 > 0 {
-		result["momentum_15min"] = (currentPrice.Float64 - price15min.Float64) / price15min.Float64 * 100
+	if prev := value(r.Price15MinAgo); prev > 0 {
+		r.Momentum15Min = percentChange(prev, current)
 	}
 
-	return result, nil
+	return &r, nil
 }
 
 // GetRealTimeMarketState uses TiDB's real-time capabilities for instant analysis
-func (m *MarketDataStore) GetRealTimeMarketState(symbol string) (map[string]interface{}, error) {
+func (m *MarketDataStore) GetRealTimeMarketState(symbol string) (*RealTimeState, error) {
 	// TiDB Real-time aggregation with TIFLASH for OLAP queries
 	query := `
 	SELECT 
@@ -493,44 +699,50 @@ func (m *MarketDataStore) GetRealTimeMarketState(symbol string) (map[string]inte
 		 WHERE symbol = ? AND ts >= DATE_SUB(NOW(), INTERVAL 1 HOUR)) as volatility_1hour
 	`
 
-	var latestPrice, prevPrice, vol5min, volPrev5min sql.NullFloat64
-	var recentBuys, recentSells sql.NullInt64
-	var vol15min, vol1hour sql.NullFloat64
-
+	r := RealTimeState{Symbol: symbol, Timestamp: time.Now()}
 	err := m.db.conn.QueryRow(query, symbol, symbol, symbol, symbol, symbol, symbol, symbol, symbol).Scan(
-		&latestPrice, &prevPrice, &vol5min, &volPrev5min, &recentBuys, &recentSells, &vol15min, &vol1hour,
+		&r.LatestPrice, &r.PreviousPrice, &r.VolumeLast5Min, &r.VolumePrev5Min,
+		&r.RecentBuys, &r.RecentSells, &r.Volatility15Min, &r.Volatility1Hour,
 	)
 	if err != nil {
 		return nil, err
 	}
 
-	result := map[string]interface{}{
-		"latest_price":     latestPrice.Float64,
-		"previous_price":   prevPrice.Float64,
-		"volume_last_5min": vol5min.Float64,
-		"volume_prev_5min": volPrev5min.Float64,
-		"recent_buys":      recentBuys.Int64,
-		"recent_sells":     recentSells.Int64,
-		"volatility_15min": vol15min.Float64,
-		"volatility_1hour": vol1hour.Float64,
+	// Calculate real-time signals
+	if prev := value(r.PreviousPrice); prev > 0 {
+		r.PriceMomentum = percentChange(prev, value(r.LatestPrice))
 	}
 
-	// Calculate real-time signals
-	if prevPrice.Float64 > 0 {
-		result["price_momentum"] = (latestPrice.Float64 - prevPrice.Float64) / prevPrice.Float64 * 100
+	if r.VolumePrev5Min > 0 {
+		r.VolumeSurge = ratio(r.VolumeLast5Min, r.VolumePrev5Min)
 	}
 
-	if volPrev5min.Float64 > 0 {
-		result["volume_surge"] = vol5min.Float64 / volPrev5min.Float64
+	if trades := r.RecentBuys + r.RecentSells; trades > 0 {
+		r.BuyPressure = ratio(float64(r.RecentBuys), float64(trades))
 	}
 
-	if recentBuys.Int64+recentSells.Int64 > 0 {
-		result["buy_pressure"] = float64(recentBuys.Int64) / float64(recentBuys.Int64+recentSells.Int64)
+	if vol1hour := value(r.Volatility1Hour); vol1hour > 0 {
+		r.VolatilitySpike = ratio(value(r.Volatility15Min), vol1hour)
 	}
 
-	if vol1hour.Float64 > 0 {
-		result["volatility_spike"] = vol15min.Float64 / vol1hour.Float64
+	return &r, nil
+}
+
+// value dereferences a nullable reading, treating NULL as zero
+func value(f *float64) float64 {
+	if f == nil {
+		return 0
 	}
+	return *f
+}
+
+// percentChange returns the change from base to current in percent
+func percentChange(base, current float64) *float64 {
+	change := (current - base) / base * 100
+	return &change
+}
 
-	return result, nil
+func ratio(numerator, denominator float64) *float64 {
+	r := numerator / denominator
+	return &r
 }
@@ -0,0 +1,101 @@
+package db
+
+import (
+	"fmt"
+	"time"
+)
+
+// OrderAuditStore records the pre-trade checks' decision on every order
+type OrderAuditStore struct {
+	db *DB
+}
+
+func NewOrderAuditStore(db *DB) *OrderAuditStore {
+	return &OrderAuditStore{db: db}
+}
+
+// OrderAudit is one order as the pre-trade checks saw it
+type OrderAudit struct {
+	ID        uint      `json:"id"`
+	BotID     string    `json:"bot_id"`
+	Ts        time.Time `json:"ts"`
+	Symbol    string    `json:"symbol"`
+	Side      string    `json:"side"`
+	Type      string    `json:"type"` // MARKET, LIMIT, STOP_LOSS_LIMIT, TAKE_PROFIT_LIMIT or OCO
+	Qty       float64   `json:"qty"`
+	Price     float64   `json:"price"`
+	StopPrice float64   `json:"stop_price,omitempty"`
+	Notional  float64   `json:"notional"`
+	Reduce    bool      `json:"reduce_only"` // Order only closed part or all of a position
+	Accepted  bool      `json:"accepted"`
+	Check     string    `json:"check,omitempty"`  // Check that rejected the order
+	Reason    string    `json:"reason,omitempty"` // Why it was rejected
+}
+
+const orderAuditColumns = `id, bot_id, ts, symbol, side, order_type, qty, price, stop_price, notional, reduce_only, accepted,
+		COALESCE(check_name, ''), COALESCE(reason, '')`
+
+// Record inserts an audit entry at its Ts
+func (o *OrderAuditStore) Record(entry OrderAudit) error {
+	if o.db == nil || o.db.conn == nil {
+		return fmt.Errorf("database connection is nil")
+	}
+
+	query := `INSERT INTO order_audit (bot_id, ts, symbol, side, order_type, qty, price, stop_price, notional,
+		reduce_only, accepted, check_name, reason)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, NULLIF(?, ''), NULLIF(?, ''))`
+
+	_, err := o.db.conn.Exec(query, entry.BotID, entry.Ts, entry.Symbol, entry.Side, entry.Type, entry.Qty,
+		entry.Price, entry.StopPrice, entry.Notional, entry.Reduce, entry.Accepted, entry.Check, entry.Reason)
+	if err != nil {
+		return fmt.Errorf("failed to save order audit: %w", err)
+	}
+	return nil
+}
+
+// Accepted retrieves the bot's orders that passed the checks since a time,
+// oldest first
+func (o *OrderAuditStore) Accepted(botID string, since time.Time) ([]OrderAudit, error) {
+	return o.query(`SELECT `+orderAuditColumns+` FROM order_audit
+	WHERE bot_id = ? AND ts >= ? AND accepted = TRUE
+	ORDER BY ts, id`, botID, since)
+}
+
+// List retrieves audit entries matching the filter, newest first. Only
+// rejections are returned when rejected is set.
+func (o *OrderAuditStore) List(filter QueryFilter, rejected bool) ([]OrderAudit, error) {
+	var conditions []string
+	if rejected {
+		conditions = append(conditions, "accepted = FALSE")
+	}
+	where, args := filter.Where("ts", conditions...)
+	limit, offset := filter.Page(50)
+
+	return o.query(`SELECT `+orderAuditColumns+` FROM order_audit `+where+`
+	ORDER BY ts DESC, id DESC
+	LIMIT ? OFFSET ?`, append(args, limit, offset)...)
+}
+
+func (o *OrderAuditStore) query(query string, args ...interface{}) ([]OrderAudit, error) {
+	if o.db == nil || o.db.conn == nil {
+		return nil, fmt.Errorf("database connection is nil")
+	}
+
+	rows, err := o.db.conn.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query order audit: %w", err)
+	}
+	defer rows.Close()
+
+	entries := []OrderAudit{}
+	for rows.Next() {
+		var entry OrderAudit
+		err := rows.Scan(&entry.ID, &entry.BotID, &entry.Ts, &entry.Symbol, &entry.Side, &entry.Type, &entry.Qty,
+			&entry.Price, &entry.StopPrice, &entry.Notional, &entry.Reduce, &entry.Accepted, &entry.Check, &entry.Reason)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan order audit: %w", err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
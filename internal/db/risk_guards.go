@@ -0,0 +1,99 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/adeilh/agentic_go_signals/internal/risk"
+)
+
+// RiskGuardStore persists each bot's circuit breaker state
+type RiskGuardStore struct {
+	db *DB
+}
+
+func NewRiskGuardStore(db *DB) *RiskGuardStore {
+	return &RiskGuardStore{db: db}
+}
+
+const riskGuardColumns = `bot_id, since, day, day_start_equity, peak_equity, tripped_at, COALESCE(reason, '')`
+
+func scanGuardState(row interface{ Scan(...interface{}) error }) (*risk.GuardState, error) {
+	var state risk.GuardState
+	var trippedAt sql.NullTime
+	err := row.Scan(&state.BotID, &state.Since, &state.Day, &state.DayStartEquity,
+		&state.PeakEquity, &trippedAt, &state.Reason)
+	if err != nil {
+		return nil, err
+	}
+	if trippedAt.Valid {
+		state.TrippedAt = &trippedAt.Time
+	}
+	return &state, nil
+}
+
+// Get retrieves a bot's circuit breaker state, returning nil if the bot has
+// none yet
+func (r *RiskGuardStore) Get(botID string) (*risk.GuardState, error) {
+	if r.db == nil || r.db.conn == nil {
+		return nil, fmt.Errorf("database connection is nil")
+	}
+
+	row := r.db.conn.QueryRow(`SELECT `+riskGuardColumns+` FROM risk_guards WHERE bot_id = ?`, botID)
+	state, err := scanGuardState(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get risk guard: %w", err)
+	}
+	return state, nil
+}
+
+// List retrieves the circuit breaker state of every bot that has one
+func (r *RiskGuardStore) List() ([]risk.GuardState, error) {
+	if r.db == nil || r.db.conn == nil {
+		return nil, fmt.Errorf("database connection is nil")
+	}
+
+	rows, err := r.db.conn.Query(`SELECT ` + riskGuardColumns + ` FROM risk_guards ORDER BY bot_id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query risk guards: %w", err)
+	}
+	defer rows.Close()
+
+	states := []risk.GuardState{}
+	for rows.Next() {
+		state, err := scanGuardState(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan risk guard: %w", err)
+		}
+		states = append(states, *state)
+	}
+	return states, rows.Err()
+}
+
+// Save inserts or overwrites a bot's circuit breaker state
+func (r *RiskGuardStore) Save(state risk.GuardState) error {
+	if r.db == nil || r.db.conn == nil {
+		return fmt.Errorf("database connection is nil")
+	}
+
+	query := `INSERT INTO risk_guards (
+		bot_id, since, day, day_start_equity, peak_equity, tripped_at, reason
+	) VALUES (?, ?, ?, ?, ?, ?, NULLIF(?, ''))
+	ON DUPLICATE KEY UPDATE
+		since = VALUES(since),
+		day = VALUES(day),
+		day_start_equity = VALUES(day_start_equity),
+		peak_equity = VALUES(peak_equity),
+		tripped_at = VALUES(tripped_at),
+		reason = VALUES(reason)`
+
+	_, err := r.db.conn.Exec(query, state.BotID, state.Since, state.Day, state.DayStartEquity,
+		state.PeakEquity, state.TrippedAt, state.Reason)
+	if err != nil {
+		return fmt.Errorf("failed to save risk guard: %w", err)
+	}
+	return nil
+}
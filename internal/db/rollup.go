@@ -0,0 +1,196 @@
+package db
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/adeilh/agentic_go_signals/internal/trader"
+)
+
+// BaseInterval is the only kline interval streamed from the exchange. Every
+// longer interval is rolled up from it inside the database, so all
+// timeframes agree with the base data.
+const BaseInterval = "1m"
+
+// RollupIntervals are materialized into market_klines by MaterializeRollups.
+// Other intervals that divide a day are aggregated when queried.
+var RollupIntervals = []string{"5m", "15m", "1h", "4h", "1d"}
+
+// rollupSelect aggregates base klines into buckets of a longer interval.
+// Buckets are counted from an aligned anchor with TIMESTAMPDIFF, which works
+// on the stored DATETIMEs as written, whatever the session time zone. Open
+// and close come from the first and last minute of each bucket; a bucket is
+// closed once all of its minutes are stored and closed.
+const rollupSelect = `SELECT symbol, ?,
+		CAST(SUBSTRING_INDEX(GROUP_CONCAT(open_price ORDER BY open_time ASC), ',', 1) AS DECIMAL(20,8)),
+		MAX(high_price), MIN(low_price),
+		CAST(SUBSTRING_INDEX(GROUP_CONCAT(close_price ORDER BY open_time DESC), ',', 1) AS DECIMAL(20,8)),
+		SUM(volume), SUM(quote_volume),
+		CAST(DATE_ADD(?, INTERVAL bucket * ? SECOND) AS DATETIME),
+		CAST(DATE_ADD(?, INTERVAL (bucket + 1) * ? - 1 SECOND) AS DATETIME),
+		COUNT(*) = ? AND MIN(is_closed) = 1,
+		SUM(trade_count), MAX(ts)
+	FROM (
+		SELECT symbol, open_price, high_price, low_price, close_price, volume,
+			quote_volume, open_time, is_closed, trade_count, ts,
+			FLOOR(TIMESTAMPDIFF(SECOND, ?, open_time) / ?) AS bucket
+		FROM market_klines
+		WHERE symbol = ? AND interval_type = ? AND open_time >= ? AND open_time < ?
+	) base
+	GROUP BY symbol, bucket`
+
+// rollupWindow widens [from, to) to whole buckets of interval, so that a
+// bucket is never aggregated from part of its minutes. It fails for
+// intervals that cannot be rolled up from BaseInterval.
+func rollupWindow(interval string, from, to time.Time) (step time.Duration, start, end time.Time, err error) {
+	step, err = trader.IntervalDuration(interval)
+	if err != nil {
+		return 0, time.Time{}, time.Time{}, err
+	}
+	// Buckets must tile a UTC day for time.Truncate, which aligns on
+	// multiples of step since year 1, to match the exchange's boundaries
+	if step <= time.Minute || step%time.Minute != 0 || (24*time.Hour)%step != 0 {
+		return 0, time.Time{}, time.Time{}, fmt.Errorf("%s klines cannot be rolled up from %s", interval, BaseInterval)
+	}
+
+	start = from.Truncate(step)
+	end = to.Truncate(step)
+	if end.Before(to) {
+		end = end.Add(step)
+	}
+	return step, start, end, nil
+}
+
+// IsRollupInterval reports whether interval is materialized from BaseInterval
+func IsRollupInterval(interval string) bool {
+	for _, rollup := range RollupIntervals {
+		if interval == rollup {
+			return true
+		}
+	}
+	return false
+}
+
+// rollupArgs returns the arguments of rollupSelect
+func rollupArgs(symbol, interval string, step time.Duration, start, end time.Time) []interface{} {
+	seconds := int64(step / time.Second)
+	return []interface{}{
+		interval,
+		start, seconds,
+		start, seconds,
+		int64(step / time.Minute),
+		start, seconds,
+		symbol, BaseInterval, start, end,
+	}
+}
+
+// MaterializeKlines rolls the base klines opening in [from, to), widened to
+// whole buckets, up into interval klines stored in market_klines. Existing
+// rows of those buckets, including ones backfilled from the exchange, are
+// overwritten, so it is safe to run repeatedly over the same range.
+func (m *MarketDataStore) MaterializeKlines(symbol, interval string, from, to time.Time) (int64, error) {
+	if m.db == nil || m.db.conn == nil {
+		return 0, fmt.Errorf("database connection is nil")
+	}
+	step, start, end, err := rollupWindow(interval, from, to)
+	if err != nil {
+		return 0, err
+	}
+
+	query := `INSERT INTO market_klines (
+		symbol, interval_type, open_price, high_price, low_price, close_price,
+		volume, quote_volume, open_time, close_time, is_closed, trade_count, ts
+	) ` + rollupSelect + `
+	ON DUPLICATE KEY UPDATE
+		open_price = VALUES(open_price),
+		high_price = VALUES(high_price),
+		low_price = VALUES(low_price),
+		close_price = VALUES(close_price),
+		volume = VALUES(volume),
+		quote_volume = VALUES(quote_volume),
+		close_time = VALUES(close_time),
+		is_closed = VALUES(is_closed),
+		trade_count = VALUES(trade_count),
+		ts = VALUES(ts)`
+
+	res, err := m.db.conn.Exec(query, rollupArgs(symbol, interval, step, start, end)...)
+	if err != nil {
+		return 0, fmt.Errorf("failed to materialize %s klines: %w", interval, err)
+	}
+	return res.RowsAffected()
+}
+
+// MaterializeRollups materializes every RollupIntervals interval from the
+// base klines opening in [from, to)
+func (m *MarketDataStore) MaterializeRollups(symbol string, from, to time.Time) error {
+	for _, interval := range RollupIntervals {
+		if _, err := m.MaterializeKlines(symbol, interval, from, to); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// aggregateKlines rolls the base klines opening in [from, to), widened to
+// whole buckets, up into interval klines without storing them, oldest first
+func (m *MarketDataStore) aggregateKlines(symbol, interval string, from, to time.Time) ([]MarketKline, error) {
+	if m.db == nil || m.db.conn == nil {
+		return nil, fmt.Errorf("database connection is nil")
+	}
+	step, start, end, err := rollupWindow(interval, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := m.db.conn.Query(rollupSelect+` ORDER BY bucket ASC`, rollupArgs(symbol, interval, step, start, end)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to aggregate %s klines: %w", interval, err)
+	}
+	defer rows.Close()
+
+	var klines []MarketKline
+	for rows.Next() {
+		var k MarketKline
+		err := rows.Scan(&k.Symbol, &k.IntervalType, &k.OpenPrice, &k.HighPrice,
+			&k.LowPrice, &k.ClosePrice, &k.Volume, &k.QuoteVolume, &k.OpenTime,
+			&k.CloseTime, &k.IsClosed, &k.TradeCount, &k.Timestamp)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan aggregated kline: %w", err)
+		}
+		klines = append(klines, k)
+	}
+	return klines, rows.Err()
+}
+
+// aggregated reports whether klines of interval are aggregated when queried
+// rather than read as stored: longer than the base, not materialized, and
+// not a calendar interval the exchange has to provide
+func aggregated(interval string) bool {
+	if interval == BaseInterval || IsRollupInterval(interval) {
+		return false
+	}
+	_, _, _, err := rollupWindow(interval, time.Time{}, time.Time{})
+	return err == nil
+}
+
+// recentAggregatedKlines aggregates the latest limit klines of interval,
+// newest first like GetKlineData
+func (m *MarketDataStore) recentAggregatedKlines(symbol, interval string, limit int) ([]MarketKline, error) {
+	step, err := trader.IntervalDuration(interval)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	klines, err := m.aggregateKlines(symbol, interval, now.Add(-time.Duration(limit-1)*step), now)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, j := 0, len(klines)-1; i < j; i, j = i+1, j-1 {
+		klines[i], klines[j] = klines[j], klines[i]
+	}
+	if len(klines) > limit {
+		klines = klines[:limit]
+	}
+	return klines, nil
+}
@@ -0,0 +1,177 @@
+package db
+
+import (
+	"fmt"
+
+	"github.com/adeilh/agentic_go_signals/internal/trader"
+)
+
+// TradeStore handles trade history queries
+type TradeStore struct {
+	db *DB
+}
+
+func NewTradeStore(db *DB) *TradeStore {
+	return &TradeStore{db: db}
+}
+
+const tradeColumns = `id, bot_id, ts, symbol, side, qty, price, status, COALESCE(order_id, ''), fee, base_fee`
+
+func scanTrade(row interface{ Scan(...interface{}) error }) (Trade, error) {
+	var trade Trade
+	err := row.Scan(&trade.ID, &trade.BotID, &trade.Ts, &trade.Symbol,
+		&trade.Side, &trade.Qty, &trade.Price, &trade.Status, &trade.OrderID, &trade.Fee, &trade.BaseFee)
+	if err != nil {
+		return Trade{}, fmt.Errorf("failed to scan trade: %w", err)
+	}
+	return trade, nil
+}
+
+// List retrieves trades matching the filter, newest first
+func (t *TradeStore) List(filter QueryFilter) ([]Trade, error) {
+	if t.db == nil || t.db.conn == nil {
+		return nil, fmt.Errorf("database connection is nil")
+	}
+
+	where, args := filter.Where("ts")
+	limit, offset := filter.Page(50)
+
+	query := `SELECT ` + tradeColumns + `
+	FROM trades ` + where + `
+	ORDER BY ts DESC, id DESC
+	LIMIT ? OFFSET ?`
+
+	rows, err := t.db.conn.Query(query, append(args, limit, offset)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query trades: %w", err)
+	}
+	defer rows.Close()
+
+	trades := []Trade{}
+	for rows.Next() {
+		trade, err := scanTrade(rows)
+		if err != nil {
+			return nil, err
+		}
+		trades = append(trades, trade)
+	}
+	return trades, rows.Err()
+}
+
+// Record inserts a trade stamped with the current time and returns its ID
+func (t *TradeStore) Record(trade Trade) (uint, error) {
+	if t.db == nil || t.db.conn == nil {
+		return 0, fmt.Errorf("database connection is nil")
+	}
+
+	query := `INSERT INTO trades (bot_id, symbol, side, qty, price, status, order_id, fee, base_fee, ts)
+	VALUES (?, ?, ?, ?, ?, ?, NULLIF(?, ''), ?, ?, NOW())`
+
+	result, err := t.db.conn.Exec(query, trade.BotID, trade.Symbol, trade.Side,
+		trade.Qty, trade.Price, trade.Status, trade.OrderID, trade.Fee, trade.BaseFee)
+	if err != nil {
+		return 0, fmt.Errorf("failed to save trade record: %w", err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("failed to read trade id: %w", err)
+	}
+	return uint(id), nil
+}
+
+// ListOpenOrders retrieves a bot's trades whose exchange order can still
+// fill, oldest first
+func (t *TradeStore) ListOpenOrders(botID string) ([]Trade, error) {
+	if t.db == nil || t.db.conn == nil {
+		return nil, fmt.Errorf("database connection is nil")
+	}
+
+	query := `SELECT ` + tradeColumns + `
+	FROM trades
+	WHERE bot_id = ? AND order_id IS NOT NULL AND status IN ('NEW', 'PARTIALLY_FILLED')
+	ORDER BY ts, id`
+
+	rows, err := t.db.conn.Query(query, botID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query open orders: %w", err)
+	}
+	defer rows.Close()
+
+	trades := []Trade{}
+	for rows.Next() {
+		trade, err := scanTrade(rows)
+		if err != nil {
+			return nil, err
+		}
+		trades = append(trades, trade)
+	}
+	return trades, rows.Err()
+}
+
+// UpdateExecution records the latest status, executed quantity, average
+// price and commission of a trade's order
+func (t *TradeStore) UpdateExecution(trade Trade) error {
+	if t.db == nil || t.db.conn == nil {
+		return fmt.Errorf("database connection is nil")
+	}
+
+	_, err := t.db.conn.Exec(`UPDATE trades SET status = ?, qty = ?, price = ?, fee = ?, base_fee = ? WHERE bot_id = ? AND id = ?`,
+		trade.Status, trade.Qty, trade.Price, trade.Fee, trade.BaseFee, trade.BotID, trade.ID)
+	if err != nil {
+		return fmt.Errorf("failed to update trade %d: %w", trade.ID, err)
+	}
+	return nil
+}
+
+// UpdateOrder records the latest status, executed quantity and average
+// price of an exchange order on whichever trade placed it, returning the
+// number of trades updated. The price is left alone while nothing has
+// executed. fee is the commission on the fill that brought the order to
+// qty; it is added only when qty is more than was recorded, so a report
+// delivered twice is not charged twice.
+func (t *TradeStore) UpdateOrder(symbol, orderID, status string, qty, price float64, fee trader.Fee) (int64, error) {
+	if t.db == nil || t.db.conn == nil {
+		return 0, fmt.Errorf("database connection is nil")
+	}
+
+	// MySQL assigns left to right, so the fees are compared with the old qty
+	result, err := t.db.conn.Exec(`UPDATE trades
+	SET fee = CASE WHEN ? > qty THEN fee + ? ELSE fee END,
+		base_fee = CASE WHEN ? > qty THEN base_fee + ? ELSE base_fee END,
+		status = ?, qty = ?, price = CASE WHEN ? > 0 THEN ? ELSE price END
+	WHERE symbol = ? AND order_id = ?`,
+		qty, fee.Quote, qty, fee.Base, status, qty, qty, price, symbol, orderID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to update order %s: %w", orderID, err)
+	}
+	return result.RowsAffected()
+}
+
+// ListFilled retrieves the trades of a bot that executed some quantity,
+// oldest first, for replaying into positions
+func (t *TradeStore) ListFilled(botID string) ([]Trade, error) {
+	if t.db == nil || t.db.conn == nil {
+		return nil, fmt.Errorf("database connection is nil")
+	}
+
+	query := `SELECT ` + tradeColumns + `
+	FROM trades
+	WHERE bot_id = ? AND qty > 0
+	ORDER BY ts, id`
+
+	rows, err := t.db.conn.Query(query, botID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query filled trades: %w", err)
+	}
+	defer rows.Close()
+
+	trades := []Trade{}
+	for rows.Next() {
+		trade, err := scanTrade(rows)
+		if err != nil {
+			return nil, err
+		}
+		trades = append(trades, trade)
+	}
+	return trades, rows.Err()
+}
@@ -0,0 +1,31 @@
+package exchange
+
+import "github.com/adeilh/agentic_go_signals/internal/trader"
+
+// BinanceName is the registry name of the Binance exchange
+const BinanceName = "binance"
+
+func init() {
+	Register(BinanceName, func(cfg Config) (Exchange, error) {
+		return NewBinance(trader.NewClientWithConfig(cfg.APIKey, cfg.APISecret, cfg.Production)), nil
+	})
+}
+
+// Binance adapts the Binance REST and websocket client to Exchange
+type Binance struct {
+	*trader.Client
+}
+
+// NewBinance wraps an existing Binance client
+func NewBinance(client *trader.Client) *Binance {
+	return &Binance{Client: client}
+}
+
+func (b *Binance) Name() string {
+	return BinanceName
+}
+
+// NewMarketStream returns a combined Binance stream over symbols
+func (b *Binance) NewMarketStream(symbols []string) MarketStream {
+	return trader.NewBinanceWebSocketManager(b.Client, symbols)
+}
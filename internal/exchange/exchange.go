@@ -0,0 +1,149 @@
+// Package exchange abstracts the trading venue behind an interface so that
+// services, the API and the bot workers do not depend on a specific exchange
+// client. Binance is the default implementation; Fake is an in-process venue
+// for tests.
+package exchange
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/adeilh/agentic_go_signals/internal/trader"
+)
+
+// Exchange is a trading venue: public market data, the account, order
+// placement and live streams. Implementations reuse the trader types as the
+// common data model.
+type Exchange interface {
+	// Name returns the registry name of the venue, e.g. "binance"
+	Name() string
+	TestConnection() error
+
+	MarketData
+	Trading
+	Streams
+}
+
+// MarketData covers the public market data endpoints
+type MarketData interface {
+	GetTickerPrice(symbol string) (trader.TickerPrice, error)
+	// GetTicker24hr returns the 24h ticker of symbol, or of every symbol
+	// when symbol is empty
+	GetTicker24hr(symbol string) ([]trader.Ticker24hr, error)
+	GetOrderBook(symbol string, limit int) (trader.OrderBook, error)
+	// GetKlines returns raw kline rows; parse them with trader.ParseKlineRow.
+	// Zero start and end times are omitted from the request.
+	GetKlines(symbol, interval string, limit int, startTime, endTime int64) ([][]interface{}, error)
+	GetRecentTrades(symbol string, limit int) ([]trader.Trade, error)
+	GetExchangeInfo() (trader.ExchangeInfo, error)
+	// GetSymbolFilters returns the price, quantity and notional limits
+	// orders on symbol must respect
+	GetSymbolFilters(symbol string) (trader.SymbolFilters, error)
+}
+
+// Trading covers the signed account and order endpoints. Bots place their
+// orders through pretrade.Gate: market orders with PlaceOrder, the others
+// with PlaceLimit and PlaceOCO.
+type Trading interface {
+	GetAccountInfo() (map[string]interface{}, error)
+	// PlaceOrder places a market order; side is BUY or SELL
+	PlaceOrder(symbol, side string, qty float64) (trader.Order, error)
+	// NewOrder places a market, limit, stop-loss limit or take-profit limit order
+	NewOrder(req trader.OrderRequest) (trader.Order, error)
+	// NewOCO places a one-cancels-the-other limit and stop-loss pair
+	NewOCO(req trader.OCORequest) (trader.OrderList, error)
+	GetOrder(symbol string, orderID int64) (trader.Order, error)
+	CancelOrder(symbol string, orderID int64) (trader.Order, error)
+	// GetOpenOrders returns the open orders on symbol, or on every symbol
+	// when symbol is empty
+	GetOpenOrders(symbol string) ([]trader.Order, error)
+	// GetMyTrades returns the account's fills on symbol, optionally of one order
+	GetMyTrades(symbol string, orderID int64, limit int) ([]trader.AccountTrade, error)
+	// SubscribeUserDataStream delivers the account's order and balance
+	// updates. It blocks until ctx is done, returning nil, or the stream
+	// fails.
+	SubscribeUserDataStream(ctx context.Context, handlers trader.UserDataHandlers) error
+}
+
+// Streams covers the live market data streams. The Subscribe methods return
+// once connected and deliver events from a goroutine until ctx is done.
+type Streams interface {
+	SubscribeTickerStream(ctx context.Context, symbol string, handler trader.TickerHandler) error
+	SubscribeTradeStream(ctx context.Context, symbol string, handler trader.TradeHandler) error
+	SubscribeDepthStream(ctx context.Context, symbol string, levels int, handler trader.DepthHandler) error
+	SubscribeKlineStream(ctx context.Context, symbol, interval string, handler trader.KlineHandler) error
+	// NewMarketStream returns a combined stream over symbols
+	NewMarketStream(symbols []string) MarketStream
+}
+
+// MarketStream multiplexes ticker, trade, depth and kline events for a set of
+// symbols. Handlers are keyed by data type ("ticker", "trade", "depth",
+// "kline") and receive the matching trader.WS*Event value.
+type MarketStream interface {
+	SetDataHandler(dataType string, handler func(interface{}))
+	Start(ctx context.Context) error
+	Stop()
+	AddSymbol(symbol string)
+	IsRunning() bool
+	// Stats returns the stream's connect, reconnect and message counters
+	Stats() trader.StreamStats
+	// GetOrderBook returns a depth snapshot from the same venue as the
+	// stream's depth diffs, to build local order books from
+	GetOrderBook(symbol string, limit int) (trader.OrderBook, error)
+}
+
+// Config holds the credentials and environment used to build an exchange
+type Config struct {
+	APIKey     string
+	APISecret  string
+	Production bool // Use the live venue instead of its testnet
+}
+
+// Factory builds an exchange from its configuration
+type Factory func(cfg Config) (Exchange, error)
+
+var (
+	mu        sync.RWMutex
+	factories = map[string]Factory{}
+)
+
+// Register makes an exchange available by name. It panics if the name is
+// already registered, mirroring database/sql drivers.
+func Register(name string, factory Factory) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	name = strings.ToLower(name)
+	if _, exists := factories[name]; exists {
+		panic(fmt.Sprintf("exchange %q registered twice", name))
+	}
+	factories[name] = factory
+}
+
+// New builds the exchange registered under name
+func New(name string, cfg Config) (Exchange, error) {
+	mu.RLock()
+	factory, ok := factories[strings.ToLower(name)]
+	mu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("unknown exchange %q (available: %s)", name, strings.Join(Names(), ", "))
+	}
+	return factory(cfg)
+}
+
+// Names lists the registered exchanges in alphabetical order
+func Names() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	names := make([]string, 0, len(factories))
+	for name := range factories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,247 @@
+package exchange
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/adeilh/agentic_go_signals/internal/trader"
+)
+
+func TestRegistry(t *testing.T) {
+	ex, err := New("Binance", Config{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := ex.(*Binance); !ok || ex.Name() != BinanceName {
+		t.Fatalf("expected the Binance exchange, got %T %s", ex, ex.Name())
+	}
+
+	if _, err := New("mtgox", Config{}); err == nil {
+		t.Fatal("expected error for unknown exchange")
+	}
+
+	// The fake fills every order and must not be selectable as a venue
+	if _, err := New(FakeName, Config{}); err == nil {
+		t.Fatal("expected the fake exchange not to be registered")
+	}
+	names := Names()
+	if len(names) != 1 || names[0] != BinanceName {
+		t.Fatalf("expected only binance to be registered, got %v", names)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected panic on duplicate registration")
+		}
+	}()
+	Register(BinanceName, func(Config) (Exchange, error) { return NewFake(), nil })
+}
+
+func TestFakeMarketData(t *testing.T) {
+	f := NewFake()
+	f.SetPrice("btcusdt", 50000)
+
+	price, err := f.GetTickerPrice("BTCUSDT")
+	if err != nil || price.Price != "50000.00000000" {
+		t.Fatalf("unexpected ticker price %+v, %v", price, err)
+	}
+	if _, err := f.GetTickerPrice("ETHUSDT"); !errors.Is(err, ErrUnknownSymbol) {
+		t.Fatalf("expected unknown symbol, got %v", err)
+	}
+	if tickers, _ := f.GetTicker24hr(""); len(tickers) != 1 || tickers[0].LastPrice != "50000.00000000" {
+		t.Fatalf("unexpected tickers %+v", tickers)
+	}
+
+	f.SetKlines("BTCUSDT", "1m", []trader.Kline{
+		{OpenTime: 0, CloseTime: 59999, Open: "1", High: "2", Low: "1", Close: "2", Volume: "3", QuoteAssetVolume: "6"},
+		{OpenTime: 60000, CloseTime: 119999, Open: "2", High: "3", Low: "2", Close: "3", Volume: "3", QuoteAssetVolume: "9"},
+		{OpenTime: 120000, CloseTime: 179999, Open: "3", High: "4", Low: "3", Close: "4", Volume: "3", QuoteAssetVolume: "12"},
+	})
+	rows, err := f.GetKlines("BTCUSDT", "1m", 1, 60000, 0)
+	if err != nil || len(rows) != 1 {
+		t.Fatalf("expected one kline, got %d, %v", len(rows), err)
+	}
+	kline, err := trader.ParseKlineRow(rows[0])
+	if err != nil {
+		t.Fatalf("expected Binance row format, got %v", err)
+	}
+	if kline.OpenTime != 60000 || kline.Close != "3" {
+		t.Fatalf("unexpected kline %+v", kline)
+	}
+	if rows, _ := f.GetKlines("BTCUSDT", "1h", 10, 0, 0); len(rows) != 0 {
+		t.Fatalf("expected no klines for another interval, got %d", len(rows))
+	}
+
+	f.SetTrades("BTCUSDT", []trader.Trade{{ID: 1}, {ID: 2}, {ID: 3}})
+	if trades, _ := f.GetRecentTrades("BTCUSDT", 2); len(trades) != 2 || trades[0].ID != 2 {
+		t.Fatalf("expected the two most recent trades, got %+v", trades)
+	}
+
+	f.SetError(errors.New("venue down"))
+	if _, err := f.GetOrderBook("BTCUSDT", 10); err == nil {
+		t.Fatal("expected injected error")
+	}
+	if err := f.TestConnection(); err == nil {
+		t.Fatal("expected injected error")
+	}
+}
+
+func TestFakePlaceOrder(t *testing.T) {
+	f := NewFake()
+	f.SetPrice("BTCUSDT", 100)
+
+	order, err := f.PlaceOrder("BTCUSDT", "buy", 2)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	qty, price, err := order.Executed()
+	if err != nil || order.Status != "FILLED" || qty != 2 || price != 100 {
+		t.Fatalf("expected 2 filled at 100, got %s %v @ %v (%v)", order.Status, qty, price, err)
+	}
+
+	if _, err := f.PlaceOrder("BTCUSDT", "HOLD", 1); err == nil {
+		t.Fatal("expected error for invalid side")
+	}
+	if _, err := f.PlaceOrder("ETHUSDT", "SELL", 1); err == nil {
+		t.Fatal("expected error for unknown symbol")
+	}
+	if orders := f.Orders(); len(orders) != 1 || orders[0].OrderID != order.OrderID {
+		t.Fatalf("expected only the filled order to be recorded, got %+v", orders)
+	}
+}
+
+func TestFakeStreams(t *testing.T) {
+	f := NewFake()
+
+	var depth []trader.WSDepthEvent
+	if err := f.SubscribeDepthStream(context.Background(), "BTCUSDT", 20, func(event trader.WSDepthEvent) {
+		depth = append(depth, event)
+	}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	stream := f.NewMarketStream([]string{"BTCUSDT"})
+	var tickers int
+	stream.SetDataHandler("ticker", func(data interface{}) {
+		if _, ok := data.(trader.WSTickerEvent); ok {
+			tickers++
+		}
+	})
+
+	// Streams only deliver while started
+	f.Emit("ticker", trader.WSTickerEvent{Symbol: "BTCUSDT"})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := stream.Start(ctx); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := stream.Start(ctx); err == nil {
+		t.Fatal("expected error starting a running stream")
+	}
+	f.Emit("ticker", trader.WSTickerEvent{Symbol: "BTCUSDT"})
+	if tickers != 1 || !stream.IsRunning() {
+		t.Fatalf("expected one ticker while running, got %d", tickers)
+	}
+
+	f.Emit("depth", trader.WSDepthEvent{Symbol: "ETHUSDT"})
+	f.Emit("depth", trader.WSDepthEvent{Symbol: "BTCUSDT"})
+	if len(depth) != 1 || depth[0].Symbol != "BTCUSDT" {
+		t.Fatalf("expected only the subscribed symbol, got %+v", depth)
+	}
+
+	stream.Stop()
+	f.Emit("ticker", trader.WSTickerEvent{Symbol: "BTCUSDT"})
+	if tickers != 1 || stream.IsRunning() {
+		t.Fatalf("expected no delivery after stop, got %d", tickers)
+	}
+}
+
+func TestFakeOrderTypes(t *testing.T) {
+	f := NewFake()
+	f.SetPrice("BTCUSDT", 100)
+
+	resting, err := f.NewOrder(trader.OrderRequest{Symbol: "BTCUSDT", Side: trader.SideBuy, Type: trader.OrderTypeLimit, Quantity: 1, Price: 95})
+	if err != nil || resting.Status != "NEW" || resting.TimeInForce != trader.TimeInForceGTC {
+		t.Fatalf("expected a resting GTC limit order, got %+v, %v", resting, err)
+	}
+	crossing, err := f.NewOrder(trader.OrderRequest{Symbol: "BTCUSDT", Side: trader.SideSell, Type: trader.OrderTypeLimit, Quantity: 1, Price: 95})
+	if err != nil || crossing.Status != "FILLED" {
+		t.Fatalf("expected a crossing limit order to fill, got %+v, %v", crossing, err)
+	}
+	quote, err := f.NewOrder(trader.OrderRequest{Symbol: "BTCUSDT", Side: trader.SideBuy, Type: trader.OrderTypeMarket, QuoteOrderQty: 50})
+	if qty, _, _ := quote.Executed(); err != nil || qty != 0.5 {
+		t.Fatalf("expected 0.5 bought for 50, got %v, %v", qty, err)
+	}
+
+	list, err := f.NewOCO(trader.ExitOCO("BTCUSDT", trader.SideBuy, 1, 110, 95))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(list.OrderReports) != 2 || list.OrderReports[0].Type != trader.OrderTypeStopLossLimit || list.OrderReports[1].OrderListID != list.OrderListID {
+		t.Fatalf("unexpected order list %+v", list)
+	}
+	if _, err := f.NewOCO(trader.ExitOCO("BTCUSDT", trader.SideBuy, 1, 90, 95)); err == nil {
+		t.Fatal("expected error for inverted OCO prices")
+	}
+}
+
+func TestFakeSymbolFilters(t *testing.T) {
+	f := NewFake()
+	f.SetPrice("BTCUSDT", 100)
+
+	if filters, err := f.GetSymbolFilters("BTCUSDT"); err != nil || filters.StepSize != 0 || filters.Status != "TRADING" {
+		t.Fatalf("expected no limits by default, got %+v, %v", filters, err)
+	}
+	if _, err := f.GetSymbolFilters("ETHUSDT"); !errors.Is(err, trader.ErrUnknownSymbol) {
+		t.Fatalf("expected unknown symbol, got %v", err)
+	}
+	if info, err := f.GetExchangeInfo(); err != nil || len(info.Symbols) != 1 || info.Symbols[0].Symbol != "BTCUSDT" {
+		t.Fatalf("unexpected exchange info %+v, %v", info, err)
+	}
+
+	f.SetSymbolFilters("btcusdt", trader.SymbolFilters{StepSize: 0.01, TickSize: 0.1, MinNotional: 10, MinNotionalMarket: true})
+	order, err := f.PlaceOrder("BTCUSDT", "BUY", 0.129)
+	if qty, _, _ := order.Executed(); err != nil || qty != 0.12 {
+		t.Fatalf("expected 0.12 filled, got %v, %v", qty, err)
+	}
+	var filterErr *trader.FilterError
+	if _, err := f.PlaceOrder("BTCUSDT", "BUY", 0.05); !errors.As(err, &filterErr) || filterErr.Filter != "NOTIONAL" {
+		t.Fatalf("expected a notional error, got %v", err)
+	}
+	if _, err := f.NewOCO(trader.ExitOCO("BTCUSDT", trader.SideBuy, 0.05, 110, 95)); !errors.As(err, &filterErr) {
+		t.Fatalf("expected a filter error for the OCO, got %v", err)
+	}
+	if len(f.Orders()) != 1 {
+		t.Fatalf("expected rejected orders not to be recorded, got %d", len(f.Orders()))
+	}
+}
+
+func TestFakeUserData(t *testing.T) {
+	f := NewFake()
+
+	var reports []trader.WSExecutionReport
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- f.SubscribeUserDataStream(ctx, trader.UserDataHandlers{
+			OnExecutionReport: func(r trader.WSExecutionReport) { reports = append(reports, r) },
+		})
+	}()
+	for subscribed := false; !subscribed; {
+		f.mu.Lock()
+		subscribed = len(f.userData) == 1
+		f.mu.Unlock()
+	}
+
+	f.EmitUserData(trader.WSExecutionReport{OrderID: 1})
+	f.EmitUserData(trader.WSAccountPosition{}) // No handler set
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	f.EmitUserData(trader.WSExecutionReport{OrderID: 2})
+	if len(reports) != 1 || reports[0].OrderID != 1 {
+		t.Fatalf("expected only the report sent while subscribed, got %+v", reports)
+	}
+}
@@ -0,0 +1,756 @@
+package exchange
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/adeilh/agentic_go_signals/internal/trader"
+)
+
+// FakeName is the name the in-process fake exchange reports. It is not
+// registered: the fake fills every order, so it must never be selectable as
+// a live venue. Tests construct it with NewFake.
+const FakeName = "fake"
+
+// ErrUnknownSymbol is returned by Fake for symbols without a configured price
+var ErrUnknownSymbol = trader.ErrUnknownSymbol
+
+// Fake is an in-process exchange for tests. Market data is whatever the test
+// sets, market orders fill in full at the symbol's price, and stream events
+// are delivered synchronously by Emit. It never touches the network.
+type Fake struct {
+	mu          sync.Mutex
+	prices      map[string]float64
+	books       map[string]trader.OrderBook
+	klines      map[string][]trader.Kline // Keyed by symbol and interval
+	trades      map[string][]trader.Trade
+	filters     map[string]trader.SymbolFilters
+	orders      []trader.Order
+	nextOrderID int64
+	err         error
+	handlers    map[string][]func(interface{})
+	userData    map[int]trader.UserDataHandlers // Keyed by subscription
+	nextUserSub int
+	streams     []*fakeStream
+	now         func() time.Time
+}
+
+// NewFake returns an empty fake exchange
+func NewFake() *Fake {
+	return &Fake{
+		prices:   make(map[string]float64),
+		books:    make(map[string]trader.OrderBook),
+		klines:   make(map[string][]trader.Kline),
+		trades:   make(map[string][]trader.Trade),
+		filters:  make(map[string]trader.SymbolFilters),
+		handlers: make(map[string][]func(interface{})),
+		userData: make(map[int]trader.UserDataHandlers),
+		now:      time.Now,
+	}
+}
+
+func (f *Fake) Name() string {
+	return FakeName
+}
+
+// SetError makes every subsequent call fail with err; nil restores normal
+// behaviour
+func (f *Fake) SetError(err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.err = err
+}
+
+// SetPrice sets the last price of symbol, which market orders fill at
+func (f *Fake) SetPrice(symbol string, price float64) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.prices[strings.ToUpper(symbol)] = price
+}
+
+// SetOrderBook sets the book returned for symbol
+func (f *Fake) SetOrderBook(symbol string, book trader.OrderBook) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.books[strings.ToUpper(symbol)] = book
+}
+
+// SetKlines sets the klines of symbol and interval, oldest first
+func (f *Fake) SetKlines(symbol, interval string, klines []trader.Kline) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.klines[klineKey(symbol, interval)] = klines
+}
+
+// SetTrades sets the recent trades of symbol, oldest first
+func (f *Fake) SetTrades(symbol string, trades []trader.Trade) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.trades[strings.ToUpper(symbol)] = trades
+}
+
+// SetSymbolFilters sets the filters orders on symbol are rounded to and
+// checked against. Symbols without filters accept any order.
+func (f *Fake) SetSymbolFilters(symbol string, filters trader.SymbolFilters) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	filters.Symbol = strings.ToUpper(symbol)
+	f.filters[filters.Symbol] = filters
+}
+
+// Orders returns the orders placed so far, oldest first
+func (f *Fake) Orders() []trader.Order {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]trader.Order(nil), f.orders...)
+}
+
+func (f *Fake) TestConnection() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.err
+}
+
+func (f *Fake) GetTickerPrice(symbol string) (trader.TickerPrice, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	price, err := f.price(symbol)
+	if err != nil {
+		return trader.TickerPrice{}, err
+	}
+	return trader.TickerPrice{Symbol: strings.ToUpper(symbol), Price: formatFloat(price)}, nil
+}
+
+func (f *Fake) GetTicker24hr(symbol string) ([]trader.Ticker24hr, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.err != nil {
+		return nil, f.err
+	}
+
+	var tickers []trader.Ticker24hr
+	for s, price := range f.prices {
+		if symbol != "" && s != strings.ToUpper(symbol) {
+			continue
+		}
+		last := formatFloat(price)
+		tickers = append(tickers, trader.Ticker24hr{
+			Symbol:             s,
+			PriceChange:        "0",
+			PriceChangePercent: "0",
+			WeightedAvgPrice:   last,
+			PrevClosePrice:     last,
+			LastPrice:          last,
+			OpenPrice:          last,
+			HighPrice:          last,
+			LowPrice:           last,
+			Volume:             "0",
+			QuoteVolume:        "0",
+		})
+	}
+	return tickers, nil
+}
+
+func (f *Fake) GetOrderBook(symbol string, limit int) (trader.OrderBook, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.err != nil {
+		return trader.OrderBook{}, f.err
+	}
+	book, ok := f.books[strings.ToUpper(symbol)]
+	if !ok {
+		return trader.OrderBook{}, fmt.Errorf("%w: %s", ErrUnknownSymbol, symbol)
+	}
+	if limit > 0 {
+		book.Bids = book.Bids[:min(limit, len(book.Bids))]
+		book.Asks = book.Asks[:min(limit, len(book.Asks))]
+	}
+	return book, nil
+}
+
+// GetKlines returns up to limit klines opening within [startTime, endTime]
+// in the array-of-arrays form of the Binance API
+func (f *Fake) GetKlines(symbol, interval string, limit int, startTime, endTime int64) ([][]interface{}, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.err != nil {
+		return nil, f.err
+	}
+
+	var rows [][]interface{}
+	for _, k := range f.klines[klineKey(symbol, interval)] {
+		if (startTime > 0 && k.OpenTime < startTime) || (endTime > 0 && k.OpenTime > endTime) {
+			continue
+		}
+		if limit > 0 && len(rows) == limit {
+			break
+		}
+		rows = append(rows, []interface{}{
+			float64(k.OpenTime), k.Open, k.High, k.Low, k.Close, k.Volume,
+			float64(k.CloseTime), k.QuoteAssetVolume, float64(k.NumberOfTrades),
+			k.TakerBuyBaseAssetVolume, k.TakerBuyQuoteAssetVolume, "0",
+		})
+	}
+	return rows, nil
+}
+
+// GetRecentTrades returns the last limit trades of symbol
+func (f *Fake) GetRecentTrades(symbol string, limit int) ([]trader.Trade, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.err != nil {
+		return nil, f.err
+	}
+	trades := f.trades[strings.ToUpper(symbol)]
+	if limit > 0 && len(trades) > limit {
+		trades = trades[len(trades)-limit:]
+	}
+	return append([]trader.Trade(nil), trades...), nil
+}
+
+// GetExchangeInfo lists the symbols with a configured price. Their filters
+// are not included; use GetSymbolFilters.
+func (f *Fake) GetExchangeInfo() (trader.ExchangeInfo, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.err != nil {
+		return trader.ExchangeInfo{}, f.err
+	}
+	info := trader.ExchangeInfo{Timezone: "UTC", ServerTime: f.now().UnixMilli()}
+	for symbol := range f.prices {
+		info.Symbols = append(info.Symbols, trader.SymbolInfo{Symbol: symbol, Status: "TRADING"})
+	}
+	sort.Slice(info.Symbols, func(i, j int) bool { return info.Symbols[i].Symbol < info.Symbols[j].Symbol })
+	return info, nil
+}
+
+// GetSymbolFilters returns the filters set for symbol, or no limits at all
+func (f *Fake) GetSymbolFilters(symbol string) (trader.SymbolFilters, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, err := f.price(symbol); err != nil {
+		return trader.SymbolFilters{}, err
+	}
+	return f.symbolFilters(symbol), nil
+}
+
+func (f *Fake) GetAccountInfo() (map[string]interface{}, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.err != nil {
+		return nil, f.err
+	}
+	return map[string]interface{}{"canTrade": true, "balances": []interface{}{}}, nil
+}
+
+// PlaceOrder fills a market order in full at the symbol's current price
+func (f *Fake) PlaceOrder(symbol, side string, qty float64) (trader.Order, error) {
+	return f.NewOrder(trader.OrderRequest{
+		Symbol:   symbol,
+		Side:     side,
+		Type:     trader.OrderTypeMarket,
+		Quantity: qty,
+	})
+}
+
+// NewOrder fills market orders and limit orders that cross the current
+// price in full at that price. Other orders are accepted as NEW and never
+// fill. Orders are rounded to and checked against the symbol's filters.
+func (f *Fake) NewOrder(req trader.OrderRequest) (trader.Order, error) {
+	if err := req.Validate(); err != nil {
+		return trader.Order{}, err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	price, err := f.price(req.Symbol)
+	if err != nil {
+		return trader.Order{}, err
+	}
+	if err := f.symbolFilters(req.Symbol).Apply(&req, price); err != nil {
+		return trader.Order{}, err
+	}
+
+	qty := req.Quantity
+	if req.QuoteOrderQty > 0 {
+		qty = req.QuoteOrderQty / price
+	}
+	order := f.newOrder(req.Symbol, req.Side, req.Type, qty, req.Price, req.StopPrice)
+	order.TimeInForce = req.TimeInForce
+	if req.NewClientOrderID != "" {
+		order.ClientID = req.NewClientOrderID
+	}
+
+	crosses := (req.Side == trader.SideBuy && req.Price >= price) || (req.Side == trader.SideSell && req.Price <= price)
+	if req.Type == trader.OrderTypeMarket || (req.Type == trader.OrderTypeLimit && crosses) {
+		order.ExecutedQty = formatFloat(qty)
+		order.CummulativeQuoteQty = formatFloat(qty * price)
+		order.Status = trader.OrderStatusFilled
+		order.Fills = []trader.Fill{{
+			Price:           formatFloat(price),
+			Qty:             formatFloat(qty),
+			Commission:      "0",
+			CommissionAsset: "USDT",
+			TradeID:         order.OrderID,
+		}}
+	}
+
+	f.orders = append(f.orders, order)
+	return order, nil
+}
+
+// NewOCO accepts both legs as NEW; neither ever fills
+func (f *Fake) NewOCO(req trader.OCORequest) (trader.OrderList, error) {
+	if err := req.Validate(); err != nil {
+		return trader.OrderList{}, err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, err := f.price(req.Symbol); err != nil {
+		return trader.OrderList{}, err
+	}
+	if err := f.symbolFilters(req.Symbol).ApplyOCO(&req); err != nil {
+		return trader.OrderList{}, err
+	}
+
+	stopType := "STOP_LOSS"
+	if req.StopLimitPrice > 0 {
+		stopType = trader.OrderTypeStopLossLimit
+	}
+	limit := f.newOrder(req.Symbol, req.Side, "LIMIT_MAKER", req.Quantity, req.Price, 0)
+	stop := f.newOrder(req.Symbol, req.Side, stopType, req.Quantity, req.StopLimitPrice, req.StopPrice)
+	stop.TimeInForce = req.StopLimitTimeInForce
+
+	list := trader.OrderList{
+		OrderListID:       f.nextOrderID,
+		ContingencyType:   "OCO",
+		ListStatusType:    "EXEC_STARTED",
+		ListOrderStatus:   "EXECUTING",
+		ListClientOrderID: req.ListClientOrderID,
+		TransactionTime:   f.now().UnixMilli(),
+		Symbol:            req.Symbol,
+	}
+	for _, order := range []trader.Order{stop, limit} {
+		order.OrderListID = list.OrderListID
+		list.Orders = append(list.Orders, trader.OrderListLeg{Symbol: order.Symbol, OrderID: order.OrderID, ClientID: order.ClientID})
+		list.OrderReports = append(list.OrderReports, order)
+		f.orders = append(f.orders, order)
+	}
+	return list, nil
+}
+
+// GetOrder returns the current state of an order placed on the fake
+func (f *Fake) GetOrder(symbol string, orderID int64) (trader.Order, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.err != nil {
+		return trader.Order{}, f.err
+	}
+	order, err := f.order(symbol, orderID)
+	if err != nil {
+		return trader.Order{}, err
+	}
+	return *order, nil
+}
+
+// CancelOrder cancels an open order
+func (f *Fake) CancelOrder(symbol string, orderID int64) (trader.Order, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.err != nil {
+		return trader.Order{}, f.err
+	}
+	order, err := f.order(symbol, orderID)
+	if err != nil {
+		return trader.Order{}, err
+	}
+	if !order.IsOpen() {
+		return trader.Order{}, fmt.Errorf("order %d is %s", orderID, order.Status)
+	}
+	order.Status = trader.OrderStatusCanceled
+	order.UpdateTime = f.now().UnixMilli()
+	return *order, nil
+}
+
+func (f *Fake) GetOpenOrders(symbol string) ([]trader.Order, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.err != nil {
+		return nil, f.err
+	}
+	orders := []trader.Order{}
+	for _, order := range f.orders {
+		if order.IsOpen() && (symbol == "" || strings.EqualFold(order.Symbol, symbol)) {
+			orders = append(orders, order)
+		}
+	}
+	return orders, nil
+}
+
+// GetMyTrades returns the fills of orders placed on the fake
+func (f *Fake) GetMyTrades(symbol string, orderID int64, limit int) ([]trader.AccountTrade, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.err != nil {
+		return nil, f.err
+	}
+	trades := []trader.AccountTrade{}
+	for _, order := range f.orders {
+		if !strings.EqualFold(order.Symbol, symbol) || (orderID > 0 && order.OrderID != orderID) {
+			continue
+		}
+		for _, fill := range order.Fills {
+			price, _ := strconv.ParseFloat(fill.Price, 64)
+			qty, _ := strconv.ParseFloat(fill.Qty, 64)
+			trades = append(trades, trader.AccountTrade{
+				Symbol:          order.Symbol,
+				ID:              fill.TradeID,
+				OrderID:         order.OrderID,
+				OrderListID:     order.OrderListID,
+				Price:           fill.Price,
+				Qty:             fill.Qty,
+				QuoteQty:        formatFloat(price * qty),
+				Commission:      fill.Commission,
+				CommissionAsset: fill.CommissionAsset,
+				Time:            order.UpdateTime,
+				IsBuyer:         order.Side == trader.SideBuy,
+			})
+		}
+	}
+	if limit > 0 && len(trades) > limit {
+		trades = trades[len(trades)-limit:]
+	}
+	return trades, nil
+}
+
+// FillOrder executes qty more of an open order at its limit price, or at
+// the current price for orders without one, as if the market had traded
+// through it
+func (f *Fake) FillOrder(orderID int64, qty float64) (trader.Order, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var order *trader.Order
+	for i := range f.orders {
+		if f.orders[i].OrderID == orderID {
+			order = &f.orders[i]
+		}
+	}
+	if order == nil {
+		return trader.Order{}, fmt.Errorf("unknown order %d", orderID)
+	}
+	if !order.IsOpen() {
+		return trader.Order{}, fmt.Errorf("order %d is %s", orderID, order.Status)
+	}
+
+	origQty, _ := strconv.ParseFloat(order.Quantity, 64)
+	executed, quoteQty, err := order.Executed()
+	if err != nil {
+		return trader.Order{}, err
+	}
+	quoteQty *= executed
+	qty = min(qty, origQty-executed)
+	price, _ := strconv.ParseFloat(order.Price, 64)
+	if price == 0 {
+		if price, err = f.price(order.Symbol); err != nil {
+			return trader.Order{}, err
+		}
+	}
+
+	order.ExecutedQty = formatFloat(executed + qty)
+	order.CummulativeQuoteQty = formatFloat(quoteQty + qty*price)
+	order.Status = trader.OrderStatusPartiallyFilled
+	if executed+qty >= origQty {
+		order.Status = trader.OrderStatusFilled
+	}
+	order.UpdateTime = f.now().UnixMilli()
+	f.nextOrderID++
+	order.Fills = append(order.Fills, trader.Fill{
+		Price:           formatFloat(price),
+		Qty:             formatFloat(qty),
+		Commission:      "0",
+		CommissionAsset: "USDT",
+		TradeID:         f.nextOrderID,
+	})
+	return *order, nil
+}
+
+// order finds an order placed on the fake; callers hold f.mu
+func (f *Fake) order(symbol string, orderID int64) (*trader.Order, error) {
+	for i := range f.orders {
+		if f.orders[i].OrderID == orderID && strings.EqualFold(f.orders[i].Symbol, symbol) {
+			return &f.orders[i], nil
+		}
+	}
+	return nil, fmt.Errorf("order %d does not exist on %s", orderID, symbol)
+}
+
+// newOrder returns an unfilled order with the next order ID; callers hold f.mu
+func (f *Fake) newOrder(symbol, side, orderType string, qty, price, stopPrice float64) trader.Order {
+	f.nextOrderID++
+	return trader.Order{
+		Symbol:              symbol,
+		OrderID:             f.nextOrderID,
+		ClientID:            "fake-" + strconv.FormatInt(f.nextOrderID, 10),
+		Side:                side,
+		Type:                orderType,
+		Quantity:            formatFloat(qty),
+		Price:               formatFloat(price),
+		StopPrice:           formatFloat(stopPrice),
+		OrderListID:         -1,
+		ExecutedQty:         formatFloat(0),
+		CummulativeQuoteQty: formatFloat(0),
+		Status:              trader.OrderStatusNew,
+		Time:                f.now().UnixMilli(),
+	}
+}
+
+func (f *Fake) SubscribeTickerStream(ctx context.Context, symbol string, handler trader.TickerHandler) error {
+	return f.subscribe("ticker", func(data interface{}) {
+		if event, ok := data.(trader.WSTickerEvent); ok && strings.EqualFold(event.Symbol, symbol) {
+			handler(event)
+		}
+	})
+}
+
+func (f *Fake) SubscribeTradeStream(ctx context.Context, symbol string, handler trader.TradeHandler) error {
+	return f.subscribe("trade", func(data interface{}) {
+		if event, ok := data.(trader.WSTradeEvent); ok && strings.EqualFold(event.Symbol, symbol) {
+			handler(event)
+		}
+	})
+}
+
+func (f *Fake) SubscribeDepthStream(ctx context.Context, symbol string, levels int, handler trader.DepthHandler) error {
+	return f.subscribe("depth", func(data interface{}) {
+		if event, ok := data.(trader.WSDepthEvent); ok && strings.EqualFold(event.Symbol, symbol) {
+			handler(event)
+		}
+	})
+}
+
+func (f *Fake) SubscribeKlineStream(ctx context.Context, symbol, interval string, handler trader.KlineHandler) error {
+	return f.subscribe("kline", func(data interface{}) {
+		if event, ok := data.(trader.WSKlineEvent); ok && strings.EqualFold(event.Symbol, symbol) && event.Kline.Interval == interval {
+			handler(event)
+		}
+	})
+}
+
+// NewMarketStream returns a stream that receives Emit events while started
+func (f *Fake) NewMarketStream(symbols []string) MarketStream {
+	stream := &fakeStream{fake: f, handlers: make(map[string]func(interface{}))}
+	for _, symbol := range symbols {
+		stream.AddSymbol(symbol)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.streams = append(f.streams, stream)
+	return stream
+}
+
+// Emit delivers a stream event of dataType ("ticker", "trade", "depth" or
+// "kline") to every subscriber and running market stream
+func (f *Fake) Emit(dataType string, event interface{}) {
+	f.mu.Lock()
+	handlers := append([]func(interface{}){}, f.handlers[dataType]...)
+	streams := append([]*fakeStream{}, f.streams...)
+	f.mu.Unlock()
+
+	for _, handler := range handlers {
+		handler(event)
+	}
+	for _, stream := range streams {
+		stream.deliver(dataType, event)
+	}
+}
+
+// SubscribeUserDataStream receives EmitUserData events until ctx is done
+func (f *Fake) SubscribeUserDataStream(ctx context.Context, handlers trader.UserDataHandlers) error {
+	f.mu.Lock()
+	if f.err != nil {
+		defer f.mu.Unlock()
+		return f.err
+	}
+	f.nextUserSub++
+	id := f.nextUserSub
+	f.userData[id] = handlers
+	f.mu.Unlock()
+
+	<-ctx.Done()
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.userData, id)
+	return nil
+}
+
+// EmitUserData delivers a trader.WSExecutionReport or
+// trader.WSAccountPosition to every user data subscriber
+func (f *Fake) EmitUserData(event interface{}) {
+	f.mu.Lock()
+	subscribers := make([]trader.UserDataHandlers, 0, len(f.userData))
+	for _, handlers := range f.userData {
+		subscribers = append(subscribers, handlers)
+	}
+	f.mu.Unlock()
+
+	for _, handlers := range subscribers {
+		switch event := event.(type) {
+		case trader.WSExecutionReport:
+			if handlers.OnExecutionReport != nil {
+				handlers.OnExecutionReport(event)
+			}
+		case trader.WSAccountPosition:
+			if handlers.OnAccountPosition != nil {
+				handlers.OnAccountPosition(event)
+			}
+		}
+	}
+}
+
+func (f *Fake) subscribe(dataType string, handler func(interface{})) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.err != nil {
+		return f.err
+	}
+	f.handlers[dataType] = append(f.handlers[dataType], handler)
+	return nil
+}
+
+// price returns the configured price of symbol; callers hold f.mu
+func (f *Fake) price(symbol string) (float64, error) {
+	if f.err != nil {
+		return 0, f.err
+	}
+	price, ok := f.prices[strings.ToUpper(symbol)]
+	if !ok {
+		return 0, fmt.Errorf("%w: %s", ErrUnknownSymbol, symbol)
+	}
+	return price, nil
+}
+
+// symbolFilters returns the filters set for symbol; callers hold f.mu
+func (f *Fake) symbolFilters(symbol string) trader.SymbolFilters {
+	symbol = strings.ToUpper(symbol)
+	if filters, ok := f.filters[symbol]; ok {
+		return filters
+	}
+	return trader.SymbolFilters{Symbol: symbol, Status: "TRADING"}
+}
+
+// fakeStream is the MarketStream returned by Fake
+type fakeStream struct {
+	fake     *Fake
+	mu       sync.Mutex
+	symbols  []string
+	handlers map[string]func(interface{})
+	running  bool
+	metrics  trader.StreamMetrics
+}
+
+func (s *fakeStream) SetDataHandler(dataType string, handler func(interface{})) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.handlers[dataType] = handler
+}
+
+func (s *fakeStream) Start(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.running {
+		return fmt.Errorf("market stream is already running")
+	}
+	s.running = true
+	s.metrics.Connected()
+	go func() {
+		<-ctx.Done()
+		s.Stop()
+	}()
+	return nil
+}
+
+func (s *fakeStream) Stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.running {
+		s.metrics.Disconnected(nil)
+	}
+	s.running = false
+}
+
+// Stats counts a connect per Start and a message per delivered event
+func (s *fakeStream) Stats() trader.StreamStats {
+	return s.metrics.Stats()
+}
+
+// GetOrderBook returns the fake's book; its events and snapshots come
+// from the same place
+func (s *fakeStream) GetOrderBook(symbol string, limit int) (trader.OrderBook, error) {
+	return s.fake.GetOrderBook(symbol, limit)
+}
+
+func (s *fakeStream) AddSymbol(symbol string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, existing := range s.symbols {
+		if strings.EqualFold(existing, symbol) {
+			return
+		}
+	}
+	s.symbols = append(s.symbols, strings.ToUpper(symbol))
+}
+
+func (s *fakeStream) IsRunning() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.running
+}
+
+// deliver hands an event to the stream's handler if the stream is running
+func (s *fakeStream) deliver(dataType string, event interface{}) {
+	s.mu.Lock()
+	handler := s.handlers[dataType]
+	running := s.running
+	s.mu.Unlock()
+
+	if running {
+		s.metrics.Message()
+	}
+	if running && handler != nil {
+		handler(event)
+	}
+}
+
+func klineKey(symbol, interval string) string {
+	return strings.ToUpper(symbol) + "/" + interval
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', 8, 64)
+}
@@ -0,0 +1,247 @@
+// Package indicators computes technical indicators over stored klines so
+// that strategies, the Kimi prompt and the UI all read the same values.
+//
+// Every series function returns a slice aligned with its input. Entries
+// before the indicator has enough bars are NaN.
+package indicators
+
+import (
+	"math"
+
+	"github.com/adeilh/agentic_go_signals/internal/db"
+)
+
+// SMA returns the simple moving average of values over period
+func SMA(values []float64, period int) []float64 {
+	out := nanSeries(len(values))
+	if period <= 0 {
+		return out
+	}
+	var sum float64
+	for i, v := range values {
+		sum += v
+		if i >= period {
+			sum -= values[i-period]
+		}
+		if i >= period-1 {
+			out[i] = sum / float64(period)
+		}
+	}
+	return out
+}
+
+// EMA returns the exponential moving average of values over period, seeded
+// with the simple average of the first period values. NaN inputs, such as
+// another indicator's warm-up, are skipped until the first number.
+func EMA(values []float64, period int) []float64 {
+	out := nanSeries(len(values))
+	if period <= 0 {
+		return out
+	}
+	alpha := 2 / float64(period+1)
+
+	start := 0
+	for start < len(values) && math.IsNaN(values[start]) {
+		start++
+	}
+	if len(values)-start < period {
+		return out
+	}
+
+	seed := start + period - 1
+	var sum float64
+	for _, v := range values[start : seed+1] {
+		sum += v
+	}
+	out[seed] = sum / float64(period)
+	for i := seed + 1; i < len(values); i++ {
+		out[i] = alpha*values[i] + (1-alpha)*out[i-1]
+	}
+	return out
+}
+
+// RSI returns Wilder's relative strength index of closes over period
+func RSI(closes []float64, period int) []float64 {
+	out := nanSeries(len(closes))
+	if period <= 0 || len(closes) <= period {
+		return out
+	}
+
+	var gain, loss float64
+	for i := 1; i <= period; i++ {
+		change := closes[i] - closes[i-1]
+		gain += math.Max(change, 0)
+		loss += math.Max(-change, 0)
+	}
+	gain /= float64(period)
+	loss /= float64(period)
+	out[period] = rsi(gain, loss)
+
+	for i := period + 1; i < len(closes); i++ {
+		change := closes[i] - closes[i-1]
+		gain = (gain*float64(period-1) + math.Max(change, 0)) / float64(period)
+		loss = (loss*float64(period-1) + math.Max(-change, 0)) / float64(period)
+		out[i] = rsi(gain, loss)
+	}
+	return out
+}
+
+func rsi(gain, loss float64) float64 {
+	if loss == 0 {
+		if gain == 0 {
+			return 50
+		}
+		return 100
+	}
+	return 100 - 100/(1+gain/loss)
+}
+
+// MACD returns the difference of the fast and slow EMAs of closes, its
+// signal EMA and the histogram between the two
+func MACD(closes []float64, fast, slow, signal int) (macd, signalLine, histogram []float64) {
+	fastEMA, slowEMA := EMA(closes, fast), EMA(closes, slow)
+	macd = nanSeries(len(closes))
+	for i := range closes {
+		macd[i] = fastEMA[i] - slowEMA[i]
+	}
+	signalLine = EMA(macd, signal)
+	histogram = nanSeries(len(closes))
+	for i := range closes {
+		histogram[i] = macd[i] - signalLine[i]
+	}
+	return macd, signalLine, histogram
+}
+
+// Bollinger returns the SMA of closes over period and the bands k
+// population standard deviations either side of it
+func Bollinger(closes []float64, period int, k float64) (middle, upper, lower []float64) {
+	middle = SMA(closes, period)
+	upper, lower = nanSeries(len(closes)), nanSeries(len(closes))
+	for i := period - 1; i >= 0 && i < len(closes); i++ {
+		var variance float64
+		for _, c := range closes[i-period+1 : i+1] {
+			variance += (c - middle[i]) * (c - middle[i])
+		}
+		deviation := math.Sqrt(variance / float64(period))
+		upper[i] = middle[i] + k*deviation
+		lower[i] = middle[i] - k*deviation
+	}
+	return middle, upper, lower
+}
+
+// TrueRange returns each bar's true range: its high-low range widened to
+// include the previous close
+func TrueRange(bars []db.MarketKline) []float64 {
+	out := make([]float64, len(bars))
+	for i, bar := range bars {
+		out[i] = bar.HighPrice - bar.LowPrice
+		if i > 0 {
+			prev := bars[i-1].ClosePrice
+			out[i] = math.Max(out[i], math.Max(math.Abs(bar.HighPrice-prev), math.Abs(bar.LowPrice-prev)))
+		}
+	}
+	return out
+}
+
+// ATR returns Wilder's average true range over period
+func ATR(bars []db.MarketKline, period int) []float64 {
+	out := nanSeries(len(bars))
+	if period <= 0 || len(bars) < period {
+		return out
+	}
+	tr := TrueRange(bars)
+
+	var sum float64
+	for _, v := range tr[:period] {
+		sum += v
+	}
+	out[period-1] = sum / float64(period)
+	for i := period; i < len(bars); i++ {
+		out[i] = (out[i-1]*float64(period-1) + tr[i]) / float64(period)
+	}
+	return out
+}
+
+// VWAP returns the volume weighted average typical price, (high + low +
+// close) / 3, anchored at the start of each UTC day
+func VWAP(bars []db.MarketKline) []float64 {
+	out := nanSeries(len(bars))
+	var notional, volume float64
+	for i, bar := range bars {
+		if i > 0 && !sameUTCDay(bar, bars[i-1]) {
+			notional, volume = 0, 0
+		}
+		typical := (bar.HighPrice + bar.LowPrice + bar.ClosePrice) / 3
+		notional += typical * bar.Volume
+		volume += bar.Volume
+		if volume > 0 {
+			out[i] = notional / volume
+		}
+	}
+	return out
+}
+
+func sameUTCDay(a, b db.MarketKline) bool {
+	ay, am, ad := a.OpenTime.UTC().Date()
+	by, bm, bd := b.OpenTime.UTC().Date()
+	return ay == by && am == bm && ad == bd
+}
+
+// OBV returns on-balance volume: the running total of volume, added on up
+// closes and subtracted on down closes, starting from zero
+func OBV(bars []db.MarketKline) []float64 {
+	out := make([]float64, len(bars))
+	for i := 1; i < len(bars); i++ {
+		out[i] = out[i-1]
+		switch {
+		case bars[i].ClosePrice > bars[i-1].ClosePrice:
+			out[i] += bars[i].Volume
+		case bars[i].ClosePrice < bars[i-1].ClosePrice:
+			out[i] -= bars[i].Volume
+		}
+	}
+	return out
+}
+
+// Stochastic returns the %K of the close within the high-low range of the
+// last kPeriod bars and %D, its SMA over dPeriod
+func Stochastic(bars []db.MarketKline, kPeriod, dPeriod int) (k, d []float64) {
+	k = nanSeries(len(bars))
+	for i := kPeriod - 1; i >= 0 && i < len(bars); i++ {
+		high, low := math.Inf(-1), math.Inf(1)
+		for _, bar := range bars[i-kPeriod+1 : i+1] {
+			high = math.Max(high, bar.HighPrice)
+			low = math.Min(low, bar.LowPrice)
+		}
+		k[i] = 50 // A flat range gives no position within it
+		if high > low {
+			k[i] = 100 * (bars[i].ClosePrice - low) / (high - low)
+		}
+	}
+
+	d = nanSeries(len(bars))
+	if kPeriod > 0 && dPeriod > 0 {
+		warm := kPeriod - 1
+		if warm < len(k) {
+			copy(d[warm:], SMA(k[warm:], dPeriod))
+		}
+	}
+	return k, d
+}
+
+// Closes returns the close prices of bars
+func Closes(bars []db.MarketKline) []float64 {
+	closes := make([]float64, len(bars))
+	for i, bar := range bars {
+		closes[i] = bar.ClosePrice
+	}
+	return closes
+}
+
+func nanSeries(n int) []float64 {
+	out := make([]float64, n)
+	for i := range out {
+		out[i] = math.NaN()
+	}
+	return out
+}
@@ -0,0 +1,236 @@
+package indicators
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/adeilh/agentic_go_signals/internal/db"
+)
+
+func near(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+func bar(open time.Time, high, low, close, volume float64) db.MarketKline {
+	return db.MarketKline{
+		OpenTime:   open,
+		CloseTime:  open.Add(time.Minute - time.Millisecond),
+		HighPrice:  high,
+		LowPrice:   low,
+		ClosePrice: close,
+		Volume:     volume,
+	}
+}
+
+// trendBars returns n one minute bars whose close rises by one each bar
+func trendBars(n int) []db.MarketKline {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	bars := make([]db.MarketKline, n)
+	for i := range bars {
+		c := float64(100 + i)
+		bars[i] = bar(start.Add(time.Duration(i)*time.Minute), c+1, c-1, c, 10)
+	}
+	return bars
+}
+
+func TestSMAAndEMA(t *testing.T) {
+	values := []float64{1, 2, 3, 4, 5}
+
+	sma := SMA(values, 3)
+	if !math.IsNaN(sma[1]) || !near(sma[2], 2) || !near(sma[4], 4) {
+		t.Fatalf("unexpected SMA %v", sma)
+	}
+
+	// Seeded with the SMA of 1, 2, 3 then alpha = 0.5
+	ema := EMA(values, 3)
+	if !math.IsNaN(ema[1]) || !near(ema[2], 2) || !near(ema[3], 3) || !near(ema[4], 4) {
+		t.Fatalf("unexpected EMA %v", ema)
+	}
+
+	// Leading NaNs are skipped before seeding
+	ema = EMA([]float64{math.NaN(), 2, 4, 6}, 2)
+	if !math.IsNaN(ema[1]) || !near(ema[2], 3) || !near(ema[3], 5) {
+		t.Fatalf("unexpected EMA over warm-up %v", ema)
+	}
+}
+
+func TestRSI(t *testing.T) {
+	rsi := RSI([]float64{1, 2, 3, 4}, 3)
+	if !math.IsNaN(rsi[2]) || !near(rsi[3], 100) {
+		t.Fatalf("expected 100 on only gains, got %v", rsi)
+	}
+
+	// Average gain 1 and loss 0.5 over the seed gives RS 2
+	rsi = RSI([]float64{10, 11, 10.5, 11.5, 11}, 4)
+	if !near(rsi[4], 100-100/(1+2.0/1)) {
+		t.Fatalf("unexpected RSI %v", rsi)
+	}
+
+	if rsi := RSI([]float64{5, 5, 5}, 2); !near(rsi[2], 50) {
+		t.Fatalf("expected 50 on a flat series, got %v", rsi)
+	}
+}
+
+func TestMACD(t *testing.T) {
+	closes := Closes(trendBars(60))
+	macd, signal, hist := MACD(closes, MACDFast, MACDSlow, MACDSignal)
+
+	if !math.IsNaN(macd[MACDSlow-2]) || math.IsNaN(macd[MACDSlow-1]) {
+		t.Fatalf("expected MACD to start at bar %d", MACDSlow-1)
+	}
+	first := MACDSlow + MACDSignal - 2
+	if !math.IsNaN(signal[first-1]) || math.IsNaN(signal[first]) {
+		t.Fatalf("expected the signal to start at bar %d", first)
+	}
+	// SMA seeded EMAs lag a linear trend by (period - 1) / 2 bars
+	if !near(macd[59], float64(MACDSlow-MACDFast)/2) {
+		t.Fatalf("expected MACD %v on a linear uptrend, got %v", float64(MACDSlow-MACDFast)/2, macd[59])
+	}
+	if !near(hist[59], macd[59]-signal[59]) {
+		t.Fatalf("histogram %v is not MACD - signal", hist[59])
+	}
+}
+
+func TestBollinger(t *testing.T) {
+	middle, upper, lower := Bollinger([]float64{2, 4, 4, 4, 5, 5, 7, 9}, 8, 2)
+	if !math.IsNaN(upper[6]) {
+		t.Fatalf("expected warm-up, got %v", upper)
+	}
+	// Population standard deviation of the series is 2
+	if !near(middle[7], 5) || !near(upper[7], 9) || !near(lower[7], 1) {
+		t.Fatalf("unexpected bands %v %v %v", middle[7], upper[7], lower[7])
+	}
+}
+
+func TestATR(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	bars := []db.MarketKline{
+		bar(start, 12, 10, 11, 1),
+		bar(start.Add(time.Minute), 15, 13, 14, 1), // Gap up: true range 15 - 11
+		bar(start.Add(2*time.Minute), 14, 12, 13, 1),
+	}
+
+	tr := TrueRange(bars)
+	if !near(tr[0], 2) || !near(tr[1], 4) || !near(tr[2], 2) {
+		t.Fatalf("unexpected true range %v", tr)
+	}
+
+	atr := ATR(bars, 2)
+	if !math.IsNaN(atr[0]) || !near(atr[1], 3) || !near(atr[2], 2.5) {
+		t.Fatalf("unexpected ATR %v", atr)
+	}
+}
+
+func TestVWAPResetsDaily(t *testing.T) {
+	day := time.Date(2024, 1, 1, 23, 59, 0, 0, time.UTC)
+	bars := []db.MarketKline{
+		bar(day.Add(-time.Minute), 10, 10, 10, 1),
+		bar(day, 20, 20, 20, 3),
+		bar(day.Add(time.Minute), 30, 30, 30, 1),
+	}
+
+	vwap := VWAP(bars)
+	if !near(vwap[0], 10) || !near(vwap[1], 17.5) {
+		t.Fatalf("unexpected VWAP %v", vwap)
+	}
+	if !near(vwap[2], 30) {
+		t.Fatalf("expected VWAP to reset at the UTC day, got %v", vwap[2])
+	}
+}
+
+func TestOBV(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	bars := []db.MarketKline{
+		bar(start, 10, 10, 10, 5),
+		bar(start.Add(time.Minute), 11, 11, 11, 3),
+		bar(start.Add(2*time.Minute), 11, 11, 11, 4),
+		bar(start.Add(3*time.Minute), 9, 9, 9, 2),
+	}
+
+	obv := OBV(bars)
+	for i, want := range []float64{0, 3, 3, 1} {
+		if !near(obv[i], want) {
+			t.Fatalf("OBV[%d] = %v, want %v", i, obv[i], want)
+		}
+	}
+}
+
+func TestStochastic(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	bars := []db.MarketKline{
+		bar(start, 10, 0, 5, 1),
+		bar(start.Add(time.Minute), 10, 0, 10, 1),
+		bar(start.Add(2*time.Minute), 10, 0, 0, 1),
+		bar(start.Add(3*time.Minute), 5, 5, 5, 1),
+	}
+
+	k, d := Stochastic(bars, 2, 2)
+	if !math.IsNaN(k[0]) || !near(k[1], 100) || !near(k[2], 0) || !near(k[3], 50) {
+		t.Fatalf("unexpected %%K %v", k)
+	}
+	if !math.IsNaN(d[1]) || !near(d[2], 50) || !near(d[3], 25) {
+		t.Fatalf("unexpected %%D %v", d)
+	}
+
+	// A flat range has no position within it
+	flat := []db.MarketKline{bar(start, 5, 5, 5, 1)}
+	if k, _ := Stochastic(flat, 1, 1); !near(k[0], 50) {
+		t.Fatalf("expected 50 on a flat range, got %v", k[0])
+	}
+}
+
+func TestParseNames(t *testing.T) {
+	names, err := ParseNames("")
+	if err != nil || len(names) != len(Names()) {
+		t.Fatalf("expected every indicator by default, got %v %v", names, err)
+	}
+
+	names, err = ParseNames(" RSI, macd ")
+	if err != nil || len(names) != 2 || names[0] != NameRSI || names[1] != NameMACD {
+		t.Fatalf("unexpected names %v %v", names, err)
+	}
+
+	if _, err := ParseNames("rsi,ichimoku"); err == nil {
+		t.Fatal("expected an error for an unknown indicator")
+	}
+}
+
+func TestCompute(t *testing.T) {
+	bars := trendBars(WarmupBars + 10)
+
+	readings, err := Compute(bars, []string{NameRSI, NameEMA})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(readings) != len(bars) {
+		t.Fatalf("expected a reading per bar, got %d", len(readings))
+	}
+
+	first := readings[0]
+	if first.EMA20 != nil || first.RSI14 != nil {
+		t.Fatalf("expected no values while warming up, got %+v", first)
+	}
+	last := readings[len(readings)-1]
+	if last.EMA20 == nil || last.EMA50 == nil || last.RSI14 == nil || *last.RSI14 != 100 {
+		t.Fatalf("expected EMAs and RSI 100 on an uptrend, got %+v", last)
+	}
+	if last.MACD != nil || last.VWAP != nil {
+		t.Fatalf("expected indicators that were not requested to be nil, got %+v", last)
+	}
+	if !last.Time.Equal(bars[len(bars)-1].CloseTime) || last.Close != bars[len(bars)-1].ClosePrice {
+		t.Fatalf("reading not aligned with its bar: %+v", last)
+	}
+
+	if _, err := Compute(bars, []string{"ichimoku"}); err == nil {
+		t.Fatal("expected an error for an unknown indicator")
+	}
+
+	if Latest(nil) != nil {
+		t.Fatal("expected no readings without bars")
+	}
+	latest := Latest(bars)
+	if latest == nil || latest.StochK == nil || latest.ATR14 == nil || latest.OBV == nil {
+		t.Fatalf("expected every indicator at the last bar, got %+v", latest)
+	}
+}
@@ -0,0 +1,158 @@
+package indicators
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/adeilh/agentic_go_signals/internal/db"
+)
+
+// Indicator names accepted by Compute
+const (
+	NameEMA        = "ema"
+	NameRSI        = "rsi"
+	NameMACD       = "macd"
+	NameBollinger  = "bollinger"
+	NameATR        = "atr"
+	NameVWAP       = "vwap"
+	NameOBV        = "obv"
+	NameStochastic = "stoch"
+)
+
+// Standard periods, the defaults of most charting tools
+const (
+	EMAFastPeriod    = 20
+	EMASlowPeriod    = 50
+	RSIPeriod        = 14
+	MACDFast         = 12
+	MACDSlow         = 26
+	MACDSignal       = 9
+	BollingerPeriod  = 20
+	BollingerStdDevs = 2
+	ATRPeriod        = 14
+	StochasticK      = 14
+	StochasticD      = 3
+)
+
+// WarmupBars is how many bars the slowest indicator needs before its first
+// value
+const WarmupBars = EMASlowPeriod
+
+// Names lists the indicators Compute knows about
+func Names() []string {
+	return []string{NameEMA, NameRSI, NameMACD, NameBollinger, NameATR, NameVWAP, NameOBV, NameStochastic}
+}
+
+// ParseNames splits a comma separated list of indicator names. An empty
+// list selects every indicator.
+func ParseNames(list string) ([]string, error) {
+	if strings.TrimSpace(list) == "" {
+		return Names(), nil
+	}
+	var names []string
+	for _, name := range strings.Split(list, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if !valid(name) {
+			return nil, fmt.Errorf("unknown indicator %q", name)
+		}
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+func valid(name string) bool {
+	for _, known := range Names() {
+		if name == known {
+			return true
+		}
+	}
+	return false
+}
+
+// Readings are the indicator values at the close of one bar. Values that
+// were not requested or are still warming up are nil.
+type Readings struct {
+	Time  time.Time `json:"time"` // Close time of the bar
+	Close float64   `json:"close"`
+
+	EMA20      *float64 `json:"ema_20,omitempty"`
+	EMA50      *float64 `json:"ema_50,omitempty"`
+	RSI14      *float64 `json:"rsi_14,omitempty"`
+	MACD       *float64 `json:"macd,omitempty"`
+	MACDSignal *float64 `json:"macd_signal,omitempty"`
+	MACDHist   *float64 `json:"macd_hist,omitempty"`
+	BBUpper    *float64 `json:"bb_upper,omitempty"`
+	BBMiddle   *float64 `json:"bb_middle,omitempty"`
+	BBLower    *float64 `json:"bb_lower,omitempty"`
+	ATR14      *float64 `json:"atr_14,omitempty"`
+	VWAP       *float64 `json:"vwap,omitempty"`
+	OBV        *float64 `json:"obv,omitempty"`
+	StochK     *float64 `json:"stoch_k,omitempty"`
+	StochD     *float64 `json:"stoch_d,omitempty"`
+}
+
+// Compute returns the readings of the named indicators at every bar. bars
+// must be oldest first; each reading only depends on the bars up to its
+// own, so the series can be replayed without lookahead.
+func Compute(bars []db.MarketKline, names []string) ([]Readings, error) {
+	readings := make([]Readings, len(bars))
+	for i, bar := range bars {
+		readings[i] = Readings{Time: bar.CloseTime, Close: bar.ClosePrice}
+	}
+	closes := Closes(bars)
+
+	// set copies series into the field chosen by field for every bar
+	set := func(series []float64, field func(*Readings) **float64) {
+		for i, v := range series {
+			if !math.IsNaN(v) && !math.IsInf(v, 0) {
+				v := v
+				*field(&readings[i]) = &v
+			}
+		}
+	}
+
+	for _, name := range names {
+		switch name {
+		case NameEMA:
+			set(EMA(closes, EMAFastPeriod), func(r *Readings) **float64 { return &r.EMA20 })
+			set(EMA(closes, EMASlowPeriod), func(r *Readings) **float64 { return &r.EMA50 })
+		case NameRSI:
+			set(RSI(closes, RSIPeriod), func(r *Readings) **float64 { return &r.RSI14 })
+		case NameMACD:
+			macd, signal, hist := MACD(closes, MACDFast, MACDSlow, MACDSignal)
+			set(macd, func(r *Readings) **float64 { return &r.MACD })
+			set(signal, func(r *Readings) **float64 { return &r.MACDSignal })
+			set(hist, func(r *Readings) **float64 { return &r.MACDHist })
+		case NameBollinger:
+			middle, upper, lower := Bollinger(closes, BollingerPeriod, BollingerStdDevs)
+			set(upper, func(r *Readings) **float64 { return &r.BBUpper })
+			set(middle, func(r *Readings) **float64 { return &r.BBMiddle })
+			set(lower, func(r *Readings) **float64 { return &r.BBLower })
+		case NameATR:
+			set(ATR(bars, ATRPeriod), func(r *Readings) **float64 { return &r.ATR14 })
+		case NameVWAP:
+			set(VWAP(bars), func(r *Readings) **float64 { return &r.VWAP })
+		case NameOBV:
+			set(OBV(bars), func(r *Readings) **float64 { return &r.OBV })
+		case NameStochastic:
+			k, d := Stochastic(bars, StochasticK, StochasticD)
+			set(k, func(r *Readings) **float64 { return &r.StochK })
+			set(d, func(r *Readings) **float64 { return &r.StochD })
+		default:
+			return nil, fmt.Errorf("unknown indicator %q", name)
+		}
+	}
+	return readings, nil
+}
+
+// Latest returns every indicator's reading at the last bar, or nil when
+// there are no bars
+func Latest(bars []db.MarketKline) *Readings {
+	if len(bars) == 0 {
+		return nil
+	}
+	readings, _ := Compute(bars, Names())
+	return &readings[len(readings)-1]
+}
@@ -41,18 +41,19 @@ func Save(database *db.DB, botID string) error {
 		vecJSON, _ := json.Marshal(vec)
 
 		// Insert into events table
-		_, err := database.GetConn().Exec(
+		result, err := database.GetConn().Exec(
 			`INSERT INTO events (bot_id, ts, symbol, source, usd_val, text) VALUES (?, ?, ?, ?, ?, ?)`,
 			botID, now, "BTC", "news", 0.0, story.Title+" "+story.Body,
 		)
 		if err != nil {
 			return fmt.Errorf("failed to insert news event: %w", err)
 		}
+		eventID, _ := result.LastInsertId()
 
-		// Insert into event_vecs table
+		// Insert into event_vecs table, keyed by the event it embeds
 		_, err = database.GetConn().Exec(
 			`INSERT INTO event_vecs (id, bot_id, ts, sym, vec, text) VALUES (?, ?, ?, ?, ?, ?)`,
-			i+1, botID, now, "BTC", string(vecJSON), story.Title+" "+story.Body,
+			eventID, botID, now, "BTC", string(vecJSON), story.Title+" "+story.Body,
 		)
 		if err != nil {
 			return fmt.Errorf("failed to insert news vector: %w", err)
@@ -63,13 +64,14 @@ func Save(database *db.DB, botID string) error {
 	chainText := fmt.Sprintf("Active addresses: %d, Transactions: %d, Price: $%.2f",
 		metrics.ActiveAddresses, metrics.TxCount, metrics.Price)
 
-	_, err = database.GetConn().Exec(
+	result, err := database.GetConn().Exec(
 		`INSERT INTO events (bot_id, ts, symbol, source, usd_val, text) VALUES (?, ?, ?, ?, ?, ?)`,
 		botID, now, "BTC", "chain", metrics.Price, chainText,
 	)
 	if err != nil {
 		return fmt.Errorf("failed to insert chain event: %w", err)
 	}
+	eventID, _ := result.LastInsertId()
 
 	// Insert chain metrics vector
 	vec := createSimpleVector(chainText)
@@ -77,7 +79,7 @@ func Save(database *db.DB, botID string) error {
 
 	_, err = database.GetConn().Exec(
 		`INSERT INTO event_vecs (id, bot_id, ts, sym, vec, text) VALUES (?, ?, ?, ?, ?, ?)`,
-		1000, botID, now, "BTC", string(vecJSON), chainText,
+		eventID, botID, now, "BTC", string(vecJSON), chainText,
 	)
 	if err != nil {
 		return fmt.Errorf("failed to insert chain vector: %w", err)
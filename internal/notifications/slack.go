@@ -151,6 +151,30 @@ func (n *Notifier) NotifyError(botID, component, errorMsg string) error {
 	return n.SendSlackMessage(message)
 }
 
+// NotifyHalt sends a notification that a bot stopped trading, because a
+// risk limit tripped or the kill switch was thrown
+func (n *Notifier) NotifyHalt(botID, reason string) error {
+	if !n.config.IsSlackEnabled() {
+		return nil
+	}
+
+	message := SlackMessage{
+		Text:      "🛑 Trading Halted",
+		Username:  "SignalBot",
+		IconEmoji: ":octagonal_sign:",
+		Attachments: []SlackMessageAttachment{
+			{
+				Color:     "danger",
+				Title:     fmt.Sprintf("Bot %s stopped", botID),
+				Text:      fmt.Sprintf("Reason: %s", reason),
+				Timestamp: time.Now().Unix(),
+			},
+		},
+	}
+
+	return n.SendSlackMessage(message)
+}
+
 // IsEnabled returns true if Slack notifications are configured
 func (n *Notifier) IsEnabled() bool {
 	return n.config.IsSlackEnabled()
@@ -86,6 +86,16 @@ func TestNotifyError_Disabled(t *testing.T) {
 	}
 }
 
+func TestNotifyHalt_Disabled(t *testing.T) {
+	cfg := &config.Config{SlackWebhook: ""}
+	notifier := NewNotifier(cfg)
+
+	err := notifier.NotifyHalt("test-bot", "daily loss limit reached")
+	if err != nil {
+		t.Fatalf("expected no error when Slack is disabled, got: %v", err)
+	}
+}
+
 func TestSlackMessageStructure(t *testing.T) {
 	// Test that SlackMessage can be created with all fields
 	message := SlackMessage{
@@ -0,0 +1,288 @@
+// Package orderbook maintains local copies of exchange order books from a
+// depth snapshot and the diff-depth stream that follows it.
+package orderbook
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/adeilh/agentic_go_signals/internal/trader"
+)
+
+// ErrInsufficientDepth is returned by VWAP when the book cannot fill the
+// requested size
+var ErrInsufficientDepth = errors.New("insufficient depth")
+
+// Level is one price level of a book
+type Level struct {
+	Price float64 `json:"price"`
+	Qty   float64 `json:"qty"`
+}
+
+// Book is an order book for one symbol. Bids are kept best (highest) first
+// and asks best (lowest) first. It is safe for concurrent use.
+type Book struct {
+	symbol string
+
+	mu           sync.RWMutex
+	lastUpdateID int64
+	bids         []Level
+	asks         []Level
+	updatedAt    time.Time
+}
+
+// NewBook creates an empty book for symbol
+func NewBook(symbol string) *Book {
+	return &Book{symbol: symbol}
+}
+
+// Symbol returns the book's symbol
+func (b *Book) Symbol() string {
+	return b.symbol
+}
+
+// LastUpdateID returns the id of the last snapshot or diff applied
+func (b *Book) LastUpdateID() int64 {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.lastUpdateID
+}
+
+// UpdatedAt returns when the book last changed
+func (b *Book) UpdatedAt() time.Time {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.updatedAt
+}
+
+// Reset replaces the book's contents with a REST depth snapshot
+func (b *Book) Reset(snapshot trader.OrderBook) error {
+	bids, err := parseLevels(snapshot.Bids)
+	if err != nil {
+		return fmt.Errorf("invalid bids: %w", err)
+	}
+	asks, err := parseLevels(snapshot.Asks)
+	if err != nil {
+		return fmt.Errorf("invalid asks: %w", err)
+	}
+	sort.Slice(bids, func(i, j int) bool { return bids[i].Price > bids[j].Price })
+	sort.Slice(asks, func(i, j int) bool { return asks[i].Price < asks[j].Price })
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.lastUpdateID = snapshot.LastUpdateId
+	b.bids, b.asks = bids, asks
+	b.updatedAt = time.Now()
+	return nil
+}
+
+// apply sets every level of a diff event; a zero quantity removes the
+// level. Sequencing is the caller's job.
+func (b *Book) apply(event trader.WSDepthEvent) error {
+	bids, err := parseUpdates(event.Bids)
+	if err != nil {
+		return fmt.Errorf("invalid bids: %w", err)
+	}
+	asks, err := parseUpdates(event.Asks)
+	if err != nil {
+		return fmt.Errorf("invalid asks: %w", err)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, level := range bids {
+		b.bids = setLevel(b.bids, level, true)
+	}
+	for _, level := range asks {
+		b.asks = setLevel(b.asks, level, false)
+	}
+	b.lastUpdateID = event.FinalUpdateId
+	if event.EventTime > 0 {
+		b.updatedAt = time.UnixMilli(event.EventTime)
+	} else {
+		b.updatedAt = time.Now()
+	}
+	return nil
+}
+
+// BestBid returns the highest bid; ok is false when there are no bids
+func (b *Book) BestBid() (level Level, ok bool) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if len(b.bids) == 0 {
+		return Level{}, false
+	}
+	return b.bids[0], true
+}
+
+// BestAsk returns the lowest ask; ok is false when there are no asks
+func (b *Book) BestAsk() (level Level, ok bool) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if len(b.asks) == 0 {
+		return Level{}, false
+	}
+	return b.asks[0], true
+}
+
+// DepthAt returns the quantity an order on side could fill at price or
+// better: the asks up to price for a buy, the bids down to price for a sell
+func (b *Book) DepthAt(side string, price float64) float64 {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	var qty float64
+	for _, level := range b.opposite(side) {
+		if (side == trader.SideBuy && level.Price > price) || (side != trader.SideBuy && level.Price < price) {
+			break
+		}
+		qty += level.Qty
+	}
+	return qty
+}
+
+// Imbalance returns (bid qty - ask qty) / (bid qty + ask qty) over the top
+// levels of each side, from -1 (all asks) to 1 (all bids). levels <= 0
+// uses the whole book; an empty book has no imbalance.
+func (b *Book) Imbalance(levels int) float64 {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	bidQty := sumQty(top(b.bids, levels))
+	askQty := sumQty(top(b.asks, levels))
+	if bidQty+askQty == 0 {
+		return 0
+	}
+	return (bidQty - askQty) / (bidQty + askQty)
+}
+
+// VWAP returns the average price at which a market order on side would fill
+// qty by sweeping the opposite side of the book
+func (b *Book) VWAP(side string, qty float64) (float64, error) {
+	if qty <= 0 {
+		return 0, fmt.Errorf("quantity must be positive, got %v", qty)
+	}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	remaining, notional := qty, 0.0
+	for _, level := range b.opposite(side) {
+		take := min(level.Qty, remaining)
+		notional += take * level.Price
+		remaining -= take
+		if remaining <= 0 {
+			return notional / qty, nil
+		}
+	}
+	return 0, fmt.Errorf("%w: %s book has %v of %v", ErrInsufficientDepth, b.symbol, qty-remaining, qty)
+}
+
+// Top returns up to levels price levels of each side, best first, in the
+// [price, qty] string form of the Binance API. levels <= 0 returns every
+// level.
+func (b *Book) Top(levels int) (bids, asks [][]string) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return formatLevels(top(b.bids, levels)), formatLevels(top(b.asks, levels))
+}
+
+// opposite returns the side of the book an order on side trades against
+func (b *Book) opposite(side string) []Level {
+	if side == trader.SideBuy {
+		return b.asks
+	}
+	return b.bids
+}
+
+// setLevel sets level in levels, which are sorted descending for bids and
+// ascending for asks, removing it when its quantity is zero
+func setLevel(levels []Level, level Level, bids bool) []Level {
+	i := sort.Search(len(levels), func(i int) bool {
+		if bids {
+			return levels[i].Price <= level.Price
+		}
+		return levels[i].Price >= level.Price
+	})
+	found := i < len(levels) && levels[i].Price == level.Price
+
+	switch {
+	case level.Qty == 0 && found:
+		return append(levels[:i], levels[i+1:]...)
+	case level.Qty == 0:
+		return levels
+	case found:
+		levels[i].Qty = level.Qty
+		return levels
+	}
+	levels = append(levels, Level{})
+	copy(levels[i+1:], levels[i:])
+	levels[i] = level
+	return levels
+}
+
+// parseLevels parses Binance [price, qty] string pairs, dropping empty levels
+func parseLevels(raw [][]string) ([]Level, error) {
+	levels, err := parseUpdates(raw)
+	if err != nil {
+		return nil, err
+	}
+	kept := levels[:0]
+	for _, level := range levels {
+		if level.Qty > 0 {
+			kept = append(kept, level)
+		}
+	}
+	return kept, nil
+}
+
+// parseUpdates parses Binance [price, qty] string pairs, keeping zero
+// quantities, which remove a level
+func parseUpdates(raw [][]string) ([]Level, error) {
+	levels := make([]Level, 0, len(raw))
+	for _, entry := range raw {
+		if len(entry) < 2 {
+			return nil, fmt.Errorf("expected price and quantity, got %v", entry)
+		}
+		price, err := strconv.ParseFloat(entry[0], 64)
+		if err != nil || price <= 0 {
+			return nil, fmt.Errorf("invalid price %q", entry[0])
+		}
+		qty, err := strconv.ParseFloat(entry[1], 64)
+		if err != nil || qty < 0 {
+			return nil, fmt.Errorf("invalid quantity %q", entry[1])
+		}
+		levels = append(levels, Level{Price: price, Qty: qty})
+	}
+	return levels, nil
+}
+
+func formatLevels(levels []Level) [][]string {
+	raw := make([][]string, len(levels))
+	for i, level := range levels {
+		raw[i] = []string{
+			strconv.FormatFloat(level.Price, 'f', -1, 64),
+			strconv.FormatFloat(level.Qty, 'f', -1, 64),
+		}
+	}
+	return raw
+}
+
+func top(levels []Level, n int) []Level {
+	if n > 0 && n < len(levels) {
+		return levels[:n]
+	}
+	return levels
+}
+
+func sumQty(levels []Level) float64 {
+	var qty float64
+	for _, level := range levels {
+		qty += level.Qty
+	}
+	return qty
+}
@@ -0,0 +1,117 @@
+package orderbook
+
+import (
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/adeilh/agentic_go_signals/internal/trader"
+)
+
+func testBook(t *testing.T) *Book {
+	t.Helper()
+	book := NewBook("BTCUSDT")
+	err := book.Reset(trader.OrderBook{
+		LastUpdateId: 100,
+		Bids:         [][]string{{"99", "2"}, {"100", "1"}, {"98", "3"}},
+		Asks:         [][]string{{"102", "2"}, {"101", "1"}, {"103", "0"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return book
+}
+
+func TestBookQueries(t *testing.T) {
+	book := testBook(t)
+
+	if bid, ok := book.BestBid(); !ok || bid.Price != 100 || bid.Qty != 1 {
+		t.Fatalf("unexpected best bid %+v", bid)
+	}
+	if ask, ok := book.BestAsk(); !ok || ask.Price != 101 || ask.Qty != 1 {
+		t.Fatalf("unexpected best ask %+v", ask)
+	}
+	if _, asks := book.Top(0); len(asks) != 2 {
+		t.Fatalf("expected empty snapshot levels to be dropped, got %v", asks)
+	}
+
+	if depth := book.DepthAt(trader.SideBuy, 102); depth != 3 {
+		t.Errorf("expected 3 on the asks up to 102, got %v", depth)
+	}
+	if depth := book.DepthAt(trader.SideSell, 99); depth != 3 {
+		t.Errorf("expected 3 on the bids down to 99, got %v", depth)
+	}
+	if depth := book.DepthAt(trader.SideBuy, 100); depth != 0 {
+		t.Errorf("expected nothing below the best ask, got %v", depth)
+	}
+
+	// Top level: 1 bid against 1 ask; whole book: 6 bids against 3 asks
+	if imbalance := book.Imbalance(1); imbalance != 0 {
+		t.Errorf("expected a balanced top level, got %v", imbalance)
+	}
+	if imbalance := book.Imbalance(0); math.Abs(imbalance-1.0/3) > 1e-9 {
+		t.Errorf("expected 1/3, got %v", imbalance)
+	}
+
+	vwap, err := book.VWAP(trader.SideBuy, 2)
+	if err != nil || vwap != 101.5 {
+		t.Errorf("expected 101.5 to buy 2, got %v (%v)", vwap, err)
+	}
+	vwap, err = book.VWAP(trader.SideSell, 3)
+	if err != nil || math.Abs(vwap-(100+2*99)/3.0) > 1e-9 {
+		t.Errorf("unexpected VWAP to sell 3: %v (%v)", vwap, err)
+	}
+	if _, err := book.VWAP(trader.SideBuy, 4); !errors.Is(err, ErrInsufficientDepth) {
+		t.Errorf("expected insufficient depth, got %v", err)
+	}
+	if _, err := book.VWAP(trader.SideBuy, 0); err == nil {
+		t.Error("expected an error for a zero quantity")
+	}
+
+	empty := NewBook("ETHUSDT")
+	if _, ok := empty.BestBid(); ok {
+		t.Error("expected no best bid in an empty book")
+	}
+	if imbalance := empty.Imbalance(5); imbalance != 0 {
+		t.Errorf("expected no imbalance in an empty book, got %v", imbalance)
+	}
+}
+
+func TestBookApply(t *testing.T) {
+	book := testBook(t)
+	err := book.apply(trader.WSDepthEvent{
+		FirstUpdateId: 101,
+		FinalUpdateId: 105,
+		Bids:          [][]string{{"100", "0"}, {"100.5", "4"}, {"97", "0"}},
+		Asks:          [][]string{{"101", "5"}, {"104", "1"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bids, asks := book.Top(0)
+	wantBids := [][]string{{"100.5", "4"}, {"99", "2"}, {"98", "3"}}
+	wantAsks := [][]string{{"101", "5"}, {"102", "2"}, {"104", "1"}}
+	if !equalLevels(bids, wantBids) || !equalLevels(asks, wantAsks) {
+		t.Fatalf("unexpected book %v / %v", bids, asks)
+	}
+	if book.LastUpdateID() != 105 {
+		t.Fatalf("expected last update 105, got %d", book.LastUpdateID())
+	}
+
+	if err := book.apply(trader.WSDepthEvent{Bids: [][]string{{"x", "1"}}}); err == nil {
+		t.Fatal("expected an error for an invalid price")
+	}
+}
+
+func equalLevels(got, want [][]string) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	for i := range got {
+		if got[i][0] != want[i][0] || got[i][1] != want[i][1] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,225 @@
+package orderbook
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/adeilh/agentic_go_signals/internal/trader"
+)
+
+const (
+	// SnapshotLimit is the depth requested for the REST snapshot a book is
+	// built from
+	SnapshotLimit = 1000
+	// maxBuffered caps the diffs held while no snapshot can be applied; the
+	// oldest are dropped, which only forces another snapshot
+	maxBuffered = 1000
+)
+
+// snapshotBackoff spaces out snapshot attempts while a book cannot be
+// synced. Each costs heavy request weight, and diffs arrive several times a
+// second, so retrying on every diff would run into Binance's rate limits.
+var snapshotBackoff = trader.ReconnectConfig{
+	InitialBackoff: time.Second,
+	MaxBackoff:     time.Minute,
+	Multiplier:     2,
+	Jitter:         0.2,
+}
+
+// SnapshotSource fetches REST depth snapshots
+type SnapshotSource interface {
+	GetOrderBook(symbol string, limit int) (trader.OrderBook, error)
+}
+
+// SyncStats describes a Syncer's state
+type SyncStats struct {
+	Synced       bool      `json:"synced"`
+	LastUpdateID int64     `json:"last_update_id"`
+	Resyncs      int64     `json:"resyncs"` // Snapshots taken after a gap
+	Snapshots    int64     `json:"snapshots"`
+	Buffered     int       `json:"buffered"`
+	LastSync     time.Time `json:"last_sync"`
+	RetryAt      time.Time `json:"retry_at"` // Earliest next snapshot attempt while unsynced
+	LastError    string    `json:"last_error,omitempty"`
+}
+
+// Syncer keeps a Book in step with a symbol's diff-depth stream, following
+// Binance's procedure: buffer the diffs, fetch a snapshot, drop the diffs
+// the snapshot already contains, then apply the rest in sequence. A diff
+// that does not continue from the last one applied is a gap, after which
+// the book is rebuilt from a new snapshot.
+type Syncer struct {
+	book   *Book
+	source SnapshotSource
+	limit  int
+
+	mu       sync.Mutex
+	synced   bool
+	fetching bool // A snapshot request is in flight
+	buffered []trader.WSDepthEvent
+	stats    SyncStats
+	backoff  *trader.Backoff
+	now      func() time.Time
+	spawn    func(func()) // Runs snapshot requests off the caller's goroutine
+}
+
+// NewSyncer creates a syncer for symbol that takes snapshots from source
+func NewSyncer(symbol string, source SnapshotSource) *Syncer {
+	return &Syncer{
+		book:    NewBook(symbol),
+		source:  source,
+		limit:   SnapshotLimit,
+		backoff: trader.NewBackoff(snapshotBackoff),
+		now:     time.Now,
+		spawn:   func(f func()) { go f() },
+	}
+}
+
+// Book returns the synced book. Check Synced before trusting it.
+func (s *Syncer) Book() *Book {
+	return s.book
+}
+
+// Synced reports whether the book currently matches the exchange's
+func (s *Syncer) Synced() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.synced
+}
+
+// Stats returns the syncer's state
+func (s *Syncer) Stats() SyncStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stats := s.stats
+	stats.Synced = s.synced
+	stats.LastUpdateID = s.book.LastUpdateID()
+	stats.Buffered = len(s.buffered)
+	return stats
+}
+
+// Handle applies a diff-depth event. While the book is not synced the event
+// is buffered and a snapshot is requested on a goroutine of its own, so the
+// stream's reader, which other symbols and streams share, never waits on
+// it; the buffered diffs are applied once the snapshot arrives. After a
+// failed snapshot the next is requested only once a growing backoff, or the
+// wait a rate limit asks for, has passed. The error says why the book is
+// not synced after the event.
+func (s *Syncer) Handle(event trader.WSDepthEvent) error {
+	s.mu.Lock()
+	if s.synced {
+		err := s.applyLocked(event)
+		if err == nil {
+			s.mu.Unlock()
+			return nil
+		}
+		log.Printf("Order book %s: %v, resyncing", s.book.Symbol(), err)
+		s.synced = false
+		s.stats.Resyncs++
+	}
+
+	s.buffered = append(s.buffered, event)
+	if len(s.buffered) > maxBuffered {
+		s.buffered = s.buffered[len(s.buffered)-maxBuffered:]
+	}
+	fetch := !s.fetching && !s.now().Before(s.stats.RetryAt)
+	s.fetching = s.fetching || fetch
+	s.mu.Unlock()
+
+	if fetch {
+		s.spawn(s.snapshot)
+	}
+	return s.syncErr()
+}
+
+// syncErr says why the book is not synced, or returns nil if it is
+func (s *Syncer) syncErr() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch {
+	case s.synced:
+		return nil
+	case s.fetching:
+		return fmt.Errorf("order book not synced, waiting for a snapshot")
+	default:
+		return fmt.Errorf("order book not synced, next snapshot at %s: %s",
+			s.stats.RetryAt.Format(time.RFC3339), s.stats.LastError)
+	}
+}
+
+// snapshot requests a snapshot without holding the lock, so diffs keep
+// being buffered meanwhile, then builds the book from it
+func (s *Syncer) snapshot() {
+	snapshot, err := s.source.GetOrderBook(s.book.Symbol(), s.limit)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.fetching = false
+	if err != nil {
+		err = fmt.Errorf("failed to get order book snapshot: %w", err)
+	} else {
+		err = s.syncLocked(snapshot)
+	}
+	if err != nil {
+		s.stats.LastError = err.Error()
+		s.stats.RetryAt = s.now().Add(s.retryDelay(err))
+		log.Printf("Order book %s: %v", s.book.Symbol(), err)
+		return
+	}
+	s.backoff.Reset()
+	s.stats.RetryAt = time.Time{}
+}
+
+// retryDelay returns how long to wait after a failed snapshot: the next
+// backoff step, or longer when the venue said how long to back off for
+func (s *Syncer) retryDelay(err error) time.Duration {
+	delay := s.backoff.Next()
+	var rateLimit *trader.RateLimitError
+	if errors.As(err, &rateLimit) && rateLimit.RetryAfter > delay {
+		delay = rateLimit.RetryAfter
+	}
+	return delay
+}
+
+// syncLocked builds the book from snapshot and the buffered diffs
+func (s *Syncer) syncLocked(snapshot trader.OrderBook) error {
+	s.stats.Snapshots++
+
+	// The snapshot must not predate the first buffered diff, or the diffs
+	// in between are lost; keep buffering and try again later
+	if first := s.buffered[0]; snapshot.LastUpdateId < first.FirstUpdateId-1 {
+		return fmt.Errorf("snapshot %d predates buffered update %d", snapshot.LastUpdateId, first.FirstUpdateId)
+	}
+	if err := s.book.Reset(snapshot); err != nil {
+		return fmt.Errorf("invalid order book snapshot: %w", err)
+	}
+
+	buffered := s.buffered
+	s.buffered = nil
+	for _, event := range buffered {
+		if err := s.applyLocked(event); err != nil {
+			return err
+		}
+	}
+	s.synced = true
+	s.stats.LastSync = time.Now()
+	s.stats.LastError = ""
+	return nil
+}
+
+// applyLocked applies event if it continues the book's sequence. Diffs the
+// book already contains are skipped.
+func (s *Syncer) applyLocked(event trader.WSDepthEvent) error {
+	last := s.book.LastUpdateID()
+	if event.FinalUpdateId <= last {
+		return nil
+	}
+	if event.FirstUpdateId > last+1 {
+		return fmt.Errorf("gap between update %d and %d", last, event.FirstUpdateId)
+	}
+	return s.book.apply(event)
+}
@@ -0,0 +1,223 @@
+package orderbook
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/adeilh/agentic_go_signals/internal/trader"
+)
+
+// snapshots returns its books in turn, repeating the last
+type snapshots struct {
+	books    []trader.OrderBook
+	err      error
+	calls    int
+	attempts int
+}
+
+func (s *snapshots) GetOrderBook(symbol string, limit int) (trader.OrderBook, error) {
+	s.attempts++
+	if s.err != nil {
+		return trader.OrderBook{}, s.err
+	}
+	book := s.books[min(s.calls, len(s.books)-1)]
+	s.calls++
+	return book, nil
+}
+
+// inlineSyncer takes snapshots on the caller's goroutine, so each Handle
+// returns with the snapshot it requested applied
+func inlineSyncer(source SnapshotSource) *Syncer {
+	syncer := NewSyncer("BTCUSDT", source)
+	syncer.spawn = func(f func()) { f() }
+	return syncer
+}
+
+// slowSnapshots holds each snapshot until it is released
+type slowSnapshots struct {
+	book    trader.OrderBook
+	release chan struct{}
+}
+
+func (s *slowSnapshots) GetOrderBook(symbol string, limit int) (trader.OrderBook, error) {
+	<-s.release
+	return s.book, nil
+}
+
+func diff(first, final int64, bidPrice, bidQty string) trader.WSDepthEvent {
+	return trader.WSDepthEvent{
+		Symbol:        "BTCUSDT",
+		FirstUpdateId: first,
+		FinalUpdateId: final,
+		Bids:          [][]string{{bidPrice, bidQty}},
+	}
+}
+
+func TestSyncerSnapshotAndSequence(t *testing.T) {
+	source := &snapshots{books: []trader.OrderBook{{
+		LastUpdateId: 105,
+		Bids:         [][]string{{"100", "1"}},
+		Asks:         [][]string{{"101", "1"}},
+	}}}
+	syncer := inlineSyncer(source)
+
+	// Straddles the snapshot: 101-104 are already in it, 105-108 is applied
+	if err := syncer.Handle(diff(101, 108, "100", "3")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !syncer.Synced() || source.calls != 1 {
+		t.Fatalf("expected one snapshot and a synced book, got %d calls", source.calls)
+	}
+	// Already applied
+	if err := syncer.Handle(diff(100, 104, "100", "9")); err != nil {
+		t.Fatal(err)
+	}
+	if err := syncer.Handle(diff(109, 110, "99", "2")); err != nil {
+		t.Fatal(err)
+	}
+
+	bids, _ := syncer.Book().Top(0)
+	if !equalLevels(bids, [][]string{{"100", "3"}, {"99", "2"}}) {
+		t.Fatalf("unexpected bids %v", bids)
+	}
+	if stats := syncer.Stats(); stats.LastUpdateID != 110 || stats.Snapshots != 1 || stats.Resyncs != 0 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+}
+
+func TestSyncerResyncsOnGap(t *testing.T) {
+	source := &snapshots{books: []trader.OrderBook{
+		{LastUpdateId: 10, Bids: [][]string{{"100", "1"}}},
+		{LastUpdateId: 30, Bids: [][]string{{"100", "7"}}},
+	}}
+	syncer := inlineSyncer(source)
+
+	if err := syncer.Handle(diff(11, 12, "99", "1")); err != nil {
+		t.Fatal(err)
+	}
+	// 13 to 19 were missed; the next snapshot covers them
+	if err := syncer.Handle(diff(20, 31, "98", "1")); err != nil {
+		t.Fatalf("expected a resync, got %v", err)
+	}
+
+	bids, _ := syncer.Book().Top(0)
+	if !equalLevels(bids, [][]string{{"100", "7"}, {"98", "1"}}) {
+		t.Fatalf("expected the book rebuilt from the new snapshot, got %v", bids)
+	}
+	if stats := syncer.Stats(); !stats.Synced || stats.Resyncs != 1 || stats.Snapshots != 2 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+}
+
+func TestSyncerBuffersUntilSnapshotIsCurrent(t *testing.T) {
+	source := &snapshots{books: []trader.OrderBook{
+		{LastUpdateId: 5, Bids: [][]string{{"100", "1"}}},
+		{LastUpdateId: 21, Bids: [][]string{{"100", "2"}}},
+	}}
+	syncer := inlineSyncer(source)
+	now := time.Unix(1700000000, 0)
+	syncer.now = func() time.Time { return now }
+
+	// The first snapshot predates the diff, so the diff is kept
+	if err := syncer.Handle(diff(20, 20, "99", "1")); err == nil || syncer.Synced() {
+		t.Fatal("expected a stale snapshot to be rejected")
+	}
+	if stats := syncer.Stats(); stats.Buffered != 1 || stats.LastError == "" || !stats.RetryAt.After(now) {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+
+	now = now.Add(2 * time.Second)
+	if err := syncer.Handle(diff(21, 22, "98", "1")); err != nil {
+		t.Fatal(err)
+	}
+	bids, _ := syncer.Book().Top(0)
+	if !equalLevels(bids, [][]string{{"100", "2"}, {"98", "1"}}) {
+		t.Fatalf("unexpected bids %v", bids)
+	}
+
+	failing := inlineSyncer(&snapshots{err: errors.New("venue down")})
+	if err := failing.Handle(diff(1, 1, "99", "1")); err == nil || failing.Synced() {
+		t.Fatal("expected the snapshot error")
+	}
+}
+
+func TestSyncerBacksOffSnapshots(t *testing.T) {
+	source := &snapshots{err: &trader.RateLimitError{StatusCode: 429, RetryAfter: 30 * time.Second}}
+	syncer := inlineSyncer(source)
+	now := time.Unix(1700000000, 0)
+	syncer.now = func() time.Time { return now }
+
+	if err := syncer.Handle(diff(1, 1, "99", "1")); err == nil {
+		t.Fatal("expected the rate limit error")
+	}
+	// Diffs keep arriving but no snapshot is requested until the venue's
+	// wait is over
+	for id := int64(2); id < 10; id++ {
+		now = now.Add(time.Second)
+		if err := syncer.Handle(diff(id, id, "99", "1")); err == nil {
+			t.Fatal("expected the book to stay unsynced")
+		}
+	}
+	if source.calls != 0 || source.attempts != 1 {
+		t.Fatalf("expected one snapshot attempt while backing off, got %d", source.attempts)
+	}
+	if stats := syncer.Stats(); stats.Buffered != 9 || stats.RetryAt != time.Unix(1700000030, 0) {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+
+	source.err = nil
+	source.books = []trader.OrderBook{{LastUpdateId: 5, Bids: [][]string{{"100", "1"}}}}
+	now = now.Add(30 * time.Second)
+	if err := syncer.Handle(diff(10, 10, "98", "1")); err != nil {
+		t.Fatalf("expected the book to sync once the wait is over, got %v", err)
+	}
+	if stats := syncer.Stats(); !stats.Synced || !stats.RetryAt.IsZero() {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+}
+
+func TestSyncerSnapshotsOffTheStream(t *testing.T) {
+	source := &slowSnapshots{
+		book:    trader.OrderBook{LastUpdateId: 5, Bids: [][]string{{"100", "1"}}},
+		release: make(chan struct{}),
+	}
+	syncer := NewSyncer("BTCUSDT", source)
+
+	// Diffs are buffered while the snapshot is pending, without waiting
+	// for it
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for id := int64(4); id < 8; id++ {
+			if err := syncer.Handle(diff(id, id, "99", strconv.FormatInt(id, 10))); err == nil {
+				t.Error("expected the book unsynced until the snapshot arrives")
+			}
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Handle waited for the snapshot")
+	}
+	if stats := syncer.Stats(); stats.Buffered != 4 || stats.Synced {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+
+	close(source.release)
+	deadline := time.Now().Add(time.Second)
+	for !syncer.Synced() {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the book synced once the snapshot arrives, got %+v", syncer.Stats())
+		}
+		time.Sleep(time.Millisecond)
+	}
+	bids, _ := syncer.Book().Top(0)
+	if !equalLevels(bids, [][]string{{"100", "1"}, {"99", "7"}}) {
+		t.Fatalf("expected the buffered diffs applied, got %v", bids)
+	}
+	if err := syncer.Handle(diff(8, 8, "99", "8")); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,106 @@
+package paper
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/adeilh/agentic_go_signals/internal/db"
+	"github.com/adeilh/agentic_go_signals/internal/trader"
+)
+
+// epsilon absorbs float rounding when quantities are subtracted
+const epsilon = 1e-12
+
+// Level is one price level of an order book
+type Level struct {
+	Price float64 `json:"price"`
+	Qty   float64 `json:"qty"`
+}
+
+// Book is a depth snapshot the broker matches orders against. Bids are
+// sorted best (highest) first and asks best (lowest) first.
+type Book struct {
+	Symbol string    `json:"symbol"`
+	Bids   []Level   `json:"bids"`
+	Asks   []Level   `json:"asks"`
+	Time   time.Time `json:"time"`
+}
+
+// BookFromDepth converts a depth stream event into a book
+func BookFromDepth(event trader.WSDepthEvent) (Book, error) {
+	return newBook(event.Symbol, event.Bids, event.Asks, time.UnixMilli(event.EventTime))
+}
+
+// BookFromSnapshot converts a stored market_orderbook row into a book
+func BookFromSnapshot(snapshot db.MarketOrderBook) (Book, error) {
+	return newBook(snapshot.Symbol, snapshot.Bids, snapshot.Asks, snapshot.Timestamp)
+}
+
+func newBook(symbol string, bids, asks [][]string, ts time.Time) (Book, error) {
+	book := Book{Symbol: symbol, Time: ts}
+
+	var err error
+	if book.Bids, err = parseLevels(bids); err != nil {
+		return Book{}, fmt.Errorf("invalid bids: %w", err)
+	}
+	if book.Asks, err = parseLevels(asks); err != nil {
+		return Book{}, fmt.Errorf("invalid asks: %w", err)
+	}
+
+	sort.Slice(book.Bids, func(i, j int) bool { return book.Bids[i].Price > book.Bids[j].Price })
+	sort.Slice(book.Asks, func(i, j int) bool { return book.Asks[i].Price < book.Asks[j].Price })
+	return book, nil
+}
+
+// parseLevels parses Binance [price, qty] string pairs, dropping empty levels
+func parseLevels(raw [][]string) ([]Level, error) {
+	levels := make([]Level, 0, len(raw))
+	for _, entry := range raw {
+		if len(entry) < 2 {
+			return nil, fmt.Errorf("expected price and quantity, got %v", entry)
+		}
+		price, err := strconv.ParseFloat(entry[0], 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid price %q: %w", entry[0], err)
+		}
+		qty, err := strconv.ParseFloat(entry[1], 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid quantity %q: %w", entry[1], err)
+		}
+		if price > 0 && qty > 0 {
+			levels = append(levels, Level{Price: price, Qty: qty})
+		}
+	}
+	return levels, nil
+}
+
+// sweep takes up to qty from levels, best price first, stopping at levels
+// beyond limit (0 means no limit). It returns the fills and the liquidity
+// left over; levels itself is not modified.
+func sweep(levels []Level, qty, limit float64, buy bool) (fills []Level, rest []Level) {
+	remaining := qty
+	i := 0
+	for ; i < len(levels) && remaining > epsilon; i++ {
+		level := levels[i]
+		if limit > 0 && ((buy && level.Price > limit) || (!buy && level.Price < limit)) {
+			break
+		}
+		take := level.Qty
+		if take > remaining {
+			take = remaining
+		}
+		fills = append(fills, Level{Price: level.Price, Qty: take})
+		remaining -= take
+
+		if take < level.Qty {
+			// Partially consumed level stays at the top of the book
+			rest = append(rest, Level{Price: level.Price, Qty: level.Qty - take})
+			i++
+			break
+		}
+	}
+	rest = append(rest, levels[i:]...)
+	return fills, rest
+}
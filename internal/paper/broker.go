@@ -0,0 +1,508 @@
+// Package paper simulates order execution against real market depth so
+// strategies can trade live data without exchange credentials.
+package paper
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/adeilh/agentic_go_signals/internal/db"
+	"github.com/adeilh/agentic_go_signals/internal/trader"
+)
+
+// Order types and statuses, named as the exchange reports them
+const (
+	TypeMarket = "MARKET"
+	TypeLimit  = "LIMIT"
+
+	StatusNew             = "NEW"
+	StatusPartiallyFilled = "PARTIALLY_FILLED"
+	StatusFilled          = "FILLED"
+	StatusCanceled        = "CANCELED"
+	StatusExpired         = "EXPIRED"
+)
+
+var (
+	ErrNoBook              = errors.New("no fresh order book")
+	ErrInsufficientBalance = errors.New("insufficient balance")
+)
+
+// Config controls how the broker fills orders
+type Config struct {
+	FeeRate         float64       `json:"fee_rate"`         // Charged on fill notional, in the quote asset
+	SlippageBps     float64       `json:"slippage_bps"`     // Extra adverse impact on market order fills
+	QuoteAsset      string        `json:"quote_asset"`      // Asset new accounts are funded in
+	StartingBalance float64       `json:"starting_balance"` // Quote balance of a new account
+	MaxBookAge      time.Duration `json:"max_book_age"`     // Older books are not traded against; 0 disables the check
+}
+
+// DefaultConfig returns Binance's base spot fee and a 10000 USDT account
+func DefaultConfig() Config {
+	return Config{
+		FeeRate:         0.001,
+		QuoteAsset:      "USDT",
+		StartingBalance: 10000,
+		MaxBookAge:      30 * time.Second,
+	}
+}
+
+// SnapshotSource supplies stored order book snapshots for symbols without
+// live depth; *db.MarketDataStore implements it
+type SnapshotSource interface {
+	GetLatestOrderBook(symbol string) (*db.MarketOrderBook, error)
+}
+
+// Broker matches orders against the latest depth per symbol and keeps a
+// simulated account per bot. Accounts live in memory for the life of the
+// broker.
+type Broker struct {
+	cfg       Config
+	snapshots SnapshotSource
+	now       func() time.Time
+
+	mu          sync.Mutex
+	books       map[string]*Book
+	accounts    map[string]*Account
+	nextOrderID int64
+	nextTradeID int64
+}
+
+// NewBroker creates a broker. snapshots may be nil when only live depth is used.
+func NewBroker(cfg Config, snapshots SnapshotSource) *Broker {
+	if cfg.QuoteAsset == "" {
+		cfg.QuoteAsset = DefaultConfig().QuoteAsset
+	}
+	return &Broker{
+		cfg:       cfg,
+		snapshots: snapshots,
+		now:       time.Now,
+		books:     make(map[string]*Book),
+		accounts:  make(map[string]*Account),
+	}
+}
+
+// UpdateDepth is a depth stream handler that replaces the symbol's book
+func (b *Broker) UpdateDepth(event trader.WSDepthEvent) {
+	book, err := BookFromDepth(event)
+	if err != nil {
+		log.Printf("Ignoring depth update for %s: %v", event.Symbol, err)
+		return
+	}
+	b.UpdateBook(book)
+}
+
+// UpdateBook replaces the symbol's book and fills resting limit orders that
+// the new book crosses
+func (b *Broker) UpdateBook(book Book) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.books[book.Symbol] = &book
+	for _, account := range b.accounts {
+		account.matchResting(&book)
+	}
+}
+
+// Account returns the bot's simulated account, funding it on first use
+func (b *Broker) Account(botID string) *Account {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if account, ok := b.accounts[botID]; ok {
+		return account
+	}
+	account := &Account{
+		broker:   b,
+		botID:    botID,
+		balances: make(map[string]*Balance),
+		open:     make(map[int64]*order),
+		orders:   make(map[int64]*order),
+	}
+	account.balance(b.cfg.QuoteAsset).Free = b.cfg.StartingBalance
+	b.accounts[botID] = account
+	return account
+}
+
+// book returns a fresh book for symbol, falling back to the latest stored
+// snapshot when live depth is missing or stale. Callers hold b.mu.
+func (b *Broker) book(symbol string) (*Book, error) {
+	if book, ok := b.books[symbol]; ok && b.fresh(book) {
+		return book, nil
+	}
+
+	if b.snapshots != nil {
+		snapshot, err := b.snapshots.GetLatestOrderBook(symbol)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load order book snapshot: %w", err)
+		}
+		// Only replace the cached book with something newer, so liquidity
+		// already taken from a snapshot is not filled twice
+		if current, ok := b.books[symbol]; snapshot != nil && (!ok || snapshot.Timestamp.After(current.Time)) {
+			book, err := BookFromSnapshot(*snapshot)
+			if err != nil {
+				return nil, fmt.Errorf("invalid order book snapshot: %w", err)
+			}
+			b.books[symbol] = &book
+		}
+	}
+
+	if book, ok := b.books[symbol]; ok && b.fresh(book) {
+		return book, nil
+	}
+	return nil, fmt.Errorf("%w for %s", ErrNoBook, symbol)
+}
+
+func (b *Broker) fresh(book *Book) bool {
+	return b.cfg.MaxBookAge <= 0 || b.now().Sub(book.Time) <= b.cfg.MaxBookAge
+}
+
+// slip moves a market order fill price against the taker
+func (b *Broker) slip(price float64, buy bool) float64 {
+	if buy {
+		return price * (1 + b.cfg.SlippageBps/10000)
+	}
+	return price * (1 - b.cfg.SlippageBps/10000)
+}
+
+// Balance is an account's holding of one asset. Free base balances go
+// negative when a bot sells what it does not hold, which is how short
+// positions are represented.
+type Balance struct {
+	Asset  string  `json:"asset"`
+	Free   float64 `json:"free"`
+	Locked float64 `json:"locked"`
+}
+
+// Account is one bot's simulated balances and open orders. Its PlaceOrder
+// matches trader.Client.PlaceOrder so it can stand in for the exchange.
+type Account struct {
+	broker   *Broker
+	botID    string
+	balances map[string]*Balance
+	open     map[int64]*order
+	orders   map[int64]*order // Every order placed, for GetOrder
+}
+
+// order is the broker's working state for an order
+type order struct {
+	id        int64
+	symbol    string
+	base      string
+	quote     string
+	side      string
+	orderType string
+	qty       float64
+	price     float64 // Limit price, 0 for market orders
+	executed  float64
+	quoteQty  float64
+	reserved  float64 // Quote locked for an open limit buy
+	status    string
+	fills     []trader.Fill
+	time      time.Time
+}
+
+// PlaceOrder fills a market order by sweeping the opposite side of the book.
+// Whatever the visible depth cannot fill expires, as on the exchange.
+func (a *Account) PlaceOrder(symbol, side string, qty float64) (trader.Order, error) {
+	return a.place(symbol, side, TypeMarket, qty, 0)
+}
+
+// PlaceLimitOrder fills what crosses the book immediately and rests the
+// remainder until a later book update crosses its price or it is cancelled
+func (a *Account) PlaceLimitOrder(symbol, side string, qty, price float64) (trader.Order, error) {
+	if price <= 0 {
+		return trader.Order{}, fmt.Errorf("limit price must be positive")
+	}
+	return a.place(symbol, side, TypeLimit, qty, price)
+}
+
+// NewOrder places a market or GTC limit order given the way the exchange
+// takes it, so that orders placed through pretrade.Gate.PlaceLimit reach
+// the account. Stop orders and quote quantities are not simulated.
+func (a *Account) NewOrder(req trader.OrderRequest) (trader.Order, error) {
+	if err := req.Validate(); err != nil {
+		return trader.Order{}, err
+	}
+	switch {
+	case req.QuoteOrderQty > 0:
+		return trader.Order{}, fmt.Errorf("quote quantity orders are not simulated")
+	case req.Type == trader.OrderTypeMarket:
+		return a.PlaceOrder(req.Symbol, req.Side, req.Quantity)
+	case req.Type == trader.OrderTypeLimit && req.TimeInForce == trader.TimeInForceGTC:
+		return a.PlaceLimitOrder(req.Symbol, req.Side, req.Quantity, req.Price)
+	}
+	return trader.Order{}, fmt.Errorf("%s %s orders are not simulated", req.Type, req.TimeInForce)
+}
+
+func (a *Account) place(symbol, side, orderType string, qty, price float64) (trader.Order, error) {
+	side = strings.ToUpper(side)
+	if side != "BUY" && side != "SELL" {
+		return trader.Order{}, fmt.Errorf("side must be BUY or SELL, got %q", side)
+	}
+	if qty <= 0 {
+		return trader.Order{}, fmt.Errorf("quantity must be positive")
+	}
+	base, quote, err := trader.SplitSymbol(symbol)
+	if err != nil {
+		return trader.Order{}, err
+	}
+
+	b := a.broker
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	book, err := b.book(symbol)
+	if err != nil {
+		return trader.Order{}, err
+	}
+
+	o := &order{
+		symbol:    symbol,
+		base:      base,
+		quote:     quote,
+		side:      side,
+		orderType: orderType,
+		qty:       qty,
+		price:     price,
+		status:    StatusNew,
+		time:      b.now(),
+	}
+	buy := side == "BUY"
+	quoteBalance := a.balance(quote)
+
+	if orderType == TypeMarket {
+		levels := book.Bids
+		if buy {
+			levels = book.Asks
+		}
+		fills, rest := sweep(levels, qty, 0, buy)
+		for i := range fills {
+			fills[i].Price = b.slip(fills[i].Price, buy)
+		}
+		if buy {
+			if cost := notional(fills) * (1 + b.cfg.FeeRate); cost > quoteBalance.Free+epsilon {
+				return trader.Order{}, fmt.Errorf("%w: buying %.8f %s costs %.2f %s, %.2f available",
+					ErrInsufficientBalance, qty, base, cost, quote, quoteBalance.Free)
+			}
+			book.Asks = rest
+		} else {
+			book.Bids = rest
+		}
+
+		b.nextOrderID++
+		o.id = b.nextOrderID
+		a.orders[o.id] = o
+		a.settle(o, fills)
+		o.status = StatusExpired
+		if o.qty-o.executed <= epsilon {
+			o.status = StatusFilled
+		}
+		return o.snapshot(a.botID), nil
+	}
+
+	// Limit buys lock their worst-case cost up front
+	if buy {
+		o.reserved = qty * price * (1 + b.cfg.FeeRate)
+		if o.reserved > quoteBalance.Free+epsilon {
+			return trader.Order{}, fmt.Errorf("%w: buying %.8f %s at %.8f needs %.2f %s, %.2f available",
+				ErrInsufficientBalance, qty, base, price, o.reserved, quote, quoteBalance.Free)
+		}
+		quoteBalance.Free -= o.reserved
+		quoteBalance.Locked += o.reserved
+	}
+
+	b.nextOrderID++
+	o.id = b.nextOrderID
+	a.orders[o.id] = o
+	a.open[o.id] = o
+	a.match(o, book)
+	return o.snapshot(a.botID), nil
+}
+
+// CancelOrder cancels an open limit order and releases its locked funds
+func (a *Account) CancelOrder(orderID int64) (trader.Order, error) {
+	a.broker.mu.Lock()
+	defer a.broker.mu.Unlock()
+
+	o, ok := a.open[orderID]
+	if !ok {
+		return trader.Order{}, fmt.Errorf("order %d is not open", orderID)
+	}
+	o.status = StatusCanceled
+	a.close(o)
+	return o.snapshot(a.botID), nil
+}
+
+// GetOrder returns the current state of an order placed on the account
+func (a *Account) GetOrder(symbol string, orderID int64) (trader.Order, error) {
+	a.broker.mu.Lock()
+	defer a.broker.mu.Unlock()
+
+	o, ok := a.orders[orderID]
+	if !ok || o.symbol != symbol {
+		return trader.Order{}, fmt.Errorf("order %d does not exist on %s", orderID, symbol)
+	}
+	return o.snapshot(a.botID), nil
+}
+
+// OpenOrders returns the account's resting limit orders, oldest first
+func (a *Account) OpenOrders() []trader.Order {
+	a.broker.mu.Lock()
+	defer a.broker.mu.Unlock()
+
+	orders := make([]trader.Order, 0, len(a.open))
+	for _, o := range a.sortedOpen() {
+		orders = append(orders, o.snapshot(a.botID))
+	}
+	return orders
+}
+
+// Balances returns the account's balances sorted by asset
+func (a *Account) Balances() []Balance {
+	a.broker.mu.Lock()
+	defer a.broker.mu.Unlock()
+
+	balances := make([]Balance, 0, len(a.balances))
+	for _, balance := range a.balances {
+		balances = append(balances, *balance)
+	}
+	sort.Slice(balances, func(i, j int) bool { return balances[i].Asset < balances[j].Asset })
+	return balances
+}
+
+// matchResting fills open orders on the book's symbol in time priority
+func (a *Account) matchResting(book *Book) {
+	for _, o := range a.sortedOpen() {
+		if o.symbol == book.Symbol {
+			a.match(o, book)
+		}
+	}
+}
+
+// match fills an open limit order against the book, taking the liquidity it uses
+func (a *Account) match(o *order, book *Book) {
+	buy := o.side == "BUY"
+	levels := book.Bids
+	if buy {
+		levels = book.Asks
+	}
+
+	fills, rest := sweep(levels, o.qty-o.executed, o.price, buy)
+	if len(fills) == 0 {
+		return
+	}
+	if buy {
+		book.Asks = rest
+	} else {
+		book.Bids = rest
+	}
+
+	a.settle(o, fills)
+	if o.qty-o.executed <= epsilon {
+		o.status = StatusFilled
+		a.close(o)
+	} else {
+		o.status = StatusPartiallyFilled
+	}
+}
+
+// settle books fills against the account's balances
+func (a *Account) settle(o *order, fills []Level) {
+	b := a.broker
+	baseBalance, quoteBalance := a.balance(o.base), a.balance(o.quote)
+
+	for _, fill := range fills {
+		value := fill.Price * fill.Qty
+		fee := value * b.cfg.FeeRate
+
+		if o.side == "BUY" {
+			baseBalance.Free += fill.Qty
+			if o.orderType == TypeLimit {
+				quoteBalance.Locked -= value + fee
+				o.reserved -= value + fee
+			} else {
+				quoteBalance.Free -= value + fee
+			}
+		} else {
+			baseBalance.Free -= fill.Qty
+			quoteBalance.Free += value - fee
+		}
+
+		o.executed += fill.Qty
+		o.quoteQty += value
+		b.nextTradeID++
+		o.fills = append(o.fills, trader.Fill{
+			Price:           formatFloat(fill.Price),
+			Qty:             formatFloat(fill.Qty),
+			Commission:      formatFloat(fee),
+			CommissionAsset: o.quote,
+			TradeID:         b.nextTradeID,
+		})
+	}
+}
+
+// close removes an order from the book of open orders and releases the
+// quote it still has locked
+func (a *Account) close(o *order) {
+	if o.reserved > 0 {
+		quoteBalance := a.balance(o.quote)
+		quoteBalance.Locked -= o.reserved
+		quoteBalance.Free += o.reserved
+		o.reserved = 0
+	}
+	delete(a.open, o.id)
+}
+
+func (a *Account) sortedOpen() []*order {
+	orders := make([]*order, 0, len(a.open))
+	for _, o := range a.open {
+		orders = append(orders, o)
+	}
+	sort.Slice(orders, func(i, j int) bool { return orders[i].id < orders[j].id })
+	return orders
+}
+
+func (a *Account) balance(asset string) *Balance {
+	balance, ok := a.balances[asset]
+	if !ok {
+		balance = &Balance{Asset: asset}
+		a.balances[asset] = balance
+	}
+	return balance
+}
+
+// snapshot renders the order the way the exchange reports it
+func (o *order) snapshot(botID string) trader.Order {
+	return trader.Order{
+		Symbol:              o.symbol,
+		OrderID:             o.id,
+		ClientID:            fmt.Sprintf("paper-%s-%d", botID, o.id),
+		Side:                o.side,
+		Type:                o.orderType,
+		Quantity:            formatFloat(o.qty),
+		Price:               formatFloat(o.price),
+		ExecutedQty:         formatFloat(o.executed),
+		CummulativeQuoteQty: formatFloat(o.quoteQty),
+		Status:              o.status,
+		Time:                o.time.UnixMilli(),
+		Fills:               append([]trader.Fill(nil), o.fills...),
+	}
+}
+
+func notional(fills []Level) float64 {
+	total := 0.0
+	for _, fill := range fills {
+		total += fill.Price * fill.Qty
+	}
+	return total
+}
+
+func formatFloat(value float64) string {
+	return strconv.FormatFloat(value, 'f', 8, 64)
+}
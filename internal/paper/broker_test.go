@@ -0,0 +1,311 @@
+package paper
+
+import (
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/adeilh/agentic_go_signals/internal/db"
+	"github.com/adeilh/agentic_go_signals/internal/trader"
+)
+
+var testNow = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func testBroker(cfg Config) *Broker {
+	b := NewBroker(cfg, nil)
+	b.now = func() time.Time { return testNow }
+	b.UpdateBook(Book{
+		Symbol: "BTCUSDT",
+		Bids:   []Level{{Price: 99, Qty: 1}, {Price: 98, Qty: 2}},
+		Asks:   []Level{{Price: 101, Qty: 1}, {Price: 102, Qty: 2}},
+		Time:   testNow,
+	})
+	return b
+}
+
+func noFees() Config {
+	cfg := DefaultConfig()
+	cfg.FeeRate = 0
+	return cfg
+}
+
+func balanceOf(t *testing.T, a *Account, asset string) Balance {
+	t.Helper()
+	for _, balance := range a.Balances() {
+		if balance.Asset == asset {
+			return balance
+		}
+	}
+	return Balance{Asset: asset}
+}
+
+func executed(t *testing.T, order trader.Order) (float64, float64) {
+	t.Helper()
+	qty, price, err := order.Executed()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return qty, price
+}
+
+func TestMarketOrderSweepsBook(t *testing.T) {
+	account := testBroker(noFees()).Account("bot_1")
+
+	order, err := account.PlaceOrder("BTCUSDT", "buy", 2)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	qty, price := executed(t, order)
+	if order.Status != StatusFilled || qty != 2 || price != 101.5 {
+		t.Fatalf("expected 2 filled at 101.5, got %s %v @ %v", order.Status, qty, price)
+	}
+	if len(order.Fills) != 2 {
+		t.Fatalf("expected a fill per level, got %d", len(order.Fills))
+	}
+	if got := balanceOf(t, account, "USDT").Free; got != 10000-203 {
+		t.Fatalf("expected 9797 USDT, got %v", got)
+	}
+	if got := balanceOf(t, account, "BTC").Free; got != 2 {
+		t.Fatalf("expected 2 BTC, got %v", got)
+	}
+
+	// The liquidity taken is gone until the next depth update
+	order, _ = account.PlaceOrder("BTCUSDT", "BUY", 1)
+	if _, price := executed(t, order); price != 102 {
+		t.Fatalf("expected the next fill at 102, got %v", price)
+	}
+}
+
+func TestMarketOrderPartialFill(t *testing.T) {
+	account := testBroker(noFees()).Account("bot_1")
+
+	order, err := account.PlaceOrder("BTCUSDT", "SELL", 5)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	qty, _ := executed(t, order)
+	if order.Status != StatusExpired || qty != 3 {
+		t.Fatalf("expected 3 of 5 filled and the rest expired, got %s %v", order.Status, qty)
+	}
+	// Selling without holdings goes short
+	if got := balanceOf(t, account, "BTC").Free; got != -3 {
+		t.Fatalf("expected -3 BTC, got %v", got)
+	}
+}
+
+func TestFeesAndSlippage(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.SlippageBps = 10
+	account := testBroker(cfg).Account("bot_1")
+
+	order, err := account.PlaceOrder("BTCUSDT", "BUY", 1)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	_, price := executed(t, order)
+	if math.Abs(price-101.101) > 1e-9 {
+		t.Fatalf("expected slipped price 101.101, got %v", price)
+	}
+	if order.Fills[0].Commission != "0.10110100" || order.Fills[0].CommissionAsset != "USDT" {
+		t.Fatalf("unexpected commission %+v", order.Fills[0])
+	}
+	if fee, err := order.Fee(); err != nil || math.Abs(fee.Quote-0.101101) > 1e-9 || fee.Base != 0 {
+		t.Fatalf("expected fee 0.101101, got %v (%v)", fee, err)
+	}
+	if got := balanceOf(t, account, "USDT").Free; math.Abs(got-(10000-101.101*1.001)) > 1e-9 {
+		t.Fatalf("unexpected USDT balance %v", got)
+	}
+}
+
+func TestInsufficientBalance(t *testing.T) {
+	cfg := noFees()
+	cfg.StartingBalance = 100
+	account := testBroker(cfg).Account("bot_1")
+
+	if _, err := account.PlaceOrder("BTCUSDT", "BUY", 1); !errors.Is(err, ErrInsufficientBalance) {
+		t.Fatalf("expected insufficient balance, got %v", err)
+	}
+	if _, err := account.PlaceLimitOrder("BTCUSDT", "BUY", 2, 90); !errors.Is(err, ErrInsufficientBalance) {
+		t.Fatalf("expected insufficient balance for limit order, got %v", err)
+	}
+	if got := balanceOf(t, account, "USDT"); got.Free != 100 || got.Locked != 0 {
+		t.Fatalf("rejected orders must not move funds, got %+v", got)
+	}
+}
+
+func TestLimitOrderRestsAndFills(t *testing.T) {
+	broker := testBroker(noFees())
+	account := broker.Account("bot_1")
+
+	order, err := account.PlaceLimitOrder("BTCUSDT", "BUY", 2, 101)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if qty, _ := executed(t, order); order.Status != StatusPartiallyFilled || qty != 1 {
+		t.Fatalf("expected 1 of 2 filled at the limit, got %s %v", order.Status, qty)
+	}
+	if got := balanceOf(t, account, "USDT"); got.Locked != 101 || got.Free != 10000-202 {
+		t.Fatalf("expected the remainder's cost locked, got %+v", got)
+	}
+
+	// A book that crosses the limit fills the rest at the better price
+	broker.UpdateBook(Book{Symbol: "BTCUSDT", Asks: []Level{{Price: 100, Qty: 5}}, Time: testNow})
+	if open := account.OpenOrders(); len(open) != 0 {
+		t.Fatalf("expected no open orders, got %+v", open)
+	}
+	if got := balanceOf(t, account, "USDT"); got.Locked != 0 || got.Free != 10000-201 {
+		t.Fatalf("expected price improvement released, got %+v", got)
+	}
+	if got := balanceOf(t, account, "BTC").Free; got != 2 {
+		t.Fatalf("expected 2 BTC, got %v", got)
+	}
+}
+
+func TestNewOrder(t *testing.T) {
+	account := testBroker(noFees()).Account("bot_1")
+
+	order, err := account.NewOrder(trader.OrderRequest{Symbol: "BTCUSDT", Side: "buy", Type: "limit", Quantity: 1, Price: 90})
+	if err != nil || order.Status != StatusNew || order.Type != TypeLimit {
+		t.Fatalf("expected a resting limit order, got %+v (%v)", order, err)
+	}
+	order, err = account.NewOrder(trader.OrderRequest{Symbol: "BTCUSDT", Side: "SELL", Type: "MARKET", Quantity: 0.5})
+	if qty, _ := executed(t, order); err != nil || qty != 0.5 {
+		t.Fatalf("expected the market order filled, got %+v (%v)", order, err)
+	}
+
+	unsupported := []trader.OrderRequest{
+		{Symbol: "BTCUSDT", Side: "SELL", Type: "STOP_LOSS_LIMIT", Quantity: 1, Price: 95, StopPrice: 96},
+		{Symbol: "BTCUSDT", Side: "BUY", Type: "LIMIT", TimeInForce: "IOC", Quantity: 1, Price: 90},
+		{Symbol: "BTCUSDT", Side: "BUY", Type: "MARKET", QuoteOrderQty: 100},
+		{Symbol: "BTCUSDT", Side: "BUY", Type: "LIMIT", Quantity: 1},
+	}
+	for _, req := range unsupported {
+		if _, err := account.NewOrder(req); err == nil {
+			t.Errorf("expected %+v to be refused", req)
+		}
+	}
+}
+
+func TestCancelOrder(t *testing.T) {
+	account := testBroker(noFees()).Account("bot_1")
+
+	order, err := account.PlaceLimitOrder("BTCUSDT", "BUY", 1, 90)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if order.Status != StatusNew {
+		t.Fatalf("expected NEW, got %s", order.Status)
+	}
+
+	canceled, err := account.CancelOrder(order.OrderID)
+	if err != nil || canceled.Status != StatusCanceled {
+		t.Fatalf("expected cancel, got %+v, %v", canceled, err)
+	}
+	if got := balanceOf(t, account, "USDT"); got.Free != 10000 || got.Locked != 0 {
+		t.Fatalf("expected funds released, got %+v", got)
+	}
+	if _, err := account.CancelOrder(order.OrderID); err == nil {
+		t.Fatal("expected error cancelling a closed order")
+	}
+
+	// Closed orders can still be looked up
+	if got, err := account.GetOrder("BTCUSDT", order.OrderID); err != nil || got.Status != StatusCanceled {
+		t.Fatalf("expected the canceled order, got %+v, %v", got, err)
+	}
+	if _, err := account.GetOrder("ETHUSDT", order.OrderID); err == nil {
+		t.Fatal("expected error for an order on another symbol")
+	}
+}
+
+func TestAccountsAreIsolated(t *testing.T) {
+	broker := testBroker(noFees())
+	if _, err := broker.Account("bot_1").PlaceOrder("BTCUSDT", "BUY", 1); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := balanceOf(t, broker.Account("bot_2"), "USDT").Free; got != 10000 {
+		t.Fatalf("expected an untouched account, got %v", got)
+	}
+	if broker.Account("bot_1") != broker.Account("bot_1") {
+		t.Fatal("expected the same account for the same bot")
+	}
+}
+
+// snapshotStore serves a fixed stored order book
+type snapshotStore struct {
+	book *db.MarketOrderBook
+}
+
+func (s snapshotStore) GetLatestOrderBook(symbol string) (*db.MarketOrderBook, error) {
+	return s.book, nil
+}
+
+func TestStaleAndStoredBooks(t *testing.T) {
+	broker := testBroker(noFees())
+	broker.now = func() time.Time { return testNow.Add(time.Minute) }
+
+	if _, err := broker.Account("bot_1").PlaceOrder("BTCUSDT", "BUY", 1); !errors.Is(err, ErrNoBook) {
+		t.Fatalf("expected stale book error, got %v", err)
+	}
+
+	broker.snapshots = snapshotStore{book: &db.MarketOrderBook{
+		Symbol:    "BTCUSDT",
+		Bids:      [][]string{{"99.5", "1"}},
+		Asks:      [][]string{{"100.5", "1"}},
+		Timestamp: testNow.Add(50 * time.Second),
+	}}
+	order, err := broker.Account("bot_1").PlaceOrder("BTCUSDT", "BUY", 1)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, price := executed(t, order); price != 100.5 {
+		t.Fatalf("expected fill from the stored snapshot at 100.5, got %v", price)
+	}
+	// The stored snapshot's liquidity is used up, not reloaded
+	order, _ = broker.Account("bot_1").PlaceOrder("BTCUSDT", "BUY", 1)
+	if order.Status != StatusExpired || order.ExecutedQty != "0.00000000" {
+		t.Fatalf("expected nothing left to fill, got %+v", order)
+	}
+}
+
+func TestOrderValidation(t *testing.T) {
+	account := testBroker(noFees()).Account("bot_1")
+
+	if _, err := account.PlaceOrder("BTCUSDT", "HOLD", 1); err == nil {
+		t.Fatal("expected error for invalid side")
+	}
+	if _, err := account.PlaceOrder("BTCUSDT", "BUY", 0); err == nil {
+		t.Fatal("expected error for zero quantity")
+	}
+	if _, err := account.PlaceOrder("XYZ", "BUY", 1); err == nil {
+		t.Fatal("expected error for unknown quote asset")
+	}
+	if _, err := account.PlaceLimitOrder("BTCUSDT", "BUY", 1, 0); err == nil {
+		t.Fatal("expected error for missing limit price")
+	}
+	if _, err := account.PlaceOrder("ETHUSDT", "BUY", 1); !errors.Is(err, ErrNoBook) {
+		t.Fatalf("expected missing book error, got %v", err)
+	}
+}
+
+func TestBookFromDepth(t *testing.T) {
+	book, err := BookFromDepth(trader.WSDepthEvent{
+		Symbol:    "BTCUSDT",
+		EventTime: testNow.UnixMilli(),
+		Bids:      [][]string{{"98", "1"}, {"99", "2"}, {"97", "0"}},
+		Asks:      [][]string{{"102", "1"}, {"101", "2"}},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(book.Bids) != 2 || book.Bids[0].Price != 99 || book.Asks[0].Price != 101 {
+		t.Fatalf("expected sorted book without empty levels, got %+v", book)
+	}
+	if !book.Time.Equal(testNow) {
+		t.Fatalf("unexpected book time %v", book.Time)
+	}
+
+	if _, err := BookFromDepth(trader.WSDepthEvent{Bids: [][]string{{"x", "1"}}}); err == nil {
+		t.Fatal("expected error for invalid price")
+	}
+}
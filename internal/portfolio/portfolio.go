@@ -0,0 +1,379 @@
+// Package portfolio tracks each bot's positions and PnL, so that risk checks
+// see the exposure the bot actually carries and its performance can be
+// reported. Positions are replayed from the trades table; for live trading
+// the exchange account's holdings are taken into account as well.
+package portfolio
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/adeilh/agentic_go_signals/internal/db"
+	"github.com/adeilh/agentic_go_signals/internal/risk"
+	"github.com/adeilh/agentic_go_signals/internal/trader"
+)
+
+// dust is the quantity below which a position counts as closed, absorbing
+// float noise from netting fills
+const dust = 1e-9
+
+// TradeSource lists a bot's executed trades oldest first; *db.TradeStore
+// implements it
+type TradeSource interface {
+	ListFilled(botID string) ([]db.Trade, error)
+}
+
+// Account reports the exchange account's balances; exchange.Exchange
+// implements it
+type Account interface {
+	GetAccountInfo() (map[string]interface{}, error)
+}
+
+// PriceFunc returns the current price of symbol, or false when it is unknown
+type PriceFunc func(symbol string) (float64, bool)
+
+// Position is a bot's net position in one symbol
+type Position struct {
+	Symbol        string  `json:"symbol"`
+	Qty           float64 `json:"qty"`       // Positive long, negative short
+	AvgPrice      float64 `json:"avg_price"` // Average entry price of the open quantity
+	MarkPrice     float64 `json:"mark_price"`
+	Value         float64 `json:"value"`        // Qty at MarkPrice, signed like Qty
+	RealizedPnL   float64 `json:"realized_pnl"` // Net of Fees
+	Fees          float64 `json:"fees"`
+	UnrealizedPnL float64 `json:"unrealized_pnl"`
+	Trades        int     `json:"trades"`
+}
+
+// Open reports whether the position holds any quantity
+func (p Position) Open() bool {
+	return math.Abs(p.Qty) > dust
+}
+
+// Build nets trades, oldest first, into one position per symbol using
+// average cost: buys and sells that add to a position move its average
+// entry price, and those that reduce it realize PnL against that price.
+// Every trade's fee is realized as it is paid, and commission taken in the
+// base asset comes out of the quantity held. Positions are not marked;
+// MarkPrice is left zero.
+func Build(trades []db.Trade) map[string]*Position {
+	positions := make(ledger)
+	for _, trade := range trades {
+		positions.apply(trade)
+	}
+	return positions
+}
+
+// ledger is the positions trades have been netted into so far, by symbol
+type ledger map[string]*Position
+
+// apply nets trade into its symbol's position and returns the PnL it
+// realized, net of its fee, and whether it reduced the position
+func (l ledger) apply(trade db.Trade) (realized float64, reduced bool) {
+	if trade.Qty <= 0 {
+		return 0, false
+	}
+	p, ok := l[trade.Symbol]
+	if !ok {
+		p = &Position{Symbol: trade.Symbol}
+		l[trade.Symbol] = p
+	}
+	p.Trades++
+	p.Fees += trade.Fee
+	realized = -trade.Fee
+
+	qty := trade.Qty
+	if !buySide(trade.Side) {
+		qty = -qty
+	}
+	// Commission taken in the base asset never reached the account. Its
+	// value is already realized in Fee.
+	qty -= trade.BaseFee
+
+	if !p.Open() || sameSign(p.Qty, qty) {
+		size := math.Abs(p.Qty) + math.Abs(qty)
+		p.AvgPrice = (math.Abs(p.Qty)*p.AvgPrice + math.Abs(qty)*trade.Price) / size
+		p.Qty += qty
+		p.RealizedPnL += realized
+		return realized, false
+	}
+
+	size := math.Min(math.Abs(qty), math.Abs(p.Qty))
+	gross := size * (trade.Price - p.AvgPrice)
+	if p.Qty < 0 {
+		gross = -gross
+	}
+	realized += gross
+	p.RealizedPnL += realized
+	p.Qty += qty
+	if !p.Open() {
+		p.Qty, p.AvgPrice = 0, 0
+	} else if sameSign(p.Qty, qty) {
+		// Flipped through zero: the rest opens at this price
+		p.AvgPrice = trade.Price
+	}
+	return realized, true
+}
+
+// buySide reports whether a trade side adds to a long position. The
+// orchestrator records directions and the exchange order sides.
+func buySide(side string) bool {
+	switch strings.ToLower(side) {
+	case "long", "buy":
+		return true
+	}
+	return false
+}
+
+func sameSign(a, b float64) bool {
+	return (a > 0) == (b > 0)
+}
+
+// Portfolio marks bots' positions at current prices
+type Portfolio struct {
+	trades  TradeSource
+	prices  PriceFunc
+	account Account
+}
+
+// New creates a portfolio reading trades from trades and marking them with
+// prices. Positions without a price are marked at their entry price.
+func New(trades TradeSource, prices PriceFunc) *Portfolio {
+	return &Portfolio{trades: trades, prices: prices}
+}
+
+// SetAccount makes Exposure count the account's holdings, for bots trading
+// on the exchange. nil stops it.
+func (p *Portfolio) SetAccount(account Account) {
+	p.account = account
+}
+
+// Positions returns the bot's position in every symbol it has traded,
+// marked at the current price and sorted by symbol
+func (p *Portfolio) Positions(botID string) ([]Position, error) {
+	trades, err := p.trades.ListFilled(botID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load trades: %w", err)
+	}
+
+	positions := make([]Position, 0)
+	for _, position := range Build(trades) {
+		p.mark(position)
+		positions = append(positions, *position)
+	}
+	sort.Slice(positions, func(i, j int) bool { return positions[i].Symbol < positions[j].Symbol })
+	return positions, nil
+}
+
+// PnL is a bot's profit and loss over all its positions
+type PnL struct {
+	Realized          float64 `json:"realized"`
+	Unrealized        float64 `json:"unrealized"`
+	ConsecutiveLosses int     `json:"consecutive_losses"` // Trades in a row, up to the latest, that closed at a loss
+}
+
+// PnL totals the bot's realized and marked unrealized PnL, counting the
+// losing streak only over trades made from since on
+func (p *Portfolio) PnL(botID string, since time.Time) (PnL, error) {
+	trades, err := p.trades.ListFilled(botID)
+	if err != nil {
+		return PnL{}, fmt.Errorf("failed to load trades: %w", err)
+	}
+
+	var pnl PnL
+	positions := make(ledger)
+	for _, trade := range trades {
+		realized, reduced := positions.apply(trade)
+		if !reduced || trade.Ts.Before(since) {
+			continue
+		}
+		if realized < 0 {
+			pnl.ConsecutiveLosses++
+		} else {
+			pnl.ConsecutiveLosses = 0
+		}
+	}
+	for _, position := range positions {
+		p.mark(position)
+		pnl.Realized += position.RealizedPnL
+		pnl.Unrealized += position.UnrealizedPnL
+	}
+	return pnl, nil
+}
+
+// DailyPnL is what a bot realized over one UTC day
+type DailyPnL struct {
+	Date     string  `json:"date"`     // YYYY-MM-DD
+	Realized float64 `json:"realized"` // Net of Fees
+	Fees     float64 `json:"fees"`
+	Trades   int     `json:"trades"`
+}
+
+// Daily returns the bot's realized PnL for each UTC day from since on that
+// it traded, oldest first. Open positions are left to Positions.
+func (p *Portfolio) Daily(botID string, since time.Time) ([]DailyPnL, error) {
+	trades, err := p.trades.ListFilled(botID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load trades: %w", err)
+	}
+
+	days := make([]DailyPnL, 0)
+	positions := make(ledger)
+	for _, trade := range trades {
+		realized, _ := positions.apply(trade)
+		if trade.Qty <= 0 || trade.Ts.Before(since) {
+			continue
+		}
+		date := trade.Ts.UTC().Format("2006-01-02")
+		if len(days) == 0 || days[len(days)-1].Date != date {
+			days = append(days, DailyPnL{Date: date})
+		}
+		day := &days[len(days)-1]
+		day.Realized += realized
+		day.Fees += trade.Fee
+		day.Trades++
+	}
+	return days, nil
+}
+
+// EquityPoint is a bot's equity at one point in time
+type EquityPoint struct {
+	Ts         time.Time `json:"ts"`
+	Equity     float64   `json:"equity"` // Start plus Realized and Unrealized
+	Realized   float64   `json:"realized"`
+	Unrealized float64   `json:"unrealized"`
+}
+
+// EquityCurve returns the bot's equity, starting from start, after each of
+// its trades from since on and, last, now. Past points mark each open
+// position at its symbol's last trade price, as no other price is known
+// for them; the last is marked at the current prices.
+func (p *Portfolio) EquityCurve(botID string, start float64, since time.Time) ([]EquityPoint, error) {
+	trades, err := p.trades.ListFilled(botID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load trades: %w", err)
+	}
+
+	curve := make([]EquityPoint, 0)
+	positions := make(ledger)
+	lastPrices := make(map[string]float64)
+	var realized float64
+	for _, trade := range trades {
+		pnl, _ := positions.apply(trade)
+		if trade.Qty <= 0 {
+			continue
+		}
+		realized += pnl
+		lastPrices[trade.Symbol] = trade.Price
+		if trade.Ts.Before(since) {
+			continue
+		}
+
+		var unrealized float64
+		for symbol, position := range positions {
+			unrealized += position.Qty * (lastPrices[symbol] - position.AvgPrice)
+		}
+		curve = append(curve, equityPoint(trade.Ts, start, realized, unrealized))
+	}
+
+	var unrealized float64
+	for _, position := range positions {
+		p.mark(position)
+		unrealized += position.UnrealizedPnL
+	}
+	return append(curve, equityPoint(time.Now(), start, realized, unrealized)), nil
+}
+
+func equityPoint(ts time.Time, start, realized, unrealized float64) EquityPoint {
+	return EquityPoint{Ts: ts, Equity: start + realized + unrealized, Realized: realized, Unrealized: unrealized}
+}
+
+func (p *Portfolio) mark(position *Position) {
+	position.MarkPrice = position.AvgPrice
+	if price, ok := p.price(position.Symbol); ok {
+		position.MarkPrice = price
+	}
+	position.Value = position.Qty * position.MarkPrice
+	position.UnrealizedPnL = position.Qty * (position.MarkPrice - position.AvgPrice)
+}
+
+func (p *Portfolio) price(symbol string) (float64, bool) {
+	if p.prices == nil {
+		return 0, false
+	}
+	price, ok := p.prices(symbol)
+	return price, ok && price > 0
+}
+
+// Exposure returns the value of the bot's open positions per symbol. With
+// an account set, the account's holdings of each of symbols' base assets
+// count instead wherever the bot is flat or long by less, so fills the
+// trades table missed are not mistaken for room under the caps. Short
+// positions keep their own, negative, value: the holdings do not offset
+// them.
+func (p *Portfolio) Exposure(botID string, symbols []string) (risk.Exposure, error) {
+	positions, err := p.Positions(botID)
+	if err != nil {
+		return nil, err
+	}
+
+	exposure := make(risk.Exposure)
+	for _, position := range positions {
+		if position.Open() {
+			exposure[position.Symbol] = position.Value
+		}
+	}
+	if p.account == nil {
+		return exposure, nil
+	}
+
+	holdings, err := p.Holdings(symbols)
+	if err != nil {
+		return nil, err
+	}
+	for symbol, qty := range holdings {
+		price, ok := p.price(symbol)
+		if !ok {
+			continue
+		}
+		if current := exposure[symbol]; current >= 0 && qty*price > current {
+			exposure[symbol] = qty * price
+		}
+	}
+	return exposure, nil
+}
+
+// Holdings returns the account's free and locked quantity of each symbol's
+// base asset, leaving out symbols it holds none of
+func (p *Portfolio) Holdings(symbols []string) (map[string]float64, error) {
+	if p.account == nil {
+		return nil, fmt.Errorf("no exchange account")
+	}
+	info, err := p.account.GetAccountInfo()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load account: %w", err)
+	}
+
+	assets := make(map[string]float64)
+	for _, balance := range trader.ParseBalances(info) {
+		free, _ := strconv.ParseFloat(balance.Free, 64)
+		locked, _ := strconv.ParseFloat(balance.Locked, 64)
+		assets[balance.Asset] = free + locked
+	}
+
+	holdings := make(map[string]float64)
+	for _, symbol := range symbols {
+		base, _, err := trader.SplitSymbol(symbol)
+		if err != nil {
+			return nil, err
+		}
+		if qty := assets[base]; qty > 0 {
+			holdings[symbol] = qty
+		}
+	}
+	return holdings, nil
+}
@@ -0,0 +1,277 @@
+package portfolio
+
+import (
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/adeilh/agentic_go_signals/internal/db"
+)
+
+type tradeList []db.Trade
+
+func (l tradeList) ListFilled(botID string) ([]db.Trade, error) {
+	if l == nil {
+		return nil, errors.New("database connection is nil")
+	}
+	return l, nil
+}
+
+type account map[string]interface{}
+
+func (a account) GetAccountInfo() (map[string]interface{}, error) {
+	return a, nil
+}
+
+func near(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+func TestBuild(t *testing.T) {
+	positions := Build([]db.Trade{
+		{Symbol: "BTCUSDT", Side: "long", Qty: 1, Price: 100},
+		{Symbol: "BTCUSDT", Side: "BUY", Qty: 1, Price: 200},
+		{Symbol: "BTCUSDT", Side: "short", Qty: 0.5, Price: 250},
+		{Symbol: "ETHUSDT", Side: "SELL", Qty: 2, Price: 10},
+		{Symbol: "ETHUSDT", Side: "long", Qty: 3, Price: 8},
+		{Symbol: "SOLUSDT", Side: "long", Qty: 0, Price: 5}, // Never filled
+	})
+
+	btc := positions["BTCUSDT"]
+	if btc == nil || !near(btc.Qty, 1.5) || !near(btc.AvgPrice, 150) || !near(btc.RealizedPnL, 50) || btc.Trades != 3 {
+		t.Fatalf("unexpected BTC position %+v", btc)
+	}
+
+	// The short of 2 is covered with a 4 profit and the rest opens long at 8
+	eth := positions["ETHUSDT"]
+	if eth == nil || !near(eth.Qty, 1) || !near(eth.AvgPrice, 8) || !near(eth.RealizedPnL, 4) {
+		t.Fatalf("unexpected ETH position %+v", eth)
+	}
+
+	if _, ok := positions["SOLUSDT"]; ok {
+		t.Fatal("expected trades without quantity to be skipped")
+	}
+
+	closed := Build([]db.Trade{
+		{Symbol: "BTCUSDT", Side: "long", Qty: 0.3, Price: 100},
+		{Symbol: "BTCUSDT", Side: "short", Qty: 0.1, Price: 110},
+		{Symbol: "BTCUSDT", Side: "short", Qty: 0.2, Price: 90},
+	})["BTCUSDT"]
+	if closed.Open() || closed.AvgPrice != 0 || !near(closed.RealizedPnL, 1-2) {
+		t.Fatalf("expected a closed position, got %+v", closed)
+	}
+}
+
+func TestPortfolioExposure(t *testing.T) {
+	trades := tradeList{
+		{Symbol: "BTCUSDT", Side: "long", Qty: 0.1, Price: 50000},
+		{Symbol: "ETHUSDT", Side: "short", Qty: 1, Price: 3000},
+		{Symbol: "SOLUSDT", Side: "long", Qty: 10, Price: 100},
+		{Symbol: "SOLUSDT", Side: "short", Qty: 10, Price: 120},
+	}
+	prices := func(symbol string) (float64, bool) {
+		if symbol == "BTCUSDT" {
+			return 60000, true
+		}
+		return 0, false
+	}
+	p := New(trades, prices)
+
+	positions, err := p.Positions("bot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(positions) != 3 || positions[0].Symbol != "BTCUSDT" {
+		t.Fatalf("expected positions sorted by symbol, got %+v", positions)
+	}
+	if btc := positions[0]; btc.MarkPrice != 60000 || !near(btc.Value, 6000) || !near(btc.UnrealizedPnL, 1000) {
+		t.Fatalf("unexpected BTC mark %+v", btc)
+	}
+	// Without a price the position is marked at its entry
+	if eth := positions[1]; eth.MarkPrice != 3000 || !near(eth.Value, -3000) || eth.UnrealizedPnL != 0 {
+		t.Fatalf("unexpected ETH mark %+v", eth)
+	}
+
+	exposure, err := p.Exposure("bot", []string{"BTCUSDT", "ETHUSDT"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(exposure) != 2 || !near(exposure["BTCUSDT"], 6000) || !near(exposure["ETHUSDT"], -3000) {
+		t.Fatalf("expected the open positions only, got %v", exposure)
+	}
+
+	// The account holds more BTC than the bot's trades account for
+	p.SetAccount(account{"balances": []interface{}{
+		map[string]interface{}{"asset": "BTC", "free": "0.15", "locked": "0.05"},
+		map[string]interface{}{"asset": "USDT", "free": "1000", "locked": "0"},
+	}})
+	exposure, err = p.Exposure("bot", []string{"BTCUSDT", "ETHUSDT"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !near(exposure["BTCUSDT"], 12000) || !near(exposure["ETHUSDT"], -3000) {
+		t.Fatalf("expected the account's holdings to count, got %v", exposure)
+	}
+
+	if _, err := New(tradeList(nil), prices).Exposure("bot", nil); err == nil {
+		t.Fatal("expected an error when trades cannot be loaded")
+	}
+}
+
+func TestPortfolioExposureKeepsShorts(t *testing.T) {
+	trades := tradeList{
+		{Symbol: "ETHUSDT", Side: "short", Qty: 1, Price: 3000},
+		{Symbol: "BTCUSDT", Side: "long", Qty: 0.1, Price: 50000},
+	}
+	prices := func(symbol string) (float64, bool) {
+		return map[string]float64{"ETHUSDT": 3000, "BTCUSDT": 50000, "SOLUSDT": 100}[symbol], true
+	}
+	p := New(trades, prices)
+	// The account holds ETH the short does not account for, less BTC than
+	// the long and SOL the bot never traded
+	p.SetAccount(account{"balances": []interface{}{
+		map[string]interface{}{"asset": "ETH", "free": "2", "locked": "0"},
+		map[string]interface{}{"asset": "BTC", "free": "0.05", "locked": "0"},
+		map[string]interface{}{"asset": "SOL", "free": "3", "locked": "0"},
+	}})
+
+	exposure, err := p.Exposure("bot", []string{"ETHUSDT", "BTCUSDT", "SOLUSDT"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !near(exposure["ETHUSDT"], -3000) || !near(exposure["BTCUSDT"], 5000) || !near(exposure["SOLUSDT"], 300) {
+		t.Fatalf("expected the short kept and the holdings counted elsewhere, got %v", exposure)
+	}
+}
+
+func TestPortfolioPnL(t *testing.T) {
+	start := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	at := func(minutes int) time.Time { return start.Add(time.Duration(minutes) * time.Minute) }
+	trades := tradeList{
+		{Ts: at(0), Symbol: "BTCUSDT", Side: "long", Qty: 1, Price: 100},
+		{Ts: at(1), Symbol: "BTCUSDT", Side: "short", Qty: 1, Price: 90},
+		{Ts: at(2), Symbol: "ETHUSDT", Side: "long", Qty: 1, Price: 10},
+		{Ts: at(3), Symbol: "ETHUSDT", Side: "short", Qty: 1, Price: 12},
+		{Ts: at(4), Symbol: "ETHUSDT", Side: "long", Qty: 2, Price: 10},
+		{Ts: at(5), Symbol: "ETHUSDT", Side: "short", Qty: 1, Price: 9},
+		{Ts: at(6), Symbol: "BTCUSDT", Side: "short", Qty: 1, Price: 90},
+		{Ts: at(7), Symbol: "BTCUSDT", Side: "long", Qty: 1, Price: 95},
+	}
+	prices := func(symbol string) (float64, bool) {
+		if symbol == "ETHUSDT" {
+			return 8, true
+		}
+		return 0, false
+	}
+	p := New(trades, prices)
+
+	pnl, err := p.PnL("bot", start)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// -10 + 2 - 1 - 5 realized, and 1 ETH left from 10 marked at 8
+	if !near(pnl.Realized, -14) || !near(pnl.Unrealized, -2) {
+		t.Fatalf("unexpected PnL %+v", pnl)
+	}
+	if pnl.ConsecutiveLosses != 2 {
+		t.Fatalf("expected the last two closes to be a losing streak, got %d", pnl.ConsecutiveLosses)
+	}
+
+	// Losses before since don't count towards the streak
+	if pnl, _ := p.PnL("bot", at(6)); pnl.ConsecutiveLosses != 1 || !near(pnl.Realized, -14) {
+		t.Fatalf("expected only the last close to count, got %+v", pnl)
+	}
+}
+
+func TestBuildFees(t *testing.T) {
+	btc := Build([]db.Trade{
+		{Symbol: "BTCUSDT", Side: "BUY", Qty: 1, Price: 100, Fee: 0.1},
+		{Symbol: "BTCUSDT", Side: "SELL", Qty: 1, Price: 110, Fee: 0.11},
+	})["BTCUSDT"]
+	if btc.Open() || !near(btc.Fees, 0.21) || !near(btc.RealizedPnL, 10-0.21) {
+		t.Fatalf("expected fees realized against the position, got %+v", btc)
+	}
+}
+
+func TestBuildBaseFees(t *testing.T) {
+	// 0.001 BTC of the buy was kept as commission
+	buy := db.Trade{Symbol: "BTCUSDT", Side: "BUY", Qty: 1, Price: 100, Fee: 0.1, BaseFee: 0.001}
+	btc := Build([]db.Trade{buy})["BTCUSDT"]
+	if !near(btc.Qty, 0.999) || !near(btc.AvgPrice, 100) || !near(btc.RealizedPnL, -0.1) {
+		t.Fatalf("expected the commission out of the quantity held, got %+v", btc)
+	}
+
+	// Selling what is held closes the position, with the fee realized once
+	sell := db.Trade{Symbol: "BTCUSDT", Side: "SELL", Qty: 0.999, Price: 110, Fee: 0.10989}
+	btc = Build([]db.Trade{buy, sell})["BTCUSDT"]
+	if btc.Open() || !near(btc.RealizedPnL, 0.999*10-0.1-0.10989) {
+		t.Fatalf("expected the position closed, got %+v", btc)
+	}
+}
+
+func TestPortfolioDaily(t *testing.T) {
+	day := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+	p := New(tradeList{
+		{Ts: day, Symbol: "BTCUSDT", Side: "long", Qty: 1, Price: 100, Fee: 0.1},
+		{Ts: day.Add(time.Hour), Symbol: "BTCUSDT", Side: "short", Qty: 0.5, Price: 110, Fee: 0.05},
+		{Ts: day.Add(24 * time.Hour), Symbol: "BTCUSDT", Side: "short", Qty: 0.5, Price: 90},
+		{Ts: day.Add(48 * time.Hour), Symbol: "ETHUSDT", Side: "long", Qty: 0, Price: 10}, // Never filled
+	}, nil)
+
+	days, err := p.Daily("bot", time.Time{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(days) != 2 {
+		t.Fatalf("expected two trading days, got %+v", days)
+	}
+	if days[0].Date != "2024-03-01" || days[0].Trades != 2 || !near(days[0].Fees, 0.15) || !near(days[0].Realized, 5-0.15) {
+		t.Errorf("unexpected first day %+v", days[0])
+	}
+	if days[1].Date != "2024-03-02" || days[1].Trades != 1 || !near(days[1].Realized, -5) {
+		t.Errorf("unexpected second day %+v", days[1])
+	}
+
+	// Days before since are left out but still priced the later trades
+	days, err = p.Daily("bot", day.Add(12*time.Hour))
+	if err != nil || len(days) != 1 || !near(days[0].Realized, -5) {
+		t.Fatalf("expected only the second day, got %+v (%v)", days, err)
+	}
+
+	if _, err := New(tradeList(nil), nil).Daily("bot", time.Time{}); err == nil {
+		t.Fatal("expected error when trades cannot be loaded")
+	}
+}
+
+func TestPortfolioEquityCurve(t *testing.T) {
+	start := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+	prices := func(symbol string) (float64, bool) { return 130, symbol == "BTCUSDT" }
+	p := New(tradeList{
+		{Ts: start, Symbol: "BTCUSDT", Side: "long", Qty: 2, Price: 100, Fee: 1},
+		{Ts: start.Add(time.Hour), Symbol: "BTCUSDT", Side: "short", Qty: 1, Price: 120},
+	}, prices)
+
+	curve, err := p.EquityCurve("bot", 1000, time.Time{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(curve) != 3 {
+		t.Fatalf("expected a point per trade and one for now, got %+v", curve)
+	}
+	if !near(curve[0].Equity, 999) || !near(curve[0].Unrealized, 0) {
+		t.Errorf("unexpected first point %+v", curve[0])
+	}
+	// The open half is marked at the last trade's 120
+	if !near(curve[1].Realized, 19) || !near(curve[1].Unrealized, 20) || !near(curve[1].Equity, 1039) {
+		t.Errorf("unexpected second point %+v", curve[1])
+	}
+	if !near(curve[2].Unrealized, 30) || !near(curve[2].Equity, 1049) {
+		t.Errorf("expected the last point marked at the current price, got %+v", curve[2])
+	}
+
+	curve, err = p.EquityCurve("bot", 1000, start.Add(time.Minute))
+	if err != nil || len(curve) != 2 || !near(curve[0].Realized, 19) {
+		t.Fatalf("expected points from since on, got %+v (%v)", curve, err)
+	}
+}
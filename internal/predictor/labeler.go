@@ -0,0 +1,203 @@
+package predictor
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/adeilh/agentic_go_signals/internal/db"
+)
+
+const (
+	// labelInterval controls how often unlabeled predictions are checked
+	labelInterval = 5 * time.Minute
+
+	// labelBatchSize bounds how many predictions are read per query
+	labelBatchSize = 500
+
+	// labelMaxAge stops retrying predictions older than the kline retention
+	labelMaxAge = 30 * 24 * time.Hour
+
+	// priceTolerance is how far back a stored price may be from the requested time
+	priceTolerance = 5 * time.Minute
+)
+
+// Labeler fills predictions.fwd_ret with the realized return of the symbol
+// between the prediction timestamp and a fixed horizon after it
+type Labeler struct {
+	db      *db.DB
+	horizon time.Duration
+}
+
+// NewLabeler creates a labeler for the given forward-return horizon
+func NewLabeler(database *db.DB, horizon time.Duration) (*Labeler, error) {
+	if database == nil || database.GetConn() == nil {
+		return nil, fmt.Errorf("database connection is nil")
+	}
+	if horizon <= 0 {
+		return nil, fmt.Errorf("horizon must be positive")
+	}
+
+	return &Labeler{db: database, horizon: horizon}, nil
+}
+
+// RunLabeler labels predictions at regular intervals until ctx is cancelled
+func RunLabeler(ctx context.Context, database *db.DB, horizon time.Duration) error {
+	labeler, err := NewLabeler(database, horizon)
+	if err != nil {
+		return err
+	}
+
+	ticker := time.NewTicker(labelInterval)
+	defer ticker.Stop()
+
+	for {
+		labeled, err := labeler.LabelPending()
+		if err != nil {
+			log.Printf("Forward-return labeling failed: %v", err)
+		} else if labeled > 0 {
+			log.Printf("Labeled %d predictions with %s forward returns", labeled, horizon)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// LabelPending labels every prediction whose horizon has elapsed and for
+// which prices exist at both ends, returning how many were labeled.
+// Predictions without price data stay NULL and are retried until they age
+// out of the kline retention window.
+func (l *Labeler) LabelPending() (int, error) {
+	now := time.Now()
+	cutoff := now.Add(-l.horizon)
+	oldest := now.Add(-labelMaxAge)
+
+	query := `
+		SELECT bot_id, id, ts, symbol
+		FROM predictions
+		WHERE fwd_ret IS NULL AND ts <= ? AND ts >= ?
+			AND (ts > ? OR (ts = ? AND (bot_id > ? OR (bot_id = ? AND id > ?))))
+		ORDER BY ts, bot_id, id
+		LIMIT ?
+	`
+
+	type pending struct {
+		botID  string
+		id     uint
+		ts     time.Time
+		symbol string
+	}
+
+	labeled := 0
+	cursorTs, cursorBot, cursorID := oldest.Add(-time.Second), "", uint(0)
+
+	for {
+		rows, err := l.db.GetConn().Query(query, cutoff, oldest,
+			cursorTs, cursorTs, cursorBot, cursorBot, cursorID, labelBatchSize)
+		if err != nil {
+			return labeled, fmt.Errorf("failed to query unlabeled predictions: %w", err)
+		}
+
+		var batch []pending
+		for rows.Next() {
+			var p pending
+			if err := rows.Scan(&p.botID, &p.id, &p.ts, &p.symbol); err != nil {
+				rows.Close()
+				return labeled, fmt.Errorf("failed to scan prediction: %w", err)
+			}
+			batch = append(batch, p)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return labeled, err
+		}
+
+		for _, p := range batch {
+			ok, err := l.label(p.botID, p.id, p.symbol, p.ts)
+			if err != nil {
+				return labeled, err
+			}
+			if ok {
+				labeled++
+			}
+		}
+
+		if len(batch) < labelBatchSize {
+			return labeled, nil
+		}
+		last := batch[len(batch)-1]
+		cursorTs, cursorBot, cursorID = last.ts, last.botID, last.id
+	}
+}
+
+// label computes and stores the forward return for a single prediction,
+// reporting false if prices are missing at either end of the horizon
+func (l *Labeler) label(botID string, id uint, symbol string, ts time.Time) (bool, error) {
+	entry, ok, err := l.priceAt(symbol, ts)
+	if err != nil || !ok {
+		return false, err
+	}
+
+	exit, ok, err := l.priceAt(symbol, ts.Add(l.horizon))
+	if err != nil || !ok {
+		return false, err
+	}
+
+	_, err = l.db.GetConn().Exec(
+		`UPDATE predictions SET fwd_ret = ? WHERE bot_id = ? AND id = ?`,
+		forwardReturn(entry, exit), botID, id,
+	)
+	if err != nil {
+		return false, fmt.Errorf("failed to update forward return: %w", err)
+	}
+	return true, nil
+}
+
+// priceAt returns the last known price of a symbol at time t, preferring the
+// close of the latest finished 1m kline and falling back to ticker snapshots
+func (l *Labeler) priceAt(symbol string, t time.Time) (float64, bool, error) {
+	var price float64
+
+	err := l.db.GetConn().QueryRow(`
+		SELECT close_price FROM market_klines
+		WHERE symbol = ? AND interval_type = '1m' AND close_time <= ? AND close_time >= ?
+		ORDER BY close_time DESC
+		LIMIT 1`,
+		symbol, t, t.Add(-priceTolerance),
+	).Scan(&price)
+	if err == nil {
+		return price, true, nil
+	}
+	if err != sql.ErrNoRows {
+		return 0, false, fmt.Errorf("failed to query kline price: %w", err)
+	}
+
+	err = l.db.GetConn().QueryRow(`
+		SELECT price FROM market_prices
+		WHERE symbol = ? AND ts <= ? AND ts >= ?
+		ORDER BY ts DESC
+		LIMIT 1`,
+		symbol, t, t.Add(-priceTolerance),
+	).Scan(&price)
+	if err == sql.ErrNoRows {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to query snapshot price: %w", err)
+	}
+	return price, true, nil
+}
+
+// forwardReturn is the fractional price change from entry to exit, e.g. 0.01 for +1%
+func forwardReturn(entry, exit float64) float64 {
+	if entry <= 0 {
+		return 0
+	}
+	return (exit - entry) / entry
+}
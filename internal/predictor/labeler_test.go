@@ -0,0 +1,37 @@
+package predictor
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/adeilh/agentic_go_signals/internal/db"
+)
+
+func TestNewLabeler(t *testing.T) {
+	// Test with nil database
+	_, err := NewLabeler(nil, time.Hour)
+	if err == nil {
+		t.Fatal("expected error with nil database")
+	}
+
+	// Test with empty database
+	_, err = NewLabeler(&db.DB{}, time.Hour)
+	if err == nil {
+		t.Fatal("expected error with nil connection")
+	}
+
+	t.Log("NewLabeler properly validates database connection")
+}
+
+func TestForwardReturn(t *testing.T) {
+	if ret := forwardReturn(100, 101); math.Abs(ret-0.01) > 1e-9 {
+		t.Errorf("expected 0.01, got %f", ret)
+	}
+	if ret := forwardReturn(100, 95); math.Abs(ret+0.05) > 1e-9 {
+		t.Errorf("expected -0.05, got %f", ret)
+	}
+	if ret := forwardReturn(0, 95); ret != 0 {
+		t.Errorf("expected 0 for zero entry price, got %f", ret)
+	}
+}
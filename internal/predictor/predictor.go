@@ -8,39 +8,39 @@ import (
 
 	"github.com/adeilh/agentic_go_signals/internal/db"
 	"github.com/adeilh/agentic_go_signals/internal/ingest"
-	"github.com/adeilh/agentic_go_signals/internal/kimi"
+	"github.com/adeilh/agentic_go_signals/internal/strategy"
 )
 
-func Generate(database *db.DB, kimiClient *kimi.Client, botID, symbol string) (*db.Prediction, error) {
+// Generate asks a strategy for a call on snap.Symbol and stores it as a
+// prediction. Recent events for the bot are loaded into the snapshot when
+// the caller has not supplied them.
+func Generate(database *db.DB, strat strategy.Strategy, botID string, snap strategy.Snapshot) (*db.Prediction, error) {
 	if database == nil || database.GetConn() == nil {
 		return nil, fmt.Errorf("database connection is nil")
 	}
 
-	if kimiClient == nil {
-		return nil, fmt.Errorf("kimi client is nil")
+	if strat == nil {
+		return nil, fmt.Errorf("strategy is nil")
 	}
 
-	// Get recent events for context
-	events, err := ingest.GetRecentEvents(database, botID, symbol, 20)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get recent events: %w", err)
+	if snap.Events == nil {
+		events, err := ingest.GetRecentEvents(database, botID, eventSymbol(snap.Symbol), 20)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get recent events: %w", err)
+		}
+		snap.Events = events
 	}
 
-	// Prepare context for Kimi
-	newsData := buildNewsContext(events)
-	chainData := buildChainContext(events)
-
-	// Generate prediction using Kimi
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
-	prediction, err := kimiClient.GeneratePrediction(ctx, symbol, newsData, chainData)
+	decision, err := strat.Decide(ctx, snap)
 	if err != nil {
-		// If Kimi fails, generate a conservative fallback prediction
-		prediction = kimi.Prediction{
-			Dir:   "FLAT",
-			Conv:  30,
-			Logic: "Unable to generate AI prediction, defaulting to neutral",
+		// If the strategy fails, generate a conservative fallback prediction
+		decision = strategy.Decision{
+			Action:     strategy.Flat,
+			Confidence: 30,
+			Reasoning:  fmt.Sprintf("Unable to generate %s prediction, defaulting to neutral", strat.Name()),
 		}
 	}
 
@@ -52,7 +52,7 @@ func Generate(database *db.DB, kimiClient *kimi.Client, botID, symbol string) (*
 		VALUES (?, ?, ?, ?, ?, ?)
 	`
 
-	result, err := database.GetConn().Exec(query, botID, now, symbol, prediction.Dir, prediction.Conv, prediction.Logic)
+	result, err := database.GetConn().Exec(query, botID, now, snap.Symbol, decision.Action, decision.Confidence, decision.Reasoning)
 	if err != nil {
 		return nil, fmt.Errorf("failed to insert prediction: %w", err)
 	}
@@ -63,50 +63,24 @@ func Generate(database *db.DB, kimiClient *kimi.Client, botID, symbol string) (*
 		ID:     uint(id),
 		BotID:  botID,
 		Ts:     now,
-		Symbol: symbol,
-		Dir:    prediction.Dir,
-		Conv:   prediction.Conv,
-		Logic:  prediction.Logic,
+		Symbol: snap.Symbol,
+		Dir:    decision.Action,
+		Conv:   decision.Confidence,
+		Logic:  decision.Reasoning,
 	}
 
 	return dbPrediction, nil
 }
 
-func buildNewsContext(events []db.Event) string {
-	var newsEvents []string
-
-	for _, event := range events {
-		if event.Source == "news" && len(newsEvents) < 5 {
-			// Truncate long text
-			text := event.Text
-			if len(text) > 200 {
-				text = text[:200] + "..."
-			}
-			newsEvents = append(newsEvents, text)
+// eventSymbol maps a trading pair such as BTCUSDT to the asset symbol that
+// ingested events are stored under
+func eventSymbol(symbol string) string {
+	for _, quote := range []string{"USDT", "BUSD", "USDC", "USD"} {
+		if len(symbol) > len(quote) && strings.HasSuffix(symbol, quote) {
+			return strings.TrimSuffix(symbol, quote)
 		}
 	}
-
-	if len(newsEvents) == 0 {
-		return "No recent news available"
-	}
-
-	return strings.Join(newsEvents, "\n")
-}
-
-func buildChainContext(events []db.Event) string {
-	var chainEvents []string
-
-	for _, event := range events {
-		if event.Source == "chain" && len(chainEvents) < 3 {
-			chainEvents = append(chainEvents, event.Text)
-		}
-	}
-
-	if len(chainEvents) == 0 {
-		return "No recent chain data available"
-	}
-
-	return strings.Join(chainEvents, "\n")
+	return symbol
 }
 
 func GetLatest(database *db.DB, botID, symbol string) (*db.Prediction, error) {
@@ -167,3 +141,80 @@ func GetHistory(database *db.DB, botID, symbol string, limit int) ([]db.Predicti
 
 	return predictions, nil
 }
+
+// List retrieves predictions matching the filter, newest first. When
+// actionableOnly is set, FLAT predictions are excluded so the result is the
+// bot's LONG/SHORT signal stream.
+func List(database *db.DB, filter db.QueryFilter, actionableOnly bool) ([]db.Prediction, error) {
+	if database == nil || database.GetConn() == nil {
+		return nil, fmt.Errorf("database connection is nil")
+	}
+
+	var extra []string
+	if actionableOnly {
+		extra = append(extra, "dir <> 'FLAT'")
+	}
+	where, args := filter.Where("ts", extra...)
+	limit, offset := filter.Page(50)
+
+	query := `
+		SELECT id, bot_id, ts, symbol, dir, conv, logic, fwd_ret
+		FROM predictions 
+		` + where + `
+		ORDER BY ts DESC, id DESC
+		LIMIT ? OFFSET ?
+	`
+
+	rows, err := database.GetConn().Query(query, append(args, limit, offset)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query predictions: %w", err)
+	}
+	defer rows.Close()
+
+	predictions := []db.Prediction{}
+	for rows.Next() {
+		var prediction db.Prediction
+		err := rows.Scan(&prediction.ID, &prediction.BotID, &prediction.Ts, &prediction.Symbol,
+			&prediction.Dir, &prediction.Conv, &prediction.Logic, &prediction.FwdRet)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan prediction: %w", err)
+		}
+		predictions = append(predictions, prediction)
+	}
+
+	return predictions, rows.Err()
+}
+
+// Range returns every prediction a bot made for a symbol with ts in
+// [from, to], oldest first, for replaying a bot's calls over history
+func Range(database *db.DB, botID, symbol string, from, to time.Time) ([]db.Prediction, error) {
+	if database == nil || database.GetConn() == nil {
+		return nil, fmt.Errorf("database connection is nil")
+	}
+
+	query := `
+		SELECT id, bot_id, ts, symbol, dir, conv, logic, fwd_ret
+		FROM predictions
+		WHERE bot_id = ? AND symbol = ? AND ts >= ? AND ts <= ?
+		ORDER BY ts, id
+	`
+
+	rows, err := database.GetConn().Query(query, botID, symbol, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query predictions: %w", err)
+	}
+	defer rows.Close()
+
+	predictions := []db.Prediction{}
+	for rows.Next() {
+		var prediction db.Prediction
+		err := rows.Scan(&prediction.ID, &prediction.BotID, &prediction.Ts, &prediction.Symbol,
+			&prediction.Dir, &prediction.Conv, &prediction.Logic, &prediction.FwdRet)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan prediction: %w", err)
+		}
+		predictions = append(predictions, prediction)
+	}
+
+	return predictions, rows.Err()
+}
@@ -4,80 +4,76 @@ import (
 	"testing"
 
 	"github.com/adeilh/agentic_go_signals/internal/db"
+	"github.com/adeilh/agentic_go_signals/internal/strategy"
 )
 
 func TestGenerate(t *testing.T) {
+	snap := strategy.Snapshot{Symbol: "BTCUSDT"}
+
 	// Test with nil database
-	_, err := Generate(nil, nil, "test-bot", "BTC")
+	_, err := Generate(nil, strategy.Rules{}, "test-bot", snap)
 	if err == nil {
 		t.Fatal("expected error with nil database")
 	}
 
-	// Test with nil kimi client
+	// Test with nil strategy
 	database := &db.DB{}
-	_, err = Generate(database, nil, "test-bot", "BTC")
+	_, err = Generate(database, nil, "test-bot", snap)
 	if err == nil {
-		t.Fatal("expected error with nil kimi client")
+		t.Fatal("expected error with nil strategy")
 	}
 
 	t.Log("Generate function properly validates inputs")
 }
 
-func TestBuildNewsContext(t *testing.T) {
-	events := []db.Event{
-		{Source: "news", Text: "Bitcoin hits new high"},
-		{Source: "news", Text: "Institutional adoption growing"},
-		{Source: "chain", Text: "Active addresses: 500000"},
-	}
-
-	context := buildNewsContext(events)
-
-	if context == "No recent news available" {
-		t.Fatal("expected news context to be built")
-	}
-
-	if !contains(context, "Bitcoin hits new high") {
-		t.Fatal("expected news content in context")
+func TestGetLatest(t *testing.T) {
+	// Test with nil database
+	_, err := GetLatest(nil, "test-bot", "BTC")
+	if err == nil {
+		t.Fatal("expected error with nil database")
 	}
 
-	t.Logf("News context: %s", context)
+	t.Log("GetLatest properly validates database connection")
 }
 
-func TestBuildChainContext(t *testing.T) {
-	events := []db.Event{
-		{Source: "news", Text: "Bitcoin hits new high"},
-		{Source: "chain", Text: "Active addresses: 500000"},
-		{Source: "chain", Text: "Transactions: 300000"},
+func TestHitRateWithoutDatabase(t *testing.T) {
+	if _, _, err := HitRate(nil, "test-bot", "BTCUSDT"); err == nil {
+		t.Fatal("expected error with nil database")
 	}
+}
 
-	context := buildChainContext(events)
-
-	if context == "No recent chain data available" {
-		t.Fatal("expected chain context to be built")
+func TestEventSymbol(t *testing.T) {
+	cases := map[string]string{
+		"BTCUSDT": "BTC",
+		"ETHBUSD": "ETH",
+		"SOLUSDC": "SOL",
+		"BTC":     "BTC",
+		"USDT":    "USDT",
 	}
 
-	if !contains(context, "Active addresses") {
-		t.Fatal("expected chain content in context")
+	for symbol, want := range cases {
+		if got := eventSymbol(symbol); got != want {
+			t.Errorf("eventSymbol(%q) = %q, want %q", symbol, got, want)
+		}
 	}
-
-	t.Logf("Chain context: %s", context)
 }
 
-func TestGetLatest(t *testing.T) {
+func TestList(t *testing.T) {
 	// Test with nil database
-	_, err := GetLatest(nil, "test-bot", "BTC")
+	_, err := List(nil, db.QueryFilter{BotID: "test-bot"}, true)
 	if err == nil {
 		t.Fatal("expected error with nil database")
 	}
 
-	t.Log("GetLatest properly validates database connection")
+	t.Log("List properly validates database connection")
 }
 
-func contains(s, substr string) bool {
-	for i := 0; i <= len(s)-len(substr); i++ {
-		if s[i:i+len(substr)] == substr {
-			return true
-		}
+func TestGetScorecards(t *testing.T) {
+	// Test with nil database
+	_, err := GetScorecards(nil, db.QueryFilter{})
+	if err == nil {
+		t.Fatal("expected error with nil database")
 	}
-	return false
+
+	t.Log("GetScorecards properly validates database connection")
 }
@@ -0,0 +1,222 @@
+package predictor
+
+import (
+	"fmt"
+
+	"github.com/adeilh/agentic_go_signals/internal/db"
+)
+
+// Scorecard summarises how well a bot's labeled LONG/SHORT predictions for a
+// symbol played out. Returns are directional: a SHORT call on a falling
+// market counts as a positive return.
+type Scorecard struct {
+	BotID       string             `json:"bot_id"`
+	Symbol      string             `json:"symbol"`
+	Predictions int                `json:"predictions"`
+	Hits        int                `json:"hits"`
+	HitRate     float64            `json:"hit_rate"`
+	AvgReturn   float64            `json:"avg_return"`
+	TotalReturn float64            `json:"total_return"`
+	BrierScore  float64            `json:"brier_score"`
+	Deciles     []DecileStats      `json:"deciles"`
+	Calibration []CalibrationPoint `json:"calibration"`
+}
+
+// DecileStats holds outcomes for one conviction decile (1 = lowest conviction)
+type DecileStats struct {
+	Decile      int     `json:"decile"`
+	MinConv     int     `json:"min_conv"`
+	MaxConv     int     `json:"max_conv"`
+	Count       int     `json:"count"`
+	HitRate     float64 `json:"hit_rate"`
+	AvgReturn   float64 `json:"avg_return"`
+	TotalReturn float64 `json:"total_return"`
+}
+
+// CalibrationPoint compares stated conviction with realized accuracy for a
+// fixed conviction bucket (0 covers 0-9, 9 covers 90-100)
+type CalibrationPoint struct {
+	Bucket        int     `json:"bucket"`
+	AvgConviction float64 `json:"avg_conviction"`
+	Accuracy      float64 `json:"accuracy"`
+	Count         int     `json:"count"`
+}
+
+// labeledPredictions is the shared CTE selecting directional predictions
+// with a forward return, their directional return and whether they hit
+const labeledPredictions = `
+	WITH labeled AS (
+		SELECT
+			bot_id,
+			symbol,
+			conv,
+			CASE WHEN dir = 'LONG' THEN fwd_ret ELSE -fwd_ret END as dir_ret,
+			CASE WHEN (dir = 'LONG' AND fwd_ret > 0) OR (dir = 'SHORT' AND fwd_ret < 0) THEN 1 ELSE 0 END as hit
+		FROM predictions
+		%s
+	)`
+
+// GetScorecards computes a scorecard per bot and symbol matching the filter
+func GetScorecards(database *db.DB, filter db.QueryFilter) ([]Scorecard, error) {
+	if database == nil || database.GetConn() == nil {
+		return nil, fmt.Errorf("database connection is nil")
+	}
+
+	where, args := filter.Where("ts", "fwd_ret IS NOT NULL", "dir <> 'FLAT'")
+	labeled := fmt.Sprintf(labeledPredictions, where)
+
+	cards, index, err := scorecardSummaries(database, labeled, args)
+	if err != nil {
+		return nil, err
+	}
+	if err := scorecardDeciles(database, labeled, args, cards, index); err != nil {
+		return nil, err
+	}
+	if err := scorecardCalibration(database, labeled, args, cards, index); err != nil {
+		return nil, err
+	}
+
+	return cards, nil
+}
+
+// HitRate returns the share of a bot's labeled LONG/SHORT predictions for
+// symbol that hit and how many there are, without the rest of the
+// scorecard
+func HitRate(database *db.DB, botID, symbol string) (float64, int, error) {
+	if database == nil || database.GetConn() == nil {
+		return 0, 0, fmt.Errorf("database connection is nil")
+	}
+
+	where, args := db.QueryFilter{BotID: botID, Symbol: symbol}.Where("ts", "fwd_ret IS NOT NULL", "dir <> 'FLAT'")
+	query := fmt.Sprintf(labeledPredictions, where) + `
+	SELECT COALESCE(AVG(hit), 0), COUNT(*) FROM labeled`
+
+	var hitRate float64
+	var samples int
+	if err := database.GetConn().QueryRow(query, args...).Scan(&hitRate, &samples); err != nil {
+		return 0, 0, fmt.Errorf("failed to query hit rate: %w", err)
+	}
+	return hitRate, samples, nil
+}
+
+// scorecardSummaries computes hit rate, returns and Brier score per bot and symbol
+func scorecardSummaries(database *db.DB, labeled string, args []interface{}) ([]Scorecard, map[string]int, error) {
+	query := labeled + `
+	SELECT
+		bot_id,
+		symbol,
+		COUNT(*) as predictions,
+		SUM(hit) as hits,
+		AVG(hit) as hit_rate,
+		AVG(dir_ret) as avg_return,
+		SUM(dir_ret) as total_return,
+		AVG(POW(conv / 100 - hit, 2)) as brier_score
+	FROM labeled
+	GROUP BY bot_id, symbol
+	ORDER BY bot_id, symbol`
+
+	rows, err := database.GetConn().Query(query, args...)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to query scorecard summary: %w", err)
+	}
+	defer rows.Close()
+
+	cards := []Scorecard{}
+	index := make(map[string]int)
+	for rows.Next() {
+		var card Scorecard
+		err := rows.Scan(&card.BotID, &card.Symbol, &card.Predictions, &card.Hits,
+			&card.HitRate, &card.AvgReturn, &card.TotalReturn, &card.BrierScore)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to scan scorecard summary: %w", err)
+		}
+		card.Deciles = []DecileStats{}
+		card.Calibration = []CalibrationPoint{}
+		index[card.BotID+"|"+card.Symbol] = len(cards)
+		cards = append(cards, card)
+	}
+	return cards, index, rows.Err()
+}
+
+// scorecardDeciles ranks predictions into conviction deciles with NTILE and
+// aggregates outcomes per decile
+func scorecardDeciles(database *db.DB, labeled string, args []interface{}, cards []Scorecard, index map[string]int) error {
+	query := labeled + `,
+	ranked AS (
+		SELECT
+			bot_id,
+			symbol,
+			conv,
+			dir_ret,
+			hit,
+			NTILE(10) OVER (PARTITION BY bot_id, symbol ORDER BY conv) as decile
+		FROM labeled
+	)
+	SELECT
+		bot_id,
+		symbol,
+		decile,
+		MIN(conv) as min_conv,
+		MAX(conv) as max_conv,
+		COUNT(*) as count,
+		AVG(hit) as hit_rate,
+		AVG(dir_ret) as avg_return,
+		SUM(dir_ret) as total_return
+	FROM ranked
+	GROUP BY bot_id, symbol, decile
+	ORDER BY bot_id, symbol, decile`
+
+	rows, err := database.GetConn().Query(query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to query scorecard deciles: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var botID, symbol string
+		var d DecileStats
+		err := rows.Scan(&botID, &symbol, &d.Decile, &d.MinConv, &d.MaxConv,
+			&d.Count, &d.HitRate, &d.AvgReturn, &d.TotalReturn)
+		if err != nil {
+			return fmt.Errorf("failed to scan scorecard decile: %w", err)
+		}
+		if i, ok := index[botID+"|"+symbol]; ok {
+			cards[i].Deciles = append(cards[i].Deciles, d)
+		}
+	}
+	return rows.Err()
+}
+
+// scorecardCalibration buckets predictions by stated conviction and compares
+// the average conviction in each bucket with its realized accuracy
+func scorecardCalibration(database *db.DB, labeled string, args []interface{}, cards []Scorecard, index map[string]int) error {
+	query := labeled + `
+	SELECT
+		bot_id,
+		symbol,
+		LEAST(FLOOR(conv / 10), 9) as bucket,
+		AVG(conv) / 100 as avg_conviction,
+		AVG(hit) as accuracy,
+		COUNT(*) as count
+	FROM labeled
+	GROUP BY bot_id, symbol, bucket
+	ORDER BY bot_id, symbol, bucket`
+
+	rows, err := database.GetConn().Query(query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to query scorecard calibration: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var botID, symbol string
+		var p CalibrationPoint
+		if err := rows.Scan(&botID, &symbol, &p.Bucket, &p.AvgConviction, &p.Accuracy, &p.Count); err != nil {
+			return fmt.Errorf("failed to scan scorecard calibration: %w", err)
+		}
+		if i, ok := index[botID+"|"+symbol]; ok {
+			cards[i].Calibration = append(cards[i].Calibration, p)
+		}
+	}
+	return rows.Err()
+}
@@ -0,0 +1,394 @@
+// Package pretrade is the gate every order passes before it reaches a
+// broker, whether market, limit, stop or OCO. It bounds each order's value
+// and prices, suppresses duplicates,
+// keeps bots to their symbols and rate limits them, and records every
+// decision in the order_audit table, so no single bad prediction can turn
+// into an unbounded order. Reduce-only orders, which close positions, are
+// held to the position instead, so a halted bot can always get flat.
+package pretrade
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/adeilh/agentic_go_signals/internal/db"
+	"github.com/adeilh/agentic_go_signals/internal/risk"
+	"github.com/adeilh/agentic_go_signals/internal/trader"
+)
+
+// Defaults for the risk params left zero
+const (
+	DefaultPriceCollar        = 0.05
+	DefaultMaxOrdersPerMinute = 10
+	DefaultDuplicateWindow    = time.Minute
+)
+
+// Names of the checks, as recorded in the audit
+const (
+	CheckOrder     = "order"
+	CheckSymbol    = "symbol"
+	CheckPrice     = "price"
+	CheckCollar    = "price_collar"
+	CheckNotional  = "notional"
+	CheckDuplicate = "duplicate"
+	CheckRate      = "rate_limit"
+	CheckReduce    = "reduce_only"
+	CheckAudit     = "audit"
+)
+
+// AuditLog records the gate's decisions and reports the orders it let
+// through; *db.OrderAuditStore implements it. Keeping the history in the
+// audit means duplicates and rate limits hold across restarts.
+type AuditLog interface {
+	Record(entry db.OrderAudit) error
+	Accepted(botID string, since time.Time) ([]db.OrderAudit, error)
+}
+
+// PriceFunc returns the last cached price of symbol, or false when there is
+// none
+type PriceFunc func(symbol string) (float64, bool)
+
+// PositionFunc returns the signed quantity a bot holds in symbol, positive
+// long and negative short
+type PositionFunc func(symbol string) (float64, error)
+
+// TypeOCO is the order type the audit records for OCO pairs
+const TypeOCO = "OCO"
+
+// Placer places market orders; exchange.Exchange and paper.Account
+// implement it
+type Placer interface {
+	PlaceOrder(symbol, side string, qty float64) (trader.Order, error)
+}
+
+// OrderPlacer places limit, stop-loss limit and take-profit limit orders;
+// exchange.Exchange and paper.Account implement it
+type OrderPlacer interface {
+	NewOrder(req trader.OrderRequest) (trader.Order, error)
+}
+
+// OCOPlacer places one-cancels-the-other pairs; exchange.Exchange
+// implements it
+type OCOPlacer interface {
+	NewOCO(req trader.OCORequest) (trader.OrderList, error)
+}
+
+// Policy is what a bot's orders are checked against
+type Policy struct {
+	Symbols            []string // Symbols the bot may trade
+	MaxNotional        float64  // Largest order value in quote currency
+	PriceCollar        float64  // Largest fraction an order's price may stray from the cached price
+	MaxOrdersPerMinute int
+	DuplicateWindow    time.Duration
+}
+
+// NewPolicy builds the policy for a bot trading symbols with params. Orders
+// are capped at MaxOrderNotional, or when that is not set at the value the
+// total exposure cap allows, which no order the bot sizes can exceed.
+func NewPolicy(params risk.RiskParams, symbols []string) Policy {
+	policy := Policy{
+		Symbols:            symbols,
+		MaxNotional:        params.MaxOrderNotional,
+		PriceCollar:        params.PriceCollar,
+		MaxOrdersPerMinute: params.MaxOrdersPerMinute,
+		DuplicateWindow:    time.Duration(params.DuplicateWindowSec) * time.Second,
+	}
+	if policy.MaxNotional == 0 {
+		exposure := params.MaxTotalExposure
+		if exposure == 0 {
+			exposure = risk.DefaultMaxTotalExposure
+		}
+		policy.MaxNotional = params.AccountBalance * exposure
+	}
+	if policy.PriceCollar == 0 {
+		policy.PriceCollar = DefaultPriceCollar
+	}
+	if policy.MaxOrdersPerMinute == 0 {
+		policy.MaxOrdersPerMinute = DefaultMaxOrdersPerMinute
+	}
+	if policy.DuplicateWindow == 0 {
+		policy.DuplicateWindow = DefaultDuplicateWindow
+	}
+	return policy
+}
+
+// Rejection is returned for orders that fail a check
+type Rejection struct {
+	Check  string
+	Reason string
+}
+
+func (r *Rejection) Error() string {
+	return fmt.Sprintf("pre-trade %s check failed: %s", r.Check, r.Reason)
+}
+
+func reject(check, format string, args ...interface{}) *Rejection {
+	return &Rejection{Check: check, Reason: fmt.Sprintf(format, args...)}
+}
+
+// Order is an order as submitted to the gate
+type Order struct {
+	Symbol         string
+	Side           string  // BUY or SELL
+	Type           string  // trader.OrderType* or TypeOCO; empty for a market order
+	Qty            float64 // Base asset quantity
+	Price          float64 // Limit price, or for market orders the price they were sized at; zero takes the cached price
+	StopPrice      float64 // Trigger of stop orders and of an OCO's stop leg
+	StopLimitPrice float64 // Limit of an OCO's stop leg, if it has one
+	Reduce         bool    // Only closes part or all of the open position
+}
+
+// Gate checks one bot's orders
+type Gate struct {
+	botID     string
+	policy    Policy
+	audit     AuditLog
+	prices    PriceFunc
+	positions PositionFunc
+	now       func() time.Time
+}
+
+// NewGate creates the gate for botID's orders
+func NewGate(botID string, policy Policy, audit AuditLog, prices PriceFunc) *Gate {
+	return &Gate{botID: botID, policy: policy, audit: audit, prices: prices, now: time.Now}
+}
+
+// SetPositions lets the gate pass reduce-only orders, checking them against
+// the positions reported by positions. Without it they are rejected.
+func (g *Gate) SetPositions(positions PositionFunc) {
+	g.positions = positions
+}
+
+// Check runs every check on order and records the decision. It returns a
+// *Rejection for orders that must not be placed. An order that passes
+// counts towards the rate limit and duplicates from then on. Reduce-only
+// orders skip the price, value, duplicate and rate checks but may not open
+// or grow a position.
+func (g *Gate) Check(order Order) error {
+	entry := g.entry(order)
+	return g.record(entry, g.check(order, &entry))
+}
+
+// entry is order's audit entry before any check has run
+func (g *Gate) entry(order Order) db.OrderAudit {
+	orderType := strings.ToUpper(order.Type)
+	if orderType == "" {
+		orderType = trader.OrderTypeMarket
+	}
+	return db.OrderAudit{
+		BotID:     g.botID,
+		Ts:        g.now(),
+		Symbol:    order.Symbol,
+		Side:      strings.ToUpper(order.Side),
+		Type:      orderType,
+		Qty:       order.Qty,
+		Price:     order.Price,
+		StopPrice: order.StopPrice,
+		Reduce:    order.Reduce,
+	}
+}
+
+// record audits the decision on entry and returns the error for it
+func (g *Gate) record(entry db.OrderAudit, rejection *Rejection) error {
+	if rejection != nil {
+		entry.Check, entry.Reason = rejection.Check, rejection.Reason
+	}
+	entry.Accepted = rejection == nil
+
+	err := g.audit.Record(entry)
+	if rejection != nil {
+		if err != nil {
+			log.Printf("Failed to record rejected order of bot %s: %v", g.botID, err)
+		}
+		return rejection
+	}
+	if err != nil {
+		// Unrecorded orders would escape the rate limit
+		return reject(CheckAudit, "order could not be recorded: %v", err)
+	}
+	return nil
+}
+
+// check fills in the entry's price and notional and returns the first
+// check order fails
+func (g *Gate) check(order Order, entry *db.OrderAudit) *Rejection {
+	if entry.Side != "BUY" && entry.Side != "SELL" {
+		return reject(CheckOrder, "side must be BUY or SELL, got %q", entry.Side)
+	}
+	if !(entry.Qty > 0) || math.IsInf(entry.Qty, 0) {
+		return reject(CheckOrder, "quantity must be positive, got %g", entry.Qty)
+	}
+	switch entry.Type {
+	case trader.OrderTypeMarket, trader.OrderTypeLimit, trader.OrderTypeStopLossLimit, trader.OrderTypeTakeProfitLimit, TypeOCO:
+	default:
+		return reject(CheckOrder, "unsupported order type %q", entry.Type)
+	}
+	if !contains(g.policy.Symbols, entry.Symbol) {
+		return reject(CheckSymbol, "%s is not one of the bot's symbols", entry.Symbol)
+	}
+	if entry.Reduce {
+		return g.checkReduce(entry)
+	}
+
+	market := entry.Type == trader.OrderTypeMarket
+	if !market && !(entry.Price > 0) {
+		return reject(CheckOrder, "%s orders need a limit price", entry.Type)
+	}
+
+	last, ok := g.price(entry.Symbol)
+	if !ok {
+		return reject(CheckPrice, "no cached price for %s", entry.Symbol)
+	}
+	if entry.Price == 0 {
+		entry.Price = last
+	}
+
+	// Market orders fill around the cached price whatever the order says,
+	// and any leg of the others may fill at its price, so the order is
+	// valued at the highest of them
+	worst := last
+	for _, price := range []float64{entry.Price, order.StopPrice, order.StopLimitPrice} {
+		if price == 0 {
+			continue
+		}
+		if deviation := math.Abs(price-last) / last; !(deviation <= g.policy.PriceCollar) {
+			return reject(CheckCollar, "price %g is %.2f%% from the last price %g, over the %g%% collar",
+				price, deviation*100, last, g.policy.PriceCollar*100)
+		}
+		worst = math.Max(worst, price)
+	}
+	entry.Notional = entry.Qty * worst
+	if entry.Notional > g.policy.MaxNotional {
+		return reject(CheckNotional, "order value %.2f exceeds the %.2f limit", entry.Notional, g.policy.MaxNotional)
+	}
+
+	now := entry.Ts
+	since := now.Add(-time.Minute)
+	if window := now.Add(-g.policy.DuplicateWindow); window.Before(since) {
+		since = window
+	}
+	recent, err := g.audit.Accepted(g.botID, since)
+	if err != nil {
+		return reject(CheckAudit, "recent orders could not be loaded: %v", err)
+	}
+
+	lastMinute := 0
+	for _, previous := range recent {
+		// Resting orders at different prices are not repeats of each other
+		samePrice := market || (previous.Price == entry.Price && previous.StopPrice == entry.StopPrice)
+		if previous.Symbol == entry.Symbol && previous.Side == entry.Side && previous.Type == entry.Type &&
+			previous.Qty == entry.Qty && samePrice && now.Sub(previous.Ts) < g.policy.DuplicateWindow {
+			return reject(CheckDuplicate, "same order was placed %s ago", now.Sub(previous.Ts).Round(time.Second))
+		}
+		if now.Sub(previous.Ts) < time.Minute {
+			lastMinute++
+		}
+	}
+	if lastMinute >= g.policy.MaxOrdersPerMinute {
+		return reject(CheckRate, "%d orders in the last minute reached the limit", lastMinute)
+	}
+	return nil
+}
+
+// checkReduce passes orders that close no more than the open position
+func (g *Gate) checkReduce(entry *db.OrderAudit) *Rejection {
+	if g.positions == nil {
+		return reject(CheckReduce, "positions are unknown")
+	}
+	position, err := g.positions(entry.Symbol)
+	if err != nil {
+		return reject(CheckReduce, "position could not be loaded: %v", err)
+	}
+
+	held := math.Abs(position)
+	if held == 0 {
+		return reject(CheckReduce, "no open %s position", entry.Symbol)
+	}
+	if (position > 0) != (entry.Side == "SELL") {
+		return reject(CheckReduce, "%s would grow the %g %s position", entry.Side, position, entry.Symbol)
+	}
+	// Allow for float noise from netting fills into the position
+	if entry.Qty > held*(1+1e-9) {
+		return reject(CheckReduce, "quantity %g exceeds the %g held", entry.Qty, held)
+	}
+
+	if last, ok := g.price(entry.Symbol); ok && entry.Price == 0 {
+		entry.Price = last
+	}
+	entry.Notional = entry.Qty * entry.Price
+	return nil
+}
+
+func (g *Gate) price(symbol string) (float64, bool) {
+	if g.prices == nil {
+		return 0, false
+	}
+	price, ok := g.prices(symbol)
+	return price, ok && price > 0
+}
+
+// PlaceOrder checks order and places it as a market order with placer when
+// it passes
+func (g *Gate) PlaceOrder(placer Placer, order Order) (trader.Order, error) {
+	if err := g.Check(order); err != nil {
+		return trader.Order{}, err
+	}
+	return placer.PlaceOrder(order.Symbol, strings.ToUpper(order.Side), order.Qty)
+}
+
+// PlaceLimit checks a limit, stop-loss limit or take-profit limit order,
+// holding its limit and stop prices to the collar and valuing it at the
+// highest, and places it with placer when it passes. Market orders go
+// through PlaceOrder.
+func (g *Gate) PlaceLimit(placer OrderPlacer, req trader.OrderRequest) (trader.Order, error) {
+	err := req.Validate()
+	order := Order{Symbol: req.Symbol, Side: req.Side, Type: req.Type, Qty: req.Quantity, Price: req.Price, StopPrice: req.StopPrice}
+	entry := g.entry(order)
+
+	var rejection *Rejection
+	switch {
+	case err != nil:
+		rejection = reject(CheckOrder, "%v", err)
+	case req.Type == trader.OrderTypeMarket:
+		rejection = reject(CheckOrder, "market orders are placed with PlaceOrder")
+	default:
+		rejection = g.check(order, &entry)
+	}
+	if err := g.record(entry, rejection); err != nil {
+		return trader.Order{}, err
+	}
+	return placer.NewOrder(req)
+}
+
+// PlaceOCO checks a one-cancels-the-other pair as one order, holding the
+// limit, stop and stop limit prices to the collar and valuing it at the
+// highest, and places it with placer when it passes
+func (g *Gate) PlaceOCO(placer OCOPlacer, req trader.OCORequest) (trader.OrderList, error) {
+	err := req.Validate()
+	order := Order{Symbol: req.Symbol, Side: req.Side, Type: TypeOCO, Qty: req.Quantity, Price: req.Price,
+		StopPrice: req.StopPrice, StopLimitPrice: req.StopLimitPrice}
+	entry := g.entry(order)
+
+	var rejection *Rejection
+	if err != nil {
+		rejection = reject(CheckOrder, "%v", err)
+	} else {
+		rejection = g.check(order, &entry)
+	}
+	if err := g.record(entry, rejection); err != nil {
+		return trader.OrderList{}, err
+	}
+	return placer.NewOCO(req)
+}
+
+func contains(symbols []string, symbol string) bool {
+	for _, s := range symbols {
+		if s == symbol {
+			return true
+		}
+	}
+	return false
+}
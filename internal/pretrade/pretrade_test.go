@@ -0,0 +1,306 @@
+package pretrade
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/adeilh/agentic_go_signals/internal/db"
+	"github.com/adeilh/agentic_go_signals/internal/risk"
+	"github.com/adeilh/agentic_go_signals/internal/trader"
+)
+
+type memoryAudit struct {
+	entries []db.OrderAudit
+	fail    error
+}
+
+func (m *memoryAudit) Record(entry db.OrderAudit) error {
+	if m.fail != nil {
+		return m.fail
+	}
+	m.entries = append(m.entries, entry)
+	return nil
+}
+
+func (m *memoryAudit) Accepted(botID string, since time.Time) ([]db.OrderAudit, error) {
+	if m.fail != nil {
+		return nil, m.fail
+	}
+	var accepted []db.OrderAudit
+	for _, entry := range m.entries {
+		if entry.BotID == botID && entry.Accepted && !entry.Ts.Before(since) {
+			accepted = append(accepted, entry)
+		}
+	}
+	return accepted, nil
+}
+
+type recordingPlacer struct {
+	placed   int
+	requests []trader.OrderRequest
+	ocos     []trader.OCORequest
+}
+
+func (r *recordingPlacer) PlaceOrder(symbol, side string, qty float64) (trader.Order, error) {
+	r.placed++
+	return trader.Order{Symbol: symbol, Side: side, Status: "FILLED"}, nil
+}
+
+func (r *recordingPlacer) NewOrder(req trader.OrderRequest) (trader.Order, error) {
+	r.requests = append(r.requests, req)
+	return trader.Order{Symbol: req.Symbol, Side: req.Side, Type: req.Type, Status: "NEW"}, nil
+}
+
+func (r *recordingPlacer) NewOCO(req trader.OCORequest) (trader.OrderList, error) {
+	r.ocos = append(r.ocos, req)
+	return trader.OrderList{Symbol: req.Symbol}, nil
+}
+
+func testGate(audit AuditLog) (*Gate, *time.Time) {
+	policy := NewPolicy(risk.RiskParams{AccountBalance: 10000, MaxOrdersPerMinute: 2}, []string{"BTCUSDT", "ETHUSDT"})
+	prices := func(symbol string) (float64, bool) {
+		if symbol == "BTCUSDT" {
+			return 100, true
+		}
+		return 0, false
+	}
+	gate := NewGate("bot", policy, audit, prices)
+	now := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+	gate.now = func() time.Time { return now }
+	return gate, &now
+}
+
+func expectRejection(t *testing.T, err error, check string) {
+	t.Helper()
+	var rejection *Rejection
+	if !errors.As(err, &rejection) {
+		t.Fatalf("expected a %s rejection, got %v", check, err)
+	}
+	if rejection.Check != check {
+		t.Fatalf("expected the %s check to reject the order, got %v", check, rejection)
+	}
+}
+
+func TestNewPolicy(t *testing.T) {
+	policy := NewPolicy(risk.RiskParams{AccountBalance: 10000}, nil)
+	if policy.MaxNotional != 5000 {
+		t.Errorf("expected orders capped at the default total exposure, got %g", policy.MaxNotional)
+	}
+	if policy.PriceCollar != DefaultPriceCollar || policy.MaxOrdersPerMinute != DefaultMaxOrdersPerMinute ||
+		policy.DuplicateWindow != DefaultDuplicateWindow {
+		t.Errorf("expected defaults, got %+v", policy)
+	}
+
+	policy = NewPolicy(risk.RiskParams{AccountBalance: 10000, MaxOrderNotional: 250, DuplicateWindowSec: 30}, nil)
+	if policy.MaxNotional != 250 || policy.DuplicateWindow != 30*time.Second {
+		t.Errorf("expected the configured limits, got %+v", policy)
+	}
+}
+
+func TestCheckRejections(t *testing.T) {
+	tests := []struct {
+		name  string
+		order Order
+		check string
+	}{
+		{"unknown side", Order{Symbol: "BTCUSDT", Side: "HOLD", Qty: 1}, CheckOrder},
+		{"zero quantity", Order{Symbol: "BTCUSDT", Side: "BUY"}, CheckOrder},
+		{"negative quantity", Order{Symbol: "BTCUSDT", Side: "BUY", Qty: -1}, CheckOrder},
+		{"other symbol", Order{Symbol: "DOGEUSDT", Side: "BUY", Qty: 1}, CheckSymbol},
+		{"no cached price", Order{Symbol: "ETHUSDT", Side: "BUY", Qty: 1}, CheckPrice},
+		{"price outside collar", Order{Symbol: "BTCUSDT", Side: "BUY", Qty: 1, Price: 110}, CheckCollar},
+		{"order too large", Order{Symbol: "BTCUSDT", Side: "BUY", Qty: 60}, CheckNotional},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			audit := &memoryAudit{}
+			gate, _ := testGate(audit)
+			expectRejection(t, gate.Check(tt.order), tt.check)
+
+			if len(audit.entries) != 1 || audit.entries[0].Accepted || audit.entries[0].Check != tt.check {
+				t.Fatalf("expected the rejection to be audited, got %+v", audit.entries)
+			}
+		})
+	}
+}
+
+func TestCheckAccepts(t *testing.T) {
+	audit := &memoryAudit{}
+	gate, _ := testGate(audit)
+
+	if err := gate.Check(Order{Symbol: "BTCUSDT", Side: "buy", Qty: 2, Price: 101}); err != nil {
+		t.Fatalf("unexpected rejection: %v", err)
+	}
+	if len(audit.entries) != 1 {
+		t.Fatalf("expected the order to be audited, got %+v", audit.entries)
+	}
+	entry := audit.entries[0]
+	if !entry.Accepted || entry.Side != "BUY" || entry.Notional != 202 {
+		t.Errorf("unexpected audit entry %+v", entry)
+	}
+}
+
+func TestCheckDuplicatesAndRateLimit(t *testing.T) {
+	audit := &memoryAudit{}
+	gate, now := testGate(audit)
+
+	order := Order{Symbol: "BTCUSDT", Side: "BUY", Qty: 1}
+	if err := gate.Check(order); err != nil {
+		t.Fatalf("unexpected rejection: %v", err)
+	}
+	*now = now.Add(10 * time.Second)
+	expectRejection(t, gate.Check(order), CheckDuplicate)
+
+	// A different order is not a duplicate but still counts towards the
+	// rate limit
+	if err := gate.Check(Order{Symbol: "BTCUSDT", Side: "SELL", Qty: 1}); err != nil {
+		t.Fatalf("unexpected rejection: %v", err)
+	}
+	*now = now.Add(10 * time.Second)
+	expectRejection(t, gate.Check(Order{Symbol: "BTCUSDT", Side: "BUY", Qty: 2}), CheckRate)
+
+	// Both limits clear once the window has passed
+	*now = now.Add(time.Minute)
+	if err := gate.Check(order); err != nil {
+		t.Fatalf("unexpected rejection after the window: %v", err)
+	}
+}
+
+func TestCheckAuditFailure(t *testing.T) {
+	audit := &memoryAudit{fail: fmt.Errorf("connection lost")}
+	gate, _ := testGate(audit)
+
+	expectRejection(t, gate.Check(Order{Symbol: "BTCUSDT", Side: "BUY", Qty: 1}), CheckAudit)
+}
+
+func TestPlaceOrder(t *testing.T) {
+	gate, _ := testGate(&memoryAudit{})
+	placer := &recordingPlacer{}
+
+	if _, err := gate.PlaceOrder(placer, Order{Symbol: "BTCUSDT", Side: "BUY", Qty: 1000}); err == nil {
+		t.Fatal("expected an oversized order to be rejected")
+	}
+	if placer.placed != 0 {
+		t.Fatal("expected a rejected order not to reach the broker")
+	}
+
+	if _, err := gate.PlaceOrder(placer, Order{Symbol: "BTCUSDT", Side: "BUY", Qty: 1}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if placer.placed != 1 {
+		t.Fatalf("expected the order to be placed once, got %d", placer.placed)
+	}
+}
+
+func TestCheckReduceOnly(t *testing.T) {
+	audit := &memoryAudit{}
+	gate, now := testGate(audit)
+
+	closing := Order{Symbol: "BTCUSDT", Side: "SELL", Qty: 80, Reduce: true}
+	expectRejection(t, gate.Check(closing), CheckReduce)
+
+	gate.SetPositions(func(symbol string) (float64, error) {
+		if symbol == "BTCUSDT" {
+			return 80, nil
+		}
+		return 0, nil
+	})
+
+	// Far over the value cap, with the rate limit used up and a repeat of
+	// the same order, but it only closes the position
+	for i := 0; i < 3; i++ {
+		if err := gate.Check(closing); err != nil {
+			t.Fatalf("unexpected rejection of a closing order: %v", err)
+		}
+		*now = now.Add(time.Second)
+	}
+	if last := audit.entries[len(audit.entries)-1]; !last.Accepted || !last.Reduce || last.Notional != 8000 {
+		t.Errorf("unexpected audit entry %+v", last)
+	}
+
+	expectRejection(t, gate.Check(Order{Symbol: "BTCUSDT", Side: "BUY", Qty: 1, Reduce: true}), CheckReduce)
+	expectRejection(t, gate.Check(Order{Symbol: "BTCUSDT", Side: "SELL", Qty: 81, Reduce: true}), CheckReduce)
+	// No price is needed to close, but a position is
+	expectRejection(t, gate.Check(Order{Symbol: "ETHUSDT", Side: "SELL", Qty: 1, Reduce: true}), CheckReduce)
+	expectRejection(t, gate.Check(Order{Symbol: "DOGEUSDT", Side: "SELL", Qty: 1, Reduce: true}), CheckSymbol)
+
+	gate.SetPositions(func(symbol string) (float64, error) { return 0, fmt.Errorf("connection lost") })
+	expectRejection(t, gate.Check(closing), CheckReduce)
+}
+
+func TestPlaceLimit(t *testing.T) {
+	audit := &memoryAudit{}
+	gate, now := testGate(audit)
+	placer := &recordingPlacer{}
+
+	rejections := []struct {
+		name  string
+		req   trader.OrderRequest
+		check string
+	}{
+		{"market order", trader.OrderRequest{Symbol: "BTCUSDT", Side: "BUY", Type: "MARKET", Quantity: 1}, CheckOrder},
+		{"no limit price", trader.OrderRequest{Symbol: "BTCUSDT", Side: "BUY", Type: "LIMIT", Quantity: 1}, CheckOrder},
+		{"limit outside collar", trader.OrderRequest{Symbol: "BTCUSDT", Side: "BUY", Type: "LIMIT", Quantity: 1, Price: 90}, CheckCollar},
+		{"stop outside collar", trader.OrderRequest{Symbol: "BTCUSDT", Side: "SELL", Type: "STOP_LOSS_LIMIT", Quantity: 1,
+			Price: 96, StopPrice: 94}, CheckCollar},
+		// Worth 4900 at the last price but 5096 at its limit
+		{"limit over notional", trader.OrderRequest{Symbol: "BTCUSDT", Side: "SELL", Type: "LIMIT", Quantity: 49, Price: 104}, CheckNotional},
+	}
+	for _, tt := range rejections {
+		_, err := gate.PlaceLimit(placer, tt.req)
+		expectRejection(t, err, tt.check)
+	}
+	if len(placer.requests) != 0 || len(audit.entries) != len(rejections) {
+		t.Fatalf("expected every rejection audited and none placed, got %d placed, %+v", len(placer.requests), audit.entries)
+	}
+
+	stop := trader.OrderRequest{Symbol: "BTCUSDT", Side: "SELL", Type: "stop_loss_limit", Quantity: 2, Price: 97, StopPrice: 98}
+	if _, err := gate.PlaceLimit(placer, stop); err != nil {
+		t.Fatalf("unexpected rejection: %v", err)
+	}
+	entry := audit.entries[len(audit.entries)-1]
+	if !entry.Accepted || entry.Type != "STOP_LOSS_LIMIT" || entry.Price != 97 || entry.StopPrice != 98 || entry.Notional != 200 {
+		t.Errorf("unexpected audit entry %+v", entry)
+	}
+
+	// A resting order at another price is not a duplicate, but the same one is
+	*now = now.Add(time.Second)
+	stop.Price = 96
+	if _, err := gate.PlaceLimit(placer, stop); err != nil {
+		t.Fatalf("unexpected rejection: %v", err)
+	}
+	*now = now.Add(time.Second)
+	_, err := gate.PlaceLimit(placer, stop)
+	expectRejection(t, err, CheckDuplicate)
+	if len(placer.requests) != 2 {
+		t.Fatalf("expected two orders placed, got %+v", placer.requests)
+	}
+}
+
+func TestPlaceOCO(t *testing.T) {
+	audit := &memoryAudit{}
+	gate, _ := testGate(audit)
+	placer := &recordingPlacer{}
+
+	_, err := gate.PlaceOCO(placer, trader.ExitOCO("BTCUSDT", "BUY", 1, 104, 90))
+	expectRejection(t, err, CheckCollar)
+	_, err = gate.PlaceOCO(placer, trader.OCORequest{Symbol: "BTCUSDT", Side: "BUY", Quantity: 1, Price: 104, StopPrice: 96})
+	expectRejection(t, err, CheckOrder)
+	_, err = gate.PlaceOCO(placer, trader.ExitOCO("DOGEUSDT", "BUY", 1, 104, 96))
+	expectRejection(t, err, CheckSymbol)
+	if len(placer.ocos) != 0 {
+		t.Fatalf("expected rejected pairs not to be placed, got %+v", placer.ocos)
+	}
+
+	if _, err := gate.PlaceOCO(placer, trader.ExitOCO("BTCUSDT", "BUY", 10, 104, 96)); err != nil {
+		t.Fatalf("unexpected rejection: %v", err)
+	}
+	entry := audit.entries[len(audit.entries)-1]
+	if len(placer.ocos) != 1 || !entry.Accepted || entry.Type != TypeOCO || entry.Side != "SELL" ||
+		entry.Price != 104 || entry.StopPrice != 96 || entry.Notional != 1040 {
+		t.Errorf("unexpected audit entry %+v", entry)
+	}
+}
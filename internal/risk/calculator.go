@@ -2,17 +2,79 @@ package risk
 
 import (
 	"errors"
+	"fmt"
 	"math"
 )
 
-// RiskParams contains risk management parameters
+// RiskParams contains risk management parameters. Exposure caps and the
+// daily loss limit are fractions of AccountBalance.
 type RiskParams struct {
-	AccountBalance  float64 // Total account balance
-	RiskPerTrade    float64 // Risk percentage per trade (e.g., 0.02 for 2%)
-	MaxPositionSize float64 // Maximum position size percentage (e.g., 0.10 for 10%)
-	StopLossPercent float64 // Stop loss percentage (e.g., 0.05 for 5%)
+	AccountBalance    float64            `json:"account_balance"`             // Total account balance
+	RiskPerTrade      float64            `json:"risk_per_trade"`              // Risk percentage per trade (e.g., 0.02 for 2%)
+	MaxPositionSize   float64            `json:"max_position_size"`           // Maximum position size percentage (e.g., 0.10 for 10%)
+	StopLossPercent   float64            `json:"stop_loss_percent"`           // Stop loss percentage (e.g., 0.05 for 5%)
+	MaxTotalExposure  float64            `json:"max_total_exposure"`          // Cap on all open positions together; zero means DefaultMaxTotalExposure
+	MaxSymbolExposure float64            `json:"max_symbol_exposure"`         // Cap on one symbol's position; zero leaves only the total cap
+	SymbolCaps        map[string]float64 `json:"symbol_caps,omitempty"`       // Per-symbol overrides of MaxSymbolExposure
+	CorrelatedGroups  []ExposureGroup    `json:"correlated_groups,omitempty"` // Caps on symbols that move together
+
+	SizingMode       string  `json:"sizing_mode,omitempty"`       // How Size picks the quantity; empty means SizingFixed
+	ATRMultiplier    float64 `json:"atr_multiplier,omitempty"`    // Stop distance in ATRs for SizingATR; zero means DefaultATRMultiplier
+	TargetVolatility float64 `json:"target_volatility,omitempty"` // Account fraction one ATR move may cost for SizingVolatility; zero means DefaultTargetVolatility
+	KellyFraction    float64 `json:"kelly_fraction,omitempty"`    // Share of the full Kelly bet for SizingKelly; zero means DefaultKellyFraction
+
+	MaxDailyLoss         float64 `json:"max_daily_loss,omitempty"`         // Loss since UTC midnight that stops the bot; zero means no limit
+	MaxDrawdown          float64 `json:"max_drawdown,omitempty"`           // Drop from the equity peak, as a fraction of the peak, that stops the bot
+	MaxConsecutiveLosses int     `json:"max_consecutive_losses,omitempty"` // Losing trades in a row that stop the bot
+	FlattenOnTrip        bool    `json:"flatten_on_trip,omitempty"`        // Close the bot's positions when a limit stops it
+
+	MaxOrderNotional   float64 `json:"max_order_notional,omitempty"`    // Largest order value, in quote currency, pre-trade checks let through
+	PriceCollar        float64 `json:"price_collar,omitempty"`          // Largest fraction an order's price may stray from the last cached price
+	MaxOrdersPerMinute int     `json:"max_orders_per_minute,omitempty"` // Orders the bot may place per minute
+	DuplicateWindowSec int     `json:"duplicate_window_sec,omitempty"`  // How long an identical order counts as a duplicate
+}
+
+// ExposureGroup caps the combined exposure of correlated symbols, e.g. the
+// large caps that all follow BTC
+type ExposureGroup struct {
+	Name        string   `json:"name"`
+	Symbols     []string `json:"symbols"`
+	MaxExposure float64  `json:"max_exposure"`
+}
+
+// DefaultMaxTotalExposure applies when RiskParams.MaxTotalExposure is not set
+const DefaultMaxTotalExposure = 0.5
+
+// DefaultParams returns the parameters used when a bot or backtest doesn't set its own
+func DefaultParams() RiskParams {
+	return RiskParams{
+		AccountBalance:    10000,
+		RiskPerTrade:      0.02,
+		MaxPositionSize:   0.10,
+		StopLossPercent:   0.05,
+		MaxTotalExposure:  DefaultMaxTotalExposure,
+		MaxSymbolExposure: 0.25,
+	}
+}
+
+// Exposure is the signed value of the open position in each symbol,
+// positive for long and negative for short
+type Exposure map[string]float64
+
+// Total returns the gross exposure: the summed size of all positions,
+// whichever their direction
+func (e Exposure) Total() float64 {
+	total := 0.0
+	for _, value := range e {
+		total += math.Abs(value)
+	}
+	return total
 }
 
+// DefaultQuantityStep is the quantity increment used when the symbol's lot
+// size is not known
+const DefaultQuantityStep = 0.00001
+
 // PositionSize calculates the position size based on risk parameters
 type PositionSize struct {
 	Quantity   float64 // Number of units to trade
@@ -40,53 +102,65 @@ func NewCalculator(params RiskParams) (*Calculator, error) {
 	if params.StopLossPercent <= 0 || params.StopLossPercent > 1 {
 		return nil, errors.New("stop loss percent must be between 0 and 1")
 	}
-
-	return &Calculator{params: params}, nil
-}
-
-// CalculatePositionSize calculates optimal position size for a trade
-func (c *Calculator) CalculatePositionSize(currentPrice float64, direction string) (*PositionSize, error) {
-	if currentPrice <= 0 {
-		return nil, errors.New("current price must be positive")
+	if params.MaxTotalExposure < 0 || params.MaxTotalExposure > 1 {
+		return nil, errors.New("max total exposure must be between 0 and 1")
 	}
-
-	if direction != "long" && direction != "short" {
-		return nil, errors.New("direction must be 'long' or 'short'")
+	if params.MaxSymbolExposure < 0 || params.MaxSymbolExposure > 1 {
+		return nil, errors.New("max symbol exposure must be between 0 and 1")
 	}
-
-	// Calculate risk amount
-	riskAmount := c.params.AccountBalance * c.params.RiskPerTrade
-
-	// Calculate stop loss price
-	var stopLoss float64
-	if direction == "long" {
-		stopLoss = currentPrice * (1 - c.params.StopLossPercent)
-	} else {
-		stopLoss = currentPrice * (1 + c.params.StopLossPercent)
+	for symbol, limit := range params.SymbolCaps {
+		if limit <= 0 || limit > 1 {
+			return nil, fmt.Errorf("exposure cap of %s must be between 0 and 1", symbol)
+		}
+	}
+	for _, group := range params.CorrelatedGroups {
+		if len(group.Symbols) == 0 {
+			return nil, fmt.Errorf("correlated group %q has no symbols", group.Name)
+		}
+		if group.MaxExposure <= 0 || group.MaxExposure > 1 {
+			return nil, fmt.Errorf("exposure cap of correlated group %q must be between 0 and 1", group.Name)
+		}
+	}
+	if params.MaxDailyLoss < 0 || params.MaxDailyLoss > 1 {
+		return nil, errors.New("max daily loss must be between 0 and 1")
+	}
+	if params.MaxDrawdown < 0 || params.MaxDrawdown > 1 {
+		return nil, errors.New("max drawdown must be between 0 and 1")
+	}
+	if params.MaxConsecutiveLosses < 0 {
+		return nil, errors.New("max consecutive losses must not be negative")
+	}
+	if params.MaxOrderNotional < 0 {
+		return nil, errors.New("max order notional must not be negative")
+	}
+	if params.PriceCollar < 0 || params.PriceCollar > 1 {
+		return nil, errors.New("price collar must be between 0 and 1")
+	}
+	if params.MaxOrdersPerMinute < 0 {
+		return nil, errors.New("max orders per minute must not be negative")
+	}
+	if params.DuplicateWindowSec < 0 {
+		return nil, errors.New("duplicate window must not be negative")
+	}
+	if err := validateSizing(params); err != nil {
+		return nil, err
 	}
 
-	// Calculate risk per unit
-	riskPerUnit := math.Abs(currentPrice - stopLoss)
-
-	// Calculate quantity based on risk
-	quantity := riskAmount / riskPerUnit
-
-	// Calculate position value
-	positionValue := quantity * currentPrice
+	return &Calculator{params: params}, nil
+}
 
-	// Check against maximum position size
-	maxPositionValue := c.params.AccountBalance * c.params.MaxPositionSize
-	if positionValue > maxPositionValue {
-		quantity = maxPositionValue / currentPrice
-		positionValue = maxPositionValue
-	}
+// CalculatePositionSize calculates optimal position size for a trade, with
+// the quantity rounded down to DefaultQuantityStep
+func (c *Calculator) CalculatePositionSize(currentPrice float64, direction string) (*PositionSize, error) {
+	return c.CalculatePositionSizeWithStep(currentPrice, direction, DefaultQuantityStep)
+}
 
-	return &PositionSize{
-		Quantity:   math.Floor(quantity*100000) / 100000, // Round to 5 decimal places
-		Value:      math.Floor(positionValue*100) / 100,  // Round to 2 decimal places
-		StopLoss:   math.Floor(stopLoss*100) / 100,       // Round to 2 decimal places
-		RiskAmount: math.Floor(riskAmount*100) / 100,     // Round to 2 decimal places
-	}, nil
+// CalculatePositionSizeWithStep calculates optimal position size for a
+// trade with the quantity rounded down to a multiple of step, the symbol's
+// lot size. A zero step uses DefaultQuantityStep. It sizes without market
+// inputs, so only the fixed-fractional mode can size this way; see Size.
+func (c *Calculator) CalculatePositionSizeWithStep(currentPrice float64, direction string, step float64) (*PositionSize, error) {
+	return c.Size(currentPrice, direction, step, SizingInputs{})
 }
 
 // CalculateRiskReward calculates risk-reward ratio for a trade
@@ -114,15 +188,81 @@ func (c *Calculator) ValidateRiskLimits(positionValue, currentExposure float64)
 
 	// Check total exposure (including current position)
 	totalExposure := currentExposure + positionValue
-	maxTotalExposure := c.params.AccountBalance * 0.5 // Max 50% total exposure
+	if limit := c.totalExposureCap(); totalExposure > c.params.AccountBalance*limit {
+		return fmt.Errorf("total exposure would exceed %g%% of account", limit*100)
+	}
+
+	return nil
+}
+
+// ValidateTrade checks a trade of signed value in symbol, positive to buy
+// and negative to sell, against the position size limit and the total,
+// per-symbol and correlated group exposure caps given the open positions.
+// Trades that shrink an exposure already over its cap are allowed, so a
+// breached cap never blocks reducing the risk.
+func (c *Calculator) ValidateTrade(symbol string, value float64, exposure Exposure) error {
+	if math.Abs(value) > c.params.AccountBalance*c.params.MaxPositionSize {
+		return errors.New("position size exceeds maximum allowed")
+	}
+
+	after := make(Exposure, len(exposure)+1)
+	for s, v := range exposure {
+		after[s] = v
+	}
+	after[symbol] += value
 
-	if totalExposure > maxTotalExposure {
-		return errors.New("total exposure would exceed 50% of account")
+	// exceeds reports whether the exposure grows past a cap
+	exceeds := func(before, after, limit float64) bool {
+		return after > c.params.AccountBalance*limit && after > before
+	}
+
+	if limit := c.totalExposureCap(); exceeds(exposure.Total(), after.Total(), limit) {
+		return fmt.Errorf("total exposure would exceed %g%% of account", limit*100)
+	}
+	if limit := c.symbolExposureCap(symbol); limit > 0 && exceeds(math.Abs(exposure[symbol]), math.Abs(after[symbol]), limit) {
+		return fmt.Errorf("%s exposure would exceed %g%% of account", symbol, limit*100)
+	}
+	for _, group := range c.params.CorrelatedGroups {
+		if !contains(group.Symbols, symbol) {
+			continue
+		}
+		before, grown := 0.0, 0.0
+		for _, s := range group.Symbols {
+			before += math.Abs(exposure[s])
+			grown += math.Abs(after[s])
+		}
+		if exceeds(before, grown, group.MaxExposure) {
+			return fmt.Errorf("exposure of correlated group %q would exceed %g%% of account", group.Name, group.MaxExposure*100)
+		}
 	}
 
 	return nil
 }
 
+func (c *Calculator) totalExposureCap() float64 {
+	if c.params.MaxTotalExposure == 0 {
+		return DefaultMaxTotalExposure
+	}
+	return c.params.MaxTotalExposure
+}
+
+// symbolExposureCap returns the cap on symbol's exposure, or zero for none
+func (c *Calculator) symbolExposureCap(symbol string) float64 {
+	if limit, ok := c.params.SymbolCaps[symbol]; ok {
+		return limit
+	}
+	return c.params.MaxSymbolExposure
+}
+
+func contains(symbols []string, symbol string) bool {
+	for _, s := range symbols {
+		if s == symbol {
+			return true
+		}
+	}
+	return false
+}
+
 // GetRiskMetrics returns current risk metrics
 func (c *Calculator) GetRiskMetrics() map[string]interface{} {
 	return map[string]interface{}{
@@ -132,5 +272,15 @@ func (c *Calculator) GetRiskMetrics() map[string]interface{} {
 		"stop_loss_percent":  c.params.StopLossPercent * 100, // Convert to percentage
 		"max_risk_amount":    c.params.AccountBalance * c.params.RiskPerTrade,
 		"max_position_value": c.params.AccountBalance * c.params.MaxPositionSize,
+		"max_total_exposure": c.totalExposureCap() * 100, // Convert to percentage
+		"max_exposure_value": c.params.AccountBalance * c.totalExposureCap(),
+		"sizing_mode":        c.SizingMode(),
 	}
 }
+
+// floorToStep rounds v down to a multiple of step. The epsilon keeps exact
+// multiples from flooring to the step below, and the result is rounded to 8
+// decimals to drop float noise.
+func floorToStep(v, step float64) float64 {
+	return math.Round(math.Floor(v/step+1e-9)*step*1e8) / 1e8
+}
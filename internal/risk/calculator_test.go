@@ -98,6 +98,43 @@ func TestCalculatePositionSize(t *testing.T) {
 	t.Log("CalculatePositionSize works correctly for long and short positions")
 }
 
+func TestCalculatePositionSizeWithStep(t *testing.T) {
+	calc, err := NewCalculator(DefaultParams())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Capped at 10% of 10000, so 1000 / 0.0123 = 81300.81 units
+	cases := []struct {
+		step     float64
+		quantity float64
+	}{
+		{0, 81300.81300},
+		{1, 81300},
+		{100, 81300},
+		{0.1, 81300.8},
+	}
+	for _, tc := range cases {
+		position, err := calc.CalculatePositionSizeWithStep(0.0123, "long", tc.step)
+		if err != nil {
+			t.Fatalf("step %v: unexpected error: %v", tc.step, err)
+		}
+		if position.Quantity != tc.quantity {
+			t.Errorf("step %v: expected quantity %v, got %v", tc.step, tc.quantity, position.Quantity)
+		}
+	}
+
+	// Exact multiples of the step are kept
+	position, err := calc.CalculatePositionSizeWithStep(50000, "long", 0.001)
+	if err != nil || position.Quantity != 0.02 {
+		t.Fatalf("expected 0.02, got %+v, %v", position, err)
+	}
+
+	if _, err := calc.CalculatePositionSizeWithStep(50000, "long", -1); err == nil {
+		t.Fatal("expected error for negative step")
+	}
+}
+
 func TestCalculateRiskReward(t *testing.T) {
 	params := RiskParams{
 		AccountBalance:  10000,
@@ -194,3 +231,85 @@ func TestGetRiskMetrics(t *testing.T) {
 
 	t.Log("GetRiskMetrics returns correct values")
 }
+
+func TestValidateTrade(t *testing.T) {
+	params := DefaultParams()
+	params.SymbolCaps = map[string]float64{"DOGEUSDT": 0.05}
+	params.CorrelatedGroups = []ExposureGroup{{Name: "majors", Symbols: []string{"BTCUSDT", "ETHUSDT"}, MaxExposure: 0.3}}
+
+	calc, err := NewCalculator(params)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	cases := []struct {
+		name     string
+		symbol   string
+		value    float64
+		exposure Exposure
+		ok       bool
+	}{
+		{"within every cap", "BTCUSDT", 800, Exposure{"BTCUSDT": 1000}, true},
+		{"position size", "BTCUSDT", 1200, nil, false},
+		{"symbol cap", "SOLUSDT", 800, Exposure{"SOLUSDT": 2000}, false},
+		{"symbol override", "DOGEUSDT", 600, nil, false},
+		{"correlated group", "ETHUSDT", 800, Exposure{"BTCUSDT": 2000, "ETHUSDT": 500}, false},
+		{"total cap", "ADAUSDT", 800, Exposure{"SOLUSDT": 2400, "BNBUSDT": 2000}, false},
+		{"shorts count towards the total", "ADAUSDT", -800, Exposure{"SOLUSDT": -2400, "BNBUSDT": 2000}, false},
+		{"reducing a breached cap", "SOLUSDT", -800, Exposure{"SOLUSDT": 3000, "BNBUSDT": 2500}, true},
+	}
+	for _, tc := range cases {
+		err := calc.ValidateTrade(tc.symbol, tc.value, tc.exposure)
+		if tc.ok && err != nil {
+			t.Errorf("%s: unexpected error: %v", tc.name, err)
+		}
+		if !tc.ok && err == nil {
+			t.Errorf("%s: expected the trade to be rejected", tc.name)
+		}
+	}
+
+	if total := (Exposure{"BTCUSDT": 1000, "ETHUSDT": -500}).Total(); total != 1500 {
+		t.Errorf("expected gross exposure 1500, got %v", total)
+	}
+}
+
+func TestExposureCaps(t *testing.T) {
+	// Parameters stored before the caps existed keep the 50% total cap
+	params := RiskParams{AccountBalance: 10000, RiskPerTrade: 0.02, MaxPositionSize: 0.10, StopLossPercent: 0.05}
+	calc, err := NewCalculator(params)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := calc.ValidateTrade("BTCUSDT", 800, Exposure{"ETHUSDT": 4000}); err != nil {
+		t.Fatalf("unexpected error below the default cap: %v", err)
+	}
+	if err := calc.ValidateTrade("BTCUSDT", 800, Exposure{"ETHUSDT": 4500}); err == nil {
+		t.Fatal("expected the default total cap to apply")
+	}
+
+	params.MaxTotalExposure = 0.8
+	calc, err = NewCalculator(params)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := calc.ValidateRiskLimits(800, 4500); err != nil {
+		t.Fatalf("expected the configured total cap to apply: %v", err)
+	}
+
+	invalid := []func(*RiskParams){
+		func(p *RiskParams) { p.MaxTotalExposure = 1.5 },
+		func(p *RiskParams) { p.MaxSymbolExposure = -0.1 },
+		func(p *RiskParams) { p.SymbolCaps = map[string]float64{"BTCUSDT": 0} },
+		func(p *RiskParams) { p.CorrelatedGroups = []ExposureGroup{{Name: "empty", MaxExposure: 0.2}} },
+		func(p *RiskParams) {
+			p.CorrelatedGroups = []ExposureGroup{{Name: "majors", Symbols: []string{"BTCUSDT"}, MaxExposure: 2}}
+		},
+	}
+	for i, apply := range invalid {
+		p := DefaultParams()
+		apply(&p)
+		if _, err := NewCalculator(p); err == nil {
+			t.Errorf("case %d: expected invalid exposure caps to be rejected", i)
+		}
+	}
+}
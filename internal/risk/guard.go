@@ -0,0 +1,78 @@
+package risk
+
+import (
+	"fmt"
+	"time"
+)
+
+// GuardState is what the circuit breaker remembers of a bot's equity
+// between checks. It is kept in the risk_guards table so that restarts do
+// not reset the day's losses or the drawdown peak.
+type GuardState struct {
+	BotID          string     `json:"bot_id"`
+	Since          time.Time  `json:"since"` // Losing trades before this do not count
+	Day            time.Time  `json:"day"`   // UTC day DayStartEquity was taken on
+	DayStartEquity float64    `json:"day_start_equity"`
+	PeakEquity     float64    `json:"peak_equity"`
+	TrippedAt      *time.Time `json:"tripped_at,omitempty"`
+	Reason         string     `json:"reason,omitempty"`
+}
+
+// NewGuardState starts watching a bot's equity from now
+func NewGuardState(botID string, equity float64, now time.Time) GuardState {
+	return GuardState{
+		BotID:          botID,
+		Since:          now,
+		Day:            utcDay(now),
+		DayStartEquity: equity,
+		PeakEquity:     equity,
+	}
+}
+
+// Tripped reports whether the circuit breaker has stopped the bot
+func (s GuardState) Tripped() bool {
+	return s.TrippedAt != nil
+}
+
+func utcDay(t time.Time) time.Time {
+	return t.UTC().Truncate(24 * time.Hour)
+}
+
+// Guarded reports whether any circuit breaker limit is set
+func (c *Calculator) Guarded() bool {
+	return c.params.MaxDailyLoss > 0 || c.params.MaxDrawdown > 0 || c.params.MaxConsecutiveLosses > 0
+}
+
+// CheckGuard updates state with the bot's current equity, realized plus
+// unrealized PnL on top of AccountBalance, and the number of trades in a
+// row that closed at a loss since state.Since. It trips the state and
+// returns the reason when the day's loss, the drawdown from the equity peak
+// or the losing streak reaches its limit, and returns "" otherwise. A
+// tripped state stays tripped.
+func (c *Calculator) CheckGuard(state *GuardState, equity float64, losses int, now time.Time) string {
+	if state.Tripped() {
+		return state.Reason
+	}
+
+	if day := utcDay(now); !state.Day.Equal(day) {
+		state.Day, state.DayStartEquity = day, equity
+	}
+	if equity > state.PeakEquity {
+		state.PeakEquity = equity
+	}
+
+	reason := ""
+	switch {
+	case c.params.MaxDailyLoss > 0 && state.DayStartEquity-equity >= c.params.AccountBalance*c.params.MaxDailyLoss:
+		reason = fmt.Sprintf("daily loss of %.2f reached the %g%% limit", state.DayStartEquity-equity, c.params.MaxDailyLoss*100)
+	case c.params.MaxDrawdown > 0 && state.PeakEquity > 0 && (state.PeakEquity-equity)/state.PeakEquity >= c.params.MaxDrawdown:
+		reason = fmt.Sprintf("drawdown of %.2f%% from the %.2f peak reached the %g%% limit",
+			(state.PeakEquity-equity)/state.PeakEquity*100, state.PeakEquity, c.params.MaxDrawdown*100)
+	case c.params.MaxConsecutiveLosses > 0 && losses >= c.params.MaxConsecutiveLosses:
+		reason = fmt.Sprintf("%d losing trades in a row reached the limit of %d", losses, c.params.MaxConsecutiveLosses)
+	}
+	if reason != "" {
+		state.TrippedAt, state.Reason = &now, reason
+	}
+	return reason
+}
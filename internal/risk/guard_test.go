@@ -0,0 +1,71 @@
+package risk
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func guardCalculator(t *testing.T, params RiskParams) *Calculator {
+	t.Helper()
+	params.AccountBalance = 10000
+	params.RiskPerTrade = 0.02
+	params.MaxPositionSize = 0.10
+	params.StopLossPercent = 0.05
+	calc, err := NewCalculator(params)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return calc
+}
+
+func TestCheckGuard(t *testing.T) {
+	start := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+
+	calc := guardCalculator(t, RiskParams{MaxDailyLoss: 0.03})
+	if !calc.Guarded() {
+		t.Fatal("expected a daily loss limit to guard the bot")
+	}
+	state := NewGuardState("bot", 10000, start)
+	if reason := calc.CheckGuard(&state, 9750, 0, start.Add(time.Hour)); reason != "" {
+		t.Fatalf("unexpected trip: %s", reason)
+	}
+	// A new day starts from the equity it opens with
+	if reason := calc.CheckGuard(&state, 9750, 0, start.Add(16*time.Hour)); reason != "" {
+		t.Fatalf("unexpected trip: %s", reason)
+	}
+	if state.DayStartEquity != 9750 {
+		t.Errorf("expected the day to start at 9750, got %g", state.DayStartEquity)
+	}
+	reason := calc.CheckGuard(&state, 9450, 0, start.Add(17*time.Hour))
+	if !strings.Contains(reason, "daily loss") || !state.Tripped() {
+		t.Fatalf("expected the daily loss limit to trip, got %q", reason)
+	}
+	// Tripped stays tripped whatever the equity does
+	if again := calc.CheckGuard(&state, 20000, 0, start.Add(18*time.Hour)); again != reason {
+		t.Errorf("expected the trip to stick, got %q", again)
+	}
+
+	calc = guardCalculator(t, RiskParams{MaxDrawdown: 0.1})
+	state = NewGuardState("bot", 10000, start)
+	calc.CheckGuard(&state, 12000, 0, start.Add(time.Hour))
+	if reason := calc.CheckGuard(&state, 10900, 0, start.Add(2*time.Hour)); reason != "" {
+		t.Fatalf("unexpected trip at a 9%% drawdown: %s", reason)
+	}
+	if reason := calc.CheckGuard(&state, 10800, 0, start.Add(3*time.Hour)); !strings.Contains(reason, "drawdown") {
+		t.Fatalf("expected the drawdown from the 12000 peak to trip, got %q", reason)
+	}
+
+	calc = guardCalculator(t, RiskParams{MaxConsecutiveLosses: 3})
+	state = NewGuardState("bot", 10000, start)
+	if reason := calc.CheckGuard(&state, 10000, 2, start); reason != "" {
+		t.Fatalf("unexpected trip: %s", reason)
+	}
+	if reason := calc.CheckGuard(&state, 10000, 3, start); !strings.Contains(reason, "losing trades") {
+		t.Fatalf("expected the losing streak to trip, got %q", reason)
+	}
+
+	if guardCalculator(t, RiskParams{}).Guarded() {
+		t.Error("expected no guard without limits")
+	}
+}
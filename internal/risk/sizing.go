@@ -0,0 +1,192 @@
+package risk
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// Sizing modes selected by RiskParams.SizingMode
+const (
+	// SizingFixed risks RiskPerTrade of the account with the stop
+	// StopLossPercent away from the entry
+	SizingFixed = "fixed"
+	// SizingATR risks RiskPerTrade of the account with the stop
+	// ATRMultiplier average true ranges away, so quiet markets get larger
+	// positions and volatile ones smaller
+	SizingATR = "atr"
+	// SizingVolatility sizes the position so that a move of one average
+	// true range changes the account by TargetVolatility
+	SizingVolatility = "volatility"
+	// SizingKelly risks KellyFraction of the Kelly bet for the chance the
+	// trade wins, estimated from the prediction's conviction and the
+	// historical hit rate
+	SizingKelly = "kelly"
+)
+
+// Defaults for sizing parameters left zero
+const (
+	DefaultATRMultiplier    = 2.0
+	DefaultTargetVolatility = 0.01
+	DefaultKellyFraction    = 0.25
+)
+
+// MinKellySamples is how many past predictions a hit rate needs before
+// Kelly sizing trusts it alongside the conviction
+const MinKellySamples = 20
+
+// ErrNoEdge is returned by Kelly sizing when the estimated chance of
+// winning does not justify any position
+var ErrNoEdge = errors.New("no edge to size a position for")
+
+// ErrNoATR is returned by the ATR based modes when no ATR reading is given,
+// e.g. before the indicator has warmed up
+var ErrNoATR = errors.New("sizing mode needs an ATR reading")
+
+// SizingInputs are the market and track record readings the sizing modes
+// other than SizingFixed need. Readings a mode does not use may be zero.
+type SizingInputs struct {
+	ATR        float64 // Average true range of the symbol, in price
+	Conviction float64 // Conviction of the prediction being traded, 0 to 1
+	HitRate    float64 // Share of past predictions that were right, 0 to 1
+	Samples    int     // Number of past predictions HitRate is measured over
+}
+
+// SizingModes lists the valid values of RiskParams.SizingMode
+func SizingModes() []string {
+	return []string{SizingFixed, SizingATR, SizingVolatility, SizingKelly}
+}
+
+func validateSizing(params RiskParams) error {
+	switch params.SizingMode {
+	case "", SizingFixed, SizingATR, SizingVolatility, SizingKelly:
+	default:
+		return fmt.Errorf("unknown sizing mode %q", params.SizingMode)
+	}
+	if params.ATRMultiplier < 0 {
+		return errors.New("ATR multiplier must not be negative")
+	}
+	if params.TargetVolatility < 0 || params.TargetVolatility > 1 {
+		return errors.New("target volatility must be between 0 and 1")
+	}
+	if params.KellyFraction < 0 || params.KellyFraction > 1 {
+		return errors.New("kelly fraction must be between 0 and 1")
+	}
+	return nil
+}
+
+// SizingMode returns the mode Size uses
+func (c *Calculator) SizingMode() string {
+	if c.params.SizingMode == "" {
+		return SizingFixed
+	}
+	return c.params.SizingMode
+}
+
+// Size calculates the position for a trade in the configured sizing mode,
+// with the quantity rounded down to a multiple of step, the symbol's lot
+// size. A zero step uses DefaultQuantityStep. Every mode is capped at
+// MaxPositionSize. The ATR modes return ErrNoATR without an ATR reading,
+// and Kelly sizing returns ErrNoEdge when the trade is not expected to pay.
+func (c *Calculator) Size(currentPrice float64, direction string, step float64, in SizingInputs) (*PositionSize, error) {
+	if step < 0 {
+		return nil, errors.New("quantity step must not be negative")
+	}
+	if step == 0 {
+		step = DefaultQuantityStep
+	}
+	if currentPrice <= 0 {
+		return nil, errors.New("current price must be positive")
+	}
+	if direction != "long" && direction != "short" {
+		return nil, errors.New("direction must be 'long' or 'short'")
+	}
+
+	balance := c.params.AccountBalance
+	stopDistance := currentPrice * c.params.StopLossPercent
+
+	switch c.SizingMode() {
+	case SizingATR:
+		if in.ATR <= 0 {
+			return nil, ErrNoATR
+		}
+		atrDistance := in.ATR * orDefault(c.params.ATRMultiplier, DefaultATRMultiplier)
+		if direction == "long" && atrDistance >= currentPrice {
+			return nil, errors.New("ATR stop would be below zero")
+		}
+		return c.sized(currentPrice, direction, step, balance*c.params.RiskPerTrade, atrDistance), nil
+
+	case SizingVolatility:
+		if in.ATR <= 0 {
+			return nil, ErrNoATR
+		}
+		// A one ATR move costs quantity*ATR
+		quantity := balance * orDefault(c.params.TargetVolatility, DefaultTargetVolatility) / in.ATR
+		return c.sized(currentPrice, direction, step, quantity*stopDistance, stopDistance), nil
+
+	case SizingKelly:
+		fraction := orDefault(c.params.KellyFraction, DefaultKellyFraction) * Kelly(winProbability(in), 1)
+		if fraction <= 0 {
+			return nil, ErrNoEdge
+		}
+		return c.sized(currentPrice, direction, step, balance*fraction, stopDistance), nil
+	}
+
+	return c.sized(currentPrice, direction, step, balance*c.params.RiskPerTrade, stopDistance), nil
+}
+
+// sized builds the position losing riskAmount if the price moves
+// stopDistance against it, capped at MaxPositionSize
+func (c *Calculator) sized(currentPrice float64, direction string, step, riskAmount, stopDistance float64) *PositionSize {
+	stopLoss := currentPrice - stopDistance
+	if direction == "short" {
+		stopLoss = currentPrice + stopDistance
+	}
+
+	quantity := riskAmount / stopDistance
+	positionValue := quantity * currentPrice
+
+	maxPositionValue := c.params.AccountBalance * c.params.MaxPositionSize
+	if positionValue > maxPositionValue {
+		quantity = maxPositionValue / currentPrice
+		positionValue = maxPositionValue
+	}
+
+	return &PositionSize{
+		Quantity:   floorToStep(quantity, step),
+		Value:      math.Floor(positionValue*100) / 100, // Round to 2 decimal places
+		StopLoss:   math.Floor(stopLoss*100) / 100,      // Round to 2 decimal places
+		RiskAmount: math.Floor(riskAmount*100) / 100,    // Round to 2 decimal places
+	}
+}
+
+// Kelly returns the share of the account the Kelly criterion bets on a
+// trade won with probability p that pays payoff times what it risks. It is
+// negative when the trade is expected to lose.
+func Kelly(p, payoff float64) float64 {
+	if payoff <= 0 {
+		return -1
+	}
+	return p - (1-p)/payoff
+}
+
+// winProbability estimates the chance a trade wins: the conviction alone,
+// or averaged with the hit rate once that has enough samples to go on
+func winProbability(in SizingInputs) float64 {
+	p := clamp01(in.Conviction)
+	if in.Samples >= MinKellySamples {
+		p = (p + clamp01(in.HitRate)) / 2
+	}
+	return p
+}
+
+func clamp01(v float64) float64 {
+	return math.Max(0, math.Min(1, v))
+}
+
+func orDefault(v, def float64) float64 {
+	if v == 0 {
+		return def
+	}
+	return v
+}
@@ -0,0 +1,106 @@
+package risk
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func sizingCalculator(t *testing.T, mode string) *Calculator {
+	t.Helper()
+	calc, err := NewCalculator(RiskParams{
+		AccountBalance:  10000,
+		RiskPerTrade:    0.02,
+		MaxPositionSize: 1,
+		StopLossPercent: 0.05,
+		SizingMode:      mode,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return calc
+}
+
+func TestSizeModes(t *testing.T) {
+	tests := []struct {
+		mode      string
+		direction string
+		in        SizingInputs
+		quantity  float64
+		stopLoss  float64
+		risk      float64
+	}{
+		// 200 risked over a 5 stop
+		{SizingFixed, "long", SizingInputs{}, 40, 95, 200},
+		// 200 risked over a 2 ATR stop of 4
+		{SizingATR, "long", SizingInputs{ATR: 2}, 50, 96, 200},
+		{SizingATR, "short", SizingInputs{ATR: 2}, 50, 104, 200},
+		// A 2 move on 50 units costs 1% of the account
+		{SizingVolatility, "long", SizingInputs{ATR: 2}, 50, 95, 250},
+		// Quarter of a 0.2 Kelly bet risked over a 5 stop
+		{SizingKelly, "long", SizingInputs{Conviction: 0.6}, 100, 95, 500},
+		// The hit rate pulls a 0.8 conviction down to 0.6
+		{SizingKelly, "short", SizingInputs{Conviction: 0.8, HitRate: 0.4, Samples: MinKellySamples}, 100, 105, 500},
+	}
+
+	for _, tt := range tests {
+		size, err := sizingCalculator(t, tt.mode).Size(100, tt.direction, 0, tt.in)
+		if err != nil {
+			t.Fatalf("%s %s: unexpected error: %v", tt.mode, tt.direction, err)
+		}
+		if math.Abs(size.Quantity-tt.quantity) > 1e-4 {
+			t.Errorf("%s %s: expected quantity %g, got %g", tt.mode, tt.direction, tt.quantity, size.Quantity)
+		}
+		if math.Abs(size.StopLoss-tt.stopLoss) > 0.01 {
+			t.Errorf("%s %s: expected stop loss %g, got %g", tt.mode, tt.direction, tt.stopLoss, size.StopLoss)
+		}
+		if math.Abs(size.RiskAmount-tt.risk) > 0.01 {
+			t.Errorf("%s %s: expected risk %g, got %g", tt.mode, tt.direction, tt.risk, size.RiskAmount)
+		}
+	}
+}
+
+func TestSizeLimits(t *testing.T) {
+	// Half an ATR of 0.1 is a tiny stop; the position is capped
+	calc, err := NewCalculator(RiskParams{
+		AccountBalance:  10000,
+		RiskPerTrade:    0.02,
+		MaxPositionSize: 0.10,
+		StopLossPercent: 0.05,
+		SizingMode:      SizingATR,
+		ATRMultiplier:   0.5,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	size, err := calc.Size(100, "long", 0, SizingInputs{ATR: 0.1})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if size.Value != 1000 || size.Quantity != 10 {
+		t.Errorf("expected the 1000 cap, got %g units worth %g", size.Quantity, size.Value)
+	}
+
+	if _, err := calc.Size(100, "long", 0, SizingInputs{}); !errors.Is(err, ErrNoATR) {
+		t.Errorf("expected ErrNoATR without an ATR reading, got %v", err)
+	}
+
+	kelly := sizingCalculator(t, SizingKelly)
+	if _, err := kelly.Size(100, "long", 0, SizingInputs{Conviction: 0.5}); !errors.Is(err, ErrNoEdge) {
+		t.Errorf("expected ErrNoEdge for a coin flip, got %v", err)
+	}
+	// Too few samples to trust the poor hit rate
+	if _, err := kelly.Size(100, "long", 0, SizingInputs{Conviction: 0.7, HitRate: 0.1, Samples: 3}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	if _, err := NewCalculator(RiskParams{
+		AccountBalance:  10000,
+		RiskPerTrade:    0.02,
+		MaxPositionSize: 0.10,
+		StopLossPercent: 0.05,
+		SizingMode:      "martingale",
+	}); err == nil {
+		t.Error("expected an error for an unknown sizing mode")
+	}
+}
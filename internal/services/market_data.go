@@ -6,17 +6,36 @@ import (
 	"fmt"
 	"log"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/adeilh/agentic_go_signals/internal/db"
+	"github.com/adeilh/agentic_go_signals/internal/exchange"
+	"github.com/adeilh/agentic_go_signals/internal/indicators"
+	"github.com/adeilh/agentic_go_signals/internal/orderbook"
 	"github.com/adeilh/agentic_go_signals/internal/trader"
 )
 
+// indicatorBars is how many klines live indicator readings are computed
+// from, well past the warm-up so that the smoothed indicators have settled
+const indicatorBars = 300
+
+// orderBookDepth is how many levels of each local order book side are
+// stored and broadcast
+const orderBookDepth = 20
+
+// Closed base klines are rolled up as they arrive; the periodic rollup only
+// repairs the last day, e.g. after a restart or a backfill
+const (
+	rollupEvery    = 15 * time.Minute
+	rollupLookback = 24 * time.Hour
+)
+
 type MarketDataService struct {
-	binanceClient   *trader.Client
+	exchange        exchange.Exchange
 	wsHub           *trader.WSHub
-	wsManager       *trader.BinanceWebSocketManager
+	wsManager       exchange.MarketStream
 	marketDataStore *db.MarketDataStore
 	symbols         []string
 	mu              sync.RWMutex
@@ -24,6 +43,8 @@ type MarketDataService struct {
 	running         bool
 	cancel          context.CancelFunc
 	legacyBroadcast chan<- []byte // Channel to broadcast to legacy WebSocket clients
+	depthHandlers   []trader.DepthHandler
+	books           map[string]*orderbook.Syncer // Local order books by symbol
 }
 
 type PriceData struct {
@@ -77,16 +98,17 @@ type KlineData struct {
 	IsClosed  bool      `json:"isClosed"`
 }
 
-func NewMarketDataService(binanceClient *trader.Client, wsHub *trader.WSHub, database *db.DB) *MarketDataService {
+func NewMarketDataService(ex exchange.Exchange, wsHub *trader.WSHub, database *db.DB) *MarketDataService {
 	symbols := []string{"BTCUSDT", "ETHUSDT", "BNBUSDT", "ADAUSDT", "SOLUSDT"}
 
 	service := &MarketDataService{
-		binanceClient:   binanceClient,
+		exchange:        ex,
 		wsHub:           wsHub,
-		wsManager:       trader.NewBinanceWebSocketManager(binanceClient, symbols),
+		wsManager:       ex.NewMarketStream(symbols),
 		marketDataStore: db.NewMarketDataStore(database),
 		symbols:         symbols,
 		priceCache:      make(map[string]PriceData),
+		books:           make(map[string]*orderbook.Syncer),
 	}
 
 	// Set up WebSocket data handlers
@@ -95,6 +117,40 @@ func NewMarketDataService(binanceClient *trader.Client, wsHub *trader.WSHub, dat
 	return service
 }
 
+// OnDepth registers a handler that receives the top of each synced local
+// order book after every depth update, e.g. to keep a paper broker's books
+// current
+func (s *MarketDataService) OnDepth(handler trader.DepthHandler) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.depthHandlers = append(s.depthHandlers, handler)
+}
+
+// OrderBook returns the local order book kept for symbol; ok is false until
+// its first depth update
+func (s *MarketDataService) OrderBook(symbol string) (syncer *orderbook.Syncer, ok bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	syncer, ok = s.books[strings.ToUpper(symbol)]
+	return syncer, ok
+}
+
+// orderBook returns symbol's local order book, creating it on first use.
+// Snapshots are taken from the market stream's venue, which the depth diffs
+// come from, rather than the exchange orders are placed on.
+func (s *MarketDataService) orderBook(symbol string) *orderbook.Syncer {
+	symbol = strings.ToUpper(symbol)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	syncer, ok := s.books[symbol]
+	if !ok {
+		syncer = orderbook.NewSyncer(symbol, s.wsManager)
+		s.books[symbol] = syncer
+	}
+	return syncer
+}
+
 // SetLegacyBroadcast sets the legacy broadcast channel for backward compatibility
 func (s *MarketDataService) SetLegacyBroadcast(broadcast chan<- []byte) {
 	s.legacyBroadcast = broadcast
@@ -111,7 +167,7 @@ func (s *MarketDataService) StartRealtimeStateBroadcast(ctx context.Context) {
 			return
 		case <-ticker.C:
 			// Get real-time market state for all symbols
-			var realTimeStates []map[string]interface{}
+			var realTimeStates []*db.RealTimeState
 			for _, symbol := range s.symbols {
 				if state, err := s.GetRealTimeMarketState(symbol); err == nil {
 					realTimeStates = append(realTimeStates, state)
@@ -227,6 +283,7 @@ func (s *MarketDataService) StartStreaming(ctx context.Context) error {
 	// Start background services
 	go s.updatePriceCache(streamCtx)
 	go s.persistMarketData(streamCtx)
+	go s.materializeRollups(streamCtx)
 	go s.StartRealtimeStateBroadcast(streamCtx) // Start periodic real-time state broadcasting
 	go s.StartTickerBroadcast(streamCtx) // Start periodic ticker data broadcasting
 
@@ -257,6 +314,11 @@ func (s *MarketDataService) IsRunning() bool {
 	return s.running
 }
 
+// StreamStats returns the market stream's connection counters
+func (s *MarketDataService) StreamStats() trader.StreamStats {
+	return s.wsManager.Stats()
+}
+
 func (s *MarketDataService) GetPriceData(symbol string) (PriceData, bool) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
@@ -304,7 +366,7 @@ func (s *MarketDataService) updatePriceCache(ctx context.Context) {
 }
 
 func (s *MarketDataService) fetchAndCacheAllPrices() {
-	tickers, err := s.binanceClient.GetTicker24hr("")
+	tickers, err := s.exchange.GetTicker24hr("")
 	if err != nil {
 		log.Printf("Error fetching 24hr tickers: %v", err)
 		return
@@ -358,7 +420,7 @@ func (s *MarketDataService) fetchAndCacheAllPrices() {
 }
 
 func (s *MarketDataService) startTickerStream(ctx context.Context, symbol string) error {
-	return s.binanceClient.SubscribeTickerStream(ctx, symbol, func(event trader.WSTickerEvent) {
+	return s.exchange.SubscribeTickerStream(ctx, symbol, func(event trader.WSTickerEvent) {
 		price, _ := strconv.ParseFloat(event.LastPrice, 64)
 		priceChange, _ := strconv.ParseFloat(event.PriceChange, 64)
 		priceChangePercent, _ := strconv.ParseFloat(event.PriceChangePercent, 64)
@@ -417,7 +479,7 @@ func (s *MarketDataService) startTickerStream(ctx context.Context, symbol string
 }
 
 func (s *MarketDataService) startTradeStream(ctx context.Context, symbol string) error {
-	return s.binanceClient.SubscribeTradeStream(ctx, symbol, func(event trader.WSTradeEvent) {
+	return s.exchange.SubscribeTradeStream(ctx, symbol, func(event trader.WSTradeEvent) {
 		price, _ := strconv.ParseFloat(event.Price, 64)
 		quantity, _ := strconv.ParseFloat(event.Quantity, 64)
 
@@ -441,27 +503,15 @@ func (s *MarketDataService) startTradeStream(ctx context.Context, symbol string)
 }
 
 func (s *MarketDataService) startDepthStream(ctx context.Context, symbol string) error {
-	return s.binanceClient.SubscribeDepthStream(ctx, symbol, 20, func(event trader.WSDepthEvent) {
-		orderBookData := OrderBookData{
-			Symbol:    event.Symbol,
-			Bids:      event.Bids,
-			Asks:      event.Asks,
-			Timestamp: time.UnixMilli(event.EventTime),
-		}
-
-		update := MarketUpdate{
-			Type:      "depth",
-			Symbol:    event.Symbol,
-			Data:      orderBookData,
-			Timestamp: time.Now(),
-		}
-
-		s.wsHub.Broadcast("market_update", update)
-	})
+	// Diff depth, which handleDepthUpdate applies to the local order book
+	return s.exchange.SubscribeDepthStream(ctx, symbol, 0, s.handleDepthUpdate)
 }
 
-func (s *MarketDataService) startKlineStream(ctx context.Context, symbol, interval string) error {
-	return s.binanceClient.SubscribeKlineStream(ctx, symbol, interval, func(event trader.WSKlineEvent) {
+// startKlineStream subscribes to the base interval only; longer intervals
+// are rolled up from it in TiDB rather than streamed separately
+func (s *MarketDataService) startKlineStream(ctx context.Context, symbol string) error {
+	interval := db.BaseInterval
+	return s.exchange.SubscribeKlineStream(ctx, symbol, interval, func(event trader.WSKlineEvent) {
 		open, _ := strconv.ParseFloat(event.Kline.Open, 64)
 		high, _ := strconv.ParseFloat(event.Kline.High, 64)
 		low, _ := strconv.ParseFloat(event.Kline.Low, 64)
@@ -496,39 +546,46 @@ func (s *MarketDataService) startKlineStream(ctx context.Context, symbol, interv
 	})
 }
 
+// MarketSummary is an overview of the cached ticker data across all streamed symbols
+type MarketSummary struct {
+	TotalSymbols   int         `json:"total_symbols"`
+	TotalVolume    float64     `json:"total_volume"`
+	AvgPriceChange float64     `json:"avg_price_change"`
+	MajorGainers   []PriceData `json:"major_gainers"`
+	MajorLosers    []PriceData `json:"major_losers"`
+	LastUpdated    time.Time   `json:"last_updated"`
+	ServiceRunning bool        `json:"service_running"`
+}
+
 // GetMarketSummary returns a summary of current market conditions
-func (s *MarketDataService) GetMarketSummary() map[string]interface{} {
+func (s *MarketDataService) GetMarketSummary() MarketSummary {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	var totalVolume, avgPriceChange float64
-	gainers := make([]PriceData, 0)
-	losers := make([]PriceData, 0)
+	summary := MarketSummary{
+		TotalSymbols:   len(s.priceCache),
+		MajorGainers:   make([]PriceData, 0),
+		MajorLosers:    make([]PriceData, 0),
+		LastUpdated:    time.Now(),
+		ServiceRunning: s.running,
+	}
 
 	for _, data := range s.priceCache {
-		totalVolume += data.QuoteVolume
-		avgPriceChange += data.PriceChangePercent
+		summary.TotalVolume += data.QuoteVolume
+		summary.AvgPriceChange += data.PriceChangePercent
 
 		if data.PriceChangePercent > 5.0 {
-			gainers = append(gainers, data)
+			summary.MajorGainers = append(summary.MajorGainers, data)
 		} else if data.PriceChangePercent < -5.0 {
-			losers = append(losers, data)
+			summary.MajorLosers = append(summary.MajorLosers, data)
 		}
 	}
 
 	if len(s.priceCache) > 0 {
-		avgPriceChange /= float64(len(s.priceCache))
+		summary.AvgPriceChange /= float64(len(s.priceCache))
 	}
 
-	return map[string]interface{}{
-		"total_symbols":    len(s.priceCache),
-		"total_volume":     totalVolume,
-		"avg_price_change": avgPriceChange,
-		"major_gainers":    gainers,
-		"major_losers":     losers,
-		"last_updated":     time.Now(),
-		"service_running":  s.running,
-	}
+	return summary
 }
 
 // persistMarketData handles storing real-time market data to TiDB
@@ -546,6 +603,33 @@ func (s *MarketDataService) persistMarketData(ctx context.Context) {
 	}
 }
 
+// materializeRollups periodically rolls the base klines of the last day up
+// into every materialized interval. Closed klines are rolled up as they
+// arrive; this catches up on klines stored any other way.
+func (s *MarketDataService) materializeRollups(ctx context.Context) {
+	ticker := time.NewTicker(rollupEvery)
+	defer ticker.Stop()
+
+	for {
+		s.mu.RLock()
+		symbols := append([]string(nil), s.symbols...)
+		s.mu.RUnlock()
+
+		now := time.Now()
+		for _, symbol := range symbols {
+			if err := s.marketDataStore.MaterializeRollups(symbol, now.Add(-rollupLookback), now); err != nil {
+				log.Printf("Error materializing kline rollups for %s: %v", symbol, err)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
 // storeCachedPricesToTiDB stores current price cache to TiDB
 func (s *MarketDataService) storeCachedPricesToTiDB() {
 	s.mu.RLock()
@@ -641,75 +725,110 @@ func (s *MarketDataService) GetMarketDataFromTiDB() map[string]interface{} {
 	return result
 }
 
-// GetTradingSignalsFromTiDB analyzes stored data for trading decisions
-func (s *MarketDataService) GetTradingSignalsFromTiDB(symbol string) map[string]interface{} {
-	signals := make(map[string]interface{})
+// TradingSignals is a simple trend and volume read of a symbol's stored data.
+// Direction and sentiment are empty when there was not enough data.
+type TradingSignals struct {
+	Symbol             string            `json:"symbol"`
+	PriceDirection     string            `json:"price_direction,omitempty"` // BULLISH, BEARISH, SIDEWAYS
+	PriceChangePercent *float64          `json:"price_change_percent,omitempty"`
+	VolumeSentiment    string            `json:"volume_sentiment,omitempty"` // BULLISH, BEARISH, NEUTRAL
+	PriceDataPoints    int               `json:"price_data_points"`
+	TradeDataPoints    int               `json:"trade_data_points"`
+	VolumeMetrics      *db.TradingVolume `json:"volume_metrics"`
+	AnalysisTime       time.Time         `json:"analysis_time"`
+}
 
+// GetTradingSignalsFromTiDB analyzes stored data for trading decisions
+func (s *MarketDataService) GetTradingSignalsFromTiDB(symbol string) (*TradingSignals, error) {
 	// Get price history for trend analysis
 	priceHistory, err := s.marketDataStore.GetPriceHistory(symbol, 50)
 	if err != nil {
-		log.Printf("Error fetching price history: %v", err)
-		return signals
+		return nil, fmt.Errorf("failed to fetch price history: %w", err)
 	}
 
 	// Get recent trades for volume analysis
 	recentTrades, err := s.marketDataStore.GetRecentTrades(symbol, 100)
 	if err != nil {
-		log.Printf("Error fetching recent trades: %v", err)
-		return signals
+		return nil, fmt.Errorf("failed to fetch recent trades: %w", err)
 	}
 
 	// Get volume metrics
 	volumeMetrics, err := s.marketDataStore.GetTradingVolume(symbol, 24)
 	if err != nil {
-		log.Printf("Error fetching volume metrics: %v", err)
-		return signals
+		return nil, fmt.Errorf("failed to fetch volume metrics: %w", err)
+	}
+
+	signals := &TradingSignals{
+		Symbol:          symbol,
+		PriceDataPoints: len(priceHistory),
+		TradeDataPoints: len(recentTrades),
+		VolumeMetrics:   volumeMetrics,
+		AnalysisTime:    time.Now(),
 	}
 
 	// Simple trend analysis
-	if len(priceHistory) >= 2 {
+	if len(priceHistory) >= 2 && priceHistory[1].Price > 0 {
 		latest := priceHistory[0].Price
 		previous := priceHistory[1].Price
-		priceDirection := "SIDEWAYS"
 
 		changePercent := ((latest - previous) / previous) * 100
+		signals.PriceChangePercent = &changePercent
+		signals.PriceDirection = "SIDEWAYS"
 		if changePercent > 2.0 {
-			priceDirection = "BULLISH"
+			signals.PriceDirection = "BULLISH"
 		} else if changePercent < -2.0 {
-			priceDirection = "BEARISH"
+			signals.PriceDirection = "BEARISH"
 		}
-
-		signals["price_direction"] = priceDirection
-		signals["price_change_percent"] = changePercent
 	}
 
 	// Volume analysis
-	if volumeRatio, exists := volumeMetrics["volume_ratio"]; exists && volumeRatio > 0 {
-		if volumeRatio > 0.6 {
-			signals["volume_sentiment"] = "BULLISH"
-		} else if volumeRatio < 0.4 {
-			signals["volume_sentiment"] = "BEARISH"
+	if ratio := volumeMetrics.VolumeRatio; ratio != nil && *ratio > 0 {
+		if *ratio > 0.6 {
+			signals.VolumeSentiment = "BULLISH"
+		} else if *ratio < 0.4 {
+			signals.VolumeSentiment = "BEARISH"
 		} else {
-			signals["volume_sentiment"] = "NEUTRAL"
+			signals.VolumeSentiment = "NEUTRAL"
 		}
 	}
 
-	signals["symbol"] = symbol
-	signals["price_data_points"] = len(priceHistory)
-	signals["trade_data_points"] = len(recentTrades)
-	signals["volume_metrics"] = volumeMetrics
-	signals["analysis_time"] = time.Now()
-
-	return signals
+	return signals, nil
 }
 
 // GetAdvancedTiDBSignals uses TiDB's analytical capabilities for sophisticated trading signals
-func (s *MarketDataService) GetAdvancedTiDBSignals(symbol string) (map[string]interface{}, error) {
+func (s *MarketDataService) GetAdvancedTiDBSignals(symbol string) (*db.AdvancedSignals, error) {
 	return s.marketDataStore.GetAdvancedSignals(symbol)
 }
 
+// GetRecentKlines returns up to limit klines of symbol, oldest first.
+// Intervals other than the streamed base come from its rollups.
+func (s *MarketDataService) GetRecentKlines(symbol, interval string, limit int) ([]db.MarketKline, error) {
+	bars, err := s.marketDataStore.GetKlineData(symbol, interval, limit)
+	if err != nil {
+		return nil, err
+	}
+	// GetKlineData returns newest first
+	for i, j := 0, len(bars)-1; i < j; i, j = i+1, j-1 {
+		bars[i], bars[j] = bars[j], bars[i]
+	}
+	return bars, nil
+}
+
+// GetIndicators returns every indicator's latest reading over symbol's
+// stored klines of interval
+func (s *MarketDataService) GetIndicators(symbol, interval string) (*indicators.Readings, error) {
+	bars, err := s.GetRecentKlines(symbol, interval, indicatorBars)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load klines: %w", err)
+	}
+	if len(bars) == 0 {
+		return nil, fmt.Errorf("no %s klines stored for %s", interval, symbol)
+	}
+	return indicators.Latest(bars), nil
+}
+
 // GetRealTimeMarketState uses TiDB's real-time capabilities for instant analysis
-func (s *MarketDataService) GetRealTimeMarketState(symbol string) (map[string]interface{}, error) {
+func (s *MarketDataService) GetRealTimeMarketState(symbol string) (*db.RealTimeState, error) {
 	return s.marketDataStore.GetRealTimeMarketState(symbol)
 }
 
@@ -802,18 +921,44 @@ func (s *MarketDataService) handleTradeUpdate(event trader.WSTradeEvent) {
 	// log.Printf("⚡ %s Trade: %s @ %s", event.Symbol, event.Quantity, event.Price)
 }
 
+// handleDepthUpdate applies a diff-depth event to the symbol's local order
+// book and publishes the top of the book once it is synced
 func (s *MarketDataService) handleDepthUpdate(event trader.WSDepthEvent) {
+	syncer := s.orderBook(event.Symbol)
+	if err := syncer.Handle(event); err != nil {
+		log.Printf("Order book %s not synced: %v", event.Symbol, err)
+		return
+	}
+	book := syncer.Book()
+	bids, asks := book.Top(orderBookDepth)
+	top := trader.WSDepthEvent{
+		EventType:     event.EventType,
+		EventTime:     event.EventTime,
+		Symbol:        book.Symbol(),
+		FirstUpdateId: event.FirstUpdateId,
+		FinalUpdateId: book.LastUpdateID(),
+		Bids:          bids,
+		Asks:          asks,
+	}
+
 	// Store to TiDB
-	err := s.StoreOrderBookData(event.Symbol, event.Bids, event.Asks)
+	err := s.StoreOrderBookData(top.Symbol, top.Bids, top.Asks)
 	if err != nil {
-		log.Printf("Error storing order book data for %s: %v", event.Symbol, err)
+		log.Printf("Error storing order book data for %s: %v", top.Symbol, err)
+	}
+
+	s.mu.RLock()
+	handlers := s.depthHandlers
+	s.mu.RUnlock()
+	for _, handler := range handlers {
+		handler(top)
 	}
 
 	// Broadcast to WebSocket clients
 	update := MarketUpdate{
 		Type:      "depth",
-		Symbol:    event.Symbol,
-		Data:      event,
+		Symbol:    top.Symbol,
+		Data:      top,
 		Timestamp: time.Now(),
 	}
 	s.wsHub.Broadcast("market_update", update)
@@ -839,6 +984,12 @@ func (s *MarketDataService) handleKlineUpdate(event trader.WSKlineEvent) {
 	err := s.StoreKlineData(event.Kline.Symbol, event.Kline.Interval, open, high, low, close, volume, openTime, closeTime, event.Kline.IsClosed)
 	if err != nil {
 		log.Printf("Error storing kline data for %s: %v", event.Kline.Symbol, err)
+	} else if event.Kline.Interval == db.BaseInterval {
+		// Refresh the buckets this minute belongs to in every rollup
+		err := s.marketDataStore.MaterializeRollups(event.Kline.Symbol, openTime, openTime.Add(time.Minute))
+		if err != nil {
+			log.Printf("Error rolling up kline data for %s: %v", event.Kline.Symbol, err)
+		}
 	}
 
 	// Broadcast to WebSocket clients
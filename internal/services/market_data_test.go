@@ -0,0 +1,46 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/adeilh/agentic_go_signals/internal/exchange"
+	"github.com/adeilh/agentic_go_signals/internal/trader"
+)
+
+// otherVenueBooks serves REST order books from another venue than its
+// streams, the way a testnet client does next to the production stream
+type otherVenueBooks struct {
+	*exchange.Fake
+}
+
+func (otherVenueBooks) GetOrderBook(symbol string, limit int) (trader.OrderBook, error) {
+	return trader.OrderBook{LastUpdateId: 7, Bids: [][]string{{"1", "1"}}, Asks: [][]string{{"2", "1"}}}, nil
+}
+
+func TestOrderBookSnapshotsFromStreamVenue(t *testing.T) {
+	fake := exchange.NewFake()
+	fake.SetOrderBook("BTCUSDT", trader.OrderBook{
+		LastUpdateId: 100,
+		Bids:         [][]string{{"99", "1"}},
+		Asks:         [][]string{{"101", "1"}},
+	})
+	service := NewMarketDataService(otherVenueBooks{fake}, trader.NewWSHub(), nil)
+
+	syncer := service.orderBook("btcusdt")
+	syncer.Handle(trader.WSDepthEvent{
+		Symbol: "BTCUSDT", FirstUpdateId: 101, FinalUpdateId: 102,
+		Bids: [][]string{{"99.5", "2"}},
+	})
+	// The snapshot is taken in the background
+	deadline := time.Now().Add(time.Second)
+	for !syncer.Synced() {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the book synced from the stream's venue, got %+v", syncer.Stats())
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if bids, _ := syncer.Book().Top(1); len(bids) != 1 || bids[0][0] != "99.5" {
+		t.Fatalf("unexpected bids %v", bids)
+	}
+}
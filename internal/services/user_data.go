@@ -0,0 +1,177 @@
+package services
+
+import (
+	"context"
+	"log"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/adeilh/agentic_go_signals/internal/db"
+	"github.com/adeilh/agentic_go_signals/internal/exchange"
+	"github.com/adeilh/agentic_go_signals/internal/trader"
+)
+
+// userDataHealthy is how long a user data stream must stay open for the
+// retry delay to start over
+const userDataHealthy = time.Minute
+
+// orderLedger is the part of db.TradeStore the user data service needs
+type orderLedger interface {
+	UpdateOrder(symbol, orderID, status string, qty, price float64, fee trader.Fee) (int64, error)
+}
+
+// UserDataService follows the exchange account's user data stream. Order
+// updates are written to the trades recorded for those orders, balances are
+// cached, and both are rebroadcast to WebSocket clients.
+type UserDataService struct {
+	exchange exchange.Exchange
+	wsHub    *trader.WSHub
+	trades   orderLedger
+
+	mu        sync.RWMutex
+	balances  map[string]trader.WSBalance
+	updatedAt time.Time
+	running   bool
+}
+
+// NewUserDataService creates a service for ex's account. wsHub may be nil.
+func NewUserDataService(ex exchange.Exchange, wsHub *trader.WSHub, database *db.DB) *UserDataService {
+	return &UserDataService{
+		exchange: ex,
+		wsHub:    wsHub,
+		trades:   db.NewTradeStore(database),
+		balances: make(map[string]trader.WSBalance),
+	}
+}
+
+// Run keeps a user data stream open until ctx is done, opening a new one
+// with backoff whenever it fails. Balances are reloaded from the account on every
+// connect so updates missed in between are not lost.
+func (s *UserDataService) Run(ctx context.Context) error {
+	handlers := trader.UserDataHandlers{
+		OnExecutionReport: s.HandleExecutionReport,
+		OnAccountPosition: s.HandleAccountPosition,
+	}
+
+	s.setRunning(true)
+	defer s.setRunning(false)
+
+	backoff := trader.NewBackoff(trader.DefaultReconnectConfig())
+	for {
+		s.loadBalances()
+		started := time.Now()
+		err := s.exchange.SubscribeUserDataStream(ctx, handlers)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if time.Since(started) >= userDataHealthy {
+			backoff.Reset()
+		}
+		delay := backoff.Next()
+		log.Printf("User data stream stopped, retrying in %s: %v", delay.Round(time.Millisecond), err)
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(delay):
+		}
+	}
+}
+
+// HandleExecutionReport updates the trade recorded for the reported order
+// and rebroadcasts the report
+func (s *UserDataService) HandleExecutionReport(report trader.WSExecutionReport) {
+	order := report.Order()
+	qty, avgPrice, err := order.Executed()
+	if err != nil {
+		log.Printf("Execution report for order %d: %v", report.OrderID, err)
+		return
+	}
+	fee, err := report.Fee()
+	if err != nil {
+		log.Printf("Execution report for order %d: %v", report.OrderID, err)
+		return
+	}
+
+	updated, err := s.trades.UpdateOrder(report.Symbol, strconv.FormatInt(report.OrderID, 10), report.OrderStatus, qty, avgPrice, fee)
+	if err != nil {
+		log.Printf("Execution report for order %d: %v", report.OrderID, err)
+	} else if updated > 0 {
+		log.Printf("Order %d %s: %s, %.8f filled @ %.2f",
+			report.OrderID, report.Symbol, report.OrderStatus, qty, avgPrice)
+	}
+
+	s.broadcast("order_update", report.Symbol, report)
+}
+
+// HandleAccountPosition caches the changed balances and rebroadcasts them
+func (s *UserDataService) HandleAccountPosition(position trader.WSAccountPosition) {
+	s.mu.Lock()
+	for _, balance := range position.Balances {
+		s.balances[balance.Asset] = balance
+	}
+	s.updatedAt = time.UnixMilli(position.EventTime)
+	s.mu.Unlock()
+
+	s.broadcast("balance_update", "", position.Balances)
+}
+
+// Balances returns the cached balances sorted by asset and when they last
+// changed
+func (s *UserDataService) Balances() ([]trader.WSBalance, time.Time) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	balances := make([]trader.WSBalance, 0, len(s.balances))
+	for _, balance := range s.balances {
+		balances = append(balances, balance)
+	}
+	sort.Slice(balances, func(i, j int) bool { return balances[i].Asset < balances[j].Asset })
+	return balances, s.updatedAt
+}
+
+// IsRunning reports whether Run is following the stream
+func (s *UserDataService) IsRunning() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.running
+}
+
+// loadBalances replaces the cached balances with the account's
+func (s *UserDataService) loadBalances() {
+	account, err := s.exchange.GetAccountInfo()
+	if err != nil {
+		log.Printf("Failed to load account balances: %v", err)
+		return
+	}
+
+	balances := make(map[string]trader.WSBalance)
+	for _, balance := range trader.ParseBalances(account) {
+		balances[balance.Asset] = balance
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.balances = balances
+	s.updatedAt = time.Now()
+}
+
+func (s *UserDataService) setRunning(running bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.running = running
+}
+
+func (s *UserDataService) broadcast(updateType, symbol string, data interface{}) {
+	if s.wsHub == nil {
+		return
+	}
+	s.wsHub.Broadcast(updateType, MarketUpdate{
+		Type:      updateType,
+		Symbol:    symbol,
+		Data:      data,
+		Timestamp: time.Now(),
+	})
+}
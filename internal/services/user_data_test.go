@@ -0,0 +1,88 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/adeilh/agentic_go_signals/internal/exchange"
+	"github.com/adeilh/agentic_go_signals/internal/trader"
+)
+
+// orderUpdate is one UpdateOrder call
+type orderUpdate struct {
+	symbol, orderID, status string
+	qty, price              float64
+	fee                     trader.Fee
+}
+
+// memoryOrders records UpdateOrder calls
+type memoryOrders struct {
+	updates []orderUpdate
+}
+
+func (m *memoryOrders) UpdateOrder(symbol, orderID, status string, qty, price float64, fee trader.Fee) (int64, error) {
+	m.updates = append(m.updates, orderUpdate{symbol, orderID, status, qty, price, fee})
+	return 1, nil
+}
+
+func TestUserDataHandlers(t *testing.T) {
+	ledger := &memoryOrders{}
+	service := NewUserDataService(exchange.NewFake(), trader.NewWSHub(), nil)
+	service.trades = ledger
+
+	service.HandleExecutionReport(trader.WSExecutionReport{
+		Symbol:              "BTCUSDT",
+		OrderID:             42,
+		ExecutionType:       "TRADE",
+		OrderStatus:         trader.OrderStatusFilled,
+		CumulativeFilledQty: "0.5",
+		CumulativeQuoteQty:  "50",
+		LastExecutedPrice:   "100",
+		Commission:          "0.05",
+		CommissionAsset:     "USDT",
+	})
+	// Unparseable reports are dropped
+	service.HandleExecutionReport(trader.WSExecutionReport{Symbol: "BTCUSDT", OrderID: 43, CumulativeFilledQty: "x"})
+
+	if len(ledger.updates) != 1 {
+		t.Fatalf("expected one trade update, got %+v", ledger.updates)
+	}
+	if update := ledger.updates[0]; update.orderID != "42" || update.status != "FILLED" || update.qty != 0.5 || update.price != 100 || update.fee.Quote != 0.05 {
+		t.Fatalf("unexpected update %+v", update)
+	}
+
+	service.HandleAccountPosition(trader.WSAccountPosition{
+		EventTime: 1700000000000,
+		Balances:  []trader.WSBalance{{Asset: "USDT", Free: "950", Locked: "0"}, {Asset: "BTC", Free: "0.5", Locked: "0"}},
+	})
+	service.HandleAccountPosition(trader.WSAccountPosition{
+		EventTime: 1700000001000,
+		Balances:  []trader.WSBalance{{Asset: "USDT", Free: "900", Locked: "50"}},
+	})
+	balances, updatedAt := service.Balances()
+	if len(balances) != 2 || balances[0].Asset != "BTC" || balances[1].Locked != "50" {
+		t.Fatalf("expected merged balances sorted by asset, got %+v", balances)
+	}
+	if !updatedAt.Equal(time.UnixMilli(1700000001000)) {
+		t.Fatalf("unexpected update time %v", updatedAt)
+	}
+}
+
+func TestUserDataServiceRun(t *testing.T) {
+	service := NewUserDataService(exchange.NewFake(), nil, nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- service.Run(ctx) }()
+
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil || service.IsRunning() {
+			t.Fatalf("expected a clean stop, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Run did not return after cancel")
+	}
+}
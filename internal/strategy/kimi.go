@@ -0,0 +1,232 @@
+package strategy
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/adeilh/agentic_go_signals/internal/db"
+	"github.com/adeilh/agentic_go_signals/internal/indicators"
+	"github.com/adeilh/agentic_go_signals/internal/kimi"
+)
+
+const kimiSystemPrompt = `You are an expert crypto analyst with access to real-time TiDB market analytics. Analyze the provided market data, news and on-chain data for the given symbol.
+Respond with a JSON object containing:
+- "dir": "LONG" | "SHORT" | "FLAT"
+- "conv": conviction level 1-100
+- "logic": brief reasoning (max 200 chars)
+
+Consider market structure, sentiment, on-chain activity, and news impact. Be conservative with high conviction levels.`
+
+// Kimi asks the Kimi LLM for a call based on the snapshot's analytics,
+// news and on-chain events
+type Kimi struct {
+	client *kimi.Client
+}
+
+// NewKimi creates a Kimi-backed strategy
+func NewKimi(client *kimi.Client) *Kimi {
+	return &Kimi{client: client}
+}
+
+func (k *Kimi) Name() string {
+	return NameKimi
+}
+
+func (k *Kimi) Decide(ctx context.Context, snap Snapshot) (Decision, error) {
+	if k.client == nil {
+		return Decision{}, fmt.Errorf("kimi client is nil")
+	}
+
+	prediction, err := k.client.Ask(ctx, kimiSystemPrompt, kimiPrompt(snap))
+	if err != nil {
+		return Decision{}, err
+	}
+
+	confidence := clampConfidence(prediction.Conv)
+	return Decision{
+		Action:     normalizeAction(prediction.Dir),
+		Confidence: confidence,
+		Reasoning:  prediction.Logic,
+		Analysis:   Analyze(snap.Signals, snap.RealTime, confidence),
+	}, nil
+}
+
+// kimiPrompt renders the snapshot as the user message for Kimi
+func kimiPrompt(snap Snapshot) string {
+	return fmt.Sprintf(`Symbol: %s
+
+Market Analytics:
+%s
+
+Kline Indicators:
+%s
+
+Recent News:
+%s
+
+On-Chain Metrics:
+%s
+
+Provide your trading signal analysis:`,
+		snap.Symbol,
+		buildMarketContext(snap.Signals, snap.RealTime),
+		buildIndicatorContext(snap.Indicators),
+		buildNewsContext(snap.Events),
+		buildChainContext(snap.Events),
+	)
+}
+
+// buildMarketContext renders the analytics for the prompt. Readings the
+// query could not produce are shown as n/a rather than as zero, so the model
+// never mistakes missing data for a flat market.
+func buildMarketContext(signals *db.AdvancedSignals, realTimeState *db.RealTimeState) string {
+	if signals == nil {
+		return "No market analytics available"
+	}
+	if realTimeState == nil {
+		realTimeState = &db.RealTimeState{}
+	}
+
+	smaCross := "n/a"
+	if signals.SMACross != nil {
+		smaCross = fmt.Sprintf("%t", *signals.SMACross)
+	}
+	volumeSpike := "n/a"
+	if realTimeState.VolumeSurge != nil {
+		volumeSpike = fmt.Sprintf("%t", *realTimeState.VolumeSurge > 1.5)
+	}
+	var buyPressure *float64
+	if realTimeState.BuyPressure != nil {
+		percent := *realTimeState.BuyPressure * 100
+		buyPressure = &percent
+	}
+
+	return fmt.Sprintf(`TECHNICAL INDICATORS:
+- Current Price: $%s
+- SMA10: $%s | SMA20: $%s
+- Price vs SMA10: %s%% | Price vs SMA20: %s%%
+- Golden Cross: %s (SMA10 > SMA20)
+- Volatility: %s
+
+MOMENTUM ANALYSIS:
+- 1min: %s%% | 5min: %s%% | 15min: %s%%
+- Price Trend: %s
+
+VOLUME DYNAMICS:
+- Buy/Sell Ratio: %s (>0.6=Bullish, <0.4=Bearish)
+- Volume Surge: %sx vs previous period
+- Trade Frequency: %d trades/30min
+- Buy Pressure: %s%%
+
+SUPPORT/RESISTANCE:
+- Support: $%s (%s%% away)
+- Resistance: $%s (%s%% away)
+- Risk Zone: %s
+
+REAL-TIME SIGNALS:
+- Volume Spike: %s
+- Volatility Spike: %sx
+- Order Flow: %s`,
+		formatReading(signals.CurrentPrice, 2),
+		formatReading(signals.SMA10, 2),
+		formatReading(signals.SMA20, 2),
+		formatReading(signals.PriceVsSMA10, 2),
+		formatReading(signals.PriceVsSMA20, 2),
+		smaCross,
+		formatReading(signals.Volatility, 4),
+		formatReading(signals.Momentum1Min, 3),
+		formatReading(signals.Momentum5Min, 3),
+		formatReading(signals.Momentum15Min, 3),
+		Trend(signals),
+		formatReading(signals.VolumeRatio, 2),
+		formatReading(realTimeState.VolumeSurge, 2),
+		signals.TradeFrequency,
+		formatReading(buyPressure, 2),
+		formatReading(signals.SupportLevel, 2),
+		formatReading(signals.SupportDistance, 2),
+		formatReading(signals.ResistanceLevel, 2),
+		formatReading(signals.ResistanceDistance, 2),
+		RiskZone(signals),
+		volumeSpike,
+		formatReading(realTimeState.VolatilitySpike, 2),
+		OrderFlow(realTimeState),
+	)
+}
+
+// buildIndicatorContext renders the kline indicators for the prompt
+func buildIndicatorContext(r *indicators.Readings) string {
+	if r == nil {
+		return "No kline indicators available"
+	}
+
+	return fmt.Sprintf(`- EMA20: $%s | EMA50: $%s
+- RSI(14): %s (>70=Overbought, <30=Oversold)
+- MACD: %s | Signal: %s | Histogram: %s
+- Bollinger Bands: $%s / $%s / $%s (upper / middle / lower)
+- ATR(14): $%s
+- VWAP: $%s
+- OBV: %s
+- Stochastic: %%K %s | %%D %s`,
+		formatReading(r.EMA20, 2),
+		formatReading(r.EMA50, 2),
+		formatReading(r.RSI14, 1),
+		formatReading(r.MACD, 4),
+		formatReading(r.MACDSignal, 4),
+		formatReading(r.MACDHist, 4),
+		formatReading(r.BBUpper, 2),
+		formatReading(r.BBMiddle, 2),
+		formatReading(r.BBLower, 2),
+		formatReading(r.ATR14, 2),
+		formatReading(r.VWAP, 2),
+		formatReading(r.OBV, 2),
+		formatReading(r.StochK, 1),
+		formatReading(r.StochD, 1),
+	)
+}
+
+// formatReading formats an optional reading with the given precision, or n/a if missing
+func formatReading(f *float64, precision int) string {
+	if f == nil {
+		return "n/a"
+	}
+	return strconv.FormatFloat(*f, 'f', precision, 64)
+}
+
+func buildNewsContext(events []db.Event) string {
+	var newsEvents []string
+
+	for _, event := range events {
+		if event.Source == "news" && len(newsEvents) < 5 {
+			// Truncate long text
+			text := event.Text
+			if len(text) > 200 {
+				text = text[:200] + "..."
+			}
+			newsEvents = append(newsEvents, text)
+		}
+	}
+
+	if len(newsEvents) == 0 {
+		return "No recent news available"
+	}
+
+	return strings.Join(newsEvents, "\n")
+}
+
+func buildChainContext(events []db.Event) string {
+	var chainEvents []string
+
+	for _, event := range events {
+		if event.Source == "chain" && len(chainEvents) < 3 {
+			chainEvents = append(chainEvents, event.Text)
+		}
+	}
+
+	if len(chainEvents) == 0 {
+		return "No recent chain data available"
+	}
+
+	return strings.Join(chainEvents, "\n")
+}
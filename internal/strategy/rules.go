@@ -0,0 +1,294 @@
+package strategy
+
+import (
+	"context"
+	"fmt"
+	"math"
+
+	"github.com/adeilh/agentic_go_signals/internal/db"
+)
+
+// Analysis holds the rule-based readings derived from a snapshot. Every
+// strategy attaches it to its decision so callers see the same context.
+type Analysis struct {
+	Trend                        string  `json:"trend"`
+	RiskZone                     string  `json:"risk_zone"`
+	OrderFlow                    string  `json:"order_flow"`
+	EntryTimingScore             float64 `json:"entry_timing_score"`
+	Urgency                      string  `json:"urgency"`
+	VolatilityAdjustedConfidence float64 `json:"volatility_adjusted_confidence"`
+}
+
+// Rules is the deterministic strategy built from trend, order flow and risk
+// zone readings of the TiDB analytics
+type Rules struct{}
+
+func (Rules) Name() string {
+	return NameRules
+}
+
+// Decide follows the trend when order flow does not contradict it, scaling
+// confidence by trend strength, flow confirmation and risk zone
+func (Rules) Decide(ctx context.Context, snap Snapshot) (Decision, error) {
+	signals := snap.Signals
+	if signals == nil {
+		signals = SignalsFromBars(snap.Bars)
+	}
+	if signals == nil || signals.CurrentPrice == nil {
+		return Decision{Action: Flat, Reasoning: "no market data"}, nil
+	}
+
+	trend := Trend(signals)
+	flow := OrderFlow(snap.RealTime)
+	zone := RiskZone(signals)
+
+	action, confidence := Flat, 50
+	switch trend {
+	case "STRONG_BULLISH":
+		action, confidence = Long, 75
+	case "BULLISH":
+		action, confidence = Long, 60
+	case "STRONG_BEARISH":
+		action, confidence = Short, 75
+	case "BEARISH":
+		action, confidence = Short, 60
+	}
+
+	switch {
+	case action == Long && (flow == "SELL_FLOW" || flow == "STRONG_SELL_FLOW"),
+		action == Short && (flow == "BUY_FLOW" || flow == "STRONG_BUY_FLOW"):
+		action, confidence = Flat, 40
+	case action == Long && flow == "STRONG_BUY_FLOW",
+		action == Short && flow == "STRONG_SELL_FLOW":
+		confidence += 10
+	}
+
+	if action != Flat {
+		switch zone {
+		case "HIGH_RISK":
+			confidence -= 15
+		case "HIGH_VOLATILITY":
+			confidence -= 10
+		case "SAFE_ZONE":
+			confidence += 5
+		}
+	}
+	confidence = clampConfidence(confidence)
+
+	return Decision{
+		Action:     action,
+		Confidence: confidence,
+		Reasoning:  fmt.Sprintf("trend %s, order flow %s, risk zone %s", trend, flow, zone),
+		Analysis:   Analyze(signals, snap.RealTime, confidence),
+	}, nil
+}
+
+// Analyze computes the rule-based readings for a snapshot and a decision's
+// confidence. Missing signals or state count as zero readings.
+func Analyze(signals *db.AdvancedSignals, realTimeState *db.RealTimeState, confidence int) Analysis {
+	if signals == nil {
+		signals = &db.AdvancedSignals{}
+	}
+	if realTimeState == nil {
+		realTimeState = &db.RealTimeState{}
+	}
+
+	volatility := value(signals.Volatility)
+	analysis := Analysis{
+		Trend:                        Trend(signals),
+		RiskZone:                     RiskZone(signals),
+		OrderFlow:                    OrderFlow(realTimeState),
+		VolatilityAdjustedConfidence: VolatilityAdjustedConfidence(float64(confidence), volatility),
+	}
+
+	momentum := value(signals.Momentum5Min)
+	volumeRatio := value(signals.VolumeRatio)
+	buyPressure := value(realTimeState.BuyPressure)
+	analysis.EntryTimingScore = (momentum + (volumeRatio-0.5)*2 + (buyPressure-0.5)*2) / 3
+
+	switch volumeSurge := value(realTimeState.VolumeSurge); {
+	case volumeSurge > 2.0:
+		analysis.Urgency = "HIGH"
+	case volumeSurge > 1.5:
+		analysis.Urgency = "MEDIUM"
+	default:
+		analysis.Urgency = "LOW"
+	}
+
+	return analysis
+}
+
+// Trend classifies short-term momentum and the SMA cross
+func Trend(signals *db.AdvancedSignals) string {
+	if signals == nil {
+		return "SIDEWAYS"
+	}
+	momentum1 := value(signals.Momentum1Min)
+	momentum5 := value(signals.Momentum5Min)
+	smaCross := signals.SMACross != nil && *signals.SMACross
+
+	if momentum1 > 0.5 && momentum5 > 1.0 && smaCross {
+		return "STRONG_BULLISH"
+	} else if momentum1 > 0.2 && momentum5 > 0.5 {
+		return "BULLISH"
+	} else if momentum1 < -0.5 && momentum5 < -1.0 && !smaCross {
+		return "STRONG_BEARISH"
+	} else if momentum1 < -0.2 && momentum5 < -0.5 {
+		return "BEARISH"
+	}
+	return "SIDEWAYS"
+}
+
+// RiskZone classifies how close price is to support/resistance and how volatile it is
+func RiskZone(signals *db.AdvancedSignals) string {
+	if signals == nil {
+		return "MODERATE"
+	}
+	supportDist := signals.SupportDistance
+	resistanceDist := signals.ResistanceDistance
+
+	if (supportDist != nil && *supportDist < 2.0) || (resistanceDist != nil && *resistanceDist < 2.0) {
+		return "HIGH_RISK" // Near support/resistance
+	} else if value(signals.Volatility) > 0.05 {
+		return "HIGH_VOLATILITY"
+	} else if value(supportDist) > 5.0 && value(resistanceDist) > 5.0 {
+		return "SAFE_ZONE"
+	}
+	return "MODERATE"
+}
+
+// OrderFlow classifies recent buy pressure, treating missing trade data as balanced
+func OrderFlow(realTimeState *db.RealTimeState) string {
+	if realTimeState == nil || realTimeState.BuyPressure == nil {
+		return "BALANCED"
+	}
+	buyPressure := *realTimeState.BuyPressure
+	volumeSurge := value(realTimeState.VolumeSurge)
+
+	if buyPressure > 0.7 && volumeSurge > 1.5 {
+		return "STRONG_BUY_FLOW"
+	} else if buyPressure > 0.6 {
+		return "BUY_FLOW"
+	} else if buyPressure < 0.3 && volumeSurge > 1.5 {
+		return "STRONG_SELL_FLOW"
+	} else if buyPressure < 0.4 {
+		return "SELL_FLOW"
+	}
+	return "BALANCED"
+}
+
+// VolatilityAdjustedConfidence reduces confidence in high volatility environments
+func VolatilityAdjustedConfidence(baseConfidence, volatility float64) float64 {
+	volatilityFactor := 1.0 - (volatility * 2)
+	if volatilityFactor < 0.5 {
+		volatilityFactor = 0.5
+	}
+	return baseConfidence * volatilityFactor
+}
+
+// SignalsFromBars derives the advanced signals the TiDB analytics produce
+// from klines alone, so rule-based strategies can run in backtests. Bars are
+// treated as the price series the live query samples; there is no trade
+// flow, so the volume fields stay nil.
+func SignalsFromBars(bars []db.MarketKline) *db.AdvancedSignals {
+	if len(bars) == 0 {
+		return nil
+	}
+
+	// closeAt returns the close n bars back, or nil if the series is shorter
+	closeAt := func(back int) *float64 {
+		if back >= len(bars) {
+			return nil
+		}
+		price := bars[len(bars)-1-back].ClosePrice
+		return &price
+	}
+
+	last := bars[len(bars)-1]
+	current := last.ClosePrice
+	sma10 := averageClose(tail(bars, 10))
+	sma20 := averageClose(tail(bars, 20))
+
+	window := tail(bars, 20)
+	variance := 0.0
+	for _, bar := range window {
+		variance += (bar.ClosePrice - sma20) * (bar.ClosePrice - sma20)
+	}
+	volatility := math.Sqrt(variance / float64(len(window)))
+
+	// Support and resistance over the last day of 1m bars, as the live query uses
+	support, resistance := math.Inf(1), math.Inf(-1)
+	for _, bar := range tail(bars, 1440) {
+		support = math.Min(support, bar.LowPrice)
+		resistance = math.Max(resistance, bar.HighPrice)
+	}
+
+	signals := &db.AdvancedSignals{
+		Symbol:          last.Symbol,
+		CurrentPrice:    &current,
+		PreviousPrice:   closeAt(1),
+		Price5MinAgo:    closeAt(5),
+		Price15MinAgo:   closeAt(15),
+		SMA10:           &sma10,
+		SMA20:           &sma20,
+		Volatility:      &volatility,
+		SupportLevel:    &support,
+		ResistanceLevel: &resistance,
+		Timestamp:       last.CloseTime,
+	}
+
+	if current > 0 && sma10 > 0 && sma20 > 0 {
+		signals.PriceVsSMA10 = percentChange(sma10, current)
+		signals.PriceVsSMA20 = percentChange(sma20, current)
+		cross := sma10 > sma20
+		signals.SMACross = &cross
+	}
+	if current > 0 && support > 0 && resistance > 0 {
+		signals.SupportDistance = percentChange(support, current)
+		signals.ResistanceDistance = percentChange(current, resistance)
+	}
+	if prev := value(signals.PreviousPrice); prev > 0 {
+		signals.Momentum1Min = percentChange(prev, current)
+	}
+	if prev := value(signals.Price5MinAgo); prev > 0 {
+		signals.Momentum5Min = percentChange(prev, current)
+	}
+	if prev := value(signals.Price15MinAgo); prev > 0 {
+		signals.Momentum15Min = percentChange(prev, current)
+	}
+
+	return signals
+}
+
+// tail returns the last n bars, or all of them if there are fewer
+func tail(bars []db.MarketKline, n int) []db.MarketKline {
+	if len(bars) <= n {
+		return bars
+	}
+	return bars[len(bars)-n:]
+}
+
+func averageClose(bars []db.MarketKline) float64 {
+	if len(bars) == 0 {
+		return 0
+	}
+	sum := 0.0
+	for _, bar := range bars {
+		sum += bar.ClosePrice
+	}
+	return sum / float64(len(bars))
+}
+
+// value dereferences an optional reading, treating a missing one as zero
+func value(f *float64) float64 {
+	if f == nil {
+		return 0
+	}
+	return *f
+}
+
+// percentChange returns the change from base to current in percent
+func percentChange(base, current float64) *float64 {
+	change := (current - base) / base * 100
+	return &change
+}
@@ -0,0 +1,39 @@
+package strategy
+
+import (
+	"context"
+	"fmt"
+)
+
+// SMACross goes long when the fast simple moving average of closes is above
+// the slow one and short when it is below
+type SMACross struct {
+	Fast int
+	Slow int
+}
+
+func (s SMACross) Name() string {
+	return fmt.Sprintf("%s_%d_%d", NameSMACross, s.Fast, s.Slow)
+}
+
+func (s SMACross) Decide(ctx context.Context, snap Snapshot) (Decision, error) {
+	if s.Fast <= 0 || s.Slow <= s.Fast {
+		return Decision{}, fmt.Errorf("invalid SMA periods %d/%d", s.Fast, s.Slow)
+	}
+	if len(snap.Bars) < s.Slow {
+		return Decision{Action: Flat, Reasoning: "not enough bars"}, nil
+	}
+
+	fast := averageClose(tail(snap.Bars, s.Fast))
+	slow := averageClose(tail(snap.Bars, s.Slow))
+
+	decision := Decision{Action: Flat, Confidence: 50}
+	switch {
+	case fast > slow:
+		decision.Action = Long
+	case fast < slow:
+		decision.Action = Short
+	}
+	decision.Reasoning = fmt.Sprintf("SMA%d %.2f vs SMA%d %.2f", s.Fast, fast, s.Slow, slow)
+	return decision, nil
+}
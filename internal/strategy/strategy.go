@@ -0,0 +1,109 @@
+package strategy
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/adeilh/agentic_go_signals/internal/db"
+	"github.com/adeilh/agentic_go_signals/internal/indicators"
+	"github.com/adeilh/agentic_go_signals/internal/kimi"
+)
+
+// Actions a Decision can carry
+const (
+	Long  = "LONG"
+	Short = "SHORT"
+	Flat  = "FLAT"
+)
+
+// Names of the built-in strategies accepted by New
+const (
+	NameKimi     = "kimi"
+	NameRules    = "rules"
+	NameSMACross = "sma_cross"
+)
+
+// Snapshot is the market context a strategy decides on. Live callers fill
+// Signals and RealTime from the TiDB analytics; backtests only fill Bars and
+// Indicators and strategies derive what they need from them.
+type Snapshot struct {
+	Symbol     string
+	Time       time.Time
+	Signals    *db.AdvancedSignals  // Advanced TiDB signals
+	RealTime   *db.RealTimeState    // Real-time market state
+	Events     []db.Event           // Recent news and chain events
+	Bars       []db.MarketKline     // Klines closed by Time, oldest first
+	Indicators *indicators.Readings // Kline indicators as of the last closed bar
+}
+
+// Decision is a strategy's call for a symbol
+type Decision struct {
+	Action     string   `json:"action"`     // LONG, SHORT or FLAT
+	Confidence int      `json:"confidence"` // 0-100
+	Reasoning  string   `json:"reasoning"`
+	Analysis   Analysis `json:"analysis"`
+}
+
+// Strategy turns a market snapshot into a trading decision
+type Strategy interface {
+	Name() string
+	Decide(ctx context.Context, snap Snapshot) (Decision, error)
+}
+
+// Names lists the strategies New can build
+func Names() []string {
+	return []string{NameKimi, NameRules, NameSMACross}
+}
+
+// New builds a built-in strategy by name. An empty name selects the Kimi
+// strategy, which needs a client; the others are pure rules.
+func New(name string, kimiClient *kimi.Client) (Strategy, error) {
+	switch name {
+	case "", NameKimi:
+		if kimiClient == nil {
+			return nil, fmt.Errorf("kimi client is nil")
+		}
+		return NewKimi(kimiClient), nil
+	case NameRules:
+		return Rules{}, nil
+	case NameSMACross:
+		return SMACross{Fast: 10, Slow: 30}, nil
+	default:
+		return nil, fmt.Errorf("unknown strategy %q", name)
+	}
+}
+
+// Valid reports whether name is a strategy New knows about
+func Valid(name string) bool {
+	for _, known := range Names() {
+		if name == known {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeAction maps the actions LLMs and rules produce onto LONG/SHORT/FLAT
+func normalizeAction(action string) string {
+	switch strings.ToUpper(strings.TrimSpace(action)) {
+	case Long, "BUY":
+		return Long
+	case Short, "SELL":
+		return Short
+	default:
+		return Flat
+	}
+}
+
+// clampConfidence keeps a confidence within 0-100
+func clampConfidence(confidence int) int {
+	if confidence < 0 {
+		return 0
+	}
+	if confidence > 100 {
+		return 100
+	}
+	return confidence
+}
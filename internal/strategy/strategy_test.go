@@ -0,0 +1,253 @@
+package strategy
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/adeilh/agentic_go_signals/internal/db"
+	"github.com/adeilh/agentic_go_signals/internal/indicators"
+	"github.com/adeilh/agentic_go_signals/internal/kimi"
+)
+
+func ptr[T any](v T) *T { return &v }
+
+// makeBars builds 1m bars from closes, oldest first
+func makeBars(closes ...float64) []db.MarketKline {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	bars := make([]db.MarketKline, len(closes))
+	for i, c := range closes {
+		bars[i] = db.MarketKline{
+			OpenPrice:  c,
+			HighPrice:  c,
+			LowPrice:   c,
+			ClosePrice: c,
+			OpenTime:   start.Add(time.Duration(i) * time.Minute),
+			CloseTime:  start.Add(time.Duration(i+1)*time.Minute - time.Millisecond),
+		}
+	}
+	return bars
+}
+
+func TestNew(t *testing.T) {
+	if _, err := New(NameKimi, nil); err == nil {
+		t.Fatal("expected error for kimi strategy without a client")
+	}
+
+	strat, err := New("", kimi.NewClient(""))
+	if err != nil || strat.Name() != NameKimi {
+		t.Fatalf("expected kimi strategy by default, got %v, %v", strat, err)
+	}
+
+	for _, name := range []string{NameRules, NameSMACross} {
+		if _, err := New(name, nil); err != nil {
+			t.Fatalf("unexpected error for %s: %v", name, err)
+		}
+	}
+
+	if _, err := New("martingale", nil); err == nil {
+		t.Fatal("expected error for unknown strategy")
+	}
+	if Valid("martingale") || !Valid(NameRules) {
+		t.Fatal("Valid does not match Names")
+	}
+}
+
+func TestTrend(t *testing.T) {
+	cases := []struct {
+		signals *db.AdvancedSignals
+		want    string
+	}{
+		{&db.AdvancedSignals{Momentum1Min: ptr(0.6), Momentum5Min: ptr(1.2), SMACross: ptr(true)}, "STRONG_BULLISH"},
+		{&db.AdvancedSignals{Momentum1Min: ptr(0.3), Momentum5Min: ptr(0.6)}, "BULLISH"},
+		{&db.AdvancedSignals{Momentum1Min: ptr(-0.6), Momentum5Min: ptr(-1.2), SMACross: ptr(false)}, "STRONG_BEARISH"},
+		{&db.AdvancedSignals{Momentum1Min: ptr(-0.3), Momentum5Min: ptr(-0.6), SMACross: ptr(true)}, "BEARISH"},
+		{&db.AdvancedSignals{}, "SIDEWAYS"},
+		{nil, "SIDEWAYS"},
+	}
+
+	for _, tc := range cases {
+		if got := Trend(tc.signals); got != tc.want {
+			t.Errorf("Trend(%+v) = %s, want %s", tc.signals, got, tc.want)
+		}
+	}
+}
+
+func TestRiskZoneAndOrderFlow(t *testing.T) {
+	if got := RiskZone(&db.AdvancedSignals{SupportDistance: ptr(1.0), ResistanceDistance: ptr(10.0)}); got != "HIGH_RISK" {
+		t.Errorf("expected HIGH_RISK near support, got %s", got)
+	}
+	if got := RiskZone(&db.AdvancedSignals{SupportDistance: ptr(6.0), ResistanceDistance: ptr(6.0)}); got != "SAFE_ZONE" {
+		t.Errorf("expected SAFE_ZONE, got %s", got)
+	}
+	if got := RiskZone(&db.AdvancedSignals{}); got != "MODERATE" {
+		t.Errorf("expected MODERATE without levels, got %s", got)
+	}
+
+	if got := OrderFlow(&db.RealTimeState{BuyPressure: ptr(0.8), VolumeSurge: ptr(2.0)}); got != "STRONG_BUY_FLOW" {
+		t.Errorf("expected STRONG_BUY_FLOW, got %s", got)
+	}
+	if got := OrderFlow(&db.RealTimeState{BuyPressure: ptr(0.35)}); got != "SELL_FLOW" {
+		t.Errorf("expected SELL_FLOW, got %s", got)
+	}
+	if got := OrderFlow(&db.RealTimeState{}); got != "BALANCED" {
+		t.Errorf("expected BALANCED without trade data, got %s", got)
+	}
+	if got := OrderFlow(nil); got != "BALANCED" {
+		t.Errorf("expected BALANCED without real-time state, got %s", got)
+	}
+}
+
+func TestRulesDecide(t *testing.T) {
+	ctx := context.Background()
+	bullish := &db.AdvancedSignals{CurrentPrice: ptr(100.0), Momentum1Min: ptr(0.6), Momentum5Min: ptr(1.2), SMACross: ptr(true)}
+
+	decision, err := Rules{}.Decide(ctx, Snapshot{Signals: bullish})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if decision.Action != Long || decision.Confidence != 75 {
+		t.Fatalf("expected LONG at 75, got %s at %d", decision.Action, decision.Confidence)
+	}
+	if decision.Analysis.Trend != "STRONG_BULLISH" {
+		t.Fatalf("expected analysis to carry the trend, got %+v", decision.Analysis)
+	}
+
+	// Selling pressure contradicts the trend
+	decision, _ = Rules{}.Decide(ctx, Snapshot{Signals: bullish, RealTime: &db.RealTimeState{BuyPressure: ptr(0.2)}})
+	if decision.Action != Flat {
+		t.Fatalf("expected FLAT on conflicting order flow, got %s", decision.Action)
+	}
+
+	// Backtests only supply bars
+	closes := make([]float64, 30)
+	for i := range closes {
+		closes[i] = 100 * (1 + 0.004*float64(i))
+	}
+	decision, _ = Rules{}.Decide(ctx, Snapshot{Bars: makeBars(closes...)})
+	if decision.Action != Long {
+		t.Fatalf("expected LONG on rising bars, got %s (%s)", decision.Action, decision.Reasoning)
+	}
+
+	decision, _ = Rules{}.Decide(ctx, Snapshot{})
+	if decision.Action != Flat {
+		t.Fatalf("expected FLAT without data, got %s", decision.Action)
+	}
+}
+
+func TestSMACross(t *testing.T) {
+	ctx := context.Background()
+	strat := SMACross{Fast: 2, Slow: 4}
+
+	cases := []struct {
+		bars []db.MarketKline
+		want string
+	}{
+		{makeBars(1, 2, 3), Flat},
+		{makeBars(1, 2, 3, 4), Long},
+		{makeBars(4, 3, 2, 1), Short},
+	}
+	for _, tc := range cases {
+		decision, err := strat.Decide(ctx, Snapshot{Bars: tc.bars})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if decision.Action != tc.want {
+			t.Errorf("expected %s, got %s", tc.want, decision.Action)
+		}
+	}
+
+	if _, err := (SMACross{Fast: 5, Slow: 2}).Decide(ctx, Snapshot{}); err == nil {
+		t.Fatal("expected error for invalid periods")
+	}
+}
+
+func TestSignalsFromBars(t *testing.T) {
+	if SignalsFromBars(nil) != nil {
+		t.Fatal("expected nil signals without bars")
+	}
+
+	signals := SignalsFromBars(makeBars(100, 101, 102, 103, 104, 105))
+	if value(signals.CurrentPrice) != 105 {
+		t.Fatalf("unexpected current price %v", value(signals.CurrentPrice))
+	}
+	if got := value(signals.Momentum5Min); got != 5 {
+		t.Fatalf("expected 5%% momentum over 5 bars, got %v", got)
+	}
+	if signals.Price15MinAgo != nil || signals.Momentum15Min != nil {
+		t.Fatal("expected no 15 bar momentum with 6 bars")
+	}
+	if signals.VolumeRatio != nil {
+		t.Fatal("expected no volume ratio without trade flow")
+	}
+	if value(signals.SupportLevel) != 100 || value(signals.ResistanceLevel) != 105 {
+		t.Fatalf("unexpected support/resistance %v/%v", value(signals.SupportLevel), value(signals.ResistanceLevel))
+	}
+}
+
+func TestNormalizeAction(t *testing.T) {
+	cases := map[string]string{"LONG": Long, "buy": Long, " SELL ": Short, "SHORT": Short, "HOLD": Flat, "": Flat}
+	for action, want := range cases {
+		if got := normalizeAction(action); got != want {
+			t.Errorf("normalizeAction(%q) = %s, want %s", action, got, want)
+		}
+	}
+}
+
+func TestKimiPrompt(t *testing.T) {
+	events := []db.Event{
+		{Source: "news", Text: "Bitcoin hits new high"},
+		{Source: "chain", Text: "Active addresses: 500000"},
+	}
+
+	prompt := kimiPrompt(Snapshot{Symbol: "BTCUSDT", Events: events})
+	for _, want := range []string{"BTCUSDT", "No market analytics available", "Bitcoin hits new high", "Active addresses"} {
+		if !strings.Contains(prompt, want) {
+			t.Fatalf("expected prompt to contain %q:\n%s", want, prompt)
+		}
+	}
+
+	prompt = kimiPrompt(Snapshot{Symbol: "BTCUSDT", Signals: &db.AdvancedSignals{CurrentPrice: ptr(50000.0)}})
+	if !strings.Contains(prompt, "Current Price: $50000.00") || !strings.Contains(prompt, "No recent news available") {
+		t.Fatalf("unexpected prompt:\n%s", prompt)
+	}
+	// Missing readings must not be reported as zeros
+	if !strings.Contains(prompt, "SMA10: $n/a") || !strings.Contains(prompt, "Buy Pressure: n/a%") {
+		t.Fatalf("expected missing readings as n/a:\n%s", prompt)
+	}
+	if !strings.Contains(prompt, "No kline indicators available") {
+		t.Fatalf("expected the indicator section without readings:\n%s", prompt)
+	}
+
+	prompt = kimiPrompt(Snapshot{Symbol: "BTCUSDT", Indicators: &indicators.Readings{RSI14: ptr(72.5)}})
+	if !strings.Contains(prompt, "RSI(14): 72.5") || !strings.Contains(prompt, "EMA20: $n/a") {
+		t.Fatalf("expected kline indicators in the prompt:\n%s", prompt)
+	}
+}
+
+func TestBuildNewsContext(t *testing.T) {
+	events := []db.Event{
+		{Source: "news", Text: "Bitcoin hits new high"},
+		{Source: "news", Text: "Institutional adoption growing"},
+		{Source: "chain", Text: "Active addresses: 500000"},
+	}
+
+	context := buildNewsContext(events)
+	if !strings.Contains(context, "Bitcoin hits new high") || strings.Contains(context, "Active addresses") {
+		t.Fatalf("unexpected news context: %s", context)
+	}
+}
+
+func TestBuildChainContext(t *testing.T) {
+	events := []db.Event{
+		{Source: "news", Text: "Bitcoin hits new high"},
+		{Source: "chain", Text: "Active addresses: 500000"},
+		{Source: "chain", Text: "Transactions: 300000"},
+	}
+
+	context := buildChainContext(events)
+	if !strings.Contains(context, "Active addresses") || strings.Contains(context, "Bitcoin") {
+		t.Fatalf("unexpected chain context: %s", context)
+	}
+}
@@ -6,16 +6,20 @@ import (
 	"github.com/adeilh/agentic_go_signals/internal/api"
 	"github.com/adeilh/agentic_go_signals/internal/config"
 	"github.com/adeilh/agentic_go_signals/internal/db"
+	"github.com/adeilh/agentic_go_signals/internal/exchange"
 	"github.com/adeilh/agentic_go_signals/internal/kimi"
-	"github.com/adeilh/agentic_go_signals/internal/trader"
+	"github.com/adeilh/agentic_go_signals/internal/paper"
+	"github.com/adeilh/agentic_go_signals/internal/services"
 )
 
 var (
-	once          sync.Once
-	DB            *db.DB
-	KClient       *kimi.Client
-	BinanceClient *trader.Client
-	App           *api.App
+	once     sync.Once
+	DB       *db.DB
+	KClient  *kimi.Client
+	Exchange exchange.Exchange
+	App      *api.App
+	Broker   *paper.Broker             // Nil unless paper trading is enabled
+	UserData *services.UserDataService // Nil when paper trading is enabled
 )
 
 func Init(cfg *config.Config) error {
@@ -32,8 +36,27 @@ func Init(cfg *config.Config) error {
 			return
 		}
 		KClient = kimi.NewClient(cfg.KimiKey)
-		BinanceClient = trader.NewClientWithConfig(cfg.BinanceKey, cfg.BinanceSecret, cfg.BinanceProduction)
-		App = api.New(DB, BinanceClient, KClient)
+		Exchange, err = exchange.New(cfg.Exchange, exchange.Config{
+			APIKey:     cfg.BinanceKey,
+			APISecret:  cfg.BinanceSecret,
+			Production: cfg.BinanceProduction,
+		})
+		if err != nil {
+			initErr = err
+			return
+		}
+		App = api.New(DB, Exchange, KClient)
+
+		if cfg.PaperTrading {
+			paperCfg := paper.DefaultConfig()
+			paperCfg.StartingBalance = cfg.PaperBalance
+			Broker = paper.NewBroker(paperCfg, db.NewMarketDataStore(DB))
+			App.MarketData().OnDepth(Broker.UpdateDepth)
+			App.SetPaperBroker(Broker)
+		} else {
+			UserData = services.NewUserDataService(Exchange, App.WSHub(), DB)
+			App.SetUserData(UserData)
+		}
 	})
 	return initErr
 }
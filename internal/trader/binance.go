@@ -12,6 +12,7 @@ import (
 	"net/url"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/gorilla/websocket"
@@ -23,6 +24,11 @@ type Client struct {
 	baseURL   string
 	wsURL     string
 	client    *http.Client
+	filters   *FilterCache
+	reconnect ReconnectConfig
+
+	streamsMu sync.Mutex
+	streams   map[string]*StreamMetrics // Keyed by stream URL
 }
 
 // Market Data Structures
@@ -160,15 +166,109 @@ type WSKlineEvent struct {
 
 // Order structures
 type Order struct {
-	Symbol   string `json:"symbol"`
-	OrderID  int64  `json:"orderId"`
-	ClientID string `json:"clientOrderId"`
-	Side     string `json:"side"`
-	Type     string `json:"type"`
-	Quantity string `json:"origQty"`
-	Price    string `json:"price"`
-	Status   string `json:"status"`
-	Time     int64  `json:"transactTime"`
+	Symbol              string `json:"symbol"`
+	OrderID             int64  `json:"orderId"`
+	ClientID            string `json:"clientOrderId"`
+	Side                string `json:"side"`
+	Type                string `json:"type"`
+	TimeInForce         string `json:"timeInForce"`
+	Quantity            string `json:"origQty"`
+	Price               string `json:"price"`
+	StopPrice           string `json:"stopPrice"`
+	OrderListID         int64  `json:"orderListId"`
+	ExecutedQty         string `json:"executedQty"`
+	CummulativeQuoteQty string `json:"cummulativeQuoteQty"`
+	Status              string `json:"status"`
+	Time                int64  `json:"transactTime"`
+	UpdateTime          int64  `json:"updateTime"`
+	Fills               []Fill `json:"fills"`
+}
+
+// Executed returns the filled quantity and average fill price of an order.
+// The price is zero when nothing has filled.
+func (o Order) Executed() (qty, avgPrice float64, err error) {
+	if qty, err = strconv.ParseFloat(o.ExecutedQty, 64); err != nil {
+		return 0, 0, fmt.Errorf("invalid executed quantity %q: %w", o.ExecutedQty, err)
+	}
+	quoteQty, err := strconv.ParseFloat(o.CummulativeQuoteQty, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid cumulative quote quantity %q: %w", o.CummulativeQuoteQty, err)
+	}
+	if qty > 0 {
+		avgPrice = quoteQty / qty
+	}
+	return qty, avgPrice, nil
+}
+
+// Fee is the commission charged on an order's fills
+type Fee struct {
+	Quote float64 // All of it in the quote asset, commission taken in the base asset valued at the fill price
+	Base  float64 // What was taken in the base asset, in base units, out of the quantity bought
+}
+
+// add counts one fill's commission. Commission paid in any asset but the
+// symbol's base and quote assets, such as BNB, is left out.
+func (f *Fee) add(base, quote, asset, amount, price string) error {
+	if asset != base && asset != quote {
+		return nil
+	}
+	commission, err := strconv.ParseFloat(amount, 64)
+	if err != nil {
+		return fmt.Errorf("invalid commission %q: %w", amount, err)
+	}
+	if asset == quote {
+		f.Quote += commission
+		return nil
+	}
+	fillPrice, err := strconv.ParseFloat(price, 64)
+	if err != nil {
+		return fmt.Errorf("invalid fill price %q: %w", price, err)
+	}
+	f.Base += commission
+	f.Quote += commission * fillPrice
+	return nil
+}
+
+// Fee returns the commission charged on an order's fills
+func (o Order) Fee() (Fee, error) {
+	base, quote, err := SplitSymbol(o.Symbol)
+	if err != nil {
+		return Fee{}, err
+	}
+
+	var fee Fee
+	for _, fill := range o.Fills {
+		if err := fee.add(base, quote, fill.CommissionAsset, fill.Commission, fill.Price); err != nil {
+			return Fee{}, err
+		}
+	}
+	return fee, nil
+}
+
+// TradesFee returns the commission charged on the account trades of one
+// symbol, the way Order.Fee does for an order's fills
+func TradesFee(symbol string, trades []AccountTrade) (Fee, error) {
+	base, quote, err := SplitSymbol(symbol)
+	if err != nil {
+		return Fee{}, err
+	}
+
+	var fee Fee
+	for _, trade := range trades {
+		if err := fee.add(base, quote, trade.CommissionAsset, trade.Commission, trade.Price); err != nil {
+			return Fee{}, err
+		}
+	}
+	return fee, nil
+}
+
+// Fill is one execution of an order against the book
+type Fill struct {
+	Price           string `json:"price"`
+	Qty             string `json:"qty"`
+	Commission      string `json:"commission"`
+	CommissionAsset string `json:"commissionAsset"`
+	TradeID         int64  `json:"tradeId"`
 }
 
 type ErrorResponse struct {
@@ -176,6 +276,28 @@ type ErrorResponse struct {
 	Msg  string `json:"msg"`
 }
 
+// RateLimitError is returned when Binance rejects a request for exceeding
+// its request weight limit: HTTP 429, or 418 once the IP has been banned
+// for ignoring 429s
+type RateLimitError struct {
+	StatusCode int
+	RetryAfter time.Duration // Zero when the response did not say
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("rate limited by Binance (HTTP %d), retry after %s", e.StatusCode, e.RetryAfter)
+}
+
+// rateLimitError returns a *RateLimitError for a rate limited response and
+// nil for any other
+func rateLimitError(resp *http.Response) error {
+	if resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode != http.StatusTeapot {
+		return nil
+	}
+	seconds, _ := strconv.Atoi(resp.Header.Get("Retry-After"))
+	return &RateLimitError{StatusCode: resp.StatusCode, RetryAfter: time.Duration(seconds) * time.Second}
+}
+
 // WebSocket Handler Function Types
 type TickerHandler func(WSTickerEvent)
 type TradeHandler func(WSTradeEvent)
@@ -183,24 +305,32 @@ type DepthHandler func(WSDepthEvent)
 type KlineHandler func(WSKlineEvent)
 
 func NewClient(apiKey, apiSecret string) *Client {
-	return &Client{
+	c := &Client{
 		apiKey:    apiKey,
 		apiSecret: apiSecret,
 		baseURL:   "https://testnet.binance.vision",
 		wsURL:     "wss://testnet.binance.vision/ws",
 		client:    &http.Client{Timeout: 120 * time.Second},
+		reconnect: DefaultReconnectConfig(),
+		streams:   make(map[string]*StreamMetrics),
 	}
+	c.filters = NewFilterCache(c.GetExchangeInfo, filterRefresh)
+	return c
 }
 
 // NewProductionClient creates a client for Binance production environment
 func NewProductionClient(apiKey, apiSecret string) *Client {
-	return &Client{
+	c := &Client{
 		apiKey:    apiKey,
 		apiSecret: apiSecret,
 		baseURL:   "https://api.binance.com",
 		wsURL:     "wss://stream.binance.com:9443",
 		client:    &http.Client{Timeout: 120 * time.Second},
+		reconnect: DefaultReconnectConfig(),
+		streams:   make(map[string]*StreamMetrics),
 	}
+	c.filters = NewFilterCache(c.GetExchangeInfo, filterRefresh)
+	return c
 }
 
 // NewClientWithConfig creates a client with custom configuration
@@ -233,46 +363,14 @@ func (c *Client) TestConnection() error {
 	return nil
 }
 
+// PlaceOrder places a market order for qty of the base asset
 func (c *Client) PlaceOrder(symbol, side string, qty float64) (Order, error) {
-	endpoint := "/api/v3/order"
-
-	params := url.Values{}
-	params.Set("symbol", symbol)
-	params.Set("side", strings.ToUpper(side))
-	params.Set("type", "MARKET")
-	params.Set("quantity", strconv.FormatFloat(qty, 'f', 8, 64))
-	params.Set("timestamp", strconv.FormatInt(time.Now().UnixMilli(), 10))
-
-	query := params.Encode()
-	signature := c.sign(query)
-	query += "&signature=" + signature
-
-	req, err := http.NewRequest("POST", c.baseURL+endpoint+"?"+query, nil)
-	if err != nil {
-		return Order{}, fmt.Errorf("failed to create request: %w", err)
-	}
-
-	req.Header.Set("X-MBX-APIKEY", c.apiKey)
-	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
-
-	resp, err := c.client.Do(req)
-	if err != nil {
-		return Order{}, fmt.Errorf("failed to send request: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		var errResp ErrorResponse
-		json.NewDecoder(resp.Body).Decode(&errResp)
-		return Order{}, fmt.Errorf("API error: %d - %s", errResp.Code, errResp.Msg)
-	}
-
-	var order Order
-	if err := json.NewDecoder(resp.Body).Decode(&order); err != nil {
-		return Order{}, fmt.Errorf("failed to decode response: %w", err)
-	}
-
-	return order, nil
+	return c.NewOrder(OrderRequest{
+		Symbol:   symbol,
+		Side:     side,
+		Type:     OrderTypeMarket,
+		Quantity: qty,
+	})
 }
 
 func (c *Client) GetAccountInfo() (map[string]interface{}, error) {
@@ -393,6 +491,9 @@ func (c *Client) GetOrderBook(symbol string, limit int) (OrderBook, error) {
 	}
 	defer resp.Body.Close()
 
+	if err := rateLimitError(resp); err != nil {
+		return OrderBook{}, err
+	}
 	if resp.StatusCode != http.StatusOK {
 		var errResp ErrorResponse
 		json.NewDecoder(resp.Body).Decode(&errResp)
@@ -429,6 +530,9 @@ func (c *Client) GetKlines(symbol, interval string, limit int, startTime, endTim
 	}
 	defer resp.Body.Close()
 
+	if err := rateLimitError(resp); err != nil {
+		return nil, err
+	}
 	if resp.StatusCode != http.StatusOK {
 		var errResp ErrorResponse
 		json.NewDecoder(resp.Body).Decode(&errResp)
@@ -473,29 +577,35 @@ func (c *Client) GetRecentTrades(symbol string, limit int) ([]Trade, error) {
 }
 
 // GetExchangeInfo gets current exchange trading rules and symbol information
-func (c *Client) GetExchangeInfo() (map[string]interface{}, error) {
+func (c *Client) GetExchangeInfo() (ExchangeInfo, error) {
 	endpoint := "/api/v3/exchangeInfo"
 
 	resp, err := c.client.Get(c.baseURL + endpoint)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get exchange info: %w", err)
+		return ExchangeInfo{}, fmt.Errorf("failed to get exchange info: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
 		var errResp ErrorResponse
 		json.NewDecoder(resp.Body).Decode(&errResp)
-		return nil, fmt.Errorf("API error: %d - %s", errResp.Code, errResp.Msg)
+		return ExchangeInfo{}, fmt.Errorf("API error: %d - %s", errResp.Code, errResp.Msg)
 	}
 
-	var info map[string]interface{}
+	var info ExchangeInfo
 	if err := json.NewDecoder(resp.Body).Decode(&info); err != nil {
-		return nil, fmt.Errorf("failed to decode response: %w", err)
+		return ExchangeInfo{}, fmt.Errorf("failed to decode response: %w", err)
 	}
 
 	return info, nil
 }
 
+// GetSymbolFilters returns the cached order filters of symbol, reloading
+// exchange info when it is more than an hour old
+func (c *Client) GetSymbolFilters(symbol string) (SymbolFilters, error) {
+	return c.filters.Get(symbol)
+}
+
 // WebSocket Methods for Real-time Data
 
 // SubscribeTickerStream subscribes to 24hr ticker statistics stream
@@ -583,40 +693,60 @@ func (c *Client) subscribeWebSocket(ctx context.Context, stream string, handler
 	return c.connectWebSocket(ctx, wsURL, handler)
 }
 
-// connectWebSocket establishes WebSocket connection and handles messages
+// connectWebSocket connects to wsURL and passes every message to handler
+// until ctx is canceled. Only the first dial's error is returned; after
+// that the connection is redialed with backoff whenever it drops or goes
+// stale.
 func (c *Client) connectWebSocket(ctx context.Context, wsURL string, handler func([]byte)) error {
-	conn, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	conn, err := dialStream(ctx, wsURL)
 	if err != nil {
-		return fmt.Errorf("failed to dial websocket: %w", err)
+		return err
 	}
-	defer conn.Close()
 
 	log.Printf("Connected to WebSocket: %s", wsURL)
 
-	// Handle WebSocket messages in a goroutine
-	go func() {
-		defer conn.Close()
-		for {
-			select {
-			case <-ctx.Done():
-				log.Println("WebSocket context cancelled")
-				return
-			default:
-				_, message, err := conn.ReadMessage()
-				if err != nil {
-					log.Printf("WebSocket read error: %v", err)
-					return
-				}
+	stream := &reconnectingStream{
+		name:    wsURL,
+		dial:    func(ctx context.Context) (*websocket.Conn, error) { return dialStream(ctx, wsURL) },
+		handler: handler,
+		config:  c.reconnect,
+		metrics: c.streamMetrics(wsURL),
+	}
+	stream.metrics.Connected()
+	stream.run(ctx, conn)
+	return nil
+}
 
-				// Call the handler with the raw message
-				handler(message)
-			}
-		}
-	}()
+// SetReconnectConfig sets how streams subscribed afterwards are redialed
+func (c *Client) SetReconnectConfig(config ReconnectConfig) {
+	c.reconnect = config
+}
 
-	// Keep connection alive until context is cancelled
-	<-ctx.Done()
-	return nil
+// StreamStats returns the connection counters of every stream subscribed
+// through the client, keyed by stream URL
+func (c *Client) StreamStats() map[string]StreamStats {
+	c.streamsMu.Lock()
+	defer c.streamsMu.Unlock()
+
+	stats := make(map[string]StreamStats, len(c.streams))
+	for url, metrics := range c.streams {
+		stats[url] = metrics.Stats()
+	}
+	return stats
+}
+
+// streamMetrics returns the metrics of a stream URL, keeping counts across
+// resubscriptions
+func (c *Client) streamMetrics(wsURL string) *StreamMetrics {
+	c.streamsMu.Lock()
+	defer c.streamsMu.Unlock()
+
+	metrics, ok := c.streams[wsURL]
+	if !ok {
+		metrics = &StreamMetrics{}
+		c.streams[wsURL] = metrics
+	}
+	return metrics
 }
 
 // WebSocket Hub for Broadcasting to Frontend
@@ -2,6 +2,7 @@ package trader
 
 import (
 	"testing"
+	"time"
 )
 
 func TestNewClient(t *testing.T) {
@@ -85,3 +86,47 @@ func TestTestConnection(t *testing.T) {
 		t.Log("Connection test succeeded")
 	}
 }
+
+func TestIntervalDuration(t *testing.T) {
+	d, err := IntervalDuration("4h")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if d != 4*time.Hour {
+		t.Fatalf("expected 4h, got %v", d)
+	}
+
+	if _, err := IntervalDuration("1M"); err == nil {
+		t.Fatal("expected error for monthly interval")
+	}
+}
+
+func TestParseKlineRow(t *testing.T) {
+	row := []interface{}{
+		float64(1700000000000), "100.0", "110.0", "95.0", "105.0", "12.5",
+		float64(1700000059999), "1300.0", float64(42), "6.0", "630.0", "0",
+	}
+
+	kline, err := ParseKlineRow(row)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if kline.OpenTime != 1700000000000 || kline.CloseTime != 1700000059999 {
+		t.Fatalf("unexpected kline times: %d-%d", kline.OpenTime, kline.CloseTime)
+	}
+	if kline.NumberOfTrades != 42 {
+		t.Fatalf("expected 42 trades, got %d", kline.NumberOfTrades)
+	}
+
+	open, high, low, close, volume, quoteVolume, err := KlineFloats(kline)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if open != 100 || high != 110 || low != 95 || close != 105 || volume != 12.5 || quoteVolume != 1300 {
+		t.Fatalf("unexpected kline values: %v %v %v %v %v %v", open, high, low, close, volume, quoteVolume)
+	}
+
+	if _, err := ParseKlineRow(row[:5]); err == nil {
+		t.Fatal("expected error for short row")
+	}
+}
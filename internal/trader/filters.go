@@ -0,0 +1,385 @@
+package trader
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// filterRefresh is how long exchange info is trusted before it is reloaded
+const filterRefresh = time.Hour
+
+// ErrUnknownSymbol is returned for symbols the exchange does not list
+var ErrUnknownSymbol = errors.New("unknown symbol")
+
+// quoteAssets are the quote currencies symbols are split on, checked in order
+var quoteAssets = []string{"FDUSD", "USDT", "USDC", "BUSD", "TUSD", "BTC", "ETH", "BNB"}
+
+// SplitSymbol splits a symbol such as BTCUSDT into its base and quote assets
+func SplitSymbol(symbol string) (base, quote string, err error) {
+	for _, quote := range quoteAssets {
+		if base := strings.TrimSuffix(symbol, quote); base != symbol && base != "" {
+			return base, quote, nil
+		}
+	}
+	return "", "", fmt.Errorf("unknown quote asset in symbol %q", symbol)
+}
+
+// ExchangeInfo is the exchange's trading rules
+type ExchangeInfo struct {
+	Timezone   string       `json:"timezone"`
+	ServerTime int64        `json:"serverTime"`
+	Symbols    []SymbolInfo `json:"symbols"`
+}
+
+// SymbolInfo is one symbol's trading rules as the exchange reports them
+type SymbolInfo struct {
+	Symbol              string         `json:"symbol"`
+	Status              string         `json:"status"`
+	BaseAsset           string         `json:"baseAsset"`
+	BaseAssetPrecision  int            `json:"baseAssetPrecision"`
+	QuoteAsset          string         `json:"quoteAsset"`
+	QuoteAssetPrecision int            `json:"quoteAssetPrecision"`
+	OrderTypes          []string       `json:"orderTypes"`
+	Filters             []SymbolFilter `json:"filters"`
+}
+
+// SymbolFilter is one raw filter; only the fields of its FilterType are set
+type SymbolFilter struct {
+	FilterType       string `json:"filterType"`
+	MinPrice         string `json:"minPrice"`
+	MaxPrice         string `json:"maxPrice"`
+	TickSize         string `json:"tickSize"`
+	MinQty           string `json:"minQty"`
+	MaxQty           string `json:"maxQty"`
+	StepSize         string `json:"stepSize"`
+	MinNotional      string `json:"minNotional"`
+	MaxNotional      string `json:"maxNotional"`
+	ApplyToMarket    bool   `json:"applyToMarket"`
+	ApplyMinToMarket bool   `json:"applyMinToMarket"`
+	ApplyMaxToMarket bool   `json:"applyMaxToMarket"`
+}
+
+// SymbolFilters are the parsed order limits of a symbol. Zero values mean
+// the exchange sets no limit.
+type SymbolFilters struct {
+	Symbol string `json:"symbol"`
+	Status string `json:"status"`
+
+	// PRICE_FILTER
+	MinPrice float64 `json:"min_price"`
+	MaxPrice float64 `json:"max_price"`
+	TickSize float64 `json:"tick_size"`
+
+	// LOT_SIZE, and MARKET_LOT_SIZE for market orders
+	MinQty         float64 `json:"min_qty"`
+	MaxQty         float64 `json:"max_qty"`
+	StepSize       float64 `json:"step_size"`
+	MarketMinQty   float64 `json:"market_min_qty"`
+	MarketMaxQty   float64 `json:"market_max_qty"`
+	MarketStepSize float64 `json:"market_step_size"`
+
+	// MIN_NOTIONAL or NOTIONAL
+	MinNotional       float64 `json:"min_notional"`
+	MaxNotional       float64 `json:"max_notional"`
+	MinNotionalMarket bool    `json:"min_notional_market"` // Whether MinNotional applies to market orders
+	MaxNotionalMarket bool    `json:"max_notional_market"`
+}
+
+// FilterError is an order that breaks one of the symbol's filters
+type FilterError struct {
+	Symbol string
+	Filter string // PRICE_FILTER, LOT_SIZE, MARKET_LOT_SIZE, NOTIONAL or STATUS
+	Reason string
+}
+
+func (e *FilterError) Error() string {
+	return fmt.Sprintf("%s order violates %s: %s", e.Symbol, e.Filter, e.Reason)
+}
+
+// ParseFilters converts the raw filters of a symbol into SymbolFilters
+func (s SymbolInfo) ParseFilters() (SymbolFilters, error) {
+	f := SymbolFilters{Symbol: s.Symbol, Status: s.Status}
+
+	for _, filter := range s.Filters {
+		var err error
+		switch filter.FilterType {
+		case "PRICE_FILTER":
+			err = parseInto(filter.FilterType,
+				[]string{filter.MinPrice, filter.MaxPrice, filter.TickSize},
+				[]*float64{&f.MinPrice, &f.MaxPrice, &f.TickSize})
+		case "LOT_SIZE":
+			err = parseInto(filter.FilterType,
+				[]string{filter.MinQty, filter.MaxQty, filter.StepSize},
+				[]*float64{&f.MinQty, &f.MaxQty, &f.StepSize})
+		case "MARKET_LOT_SIZE":
+			err = parseInto(filter.FilterType,
+				[]string{filter.MinQty, filter.MaxQty, filter.StepSize},
+				[]*float64{&f.MarketMinQty, &f.MarketMaxQty, &f.MarketStepSize})
+		case "MIN_NOTIONAL":
+			err = parseInto(filter.FilterType, []string{filter.MinNotional}, []*float64{&f.MinNotional})
+			f.MinNotionalMarket = filter.ApplyToMarket
+		case "NOTIONAL":
+			err = parseInto(filter.FilterType,
+				[]string{filter.MinNotional, filter.MaxNotional},
+				[]*float64{&f.MinNotional, &f.MaxNotional})
+			f.MinNotionalMarket = filter.ApplyMinToMarket
+			f.MaxNotionalMarket = filter.ApplyMaxToMarket
+		}
+		if err != nil {
+			return SymbolFilters{}, fmt.Errorf("%s: %w", s.Symbol, err)
+		}
+	}
+	return f, nil
+}
+
+// QuantityStep returns the step size that applies to market or limit orders
+func (f SymbolFilters) QuantityStep(market bool) float64 {
+	if market && f.MarketStepSize > 0 {
+		return f.MarketStepSize
+	}
+	return f.StepSize
+}
+
+// RoundQuantity rounds qty down to the step size, so an order never
+// exceeds what was sized for it
+func (f SymbolFilters) RoundQuantity(qty float64, market bool) float64 {
+	return floorStep(qty, f.QuantityStep(market))
+}
+
+// RoundPrice rounds price to the nearest tick
+func (f SymbolFilters) RoundPrice(price float64) float64 {
+	if f.TickSize <= 0 {
+		return price
+	}
+	return roundDecimals(math.Round(price/f.TickSize) * f.TickSize)
+}
+
+// CheckQuantity validates qty against the lot size filters
+func (f SymbolFilters) CheckQuantity(qty float64, market bool) error {
+	filter, minQty, maxQty, step := "LOT_SIZE", f.MinQty, f.MaxQty, f.StepSize
+	if market && (f.MarketMinQty > 0 || f.MarketMaxQty > 0 || f.MarketStepSize > 0) {
+		filter = "MARKET_LOT_SIZE"
+		minQty = math.Max(minQty, f.MarketMinQty)
+		if f.MarketMaxQty > 0 {
+			maxQty = f.MarketMaxQty
+		}
+		if f.MarketStepSize > 0 {
+			step = f.MarketStepSize
+		}
+	}
+
+	switch {
+	case minQty > 0 && qty < minQty:
+		return f.violation(filter, "quantity %s is below the minimum %s", qty, minQty)
+	case maxQty > 0 && qty > maxQty:
+		return f.violation(filter, "quantity %s is above the maximum %s", qty, maxQty)
+	case !onStep(qty, step):
+		return f.violation(filter, "quantity %s is not a multiple of the step size %s", qty, step)
+	}
+	return nil
+}
+
+// CheckPrice validates a limit or stop price against the price filter
+func (f SymbolFilters) CheckPrice(price float64) error {
+	switch {
+	case f.MinPrice > 0 && price < f.MinPrice:
+		return f.violation("PRICE_FILTER", "price %s is below the minimum %s", price, f.MinPrice)
+	case f.MaxPrice > 0 && price > f.MaxPrice:
+		return f.violation("PRICE_FILTER", "price %s is above the maximum %s", price, f.MaxPrice)
+	case !onStep(price, f.TickSize):
+		return f.violation("PRICE_FILTER", "price %s is not a multiple of the tick size %s", price, f.TickSize)
+	}
+	return nil
+}
+
+// CheckNotional validates an order's value in the quote asset
+func (f SymbolFilters) CheckNotional(notional float64, market bool) error {
+	if f.MinNotional > 0 && (!market || f.MinNotionalMarket) && notional < f.MinNotional {
+		return f.violation("NOTIONAL", "value %s is below the minimum %s", notional, f.MinNotional)
+	}
+	if f.MaxNotional > 0 && (!market || f.MaxNotionalMarket) && notional > f.MaxNotional {
+		return f.violation("NOTIONAL", "value %s is above the maximum %s", notional, f.MaxNotional)
+	}
+	return nil
+}
+
+// Apply rounds a validated request's quantity and prices to the filters and
+// checks the result. refPrice values market orders sized in the base asset
+// and is ignored when zero.
+func (f SymbolFilters) Apply(req *OrderRequest, refPrice float64) error {
+	if err := f.checkStatus(); err != nil {
+		return err
+	}
+	market := req.Type == OrderTypeMarket
+
+	if req.Quantity > 0 {
+		req.Quantity = f.RoundQuantity(req.Quantity, market)
+		if err := f.CheckQuantity(req.Quantity, market); err != nil {
+			return err
+		}
+	}
+	for _, price := range []*float64{&req.Price, &req.StopPrice} {
+		if *price > 0 {
+			*price = f.RoundPrice(*price)
+			if err := f.CheckPrice(*price); err != nil {
+				return err
+			}
+		}
+	}
+
+	switch {
+	case req.QuoteOrderQty > 0:
+		return f.CheckNotional(req.QuoteOrderQty, market)
+	case !market:
+		return f.CheckNotional(req.Quantity*req.Price, false)
+	case refPrice > 0:
+		return f.CheckNotional(req.Quantity*refPrice, true)
+	}
+	return nil
+}
+
+// ApplyOCO rounds and checks both legs of a validated OCO request
+func (f SymbolFilters) ApplyOCO(req *OCORequest) error {
+	if err := f.checkStatus(); err != nil {
+		return err
+	}
+
+	req.Quantity = f.RoundQuantity(req.Quantity, false)
+	if err := f.CheckQuantity(req.Quantity, false); err != nil {
+		return err
+	}
+	for _, price := range []*float64{&req.Price, &req.StopPrice, &req.StopLimitPrice} {
+		if *price > 0 {
+			*price = f.RoundPrice(*price)
+			if err := f.CheckPrice(*price); err != nil {
+				return err
+			}
+			if err := f.CheckNotional(req.Quantity**price, false); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (f SymbolFilters) checkStatus() error {
+	if f.Status != "" && f.Status != "TRADING" {
+		return &FilterError{Symbol: f.Symbol, Filter: "STATUS", Reason: fmt.Sprintf("symbol is %s", f.Status)}
+	}
+	return nil
+}
+
+func (f SymbolFilters) violation(filter, format string, value, limit float64) *FilterError {
+	return &FilterError{
+		Symbol: f.Symbol,
+		Filter: filter,
+		Reason: fmt.Sprintf(format, formatDecimal(value), formatDecimal(limit)),
+	}
+}
+
+// FilterCache keeps every symbol's filters from one exchange info load and
+// reloads them once they are older than the refresh interval
+type FilterCache struct {
+	load    func() (ExchangeInfo, error)
+	refresh time.Duration
+	now     func() time.Time
+
+	mu       sync.Mutex
+	filters  map[string]SymbolFilters
+	loadedAt time.Time
+}
+
+// NewFilterCache creates a cache that loads exchange info with load
+func NewFilterCache(load func() (ExchangeInfo, error), refresh time.Duration) *FilterCache {
+	return &FilterCache{load: load, refresh: refresh, now: time.Now}
+}
+
+// Get returns the filters of symbol. A failed reload keeps serving the
+// previous filters rather than blocking orders.
+func (c *FilterCache) Get(symbol string) (SymbolFilters, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.filters == nil || c.now().Sub(c.loadedAt) >= c.refresh {
+		if err := c.reload(); err != nil {
+			if c.filters == nil {
+				return SymbolFilters{}, err
+			}
+			log.Printf("Using exchange info from %s: %v", c.loadedAt.Format(time.RFC3339), err)
+		}
+	}
+
+	filters, ok := c.filters[strings.ToUpper(symbol)]
+	if !ok {
+		return SymbolFilters{}, fmt.Errorf("%w: %s", ErrUnknownSymbol, symbol)
+	}
+	return filters, nil
+}
+
+// reload replaces the cached filters; callers hold c.mu
+func (c *FilterCache) reload() error {
+	info, err := c.load()
+	if err != nil {
+		return fmt.Errorf("failed to load exchange info: %w", err)
+	}
+
+	filters := make(map[string]SymbolFilters, len(info.Symbols))
+	for _, symbol := range info.Symbols {
+		f, err := symbol.ParseFilters()
+		if err != nil {
+			return fmt.Errorf("failed to parse exchange info: %w", err)
+		}
+		filters[symbol.Symbol] = f
+	}
+	c.filters = filters
+	c.loadedAt = c.now()
+	return nil
+}
+
+// parseInto parses each decimal string into its target, leaving empty ones
+func parseInto(filterType string, values []string, targets []*float64) error {
+	for i, value := range values {
+		if value == "" {
+			continue
+		}
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("invalid %s value %q: %w", filterType, value, err)
+		}
+		*targets[i] = f
+	}
+	return nil
+}
+
+// floorStep rounds v down to a multiple of step
+func floorStep(v, step float64) float64 {
+	if step <= 0 {
+		return v
+	}
+	// The epsilon keeps exact multiples from flooring to the step below
+	return roundDecimals(math.Floor(v/step+1e-9) * step)
+}
+
+// onStep reports whether v is a multiple of step within float precision
+func onStep(v, step float64) bool {
+	if step <= 0 {
+		return true
+	}
+	n := v / step
+	return math.Abs(n-math.Round(n)) < 1e-6
+}
+
+// roundDecimals rounds to the 8 decimals orders are sent with
+func roundDecimals(v float64) float64 {
+	return math.Round(v*1e8) / 1e8
+}
+
+func formatDecimal(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
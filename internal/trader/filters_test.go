@@ -0,0 +1,243 @@
+package trader
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+const exchangeInfoJSON = `{
+	"timezone": "UTC",
+	"serverTime": 1700000000000,
+	"symbols": [{
+		"symbol": "PEPEUSDT",
+		"status": "TRADING",
+		"baseAsset": "PEPE",
+		"quoteAsset": "USDT",
+		"filters": [
+			{"filterType": "PRICE_FILTER", "minPrice": "0.00000001", "maxPrice": "1.00000000", "tickSize": "0.00000001"},
+			{"filterType": "LOT_SIZE", "minQty": "1.00", "maxQty": "92233720368.00", "stepSize": "1.00"},
+			{"filterType": "MARKET_LOT_SIZE", "minQty": "0.00", "maxQty": "5000000000.00", "stepSize": "0.00"},
+			{"filterType": "NOTIONAL", "minNotional": "5.00000000", "applyMinToMarket": true, "maxNotional": "9000000.00000000", "applyMaxToMarket": false},
+			{"filterType": "ICEBERG_PARTS", "limit": 10}
+		]
+	}, {
+		"symbol": "OLDUSDT",
+		"status": "BREAK",
+		"filters": [{"filterType": "MIN_NOTIONAL", "minNotional": "10.00000000", "applyToMarket": true}]
+	}]
+}`
+
+func testFilters(t *testing.T) SymbolFilters {
+	t.Helper()
+	var info ExchangeInfo
+	if err := json.Unmarshal([]byte(exchangeInfoJSON), &info); err != nil {
+		t.Fatalf("failed to decode exchange info: %v", err)
+	}
+	filters, err := info.Symbols[0].ParseFilters()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return filters
+}
+
+func TestParseFilters(t *testing.T) {
+	f := testFilters(t)
+
+	if f.TickSize != 0.00000001 || f.MinQty != 1 || f.StepSize != 1 || f.MarketMaxQty != 5000000000 {
+		t.Fatalf("unexpected price and lot filters %+v", f)
+	}
+	if f.MinNotional != 5 || !f.MinNotionalMarket || f.MaxNotional != 9000000 || f.MaxNotionalMarket {
+		t.Fatalf("unexpected notional filters %+v", f)
+	}
+	// An unset MARKET_LOT_SIZE step falls back to LOT_SIZE
+	if f.QuantityStep(true) != 1 {
+		t.Fatalf("expected market step 1, got %v", f.QuantityStep(true))
+	}
+
+	bad := SymbolInfo{Symbol: "BADUSDT", Filters: []SymbolFilter{{FilterType: "LOT_SIZE", StepSize: "one"}}}
+	if _, err := bad.ParseFilters(); err == nil {
+		t.Fatal("expected error for an invalid decimal")
+	}
+}
+
+func TestSymbolFiltersRounding(t *testing.T) {
+	f := SymbolFilters{TickSize: 0.01, StepSize: 0.001}
+
+	if qty := f.RoundQuantity(1.23456, false); qty != 1.234 {
+		t.Errorf("expected quantity rounded down to 1.234, got %v", qty)
+	}
+	if qty := f.RoundQuantity(0.3, false); qty != 0.3 {
+		t.Errorf("expected an exact multiple to be kept, got %v", qty)
+	}
+	if price := f.RoundPrice(100.126); price != 100.13 {
+		t.Errorf("expected price rounded to the nearest tick, got %v", price)
+	}
+	if price := (SymbolFilters{}).RoundPrice(100.126); price != 100.126 {
+		t.Errorf("expected no rounding without a tick size, got %v", price)
+	}
+}
+
+func TestSymbolFiltersApply(t *testing.T) {
+	f := testFilters(t)
+
+	req := OrderRequest{Symbol: "PEPEUSDT", Side: SideBuy, Type: OrderTypeLimit, Quantity: 1000000.9, Price: 0.0000081234}
+	if err := f.Apply(&req, 0); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if req.Quantity != 1000000 || req.Price != 0.00000812 {
+		t.Fatalf("expected rounded quantity and price, got %v @ %v", req.Quantity, req.Price)
+	}
+
+	cases := []struct {
+		name   string
+		req    OrderRequest
+		ref    float64
+		filter string
+	}{
+		{"below min qty", OrderRequest{Type: OrderTypeLimit, Quantity: 0.5, Price: 1}, 0, "LOT_SIZE"},
+		{"above market max qty", OrderRequest{Type: OrderTypeMarket, Quantity: 6000000000}, 0, "MARKET_LOT_SIZE"},
+		{"above max price", OrderRequest{Type: OrderTypeLimit, Quantity: 1, Price: 2}, 0, "PRICE_FILTER"},
+		{"limit below min notional", OrderRequest{Type: OrderTypeLimit, Quantity: 100000, Price: 0.00000812}, 0, "NOTIONAL"},
+		{"market below min notional", OrderRequest{Type: OrderTypeMarket, Quantity: 100000}, 0.00000812, "NOTIONAL"},
+		{"quote below min notional", OrderRequest{Type: OrderTypeMarket, QuoteOrderQty: 4}, 0, "NOTIONAL"},
+	}
+	for _, tc := range cases {
+		tc.req.Symbol, tc.req.Side = "PEPEUSDT", SideBuy
+		err := f.Apply(&tc.req, tc.ref)
+		var filterErr *FilterError
+		if !errors.As(err, &filterErr) || filterErr.Filter != tc.filter || filterErr.Symbol != "PEPEUSDT" {
+			t.Errorf("%s: expected a %s error, got %v", tc.name, tc.filter, err)
+		}
+	}
+
+	// The max notional does not apply to market orders
+	market := OrderRequest{Symbol: "PEPEUSDT", Side: SideBuy, Type: OrderTypeMarket, Quantity: 4000000000}
+	if err := f.Apply(&market, 0.01); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	halted := SymbolFilters{Symbol: "OLDUSDT", Status: "BREAK"}
+	var filterErr *FilterError
+	if err := halted.Apply(&OrderRequest{Type: OrderTypeMarket, Quantity: 1}, 0); !errors.As(err, &filterErr) || filterErr.Filter != "STATUS" {
+		t.Fatalf("expected a status error, got %v", err)
+	}
+}
+
+func TestSymbolFiltersApplyOCO(t *testing.T) {
+	f := testFilters(t)
+
+	req := ExitOCO("PEPEUSDT", SideBuy, 1000000.5, 0.000009001, 0.000007999)
+	if err := f.ApplyOCO(&req); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if req.Quantity != 1000000 || req.Price != 0.000009 || req.StopPrice != 0.000008 || req.StopLimitPrice != 0.000008 {
+		t.Fatalf("unexpected rounded OCO %+v", req)
+	}
+
+	// The stop leg is worth 4 USDT, below the minimum notional
+	req = ExitOCO("PEPEUSDT", SideBuy, 500000, 0.000011, 0.000008)
+	if err := f.ApplyOCO(&req); err == nil {
+		t.Fatal("expected a notional error for the stop leg")
+	}
+}
+
+func TestFilterCache(t *testing.T) {
+	var loads int
+	var failing bool
+	load := func() (ExchangeInfo, error) {
+		loads++
+		if failing {
+			return ExchangeInfo{}, errors.New("exchange unavailable")
+		}
+		var info ExchangeInfo
+		err := json.Unmarshal([]byte(exchangeInfoJSON), &info)
+		return info, err
+	}
+
+	now := time.Unix(0, 0)
+	cache := NewFilterCache(load, time.Hour)
+	cache.now = func() time.Time { return now }
+
+	filters, err := cache.Get("pepeusdt")
+	if err != nil || filters.StepSize != 1 {
+		t.Fatalf("unexpected filters %+v, %v", filters, err)
+	}
+	if _, err := cache.Get("DOGEUSDT"); !errors.Is(err, ErrUnknownSymbol) {
+		t.Fatalf("expected unknown symbol, got %v", err)
+	}
+	if loads != 1 {
+		t.Fatalf("expected one load, got %d", loads)
+	}
+
+	// A failed refresh keeps the previous filters
+	now = now.Add(time.Hour)
+	failing = true
+	if _, err := cache.Get("PEPEUSDT"); err != nil || loads != 2 {
+		t.Fatalf("expected stale filters after a failed refresh, got %v after %d loads", err, loads)
+	}
+
+	empty := NewFilterCache(load, time.Hour)
+	if _, err := empty.Get("PEPEUSDT"); err == nil {
+		t.Fatal("expected error when exchange info never loaded")
+	}
+}
+
+func TestNewOrderAppliesFilters(t *testing.T) {
+	var sent int
+	var quantity string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v3/exchangeInfo":
+			w.Write([]byte(exchangeInfoJSON))
+		case "/api/v3/ticker/price":
+			w.Write([]byte(`{"symbol":"PEPEUSDT","price":"0.00000812"}`))
+		default:
+			sent++
+			quantity = r.URL.Query().Get("quantity")
+			w.Write([]byte(`{"orderId":1,"status":"FILLED"}`))
+		}
+	}))
+	defer server.Close()
+
+	client := NewClient("test-key", "test-secret")
+	client.baseURL = server.URL
+
+	if _, err := client.PlaceOrder("PEPEUSDT", "BUY", 1000000.75); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if quantity != "1000000.00000000" {
+		t.Fatalf("expected the quantity rounded to the lot size, got %s", quantity)
+	}
+
+	// 100000 PEPE at the last price is under 1 USDT
+	var filterErr *FilterError
+	if _, err := client.PlaceOrder("PEPEUSDT", "BUY", 100000); !errors.As(err, &filterErr) || filterErr.Filter != "NOTIONAL" {
+		t.Fatalf("expected a notional error, got %v", err)
+	}
+	if _, err := client.PlaceOrder("OLDUSDT", "BUY", 1); !errors.As(err, &filterErr) || filterErr.Filter != "STATUS" {
+		t.Fatalf("expected a status error, got %v", err)
+	}
+	if _, err := client.PlaceOrder("DOGEUSDT", "BUY", 1); !errors.Is(err, ErrUnknownSymbol) {
+		t.Fatalf("expected unknown symbol, got %v", err)
+	}
+	if sent != 1 {
+		t.Fatalf("expected only the valid order to be sent, got %d", sent)
+	}
+}
+
+func TestSplitSymbol(t *testing.T) {
+	cases := map[string][2]string{"BTCUSDT": {"BTC", "USDT"}, "ETHBTC": {"ETH", "BTC"}, "SOLFDUSD": {"SOL", "FDUSD"}}
+	for symbol, want := range cases {
+		base, quote, err := SplitSymbol(symbol)
+		if err != nil || base != want[0] || quote != want[1] {
+			t.Errorf("SplitSymbol(%s) = %s, %s, %v", symbol, base, quote, err)
+		}
+	}
+	if _, _, err := SplitSymbol("USDT"); err == nil {
+		t.Fatal("expected error without base asset")
+	}
+}
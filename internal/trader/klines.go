@@ -0,0 +1,95 @@
+package trader
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// intervalDurations maps Binance kline intervals to their bar length.
+// Calendar months are variable length and deliberately not supported.
+var intervalDurations = map[string]time.Duration{
+	"1s":  time.Second,
+	"1m":  time.Minute,
+	"3m":  3 * time.Minute,
+	"5m":  5 * time.Minute,
+	"15m": 15 * time.Minute,
+	"30m": 30 * time.Minute,
+	"1h":  time.Hour,
+	"2h":  2 * time.Hour,
+	"4h":  4 * time.Hour,
+	"6h":  6 * time.Hour,
+	"8h":  8 * time.Hour,
+	"12h": 12 * time.Hour,
+	"1d":  24 * time.Hour,
+	"3d":  3 * 24 * time.Hour,
+	"1w":  7 * 24 * time.Hour,
+}
+
+// IntervalDuration returns the bar length of a Binance kline interval such as "1m" or "4h"
+func IntervalDuration(interval string) (time.Duration, error) {
+	d, ok := intervalDurations[interval]
+	if !ok {
+		return 0, fmt.Errorf("unsupported kline interval %q", interval)
+	}
+	return d, nil
+}
+
+// ParseKlineRow converts one row of the GetKlines array response into a Kline
+func ParseKlineRow(row []interface{}) (Kline, error) {
+	if len(row) < 11 {
+		return Kline{}, fmt.Errorf("kline row has %d fields, expected at least 11", len(row))
+	}
+
+	openTime, ok := row[0].(float64)
+	if !ok {
+		return Kline{}, fmt.Errorf("invalid kline open time %v", row[0])
+	}
+	closeTime, ok := row[6].(float64)
+	if !ok {
+		return Kline{}, fmt.Errorf("invalid kline close time %v", row[6])
+	}
+	trades, ok := row[8].(float64)
+	if !ok {
+		return Kline{}, fmt.Errorf("invalid kline trade count %v", row[8])
+	}
+
+	var fields [7]string
+	for i, idx := range []int{1, 2, 3, 4, 5, 7, 9} {
+		value, ok := row[idx].(string)
+		if !ok {
+			return Kline{}, fmt.Errorf("invalid kline field %d: %v", idx, row[idx])
+		}
+		fields[i] = value
+	}
+
+	var takerBuyQuote string
+	if value, ok := row[10].(string); ok {
+		takerBuyQuote = value
+	}
+
+	return Kline{
+		OpenTime:                 int64(openTime),
+		Open:                     fields[0],
+		High:                     fields[1],
+		Low:                      fields[2],
+		Close:                    fields[3],
+		Volume:                   fields[4],
+		CloseTime:                int64(closeTime),
+		QuoteAssetVolume:         fields[5],
+		NumberOfTrades:           int64(trades),
+		TakerBuyBaseAssetVolume:  fields[6],
+		TakerBuyQuoteAssetVolume: takerBuyQuote,
+	}, nil
+}
+
+// KlineFloats parses the OHLCV and quote volume strings of a Kline
+func KlineFloats(k Kline) (open, high, low, close, volume, quoteVolume float64, err error) {
+	values := make([]float64, 6)
+	for i, s := range []string{k.Open, k.High, k.Low, k.Close, k.Volume, k.QuoteAssetVolume} {
+		if values[i], err = strconv.ParseFloat(s, 64); err != nil {
+			return 0, 0, 0, 0, 0, 0, fmt.Errorf("invalid kline value %q: %w", s, err)
+		}
+	}
+	return values[0], values[1], values[2], values[3], values[4], values[5], nil
+}
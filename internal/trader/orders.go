@@ -0,0 +1,456 @@
+package trader
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Order sides
+const (
+	SideBuy  = "BUY"
+	SideSell = "SELL"
+)
+
+// Order types supported by NewOrder
+const (
+	OrderTypeMarket          = "MARKET"
+	OrderTypeLimit           = "LIMIT"
+	OrderTypeStopLossLimit   = "STOP_LOSS_LIMIT"
+	OrderTypeTakeProfitLimit = "TAKE_PROFIT_LIMIT"
+)
+
+// Order statuses reported by the exchange
+const (
+	OrderStatusNew             = "NEW"
+	OrderStatusPartiallyFilled = "PARTIALLY_FILLED"
+	OrderStatusFilled          = "FILLED"
+	OrderStatusCanceled        = "CANCELED"
+	OrderStatusExpired         = "EXPIRED"
+	OrderStatusRejected        = "REJECTED"
+)
+
+// Time in force values for limit orders
+const (
+	TimeInForceGTC = "GTC" // Good till canceled
+	TimeInForceIOC = "IOC" // Immediate or cancel
+	TimeInForceFOK = "FOK" // Fill or kill
+)
+
+// OrderRequest is a new order. Zero numeric fields are omitted.
+//
+// MARKET orders take either Quantity (base asset) or QuoteOrderQty (quote
+// asset to spend or receive). LIMIT orders need Quantity and Price.
+// STOP_LOSS_LIMIT and TAKE_PROFIT_LIMIT orders additionally need StopPrice,
+// the trigger at which the limit order at Price is placed. TimeInForce
+// defaults to GTC for every limit type.
+type OrderRequest struct {
+	Symbol           string
+	Side             string
+	Type             string
+	TimeInForce      string
+	Quantity         float64
+	QuoteOrderQty    float64
+	Price            float64
+	StopPrice        float64
+	NewClientOrderID string
+}
+
+// Validate checks that the fields required by the order type are set and
+// normalizes side, type and time in force
+func (r *OrderRequest) Validate() error {
+	r.Symbol = strings.ToUpper(r.Symbol)
+	r.Side = strings.ToUpper(r.Side)
+	r.Type = strings.ToUpper(r.Type)
+	r.TimeInForce = strings.ToUpper(r.TimeInForce)
+
+	if r.Symbol == "" {
+		return fmt.Errorf("symbol is required")
+	}
+	if r.Side != SideBuy && r.Side != SideSell {
+		return fmt.Errorf("invalid side %q", r.Side)
+	}
+	if r.Quantity < 0 || r.QuoteOrderQty < 0 || r.Price < 0 || r.StopPrice < 0 {
+		return fmt.Errorf("order values must not be negative")
+	}
+
+	switch r.Type {
+	case OrderTypeMarket:
+		if (r.Quantity > 0) == (r.QuoteOrderQty > 0) {
+			return fmt.Errorf("market orders need exactly one of quantity and quoteOrderQty")
+		}
+		if r.Price > 0 || r.StopPrice > 0 || r.TimeInForce != "" {
+			return fmt.Errorf("market orders take no price, stop price or time in force")
+		}
+		return nil
+	case OrderTypeLimit, OrderTypeStopLossLimit, OrderTypeTakeProfitLimit:
+	default:
+		return fmt.Errorf("unsupported order type %q", r.Type)
+	}
+
+	if r.Quantity <= 0 || r.Price <= 0 {
+		return fmt.Errorf("%s orders need a quantity and a price", r.Type)
+	}
+	if r.QuoteOrderQty > 0 {
+		return fmt.Errorf("quoteOrderQty is only supported for market orders")
+	}
+	if r.Type == OrderTypeLimit && r.StopPrice > 0 {
+		return fmt.Errorf("limit orders take no stop price")
+	}
+	if r.Type != OrderTypeLimit && r.StopPrice <= 0 {
+		return fmt.Errorf("%s orders need a stop price", r.Type)
+	}
+	if r.TimeInForce == "" {
+		r.TimeInForce = TimeInForceGTC
+	}
+	return validateTimeInForce(r.TimeInForce)
+}
+
+// params encodes a validated request for the order endpoint
+func (r OrderRequest) params() url.Values {
+	params := url.Values{}
+	params.Set("symbol", r.Symbol)
+	params.Set("side", r.Side)
+	params.Set("type", r.Type)
+	setDecimal(params, "quantity", r.Quantity)
+	setDecimal(params, "quoteOrderQty", r.QuoteOrderQty)
+	setDecimal(params, "price", r.Price)
+	setDecimal(params, "stopPrice", r.StopPrice)
+	if r.TimeInForce != "" {
+		params.Set("timeInForce", r.TimeInForce)
+	}
+	if r.NewClientOrderID != "" {
+		params.Set("newClientOrderId", r.NewClientOrderID)
+	}
+	// FULL responses include the fills of immediately executed orders
+	params.Set("newOrderRespType", "FULL")
+	return params
+}
+
+// OCORequest is a one-cancels-the-other pair: a limit order at Price and a
+// stop-loss order triggered at StopPrice. When either executes the other is
+// canceled. For a SELL the limit sits above the market and the stop below;
+// for a BUY the other way round.
+//
+// The stop leg is a STOP_LOSS_LIMIT at StopLimitPrice when that is set and
+// a market STOP_LOSS otherwise.
+type OCORequest struct {
+	Symbol               string
+	Side                 string
+	Quantity             float64
+	Price                float64
+	StopPrice            float64
+	StopLimitPrice       float64
+	StopLimitTimeInForce string
+	ListClientOrderID    string
+	LimitClientOrderID   string
+	StopClientOrderID    string
+}
+
+// Validate checks the prices are on the correct sides of each other and
+// normalizes side and time in force
+func (r *OCORequest) Validate() error {
+	r.Symbol = strings.ToUpper(r.Symbol)
+	r.Side = strings.ToUpper(r.Side)
+	r.StopLimitTimeInForce = strings.ToUpper(r.StopLimitTimeInForce)
+
+	if r.Symbol == "" {
+		return fmt.Errorf("symbol is required")
+	}
+	if r.Side != SideBuy && r.Side != SideSell {
+		return fmt.Errorf("invalid side %q", r.Side)
+	}
+	if r.Quantity <= 0 || r.Price <= 0 || r.StopPrice <= 0 {
+		return fmt.Errorf("OCO orders need a quantity, a price and a stop price")
+	}
+	if r.StopLimitPrice < 0 {
+		return fmt.Errorf("stop limit price must not be negative")
+	}
+	if r.Side == SideSell && r.Price <= r.StopPrice {
+		return fmt.Errorf("sell OCO needs the limit price above the stop price")
+	}
+	if r.Side == SideBuy && r.Price >= r.StopPrice {
+		return fmt.Errorf("buy OCO needs the limit price below the stop price")
+	}
+
+	if r.StopLimitPrice == 0 {
+		if r.StopLimitTimeInForce != "" {
+			return fmt.Errorf("stop limit time in force needs a stop limit price")
+		}
+		return nil
+	}
+	if r.StopLimitTimeInForce == "" {
+		r.StopLimitTimeInForce = TimeInForceGTC
+	}
+	return validateTimeInForce(r.StopLimitTimeInForce)
+}
+
+// params encodes a validated request for the OCO endpoint
+func (r OCORequest) params() url.Values {
+	params := url.Values{}
+	params.Set("symbol", r.Symbol)
+	params.Set("side", r.Side)
+	setDecimal(params, "quantity", r.Quantity)
+	setDecimal(params, "price", r.Price)
+	setDecimal(params, "stopPrice", r.StopPrice)
+	setDecimal(params, "stopLimitPrice", r.StopLimitPrice)
+	if r.StopLimitTimeInForce != "" {
+		params.Set("stopLimitTimeInForce", r.StopLimitTimeInForce)
+	}
+	if r.ListClientOrderID != "" {
+		params.Set("listClientOrderId", r.ListClientOrderID)
+	}
+	if r.LimitClientOrderID != "" {
+		params.Set("limitClientOrderId", r.LimitClientOrderID)
+	}
+	if r.StopClientOrderID != "" {
+		params.Set("stopClientOrderId", r.StopClientOrderID)
+	}
+	params.Set("newOrderRespType", "FULL")
+	return params
+}
+
+// ExitOCO builds the take-profit/stop-loss pair that closes a position
+// opened with entrySide, e.g. a SELL OCO protecting a BUY entry
+func ExitOCO(symbol, entrySide string, qty, takeProfit, stopLoss float64) OCORequest {
+	side := SideSell
+	if strings.EqualFold(entrySide, SideSell) {
+		side = SideBuy
+	}
+	return OCORequest{
+		Symbol:         symbol,
+		Side:           side,
+		Quantity:       qty,
+		Price:          takeProfit,
+		StopPrice:      stopLoss,
+		StopLimitPrice: stopLoss,
+	}
+}
+
+// OrderList is the response to an OCO order
+type OrderList struct {
+	OrderListID       int64          `json:"orderListId"`
+	ContingencyType   string         `json:"contingencyType"`
+	ListStatusType    string         `json:"listStatusType"`
+	ListOrderStatus   string         `json:"listOrderStatus"`
+	ListClientOrderID string         `json:"listClientOrderId"`
+	TransactionTime   int64          `json:"transactionTime"`
+	Symbol            string         `json:"symbol"`
+	Orders            []OrderListLeg `json:"orders"`
+	OrderReports      []Order        `json:"orderReports"`
+}
+
+// OrderListLeg identifies one order of an order list
+type OrderListLeg struct {
+	Symbol   string `json:"symbol"`
+	OrderID  int64  `json:"orderId"`
+	ClientID string `json:"clientOrderId"`
+}
+
+// IsOpen reports whether the order can still fill
+func (o Order) IsOpen() bool {
+	return o.Status == OrderStatusNew || o.Status == OrderStatusPartiallyFilled
+}
+
+// AccountTrade is one fill of one of the account's orders
+type AccountTrade struct {
+	Symbol          string `json:"symbol"`
+	ID              int64  `json:"id"`
+	OrderID         int64  `json:"orderId"`
+	OrderListID     int64  `json:"orderListId"`
+	Price           string `json:"price"`
+	Qty             string `json:"qty"`
+	QuoteQty        string `json:"quoteQty"`
+	Commission      string `json:"commission"`
+	CommissionAsset string `json:"commissionAsset"`
+	Time            int64  `json:"time"`
+	IsBuyer         bool   `json:"isBuyer"`
+	IsMaker         bool   `json:"isMaker"`
+	IsBestMatch     bool   `json:"isBestMatch"`
+}
+
+// NewOrder validates and places an order. Quantity and prices are rounded
+// to the symbol's filters first; an order that still breaks them returns a
+// *FilterError without being sent.
+func (c *Client) NewOrder(req OrderRequest) (Order, error) {
+	if err := req.Validate(); err != nil {
+		return Order{}, err
+	}
+	if err := c.applyFilters(&req); err != nil {
+		return Order{}, err
+	}
+
+	var order Order
+	if err := c.signedRequest(http.MethodPost, "/api/v3/order", req.params(), &order); err != nil {
+		return Order{}, err
+	}
+	return order, nil
+}
+
+// PlaceLimitOrder places a GTC limit order
+func (c *Client) PlaceLimitOrder(symbol, side string, qty, price float64) (Order, error) {
+	return c.NewOrder(OrderRequest{
+		Symbol:   symbol,
+		Side:     side,
+		Type:     OrderTypeLimit,
+		Quantity: qty,
+		Price:    price,
+	})
+}
+
+// NewOCO validates and places a one-cancels-the-other order pair, rounded
+// and checked against the symbol's filters like NewOrder
+func (c *Client) NewOCO(req OCORequest) (OrderList, error) {
+	if err := req.Validate(); err != nil {
+		return OrderList{}, err
+	}
+	filters, err := c.GetSymbolFilters(req.Symbol)
+	if err != nil {
+		return OrderList{}, err
+	}
+	if err := filters.ApplyOCO(&req); err != nil {
+		return OrderList{}, err
+	}
+
+	var list OrderList
+	if err := c.signedRequest(http.MethodPost, "/api/v3/order/oco", req.params(), &list); err != nil {
+		return OrderList{}, err
+	}
+	return list, nil
+}
+
+// GetOrder returns the current state of an order
+func (c *Client) GetOrder(symbol string, orderID int64) (Order, error) {
+	var order Order
+	if err := c.signedRequest(http.MethodGet, "/api/v3/order", orderParams(symbol, orderID), &order); err != nil {
+		return Order{}, err
+	}
+	return order, nil
+}
+
+// CancelOrder cancels an open order and returns its final state
+func (c *Client) CancelOrder(symbol string, orderID int64) (Order, error) {
+	var order Order
+	if err := c.signedRequest(http.MethodDelete, "/api/v3/order", orderParams(symbol, orderID), &order); err != nil {
+		return Order{}, err
+	}
+	return order, nil
+}
+
+// GetOpenOrders returns the open orders on symbol, or on every symbol when
+// symbol is empty
+func (c *Client) GetOpenOrders(symbol string) ([]Order, error) {
+	params := url.Values{}
+	if symbol != "" {
+		params.Set("symbol", strings.ToUpper(symbol))
+	}
+
+	var orders []Order
+	if err := c.signedRequest(http.MethodGet, "/api/v3/openOrders", params, &orders); err != nil {
+		return nil, err
+	}
+	return orders, nil
+}
+
+// GetMyTrades returns the account's fills on symbol, oldest first. A
+// non-zero orderID restricts them to that order; limit caps the count.
+func (c *Client) GetMyTrades(symbol string, orderID int64, limit int) ([]AccountTrade, error) {
+	params := url.Values{}
+	params.Set("symbol", strings.ToUpper(symbol))
+	if orderID > 0 {
+		params.Set("orderId", strconv.FormatInt(orderID, 10))
+	}
+	if limit > 0 {
+		params.Set("limit", strconv.Itoa(limit))
+	}
+
+	var trades []AccountTrade
+	if err := c.signedRequest(http.MethodGet, "/api/v3/myTrades", params, &trades); err != nil {
+		return nil, err
+	}
+	return trades, nil
+}
+
+// signedRequest sends a signed request and decodes the JSON response into out
+func (c *Client) signedRequest(method, endpoint string, params url.Values, out interface{}) error {
+	params.Set("timestamp", strconv.FormatInt(time.Now().UnixMilli(), 10))
+
+	query := params.Encode()
+	query += "&signature=" + c.sign(query)
+
+	req, err := http.NewRequest(method, c.baseURL+endpoint+"?"+query, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("X-MBX-APIKEY", c.apiKey)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var errResp ErrorResponse
+		json.NewDecoder(resp.Body).Decode(&errResp)
+		return fmt.Errorf("API error: %d - %s", errResp.Code, errResp.Msg)
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
+
+// applyFilters rounds and checks req against its symbol's filters. Market
+// orders sized in the base asset are valued at the last price, which is
+// only fetched when the symbol has a notional limit for market orders.
+func (c *Client) applyFilters(req *OrderRequest) error {
+	filters, err := c.GetSymbolFilters(req.Symbol)
+	if err != nil {
+		return err
+	}
+
+	var refPrice float64
+	marketNotional := (filters.MinNotional > 0 && filters.MinNotionalMarket) ||
+		(filters.MaxNotional > 0 && filters.MaxNotionalMarket)
+	if req.Type == OrderTypeMarket && req.Quantity > 0 && marketNotional {
+		ticker, err := c.GetTickerPrice(req.Symbol)
+		if err != nil {
+			return err
+		}
+		if refPrice, err = strconv.ParseFloat(ticker.Price, 64); err != nil {
+			return fmt.Errorf("invalid price %q: %w", ticker.Price, err)
+		}
+	}
+	return filters.Apply(req, refPrice)
+}
+
+func orderParams(symbol string, orderID int64) url.Values {
+	params := url.Values{}
+	params.Set("symbol", strings.ToUpper(symbol))
+	params.Set("orderId", strconv.FormatInt(orderID, 10))
+	return params
+}
+
+func validateTimeInForce(tif string) error {
+	switch tif {
+	case TimeInForceGTC, TimeInForceIOC, TimeInForceFOK:
+		return nil
+	default:
+		return fmt.Errorf("invalid time in force %q", tif)
+	}
+}
+
+// setDecimal sets key to v with 8 decimals unless v is zero
+func setDecimal(params url.Values, key string, v float64) {
+	if v != 0 {
+		params.Set(key, strconv.FormatFloat(v, 'f', 8, 64))
+	}
+}
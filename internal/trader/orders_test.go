@@ -0,0 +1,230 @@
+package trader
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// testServer records the last request and answers with status and body.
+// Exchange info lists BTCUSDT without filters so orders are sent unchanged.
+func testServer(t *testing.T, status int, body string) (*Client, *url.Values, *string) {
+	t.Helper()
+	var query url.Values
+	var path string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/v3/exchangeInfo" {
+			w.Write([]byte(`{"symbols":[{"symbol":"BTCUSDT","status":"TRADING","filters":[]}]}`))
+			return
+		}
+		query, path = r.URL.Query(), r.URL.Path
+		if r.Header.Get("X-MBX-APIKEY") != "test-key" {
+			t.Errorf("expected API key header, got %q", r.Header.Get("X-MBX-APIKEY"))
+		}
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(server.Close)
+
+	client := NewClient("test-key", "test-secret")
+	client.baseURL = server.URL
+	return client, &query, &path
+}
+
+func TestOrderRequestValidate(t *testing.T) {
+	cases := []struct {
+		name string
+		req  OrderRequest
+		ok   bool
+	}{
+		{"market qty", OrderRequest{Symbol: "BTCUSDT", Side: "buy", Type: "market", Quantity: 1}, true},
+		{"market quote qty", OrderRequest{Symbol: "BTCUSDT", Side: "BUY", Type: "MARKET", QuoteOrderQty: 100}, true},
+		{"market both qtys", OrderRequest{Symbol: "BTCUSDT", Side: "BUY", Type: "MARKET", Quantity: 1, QuoteOrderQty: 100}, false},
+		{"market price", OrderRequest{Symbol: "BTCUSDT", Side: "BUY", Type: "MARKET", Quantity: 1, Price: 10}, false},
+		{"limit", OrderRequest{Symbol: "BTCUSDT", Side: "SELL", Type: "LIMIT", Quantity: 1, Price: 10}, true},
+		{"limit without price", OrderRequest{Symbol: "BTCUSDT", Side: "SELL", Type: "LIMIT", Quantity: 1}, false},
+		{"limit with stop", OrderRequest{Symbol: "BTCUSDT", Side: "SELL", Type: "LIMIT", Quantity: 1, Price: 10, StopPrice: 9}, false},
+		{"limit bad tif", OrderRequest{Symbol: "BTCUSDT", Side: "SELL", Type: "LIMIT", Quantity: 1, Price: 10, TimeInForce: "DAY"}, false},
+		{"stop loss", OrderRequest{Symbol: "BTCUSDT", Side: "SELL", Type: "STOP_LOSS_LIMIT", Quantity: 1, Price: 9, StopPrice: 9.5}, true},
+		{"take profit without stop", OrderRequest{Symbol: "BTCUSDT", Side: "SELL", Type: "TAKE_PROFIT_LIMIT", Quantity: 1, Price: 11}, false},
+		{"unknown type", OrderRequest{Symbol: "BTCUSDT", Side: "SELL", Type: "ICEBERG", Quantity: 1}, false},
+		{"bad side", OrderRequest{Symbol: "BTCUSDT", Side: "HOLD", Type: "MARKET", Quantity: 1}, false},
+		{"no symbol", OrderRequest{Side: "BUY", Type: "MARKET", Quantity: 1}, false},
+	}
+
+	for _, tc := range cases {
+		if err := tc.req.Validate(); (err == nil) != tc.ok {
+			t.Errorf("%s: expected ok=%v, got %v", tc.name, tc.ok, err)
+		}
+	}
+
+	req := OrderRequest{Symbol: "btcusdt", Side: "sell", Type: "limit", Quantity: 1, Price: 10}
+	if err := req.Validate(); err != nil || req.TimeInForce != TimeInForceGTC || req.Symbol != "BTCUSDT" {
+		t.Fatalf("expected normalized GTC request, got %+v, %v", req, err)
+	}
+}
+
+func TestNewOrder(t *testing.T) {
+	client, query, path := testServer(t, 200, `{"symbol":"BTCUSDT","orderId":7,"clientOrderId":"bot-1","type":"TAKE_PROFIT_LIMIT","timeInForce":"IOC","price":"110.00000000","stopPrice":"109.00000000","status":"NEW","orderListId":-1}`)
+
+	order, err := client.NewOrder(OrderRequest{
+		Symbol:           "BTCUSDT",
+		Side:             SideSell,
+		Type:             OrderTypeTakeProfitLimit,
+		TimeInForce:      TimeInForceIOC,
+		Quantity:         0.5,
+		Price:            110,
+		StopPrice:        109,
+		NewClientOrderID: "bot-1",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if order.OrderID != 7 || order.StopPrice != "109.00000000" || order.TimeInForce != "IOC" {
+		t.Fatalf("unexpected order %+v", order)
+	}
+
+	q := *query
+	if *path != "/api/v3/order" || q.Get("type") != "TAKE_PROFIT_LIMIT" || q.Get("timeInForce") != "IOC" {
+		t.Fatalf("unexpected request %s?%s", *path, q.Encode())
+	}
+	if q.Get("quantity") != "0.50000000" || q.Get("price") != "110.00000000" || q.Get("stopPrice") != "109.00000000" {
+		t.Fatalf("unexpected amounts %s", q.Encode())
+	}
+	if q.Get("newClientOrderId") != "bot-1" || q.Get("signature") == "" || q.Get("timestamp") == "" {
+		t.Fatalf("expected a signed request with the client order ID, got %s", q.Encode())
+	}
+
+	if _, err := client.PlaceOrder("BTCUSDT", "buy", 0); err == nil {
+		t.Fatal("expected validation error before sending")
+	}
+}
+
+func TestNewOrderQuoteQty(t *testing.T) {
+	client, query, _ := testServer(t, 200, `{"orderId":1,"status":"FILLED"}`)
+
+	if _, err := client.NewOrder(OrderRequest{Symbol: "BTCUSDT", Side: SideBuy, Type: OrderTypeMarket, QuoteOrderQty: 25}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	q := *query
+	if q.Get("quoteOrderQty") != "25.00000000" || q.Has("quantity") || q.Has("price") || q.Has("timeInForce") {
+		t.Fatalf("expected only quoteOrderQty, got %s", q.Encode())
+	}
+}
+
+func TestNewOrderAPIError(t *testing.T) {
+	client, _, _ := testServer(t, 400, `{"code":-2010,"msg":"Account has insufficient balance for requested action."}`)
+
+	_, err := client.PlaceLimitOrder("BTCUSDT", "BUY", 1, 100)
+	if err == nil || !strings.Contains(err.Error(), "-2010") {
+		t.Fatalf("expected the API error, got %v", err)
+	}
+}
+
+func TestNewOCO(t *testing.T) {
+	client, query, path := testServer(t, 200, `{
+		"orderListId": 3, "contingencyType": "OCO", "listStatusType": "EXEC_STARTED",
+		"listOrderStatus": "EXECUTING", "symbol": "BTCUSDT",
+		"orders": [{"symbol": "BTCUSDT", "orderId": 10}, {"symbol": "BTCUSDT", "orderId": 11}],
+		"orderReports": [{"orderId": 10, "type": "STOP_LOSS_LIMIT", "orderListId": 3}, {"orderId": 11, "type": "LIMIT_MAKER", "orderListId": 3}]
+	}`)
+
+	list, err := client.NewOCO(ExitOCO("BTCUSDT", "buy", 1, 110, 95))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if list.OrderListID != 3 || len(list.Orders) != 2 || list.OrderReports[1].Type != "LIMIT_MAKER" {
+		t.Fatalf("unexpected order list %+v", list)
+	}
+
+	q := *query
+	if *path != "/api/v3/order/oco" || q.Get("side") != SideSell {
+		t.Fatalf("expected a sell OCO, got %s?%s", *path, q.Encode())
+	}
+	if q.Get("price") != "110.00000000" || q.Get("stopPrice") != "95.00000000" || q.Get("stopLimitPrice") != "95.00000000" || q.Get("stopLimitTimeInForce") != "GTC" {
+		t.Fatalf("unexpected OCO prices %s", q.Encode())
+	}
+}
+
+func TestOCORequestValidate(t *testing.T) {
+	if err := (&OCORequest{Symbol: "BTCUSDT", Side: "SELL", Quantity: 1, Price: 90, StopPrice: 95}).Validate(); err == nil {
+		t.Fatal("expected error for a sell take-profit below the stop")
+	}
+	if err := (&OCORequest{Symbol: "BTCUSDT", Side: "BUY", Quantity: 1, Price: 110, StopPrice: 105}).Validate(); err == nil {
+		t.Fatal("expected error for a buy limit above the stop")
+	}
+	if err := (&OCORequest{Symbol: "BTCUSDT", Side: "SELL", Quantity: 1, Price: 110, StopPrice: 95, StopLimitTimeInForce: "GTC"}).Validate(); err == nil {
+		t.Fatal("expected error for time in force without a stop limit price")
+	}
+
+	req := ExitOCO("BTCUSDT", SideSell, 1, 90, 105)
+	if err := req.Validate(); err != nil || req.Side != SideBuy {
+		t.Fatalf("expected a buy OCO covering a short, got %+v, %v", req, err)
+	}
+}
+
+func TestOrderLifecycle(t *testing.T) {
+	client, query, path := testServer(t, 200, `{"symbol":"BTCUSDT","orderId":9,"status":"PARTIALLY_FILLED","executedQty":"0.5","cummulativeQuoteQty":"50","updateTime":1700000000000}`)
+
+	order, err := client.GetOrder("btcusdt", 9)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if *path != "/api/v3/order" || (*query).Get("orderId") != "9" || (*query).Get("symbol") != "BTCUSDT" {
+		t.Fatalf("unexpected request %s?%s", *path, query.Encode())
+	}
+	if !order.IsOpen() || order.UpdateTime != 1700000000000 {
+		t.Fatalf("unexpected order %+v", order)
+	}
+
+	if _, err := client.CancelOrder("BTCUSDT", 9); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if (*query).Get("signature") == "" {
+		t.Fatal("expected a signed cancel")
+	}
+}
+
+func TestOpenOrdersAndMyTrades(t *testing.T) {
+	client, query, path := testServer(t, 200, `[{"symbol":"BTCUSDT","orderId":1,"status":"NEW"}]`)
+
+	orders, err := client.GetOpenOrders("")
+	if err != nil || len(orders) != 1 || orders[0].OrderID != 1 {
+		t.Fatalf("unexpected open orders %+v, %v", orders, err)
+	}
+	if *path != "/api/v3/openOrders" || (*query).Has("symbol") {
+		t.Fatalf("expected open orders on every symbol, got %s?%s", *path, query.Encode())
+	}
+
+	client, query, path = testServer(t, 200, `[{"symbol":"BTCUSDT","id":5,"orderId":1,"price":"100","qty":"0.5","commission":"0.0005","commissionAsset":"BTC","isBuyer":true}]`)
+	trades, err := client.GetMyTrades("BTCUSDT", 1, 10)
+	if err != nil || len(trades) != 1 || trades[0].ID != 5 || !trades[0].IsBuyer || trades[0].CommissionAsset != "BTC" {
+		t.Fatalf("unexpected trades %+v, %v", trades, err)
+	}
+	if *path != "/api/v3/myTrades" || (*query).Get("orderId") != "1" || (*query).Get("limit") != "10" {
+		t.Fatalf("unexpected request %s?%s", *path, query.Encode())
+	}
+}
+
+func TestOrderFee(t *testing.T) {
+	order := Order{Symbol: "BTCUSDT", Fills: []Fill{
+		{Price: "100", Qty: "0.5", Commission: "0.05", CommissionAsset: "USDT"},
+		{Price: "102", Qty: "0.5", Commission: "0.0005", CommissionAsset: "BTC"},
+		{Price: "102", Qty: "0.1", Commission: "0.0001", CommissionAsset: "BNB"},
+	}}
+	fee, err := order.Fee()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// BNB commission cannot be valued from the order alone
+	if want := 0.05 + 0.0005*102; fee.Quote < want-1e-12 || fee.Quote > want+1e-12 || fee.Base != 0.0005 {
+		t.Fatalf("expected fee %v with 0.0005 BTC, got %+v", want, fee)
+	}
+
+	order.Fills[0].Commission = "n/a"
+	if _, err := order.Fee(); err == nil {
+		t.Fatal("expected error for an invalid commission")
+	}
+}
@@ -0,0 +1,251 @@
+package trader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"math/rand"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// errStale is returned by readStream when nothing arrives within the stale
+// timeout
+var errStale = errors.New("no message received within the stale timeout")
+
+// ReconnectConfig controls how dropped streams are redialed
+type ReconnectConfig struct {
+	InitialBackoff time.Duration // Delay before the first redial
+	MaxBackoff     time.Duration // Cap on the delay between redials
+	Multiplier     float64       // Growth of the delay after each failed redial
+	Jitter         float64       // Fraction of each delay that is randomized, 0 to 1
+	// StaleTimeout drops a connection that has received neither a message
+	// nor a ping for this long; zero disables the check
+	StaleTimeout time.Duration
+}
+
+// DefaultReconnectConfig suits the market data streams, which deliver
+// several messages a second
+func DefaultReconnectConfig() ReconnectConfig {
+	return ReconnectConfig{
+		InitialBackoff: time.Second,
+		MaxBackoff:     time.Minute,
+		Multiplier:     2,
+		Jitter:         0.2,
+		StaleTimeout:   30 * time.Second,
+	}
+}
+
+// Backoff produces jittered, exponentially growing redial delays
+type Backoff struct {
+	config  ReconnectConfig
+	attempt int
+	random  func() float64
+}
+
+// NewBackoff creates a backoff starting at config.InitialBackoff
+func NewBackoff(config ReconnectConfig) *Backoff {
+	return &Backoff{config: config, random: rand.Float64}
+}
+
+// Next returns the delay before the next redial and grows the one after
+func (b *Backoff) Next() time.Duration {
+	delay := float64(b.config.InitialBackoff) * math.Pow(math.Max(b.config.Multiplier, 1), float64(b.attempt))
+	delay = math.Min(delay, float64(b.config.MaxBackoff))
+	b.attempt++
+
+	// Spread redials by up to Jitter either side so that streams dropped
+	// together do not reconnect in lockstep
+	delay *= 1 + b.config.Jitter*(2*b.random()-1)
+	return time.Duration(delay)
+}
+
+// Reset starts the delays over, e.g. once a connection has proven healthy
+func (b *Backoff) Reset() {
+	b.attempt = 0
+}
+
+// StreamStats counts a stream's connections
+type StreamStats struct {
+	Connected       bool      `json:"connected"`
+	Connects        int64     `json:"connects"`
+	Reconnects      int64     `json:"reconnects"`
+	StaleReconnects int64     `json:"stale_reconnects"` // Reconnects because the stream went quiet
+	DialFailures    int64     `json:"dial_failures"`
+	Messages        int64     `json:"messages"`
+	LastConnect     time.Time `json:"last_connect"`
+	LastMessage     time.Time `json:"last_message"`
+	LastError       string    `json:"last_error,omitempty"`
+}
+
+// StreamMetrics records StreamStats for one stream; it is safe for
+// concurrent use
+type StreamMetrics struct {
+	mu    sync.Mutex
+	stats StreamStats
+}
+
+// Connected records a successful dial
+func (m *StreamMetrics) Connected() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.stats.Connects > 0 {
+		m.stats.Reconnects++
+	}
+	m.stats.Connects++
+	m.stats.Connected = true
+	m.stats.LastConnect = time.Now()
+}
+
+// Disconnected records a dropped connection
+func (m *StreamMetrics) Disconnected(err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.stats.Connected = false
+	if errors.Is(err, errStale) {
+		m.stats.StaleReconnects++
+	}
+	if err != nil {
+		m.stats.LastError = err.Error()
+	}
+}
+
+// DialFailed records a failed dial
+func (m *StreamMetrics) DialFailed(err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.stats.DialFailures++
+	m.stats.LastError = err.Error()
+}
+
+// Message records a received message
+func (m *StreamMetrics) Message() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.stats.Messages++
+	m.stats.LastMessage = time.Now()
+}
+
+// Stats returns a copy of the counters
+func (m *StreamMetrics) Stats() StreamStats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.stats
+}
+
+// reconnectingStream keeps a websocket open until its context is done,
+// redialing with backoff whenever the connection drops or goes stale.
+// Binance closes every connection after 24 hours; that is handled like any
+// other drop. Each dial builds its URL afresh, which resubscribes to the
+// stream's current streams.
+type reconnectingStream struct {
+	name    string
+	dial    func(ctx context.Context) (*websocket.Conn, error)
+	handler func([]byte)
+	config  ReconnectConfig
+	metrics *StreamMetrics
+}
+
+// run serves conn, when non-nil, and every connection after it until ctx is
+// done
+func (s *reconnectingStream) run(ctx context.Context, conn *websocket.Conn) {
+	backoff := NewBackoff(s.config)
+	for {
+		var err error
+		if conn == nil {
+			conn, err = s.dial(ctx)
+			if err == nil {
+				s.metrics.Connected()
+			} else if ctx.Err() == nil {
+				s.metrics.DialFailed(err)
+			}
+		}
+		if conn != nil {
+			before := s.metrics.Stats().Messages
+			err = readStream(ctx, conn, s.handler, s.config.StaleTimeout, s.metrics)
+			conn = nil
+			s.metrics.Disconnected(err)
+			// A connection that delivered data was healthy, so a drop after
+			// it is retried quickly
+			if s.metrics.Stats().Messages > before {
+				backoff.Reset()
+			}
+		}
+		if ctx.Err() != nil {
+			return
+		}
+
+		delay := backoff.Next()
+		log.Printf("WebSocket %s: %v, reconnecting in %s", s.name, err, delay.Round(time.Millisecond))
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+	}
+}
+
+// dialStream connects to a websocket stream
+func dialStream(ctx context.Context, wsURL string) (*websocket.Conn, error) {
+	conn, _, err := websocket.DefaultDialer.DialContext(ctx, wsURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to dial websocket: %w", err)
+	}
+	return conn, nil
+}
+
+// readStream passes every message on conn to handler until the connection
+// fails, goes stale or ctx is done, and closes conn. Server pings count as
+// activity and are answered.
+func readStream(ctx context.Context, conn *websocket.Conn, handler func([]byte), staleTimeout time.Duration, metrics *StreamMetrics) error {
+	defer conn.Close()
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close() // Unblocks ReadMessage
+		case <-done:
+		}
+	}()
+
+	extend := func() {
+		if staleTimeout > 0 {
+			conn.SetReadDeadline(time.Now().Add(staleTimeout))
+		}
+	}
+	conn.SetPingHandler(func(data string) error {
+		extend()
+		err := conn.WriteControl(websocket.PongMessage, []byte(data), time.Now().Add(10*time.Second))
+		if errors.Is(err, websocket.ErrCloseSent) {
+			return nil
+		}
+		return err
+	})
+
+	extend()
+	for {
+		_, message, err := conn.ReadMessage()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				return errStale
+			}
+			return fmt.Errorf("read error: %w", err)
+		}
+		extend()
+		if metrics != nil {
+			metrics.Message()
+		}
+		handler(message)
+	}
+}
@@ -0,0 +1,218 @@
+package trader
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestBackoff(t *testing.T) {
+	backoff := NewBackoff(ReconnectConfig{
+		InitialBackoff: time.Second,
+		MaxBackoff:     5 * time.Second,
+		Multiplier:     2,
+		Jitter:         0.5,
+	})
+	backoff.random = func() float64 { return 0.5 } // No jitter
+
+	var delays []time.Duration
+	for i := 0; i < 5; i++ {
+		delays = append(delays, backoff.Next())
+	}
+	want := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second}
+	for i := range want {
+		if delays[i] != want[i] {
+			t.Fatalf("expected delays %v, got %v", want, delays)
+		}
+	}
+
+	backoff.Reset()
+	backoff.random = func() float64 { return 0 }
+	if delay := backoff.Next(); delay != 500*time.Millisecond {
+		t.Errorf("expected full negative jitter to halve the delay, got %s", delay)
+	}
+	backoff.random = func() float64 { return 1 }
+	if delay := backoff.Next(); delay != 3*time.Second {
+		t.Errorf("expected full positive jitter to add half the delay, got %s", delay)
+	}
+}
+
+func TestStreamMetrics(t *testing.T) {
+	var metrics StreamMetrics
+	metrics.Connected()
+	metrics.Message()
+	metrics.Disconnected(errStale)
+	metrics.DialFailed(fmt.Errorf("refused"))
+	metrics.Connected()
+
+	stats := metrics.Stats()
+	if !stats.Connected || stats.Connects != 2 || stats.Reconnects != 1 || stats.StaleReconnects != 1 ||
+		stats.DialFailures != 1 || stats.Messages != 1 || stats.LastError != "refused" {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+}
+
+// testReconnectConfig redials quickly and drops connections idle for 100ms
+var testReconnectConfig = ReconnectConfig{
+	InitialBackoff: 10 * time.Millisecond,
+	MaxBackoff:     50 * time.Millisecond,
+	Multiplier:     2,
+	StaleTimeout:   100 * time.Millisecond,
+}
+
+// streamServer serves websocket connections, passing each to serve along
+// with its index
+func streamServer(t *testing.T, serve func(index int, r *http.Request, conn *websocket.Conn)) *httptest.Server {
+	var mu sync.Mutex
+	connections := 0
+	upgrader := websocket.Upgrader{}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Errorf("upgrade failed: %v", err)
+			return
+		}
+		defer conn.Close()
+
+		mu.Lock()
+		index := connections
+		connections++
+		mu.Unlock()
+		serve(index, r, conn)
+	}))
+}
+
+func TestConnectWebSocketReconnects(t *testing.T) {
+	server := streamServer(t, func(index int, r *http.Request, conn *websocket.Conn) {
+		conn.WriteMessage(websocket.TextMessage, []byte(fmt.Sprintf("message %d", index)))
+		if index == 1 {
+			// Go quiet so the client drops the connection as stale
+			conn.ReadMessage()
+		}
+	})
+	defer server.Close()
+
+	client := NewClient("", "")
+	client.wsURL = "ws" + strings.TrimPrefix(server.URL, "http")
+	client.SetReconnectConfig(testReconnectConfig)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	received := make(chan string, 10)
+	done := make(chan error)
+	go func() {
+		done <- client.SubscribeMultiStream(ctx, []string{"btcusdt@trade"}, func(message []byte) {
+			received <- string(message)
+		})
+	}()
+
+	for i := 0; i < 3; i++ {
+		select {
+		case message := <-received:
+			if message != fmt.Sprintf("message %d", i) {
+				t.Fatalf("expected message %d, got %q", i, message)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("timed out waiting for message %d", i)
+		}
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("expected a clean stop, got %v", err)
+	}
+
+	stats := client.StreamStats()[client.wsURL+"/btcusdt@trade"]
+	if stats.Reconnects < 2 || stats.StaleReconnects != 1 || stats.Messages < 3 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+}
+
+func TestConnectWebSocketDialError(t *testing.T) {
+	client := NewClient("", "")
+	client.wsURL = "ws://127.0.0.1:1"
+	if err := client.SubscribeMultiStream(context.Background(), []string{"btcusdt@trade"}, func([]byte) {}); err == nil {
+		t.Fatal("expected the first dial's error")
+	}
+}
+
+func TestWebSocketManagerResubscribes(t *testing.T) {
+	paths := make(chan string, 10)
+	added := make(chan struct{})
+	server := streamServer(t, func(index int, r *http.Request, conn *websocket.Conn) {
+		paths <- r.URL.Query().Get("streams")
+		if index == 0 {
+			<-added // Drop the first connection once a symbol is added
+			return
+		}
+		conn.ReadMessage() // Stay connected
+	})
+	defer server.Close()
+
+	wsm := NewBinanceWebSocketManager(NewClient("", ""), []string{"BTCUSDT"})
+	wsm.streamURL = "ws" + strings.TrimPrefix(server.URL, "http")
+	wsm.SetReconnectConfig(testReconnectConfig)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := wsm.Start(ctx); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	defer wsm.Stop()
+	wsm.AddSymbol("ethusdt")
+	close(added)
+
+	var got []string
+	for len(got) < 2 {
+		select {
+		case path := <-paths:
+			got = append(got, path)
+		case <-time.After(2 * time.Second):
+			t.Fatalf("timed out waiting for a redial, got %v", got)
+		}
+	}
+	if strings.Contains(got[0], "ethusdt") {
+		t.Fatalf("expected the first connection to predate the new symbol, got %q", got[0])
+	}
+	if !strings.Contains(got[1], "ethusdt@trade") || !strings.Contains(got[1], "btcusdt@trade") {
+		t.Fatalf("expected the redial to subscribe to both symbols, got %q", got[1])
+	}
+	// The server sees the redial just before the client records it
+	deadline := time.Now().Add(time.Second)
+	for wsm.Stats().Reconnects < 1 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if stats := wsm.Stats(); stats.Connects < 2 || stats.Reconnects < 1 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+}
+
+func TestWebSocketManagerSnapshotsMatchStream(t *testing.T) {
+	// The registered domain of a URL's host, e.g. binance.com
+	venue := func(raw string) string {
+		u, err := url.Parse(raw)
+		if err != nil {
+			t.Fatalf("invalid URL %q: %v", raw, err)
+		}
+		labels := strings.Split(u.Hostname(), ".")
+		return strings.Join(labels[len(labels)-2:], ".")
+	}
+
+	for _, client := range []*Client{NewClient("key", "secret"), NewProductionClient("key", "secret")} {
+		wsm := NewBinanceWebSocketManager(client, []string{"BTCUSDT"})
+		if stream, snapshots := venue(wsm.streamURL), venue(wsm.snapshots.baseURL); stream != snapshots {
+			t.Errorf("client %s: diffs stream from %s but snapshots come from %s", client.baseURL, stream, snapshots)
+		}
+		if wsm.snapshots.apiKey != "" {
+			t.Errorf("client %s: expected unsigned public snapshots", client.baseURL)
+		}
+	}
+}
@@ -0,0 +1,322 @@
+package trader
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// userDataStream keys the user data stream's StreamStats
+const userDataStream = "userData"
+
+// userDataStaleTimeout drops a silent user data stream. Order and balance
+// updates can be hours apart, but Binance pings every few minutes.
+const userDataStaleTimeout = 10 * time.Minute
+
+// listenKeyKeepAlive is how often a listen key is extended. Binance expires
+// keys 60 minutes after the last keepalive.
+const listenKeyKeepAlive = 30 * time.Minute
+
+// WSExecutionReport is a user data stream update of one of the account's
+// orders: placed, canceled, rejected, expired or (partially) filled.
+//
+// encoding/json falls back to case-insensitive key matching, so every key
+// that differs from another only in case needs its own field, even when
+// unused; otherwise "I" would overwrite the order ID in "i".
+type WSExecutionReport struct {
+	EventType           string `json:"e"`
+	EventTime           int64  `json:"E"`
+	Symbol              string `json:"s"`
+	ClientOrderID       string `json:"c"`
+	OrigClientOrderID   string `json:"C"` // Set on cancels
+	Side                string `json:"S"`
+	OrderType           string `json:"o"`
+	TimeInForce         string `json:"f"`
+	Quantity            string `json:"q"`
+	QuoteOrderQty       string `json:"Q"`
+	Price               string `json:"p"`
+	StopPrice           string `json:"P"`
+	IcebergQty          string `json:"F"`
+	ExecutionType       string `json:"x"` // NEW, CANCELED, REJECTED, TRADE or EXPIRED
+	OrderStatus         string `json:"X"`
+	RejectReason        string `json:"r"`
+	OrderID             int64  `json:"i"`
+	LastExecutedQty     string `json:"l"`
+	CumulativeFilledQty string `json:"z"`
+	LastExecutedPrice   string `json:"L"`
+	LastQuoteQty        string `json:"Y"`
+	Commission          string `json:"n"`
+	CommissionAsset     string `json:"N"`
+	TransactionTime     int64  `json:"T"`
+	OrderCreationTime   int64  `json:"O"`
+	TradeID             int64  `json:"t"`
+	IsWorking           bool   `json:"w"`
+	WorkingTime         int64  `json:"W"`
+	IsMaker             bool   `json:"m"`
+	CumulativeQuoteQty  string `json:"Z"`
+	OrderListID         int64  `json:"g"`
+	SelfTradePrevention string `json:"V"`
+	UnusedI             int64  `json:"I"`
+	UnusedM             bool   `json:"M"`
+}
+
+// Order returns the order's state after the report, in the form GetOrder
+// returns it
+func (r WSExecutionReport) Order() Order {
+	return Order{
+		Symbol:              r.Symbol,
+		OrderID:             r.OrderID,
+		ClientID:            r.ClientOrderID,
+		Side:                r.Side,
+		Type:                r.OrderType,
+		TimeInForce:         r.TimeInForce,
+		Quantity:            r.Quantity,
+		Price:               r.Price,
+		StopPrice:           r.StopPrice,
+		OrderListID:         r.OrderListID,
+		ExecutedQty:         r.CumulativeFilledQty,
+		CummulativeQuoteQty: r.CumulativeQuoteQty,
+		Status:              r.OrderStatus,
+		UpdateTime:          r.TransactionTime,
+	}
+}
+
+// Fee returns the commission charged on the fill the report announces.
+// Reports of anything but a trade carry no fill and no fee.
+func (r WSExecutionReport) Fee() (Fee, error) {
+	if r.ExecutionType != "TRADE" {
+		return Fee{}, nil
+	}
+	base, quote, err := SplitSymbol(r.Symbol)
+	if err != nil {
+		return Fee{}, err
+	}
+	var fee Fee
+	if err := fee.add(base, quote, r.CommissionAsset, r.Commission, r.LastExecutedPrice); err != nil {
+		return Fee{}, err
+	}
+	return fee, nil
+}
+
+// WSAccountPosition is a user data stream update of the balances that
+// changed
+type WSAccountPosition struct {
+	EventType      string      `json:"e"`
+	EventTime      int64       `json:"E"`
+	LastUpdateTime int64       `json:"u"`
+	Balances       []WSBalance `json:"B"`
+}
+
+// WSBalance is the free and locked amount of one asset
+type WSBalance struct {
+	Asset  string `json:"a"`
+	Free   string `json:"f"`
+	Locked string `json:"l"`
+}
+
+// UserDataHandlers receive user data stream events. Nil handlers are
+// skipped.
+type UserDataHandlers struct {
+	OnExecutionReport func(WSExecutionReport)
+	OnAccountPosition func(WSAccountPosition)
+}
+
+// CreateListenKey starts a user data stream and returns its listen key
+func (c *Client) CreateListenKey() (string, error) {
+	var resp struct {
+		ListenKey string `json:"listenKey"`
+	}
+	if err := c.apiKeyRequest(http.MethodPost, url.Values{}, &resp); err != nil {
+		return "", fmt.Errorf("failed to create listen key: %w", err)
+	}
+	return resp.ListenKey, nil
+}
+
+// KeepAliveListenKey extends a listen key for another 60 minutes
+func (c *Client) KeepAliveListenKey(listenKey string) error {
+	params := url.Values{}
+	params.Set("listenKey", listenKey)
+	if err := c.apiKeyRequest(http.MethodPut, params, nil); err != nil {
+		return fmt.Errorf("failed to keep listen key alive: %w", err)
+	}
+	return nil
+}
+
+// CloseListenKey ends a user data stream
+func (c *Client) CloseListenKey(listenKey string) error {
+	params := url.Values{}
+	params.Set("listenKey", listenKey)
+	if err := c.apiKeyRequest(http.MethodDelete, params, nil); err != nil {
+		return fmt.Errorf("failed to close listen key: %w", err)
+	}
+	return nil
+}
+
+// SubscribeUserDataStream streams the account's order and balance updates
+// until ctx is canceled. The listen key is kept alive while connected and
+// closed on return. Unlike the market data subscriptions it does not
+// redial: a dropped, stale or expired stream needs a new listen key, so it
+// returns an error and the caller starts a new stream.
+func (c *Client) SubscribeUserDataStream(ctx context.Context, handlers UserDataHandlers) error {
+	listenKey, err := c.CreateListenKey()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := c.CloseListenKey(listenKey); err != nil {
+			log.Printf("User data stream: %v", err)
+		}
+	}()
+
+	conn, err := dialStream(ctx, c.userDataURL(listenKey))
+	if err != nil {
+		return fmt.Errorf("failed to connect user data stream: %w", err)
+	}
+	log.Println("Connected to user data stream")
+	metrics := c.streamMetrics(userDataStream)
+	metrics.Connected()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go c.keepListenKeyAlive(ctx, listenKey)
+
+	var streamErr error
+	err = readStream(ctx, conn, func(message []byte) {
+		if err := handleUserData(message, handlers); err != nil && streamErr == nil {
+			streamErr = err
+			conn.Close() // Ends readStream
+		}
+	}, userDataStaleTimeout, metrics)
+	if streamErr != nil {
+		err = streamErr
+	}
+	metrics.Disconnected(err)
+	if err != nil {
+		return fmt.Errorf("user data stream: %w", err)
+	}
+	return nil
+}
+
+// userDataURL returns the raw stream URL of listenKey. The production
+// wsURL has no /ws path, which raw streams need.
+func (c *Client) userDataURL(listenKey string) string {
+	base := strings.TrimSuffix(c.wsURL, "/")
+	if !strings.HasSuffix(base, "/ws") {
+		base += "/ws"
+	}
+	return base + "/" + listenKey
+}
+
+// keepListenKeyAlive extends listenKey until ctx is canceled
+func (c *Client) keepListenKeyAlive(ctx context.Context, listenKey string) {
+	ticker := time.NewTicker(listenKeyKeepAlive)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := c.KeepAliveListenKey(listenKey); err != nil {
+				log.Printf("User data stream: %v", err)
+			}
+		}
+	}
+}
+
+// handleUserData dispatches one user data stream message. It returns an
+// error only when the stream can no longer be used.
+func handleUserData(message []byte, handlers UserDataHandlers) error {
+	var header struct {
+		EventType string `json:"e"`
+		EventTime int64  `json:"E"` // Keeps "E" from matching "e"
+	}
+	if err := json.Unmarshal(message, &header); err != nil {
+		log.Printf("Error unmarshaling user data event: %v", err)
+		return nil
+	}
+
+	switch header.EventType {
+	case "executionReport":
+		var report WSExecutionReport
+		if err := json.Unmarshal(message, &report); err != nil {
+			log.Printf("Error unmarshaling execution report: %v", err)
+			return nil
+		}
+		if handlers.OnExecutionReport != nil {
+			handlers.OnExecutionReport(report)
+		}
+	case "outboundAccountPosition":
+		var position WSAccountPosition
+		if err := json.Unmarshal(message, &position); err != nil {
+			log.Printf("Error unmarshaling account position: %v", err)
+			return nil
+		}
+		if handlers.OnAccountPosition != nil {
+			handlers.OnAccountPosition(position)
+		}
+	case "listenKeyExpired":
+		return fmt.Errorf("user data stream listen key expired")
+	}
+	return nil
+}
+
+// apiKeyRequest sends a request to the user data stream endpoint, which
+// takes the API key but no signature, and decodes the response into out
+// unless out is nil
+func (c *Client) apiKeyRequest(method string, params url.Values, out interface{}) error {
+	endpoint := c.baseURL + "/api/v3/userDataStream"
+	if len(params) > 0 {
+		endpoint += "?" + params.Encode()
+	}
+
+	req, err := http.NewRequest(method, endpoint, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("X-MBX-APIKEY", c.apiKey)
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var errResp ErrorResponse
+		json.NewDecoder(resp.Body).Decode(&errResp)
+		return fmt.Errorf("API error: %d - %s", errResp.Code, errResp.Msg)
+	}
+
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
+
+// ParseBalances extracts the balances of a GetAccountInfo response
+func ParseBalances(account map[string]interface{}) []WSBalance {
+	raw, _ := account["balances"].([]interface{})
+	balances := make([]WSBalance, 0, len(raw))
+	for _, entry := range raw {
+		balance, ok := entry.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		asset, _ := balance["asset"].(string)
+		free, _ := balance["free"].(string)
+		locked, _ := balance["locked"].(string)
+		if asset != "" {
+			balances = append(balances, WSBalance{Asset: asset, Free: free, Locked: locked})
+		}
+	}
+	return balances
+}
@@ -0,0 +1,125 @@
+package trader
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+const executionReportJSON = `{"e":"executionReport","E":1700000000100,"s":"BTCUSDT","c":"bot-1","S":"BUY","o":"LIMIT","f":"GTC",
+	"q":"1.00000000","p":"100.00000000","P":"0.00000000","x":"TRADE","X":"PARTIALLY_FILLED","r":"NONE","i":42,
+	"l":"0.40000000","z":"0.40000000","L":"100.00000000","n":"0.00040000","N":"BTC","T":1700000000099,"t":7,
+	"I":8641984,"w":false,"m":false,"M":false,"O":1700000000000,"Z":"40.00000000","Y":"40.00000000",
+	"Q":"0.00000000","W":1700000000000,"V":"NONE","C":"","g":-1}`
+
+const accountPositionJSON = `{"e":"outboundAccountPosition","E":1700000000200,"u":1700000000199,
+	"B":[{"a":"BTC","f":"0.40000000","l":"0.00000000"},{"a":"USDT","f":"9960.00000000","l":"60.00000000"}]}`
+
+func TestHandleUserData(t *testing.T) {
+	var reports []WSExecutionReport
+	var positions []WSAccountPosition
+	handlers := UserDataHandlers{
+		OnExecutionReport: func(r WSExecutionReport) { reports = append(reports, r) },
+		OnAccountPosition: func(p WSAccountPosition) { positions = append(positions, p) },
+	}
+
+	for _, message := range []string{executionReportJSON, accountPositionJSON, `{"e":"balanceUpdate"}`, `not json`} {
+		if err := handleUserData([]byte(message), handlers); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if len(reports) != 1 || len(positions) != 1 {
+		t.Fatalf("expected one report and one position, got %d and %d", len(reports), len(positions))
+	}
+
+	report := reports[0]
+	// Single-letter keys differ only in case, e.g. s/S and x/X
+	if report.Symbol != "BTCUSDT" || report.Side != "BUY" || report.ExecutionType != "TRADE" || report.OrderStatus != "PARTIALLY_FILLED" {
+		t.Fatalf("unexpected report %+v", report)
+	}
+	order := report.Order()
+	qty, avgPrice, err := order.Executed()
+	if err != nil || order.OrderID != 42 || !order.IsOpen() || qty != 0.4 || avgPrice != 100 {
+		t.Fatalf("unexpected order %+v: %v @ %v (%v)", order, qty, avgPrice, err)
+	}
+	// 0.0004 BTC taken from the fill at 100
+	if fee, err := report.Fee(); err != nil || fee.Base != 0.0004 || fee.Quote < 0.04-1e-12 || fee.Quote > 0.04+1e-12 {
+		t.Fatalf("expected 0.0004 BTC worth 0.04, got %+v (%v)", fee, err)
+	}
+
+	if balances := positions[0].Balances; len(balances) != 2 || balances[1].Asset != "USDT" || balances[1].Locked != "60.00000000" {
+		t.Fatalf("unexpected balances %+v", balances)
+	}
+
+	if err := handleUserData([]byte(`{"e":"listenKeyExpired","E":1700000000300}`), handlers); err == nil {
+		t.Fatal("expected error for an expired listen key")
+	}
+}
+
+func TestUserDataURL(t *testing.T) {
+	if url := NewClient("", "").userDataURL("key"); url != "wss://testnet.binance.vision/ws/key" {
+		t.Errorf("unexpected testnet URL %s", url)
+	}
+	if url := NewProductionClient("", "").userDataURL("key"); url != "wss://stream.binance.com:9443/ws/key" {
+		t.Errorf("unexpected production URL %s", url)
+	}
+}
+
+func TestSubscribeUserDataStream(t *testing.T) {
+	var mu sync.Mutex
+	var calls []string
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/v3/userDataStream" {
+			mu.Lock()
+			calls = append(calls, r.Method+" "+r.URL.Query().Get("listenKey"))
+			mu.Unlock()
+			if r.Header.Get("X-MBX-APIKEY") != "test-key" || r.URL.Query().Has("signature") {
+				t.Errorf("expected an unsigned request with the API key")
+			}
+			w.Write([]byte(`{"listenKey":"abc123"}`))
+			return
+		}
+
+		if r.URL.Path != "/ws/abc123" {
+			t.Errorf("unexpected stream path %s", r.URL.Path)
+		}
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for _, message := range []string{executionReportJSON, accountPositionJSON, `{"e":"listenKeyExpired"}`} {
+			conn.WriteMessage(websocket.TextMessage, []byte(message))
+		}
+		conn.ReadMessage() // Wait for the client to hang up
+	}))
+	defer server.Close()
+
+	client := NewClient("test-key", "test-secret")
+	client.baseURL = server.URL
+	client.wsURL = "ws" + strings.TrimPrefix(server.URL, "http") + "/ws"
+
+	var reports, positions int
+	err := client.SubscribeUserDataStream(context.Background(), UserDataHandlers{
+		OnExecutionReport: func(WSExecutionReport) { reports++ },
+		OnAccountPosition: func(WSAccountPosition) { positions++ },
+	})
+	if err == nil || !strings.Contains(err.Error(), "expired") {
+		t.Fatalf("expected the stream to end on key expiry, got %v", err)
+	}
+	if reports != 1 || positions != 1 {
+		t.Fatalf("expected one report and one position, got %d and %d", reports, positions)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(calls) != 2 || calls[0] != "POST " || calls[1] != "DELETE abc123" {
+		t.Fatalf("expected the listen key to be created and closed, got %v", calls)
+	}
+}
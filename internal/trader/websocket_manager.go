@@ -6,7 +6,7 @@ import (
 	"fmt"
 	"log"
 	"strings"
-	"time"
+	"sync"
 
 	"github.com/gorilla/websocket"
 )
@@ -14,12 +14,16 @@ import (
 // BinanceWebSocketManager manages real-time Binance data streams
 type BinanceWebSocketManager struct {
 	client       *Client
-	conn         *websocket.Conn
+	mu           sync.Mutex // Guards symbols, which each redial reads
 	symbols      []string
 	isRunning    bool
 	ctx          context.Context
 	cancel       context.CancelFunc
 	dataHandlers map[string]func(interface{})
+	reconnect    ReconnectConfig
+	metrics      *StreamMetrics
+	streamURL    string  // Combined stream endpoint, without /stream
+	snapshots    *Client // Public REST client of the venue streamURL belongs to
 }
 
 // CombinedStreamResponse represents the wrapped response from combined streams
@@ -31,21 +35,50 @@ type CombinedStreamResponse struct {
 // WebSocket Event Handlers
 type StreamDataHandler func(symbol, streamType string, data interface{})
 
-// NewBinanceWebSocketManager creates a new WebSocket manager
+// NewBinanceWebSocketManager creates a new WebSocket manager. The combined
+// stream reads production market data whichever venue client trades on, so
+// its order book snapshots come from production as well.
 func NewBinanceWebSocketManager(client *Client, symbols []string) *BinanceWebSocketManager {
+	public := NewProductionClient("", "")
 	return &BinanceWebSocketManager{
 		client:       client,
 		symbols:      symbols,
 		dataHandlers: make(map[string]func(interface{})),
+		reconnect:    DefaultReconnectConfig(),
+		metrics:      &StreamMetrics{},
+		streamURL:    public.wsURL,
+		snapshots:    public,
 	}
 }
 
+// GetOrderBook returns a REST depth snapshot from the venue the stream
+// reads. Local books built from the stream's diffs must start from these:
+// update IDs of another venue, such as the testnet, never line up with
+// them.
+func (wsm *BinanceWebSocketManager) GetOrderBook(symbol string, limit int) (OrderBook, error) {
+	return wsm.snapshots.GetOrderBook(symbol, limit)
+}
+
+// SetReconnectConfig sets how the connection is redialed; call it before
+// Start
+func (wsm *BinanceWebSocketManager) SetReconnectConfig(config ReconnectConfig) {
+	wsm.reconnect = config
+}
+
+// Stats returns the connection counters of the combined stream
+func (wsm *BinanceWebSocketManager) Stats() StreamStats {
+	return wsm.metrics.Stats()
+}
+
 // SetDataHandler sets a handler for specific data types
 func (wsm *BinanceWebSocketManager) SetDataHandler(dataType string, handler func(interface{})) {
 	wsm.dataHandlers[dataType] = handler
 }
 
-// Start begins the WebSocket connection with combined streams
+// Start begins the WebSocket connection with combined streams. Only the
+// first dial's error is returned; after that the connection is redialed
+// with backoff, resubscribing to every symbol, whenever it drops or goes
+// stale.
 func (wsm *BinanceWebSocketManager) Start(ctx context.Context) error {
 	if wsm.isRunning {
 		return fmt.Errorf("WebSocket manager is already running")
@@ -54,26 +87,24 @@ func (wsm *BinanceWebSocketManager) Start(ctx context.Context) error {
 	wsm.ctx, wsm.cancel = context.WithCancel(ctx)
 	wsm.isRunning = true
 
-	// Build combined stream URL using production market data endpoints (real data)
-	// Note: We use production WebSocket for real market data, but testnet for trading
-	streams := wsm.buildCombinedStreams()
-	wsURL := fmt.Sprintf("wss://stream.binance.com:9443/stream?streams=%s", strings.Join(streams, "/"))
-
-	log.Printf("Connecting to Binance Market Data WebSocket (Production Data): %s", wsURL)
-
-	// Connect to WebSocket
-	conn, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	conn, err := wsm.dial(wsm.ctx)
 	if err != nil {
 		wsm.isRunning = false
+		wsm.cancel()
 		return fmt.Errorf("failed to connect to WebSocket: %w", err)
 	}
-	wsm.conn = conn
-
-	// Start message handling
-	go wsm.handleMessages()
-	go wsm.pingHandler()
+	wsm.metrics.Connected()
+
+	stream := &reconnectingStream{
+		name:    "market data",
+		dial:    wsm.dial,
+		handler: wsm.processMessage,
+		config:  wsm.reconnect,
+		metrics: wsm.metrics,
+	}
+	go stream.run(wsm.ctx, conn)
 
-	log.Printf("✅ Binance WebSocket connected successfully with %d streams", len(streams))
+	log.Printf("✅ Binance WebSocket connected successfully with %d streams", len(wsm.buildCombinedStreams()))
 	return nil
 }
 
@@ -90,15 +121,25 @@ func (wsm *BinanceWebSocketManager) Stop() {
 		wsm.cancel()
 	}
 
-	if wsm.conn != nil {
-		wsm.conn.Close()
-	}
-
 	log.Println("✅ Binance WebSocket manager stopped")
 }
 
+// dial connects to the combined stream of the current symbols
+func (wsm *BinanceWebSocketManager) dial(ctx context.Context) (*websocket.Conn, error) {
+	// Build combined stream URL using production market data endpoints (real data)
+	// Note: We use production WebSocket for real market data, but testnet for trading
+	streams := wsm.buildCombinedStreams()
+	wsURL := fmt.Sprintf("%s/stream?streams=%s", wsm.streamURL, strings.Join(streams, "/"))
+
+	log.Printf("Connecting to Binance Market Data WebSocket (Production Data): %s", wsURL)
+	return dialStream(ctx, wsURL)
+}
+
 // buildCombinedStreams creates the stream list for combined connection
 func (wsm *BinanceWebSocketManager) buildCombinedStreams() []string {
+	wsm.mu.Lock()
+	defer wsm.mu.Unlock()
+
 	var streams []string
 
 	for _, symbol := range wsm.symbols {
@@ -109,35 +150,13 @@ func (wsm *BinanceWebSocketManager) buildCombinedStreams() []string {
 			fmt.Sprintf("%s@ticker", symbolLower),   // 24hr ticker statistics
 			fmt.Sprintf("%s@trade", symbolLower),    // Individual trades
 			fmt.Sprintf("%s@kline_1m", symbolLower), // 1-minute candlesticks
-			fmt.Sprintf("%s@depth20", symbolLower),  // Order book top 20 levels
+			fmt.Sprintf("%s@depth", symbolLower),    // Order book diffs for the local book
 		)
 	}
 
 	return streams
 }
 
-// handleMessages processes incoming WebSocket messages
-func (wsm *BinanceWebSocketManager) handleMessages() {
-	defer wsm.conn.Close()
-
-	for {
-		select {
-		case <-wsm.ctx.Done():
-			return
-		default:
-			_, message, err := wsm.conn.ReadMessage()
-			if err != nil {
-				if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
-					log.Printf("WebSocket read error: %v", err)
-				}
-				return
-			}
-
-			wsm.processMessage(message)
-		}
-	}
-}
-
 // processMessage parses and handles individual messages
 func (wsm *BinanceWebSocketManager) processMessage(message []byte) {
 	var response CombinedStreamResponse
@@ -257,6 +276,9 @@ func (wsm *BinanceWebSocketManager) handleDepthData(symbol string, data interfac
 		log.Printf("Error unmarshaling depth event: %v", err)
 		return
 	}
+	if depthEvent.Symbol == "" {
+		depthEvent.Symbol = symbol
+	}
 
 	// Call registered handler
 	if handler, exists := wsm.dataHandlers["depth"]; exists {
@@ -267,40 +289,25 @@ func (wsm *BinanceWebSocketManager) handleDepthData(symbol string, data interfac
 	// 	symbol, len(depthEvent.Bids), len(depthEvent.Asks))
 }
 
-// pingHandler sends periodic ping messages to keep connection alive
-func (wsm *BinanceWebSocketManager) pingHandler() {
-	ticker := time.NewTicker(20 * time.Second)
-	defer ticker.Stop()
-
-	for {
-		select {
-		case <-wsm.ctx.Done():
-			return
-		case <-ticker.C:
-			if wsm.conn != nil {
-				if err := wsm.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
-					log.Printf("Error sending ping: %v", err)
-					return
-				}
-			}
-		}
-	}
-}
-
-// AddSymbol adds a new symbol to monitor (requires restart)
+// AddSymbol adds a new symbol to monitor from the next (re)connect
 func (wsm *BinanceWebSocketManager) AddSymbol(symbol string) {
+	wsm.mu.Lock()
+	defer wsm.mu.Unlock()
+
 	for _, existing := range wsm.symbols {
 		if strings.EqualFold(existing, symbol) {
 			return // Already exists
 		}
 	}
 	wsm.symbols = append(wsm.symbols, strings.ToUpper(symbol))
-	log.Printf("Added symbol %s (restart required)", symbol)
+	log.Printf("Added symbol %s (subscribed on the next reconnect)", symbol)
 }
 
 // GetSymbols returns current monitored symbols
 func (wsm *BinanceWebSocketManager) GetSymbols() []string {
-	return wsm.symbols
+	wsm.mu.Lock()
+	defer wsm.mu.Unlock()
+	return append([]string(nil), wsm.symbols...)
 }
 
 // IsRunning returns the connection status
@@ -0,0 +1,162 @@
+package worker
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"time"
+
+	"github.com/adeilh/agentic_go_signals/internal/db"
+	"github.com/adeilh/agentic_go_signals/internal/risk"
+)
+
+// HaltNotifier is told when bots stop trading; notifications.Notifier
+// implements it
+type HaltNotifier interface {
+	NotifyHalt(botID, reason string) error
+}
+
+// SetNotifier reports circuit breaker trips to notifier. nil stops it.
+func (o *Orchestrator) SetNotifier(notifier HaltNotifier) {
+	o.notifier = notifier
+}
+
+// checkGuard runs the circuit breaker ahead of an execution cycle and
+// reports whether the bot may trade. When the bot's PnL cannot be worked
+// out it sits the cycle out rather than trade blind.
+func (o *Orchestrator) checkGuard() bool {
+	if !o.riskCalc.Guarded() {
+		return true
+	}
+
+	guards := db.NewRiskGuardStore(o.db)
+	if o.guard == nil {
+		state, err := guards.Get(o.botID)
+		if err != nil {
+			log.Printf("Failed to load risk guard for bot %s: %v", o.botID, err)
+			return false
+		}
+		// A tripped bot only runs again once it is re-enabled, which
+		// starts the guard afresh
+		if state != nil && !state.Tripped() {
+			o.setGuard(state)
+		}
+	}
+
+	now := time.Now()
+	since := now
+	if o.guard != nil {
+		since = o.guard.Since
+	}
+	pnl, err := o.portfolio.PnL(o.botID, since)
+	if err != nil {
+		log.Printf("Failed to load PnL for bot %s: %v", o.botID, err)
+		return false
+	}
+	equity := o.accountBalance + pnl.Realized + pnl.Unrealized
+	if o.guard == nil {
+		state := risk.NewGuardState(o.botID, equity, now)
+		o.setGuard(&state)
+	}
+
+	o.guardMu.Lock()
+	reason := o.riskCalc.CheckGuard(o.guard, equity, pnl.ConsecutiveLosses, now)
+	state := *o.guard
+	o.guardMu.Unlock()
+	if err := guards.Save(state); err != nil {
+		log.Printf("Failed to save risk guard for bot %s: %v", o.botID, err)
+	}
+	if reason != "" {
+		o.trip(reason)
+		return false
+	}
+	return true
+}
+
+func (o *Orchestrator) setGuard(state *risk.GuardState) {
+	o.guardMu.Lock()
+	defer o.guardMu.Unlock()
+	o.guard = state
+}
+
+// guardState returns a copy of the circuit breaker state, or nil before
+// it is loaded
+func (o *Orchestrator) guardState() *risk.GuardState {
+	o.guardMu.Lock()
+	defer o.guardMu.Unlock()
+	if o.guard == nil {
+		return nil
+	}
+	state := *o.guard
+	return &state
+}
+
+// trip stops the bot after a risk limit was reached: trading is disabled
+// here and in the registry, so the bot stays stopped across restarts
+// until it is re-enabled
+func (o *Orchestrator) trip(reason string) {
+	log.Printf("Risk guard tripped for bot %s: %s", o.botID, reason)
+	o.SetEnabled(false)
+
+	if o.flattenOnTrip {
+		if err := o.Flatten(); err != nil {
+			log.Printf("Failed to flatten bot %s: %v", o.botID, err)
+		}
+	}
+	if _, err := db.NewBotStore(o.db).SetEnabled(o.botID, false); err != nil {
+		log.Printf("Failed to disable bot %s: %v", o.botID, err)
+	}
+	if o.notifier != nil {
+		if err := o.notifier.NotifyHalt(o.botID, reason); err != nil {
+			log.Printf("Failed to send halt notification for bot %s: %v", o.botID, err)
+		}
+	}
+}
+
+// Flatten closes every open position of the bot with reduce-only market
+// orders, or records closing trades at the mark price when it has no
+// broker. Closing orders are not held to the limits on new orders, so a
+// position larger than one order may be is still closed in full.
+func (o *Orchestrator) Flatten() error {
+	positions, err := o.portfolio.Positions(o.botID)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, position := range positions {
+		if !position.Open() {
+			continue
+		}
+
+		direction := "short"
+		if position.Qty < 0 {
+			direction = "long"
+		}
+		qty := math.Abs(position.Qty)
+		if source, ok := o.broker.(FilterSource); ok {
+			filters, err := source.GetSymbolFilters(position.Symbol)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("failed to get filters for %s: %w", position.Symbol, err))
+				continue
+			}
+			qty = filters.RoundQuantity(qty, true)
+		}
+
+		trade := db.Trade{
+			BotID:  o.botID,
+			Symbol: position.Symbol,
+			Side:   direction,
+			Qty:    qty,
+			Price:  position.MarkPrice,
+			Status: "simulated",
+		}
+		if _, err := o.submit(&trade, true); err != nil {
+			errs = append(errs, fmt.Errorf("failed to close %s: %w", position.Symbol, err))
+			continue
+		}
+		log.Printf("Closed %s position of bot %s: %s %.5f @ %.2f", position.Symbol, o.botID, direction, trade.Qty, trade.Price)
+	}
+	return errors.Join(errs...)
+}
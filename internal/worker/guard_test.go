@@ -0,0 +1,190 @@
+package worker
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/adeilh/agentic_go_signals/internal/db"
+	"github.com/adeilh/agentic_go_signals/internal/exchange"
+	"github.com/adeilh/agentic_go_signals/internal/portfolio"
+	"github.com/adeilh/agentic_go_signals/internal/pretrade"
+	"github.com/adeilh/agentic_go_signals/internal/risk"
+)
+
+type filledTrades []db.Trade
+
+func (f filledTrades) ListFilled(botID string) ([]db.Trade, error) {
+	return f, nil
+}
+
+type auditLog []db.OrderAudit
+
+func (a *auditLog) Record(entry db.OrderAudit) error {
+	*a = append(*a, entry)
+	return nil
+}
+
+func (a *auditLog) Accepted(botID string, since time.Time) ([]db.OrderAudit, error) {
+	var accepted []db.OrderAudit
+	for _, entry := range *a {
+		if entry.Accepted && !entry.Ts.Before(since) {
+			accepted = append(accepted, entry)
+		}
+	}
+	return accepted, nil
+}
+
+type haltRecorder struct {
+	botID, reason string
+}
+
+func (h *haltRecorder) NotifyHalt(botID, reason string) error {
+	h.botID, h.reason = botID, reason
+	return nil
+}
+
+func TestCheckGuard(t *testing.T) {
+	params := risk.RiskParams{
+		AccountBalance:  10000,
+		RiskPerTrade:    0.02,
+		MaxPositionSize: 0.10,
+		StopLossPercent: 0.05,
+	}
+	unguarded, err := risk.NewCalculator(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	params.MaxConsecutiveLosses = 2
+	guarded, err := risk.NewCalculator(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	o := &Orchestrator{db: &db.DB{}, botID: "bot", riskCalc: unguarded}
+	o.SetEnabled(true)
+	if !o.checkGuard() {
+		t.Fatal("expected a bot without limits to trade")
+	}
+
+	// Without the guard's state there is no telling what the bot lost
+	o.riskCalc = guarded
+	if o.checkGuard() {
+		t.Fatal("expected the bot to sit out when the guard state cannot be loaded")
+	}
+
+	start := time.Now().Add(-time.Hour)
+	state := risk.NewGuardState("bot", 10000, start)
+	notifier := &haltRecorder{}
+	o.setGuard(&state)
+	o.accountBalance = 10000
+	o.SetNotifier(notifier)
+	o.portfolio = portfolio.New(filledTrades{
+		{Ts: start.Add(time.Minute), Symbol: "BTCUSDT", Side: "long", Qty: 1, Price: 100},
+		{Ts: start.Add(2 * time.Minute), Symbol: "BTCUSDT", Side: "short", Qty: 1, Price: 95},
+		{Ts: start.Add(3 * time.Minute), Symbol: "BTCUSDT", Side: "long", Qty: 1, Price: 100},
+		{Ts: start.Add(4 * time.Minute), Symbol: "BTCUSDT", Side: "short", Qty: 1, Price: 99},
+	}, nil)
+
+	if o.checkGuard() {
+		t.Fatal("expected two losing trades in a row to stop the bot")
+	}
+	if o.enabled.Load() || !o.guardState().Tripped() {
+		t.Fatal("expected the orchestrator to be disabled and the guard tripped")
+	}
+	if notifier.botID != "bot" || !strings.Contains(notifier.reason, "losing trades") {
+		t.Fatalf("expected a halt notification, got %+v", notifier)
+	}
+}
+
+func TestGuardStatusWhileChecking(t *testing.T) {
+	calc, err := risk.NewCalculator(risk.RiskParams{
+		AccountBalance:  10000,
+		RiskPerTrade:    0.02,
+		MaxPositionSize: 0.10,
+		StopLossPercent: 0.05,
+		MaxDrawdown:     0.5,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	state := risk.NewGuardState("bot", 10000, time.Now())
+	o := &Orchestrator{db: &db.DB{}, botID: "bot", riskCalc: calc, accountBalance: 10000}
+	o.setGuard(&state)
+	o.portfolio = portfolio.New(filledTrades{}, nil)
+	o.SetEnabled(true)
+
+	// The API reads the status while the execute loop checks the guard;
+	// run with -race to catch unsynchronized access
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			o.GetStatus()
+			o.SetEnabled(true)
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		if !o.checkGuard() {
+			t.Fatal("expected a bot within its limits to trade")
+		}
+	}
+	<-done
+}
+
+func TestTripFlattensOversizedPosition(t *testing.T) {
+	params := risk.RiskParams{
+		AccountBalance:   10000,
+		RiskPerTrade:     0.02,
+		MaxPositionSize:  0.10,
+		StopLossPercent:  0.05,
+		MaxDrawdown:      0.05,
+		FlattenOnTrip:    true,
+		MaxOrderNotional: 100,
+	}
+	calc, err := risk.NewCalculator(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ex := exchange.NewFake()
+	ex.SetPrice("BTCUSDT", 40)
+	prices := func(symbol string) (float64, bool) { return 40, true }
+
+	// Built from several orders, the position is worth far more than one
+	// order may be
+	start := time.Now().Add(-time.Hour)
+	trades := filledTrades{
+		{Ts: start.Add(time.Minute), Symbol: "BTCUSDT", Side: "long", Qty: 5, Price: 100},
+		{Ts: start.Add(2 * time.Minute), Symbol: "BTCUSDT", Side: "long", Qty: 5, Price: 100},
+	}
+	o := &Orchestrator{db: &db.DB{}, botID: "bot", riskCalc: calc, accountBalance: 10000, flattenOnTrip: true}
+	o.SetEnabled(true)
+	o.portfolio = portfolio.New(trades, prices)
+	audit := &auditLog{}
+	o.gate = pretrade.NewGate("bot", pretrade.NewPolicy(params, []string{"BTCUSDT"}), audit, prices)
+	o.gate.SetPositions(o.position)
+	o.SetBroker(ex)
+	state := risk.NewGuardState("bot", 10000, start)
+	o.setGuard(&state)
+
+	if o.checkGuard() {
+		t.Fatal("expected the drawdown to stop the bot")
+	}
+
+	orders := ex.Orders()
+	if len(orders) != 1 {
+		t.Fatalf("expected one closing order, got %+v (audit %+v)", orders, *audit)
+	}
+	qty, price, err := orders[0].Executed()
+	if err != nil || orders[0].Side != "SELL" {
+		t.Fatalf("unexpected closing order %+v", orders[0])
+	}
+	closed := append(trades, db.Trade{Ts: time.Now(), Symbol: "BTCUSDT", Side: "short", Qty: qty, Price: price})
+	if position := portfolio.Build(closed)["BTCUSDT"]; position.Open() {
+		t.Fatalf("expected the bot to end up flat, still holding %g", position.Qty)
+	}
+	if entries := *audit; len(entries) != 1 || !entries[0].Accepted || !entries[0].Reduce {
+		t.Fatalf("expected the closing order audited as reduce-only, got %+v", entries)
+	}
+}
@@ -2,19 +2,47 @@ package worker
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
+	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/adeilh/agentic_go_signals/internal/db"
+	"github.com/adeilh/agentic_go_signals/internal/ingest"
+	"github.com/adeilh/agentic_go_signals/internal/portfolio"
+	"github.com/adeilh/agentic_go_signals/internal/predictor"
+	"github.com/adeilh/agentic_go_signals/internal/pretrade"
 	"github.com/adeilh/agentic_go_signals/internal/risk"
+	"github.com/adeilh/agentic_go_signals/internal/services"
+	"github.com/adeilh/agentic_go_signals/internal/strategy"
+	"github.com/adeilh/agentic_go_signals/internal/trader"
 )
 
+// indicatorInterval is the kline interval of the indicators strategies see;
+// the live kline stream stores 1m bars
+const indicatorInterval = "1m"
+
 // Orchestrator coordinates the full trading pipeline
 type Orchestrator struct {
-	db       *db.DB
-	riskCalc *risk.Calculator
+	db         *db.DB
+	strategy   strategy.Strategy
+	marketData *services.MarketDataService
+	riskCalc   *risk.Calculator
+	portfolio  *portfolio.Portfolio
+	gate       *pretrade.Gate
+	broker     OrderPlacer
+	reconciler *Reconciler // Set when the broker can report on its orders
+	notifier   HaltNotifier
+
+	// Circuit breaker. Only the execute loop changes the guard; guardMu
+	// lets the status report read it meanwhile.
+	guardMu        sync.Mutex
+	guard          *risk.GuardState // Loaded on the first check
+	accountBalance float64
+	flattenOnTrip  bool
 
 	// Timing configuration
 	ingestInterval  time.Duration
@@ -29,7 +57,37 @@ type Orchestrator struct {
 	// Bot configuration
 	botID   string
 	symbols []string
-	enabled bool
+	enabled atomic.Bool // Set from the API and the circuit breaker, read by the worker loops
+
+	// Last prediction acted on per symbol, so each prediction trades at most once
+	executedMu sync.Mutex
+	executed   map[string]uint
+
+	// Kelly sizing hit rates by symbol; only the execute loop uses them
+	hitRates map[string]hitRate
+}
+
+// hitRateTTL is how long a symbol's hit rate is reused for Kelly sizing.
+// It only changes as the labeler fills in outcomes, every few minutes.
+const hitRateTTL = 5 * time.Minute
+
+// hitRate is a symbol's prediction hit rate as loaded at a time
+type hitRate struct {
+	rate    float64
+	samples int
+	at      time.Time
+}
+
+// OrderPlacer submits market orders. paper.Account satisfies it, as do the
+// exchanges for live trading.
+type OrderPlacer interface {
+	PlaceOrder(symbol, side string, qty float64) (trader.Order, error)
+}
+
+// FilterSource reports a symbol's order filters. Brokers that implement it
+// have positions sized to the symbol's lot size instead of the default step.
+type FilterSource interface {
+	GetSymbolFilters(symbol string) (trader.SymbolFilters, error)
 }
 
 // Config contains orchestrator configuration
@@ -42,8 +100,10 @@ type Config struct {
 	RiskParams      risk.RiskParams
 }
 
-// NewOrchestrator creates a new trading orchestrator
-func NewOrchestrator(cfg Config, dbConn *db.DB) (*Orchestrator, error) {
+// NewOrchestrator creates a new trading orchestrator. The strategy drives
+// prediction generation and the market data service supplies its analytics
+// and the execution prices.
+func NewOrchestrator(cfg Config, dbConn *db.DB, strat strategy.Strategy, marketData *services.MarketDataService) (*Orchestrator, error) {
 	if cfg.BotID == "" {
 		return nil, fmt.Errorf("bot ID is required")
 	}
@@ -71,8 +131,10 @@ func NewOrchestrator(cfg Config, dbConn *db.DB) (*Orchestrator, error) {
 
 	ctx, cancel := context.WithCancel(context.Background())
 
-	return &Orchestrator{
+	o := &Orchestrator{
 		db:              dbConn,
+		strategy:        strat,
+		marketData:      marketData,
 		riskCalc:        riskCalc,
 		ingestInterval:  cfg.IngestInterval,
 		predictInterval: cfg.PredictInterval,
@@ -81,8 +143,32 @@ func NewOrchestrator(cfg Config, dbConn *db.DB) (*Orchestrator, error) {
 		cancel:          cancel,
 		botID:           cfg.BotID,
 		symbols:         cfg.Symbols,
-		enabled:         true,
-	}, nil
+		executed:        make(map[string]uint),
+		accountBalance:  cfg.RiskParams.AccountBalance,
+		flattenOnTrip:   cfg.RiskParams.FlattenOnTrip,
+	}
+	o.enabled.Store(true)
+	o.portfolio = portfolio.New(db.NewTradeStore(dbConn), o.markPrice)
+	o.gate = pretrade.NewGate(cfg.BotID, pretrade.NewPolicy(cfg.RiskParams, cfg.Symbols), db.NewOrderAuditStore(dbConn), o.markPrice)
+	o.gate.SetPositions(o.position)
+	return o, nil
+}
+
+// SetBroker routes executions through broker. Without one, trades are
+// recorded at the cached price with status "simulated". Brokers that are
+// also OrderTrackers have their open orders reconciled each execution cycle,
+// and those that report an exchange account have its holdings count towards
+// the bot's exposure.
+func (o *Orchestrator) SetBroker(broker OrderPlacer) {
+	o.broker = broker
+	o.reconciler = nil
+	if tracker, ok := broker.(OrderTracker); ok {
+		o.reconciler = NewReconciler(o.botID, db.NewTradeStore(o.db), tracker)
+	}
+	if o.portfolio != nil {
+		account, _ := broker.(portfolio.Account)
+		o.portfolio.SetAccount(account)
+	}
 }
 
 // Start begins the orchestrator pipeline
@@ -107,7 +193,7 @@ func (o *Orchestrator) Start() {
 // Stop gracefully shuts down the orchestrator
 func (o *Orchestrator) Stop() {
 	log.Println("Stopping orchestrator...")
-	o.enabled = false
+	o.enabled.Store(false)
 	o.cancel()
 	o.wg.Wait()
 	log.Println("Orchestrator stopped")
@@ -128,7 +214,7 @@ func (o *Orchestrator) ingestWorker() {
 		case <-o.ctx.Done():
 			return
 		case <-ticker.C:
-			if o.enabled {
+			if o.enabled.Load() {
 				o.runIngestion()
 			}
 		}
@@ -143,14 +229,16 @@ func (o *Orchestrator) predictWorker() {
 	defer ticker.Stop()
 
 	// Wait a bit before first prediction to let ingestion run
-	time.Sleep(120 * time.Second)
+	if !o.wait(120 * time.Second) {
+		return
+	}
 
 	for {
 		select {
 		case <-o.ctx.Done():
 			return
 		case <-ticker.C:
-			if o.enabled {
+			if o.enabled.Load() {
 				o.runPrediction()
 			}
 		}
@@ -165,119 +253,372 @@ func (o *Orchestrator) executeWorker() {
 	defer ticker.Stop()
 
 	// Wait a bit before first execution to let predictions run
-	time.Sleep(2 * time.Minute)
+	if !o.wait(2 * time.Minute) {
+		return
+	}
 
 	for {
 		select {
 		case <-o.ctx.Done():
 			return
 		case <-ticker.C:
-			if o.enabled {
+			if o.enabled.Load() {
 				o.runExecution()
 			}
 		}
 	}
 }
 
-// runIngestion performs data ingestion
-func (o *Orchestrator) runIngestion() {
-	log.Println("Running data ingestion...")
+// wait pauses for d, returning false if the orchestrator is stopped first
+func (o *Orchestrator) wait(d time.Duration) bool {
+	select {
+	case <-o.ctx.Done():
+		return false
+	case <-time.After(d):
+		return true
+	}
+}
 
-	// Mock ingestion for now
-	log.Println("Mock: Fetching news and chain metrics...")
+// runIngestion fetches news and chain metrics into the events tables
+func (o *Orchestrator) runIngestion() {
+	log.Printf("Running data ingestion for bot %s...", o.botID)
 
-	// Create a mock event
-	event := db.Event{
-		BotID:  o.botID,
-		Source: "mock",
-		Text:   "Mock ingestion event",
+	if err := ingest.Save(o.db, o.botID); err != nil {
+		log.Printf("Ingestion failed for bot %s: %v", o.botID, err)
+		return
 	}
 
-	if o.db != nil && o.db.GetConn() != nil {
-		// Insert using raw SQL since we don't have GORM
-		query := `INSERT INTO events (bot_id, source, text, ts) VALUES (?, ?, ?, NOW())`
-		_, err := o.db.GetConn().Exec(query, event.BotID, event.Source, event.Text)
-		if err != nil {
-			log.Printf("Failed to save mock event: %v", err)
-		} else {
-			log.Println("Mock ingestion event saved successfully")
-		}
-	}
+	log.Printf("Ingestion completed for bot %s", o.botID)
 }
 
-// runPrediction generates new predictions
+// runPrediction asks the bot's strategy for a call on each symbol
 func (o *Orchestrator) runPrediction() {
-	log.Println("Running prediction generation...")
+	log.Printf("Running prediction generation for bot %s...", o.botID)
 
 	for _, symbol := range o.symbols {
-		// Mock prediction generation
-		prediction := db.Prediction{
-			BotID:  o.botID,
-			Symbol: symbol,
-			Dir:    "bullish",
-			Conv:   75, // 75% confidence
-			Logic:  "Mock prediction: Market appears neutral",
+		prediction, err := predictor.Generate(o.db, o.strategy, o.botID, o.snapshot(symbol))
+		if err != nil {
+			log.Printf("Failed to generate prediction for %s: %v", symbol, err)
+			continue
 		}
 
-		if o.db != nil && o.db.GetConn() != nil {
-			query := `INSERT INTO predictions (bot_id, symbol, dir, conv, logic, ts) VALUES (?, ?, ?, ?, ?, NOW())`
-			_, err := o.db.GetConn().Exec(query, prediction.BotID, prediction.Symbol, prediction.Dir, prediction.Conv, prediction.Logic)
-			if err != nil {
-				log.Printf("Failed to save prediction for %s: %v", symbol, err)
-				continue
-			}
+		log.Printf("Generated prediction for %s: %s (confidence: %d%%)",
+			symbol, prediction.Dir, prediction.Conv)
+	}
+}
+
+// snapshot gathers the market analytics a strategy decides on. Missing
+// analytics are logged and left empty; predictor.Generate adds the events.
+func (o *Orchestrator) snapshot(symbol string) strategy.Snapshot {
+	snap := strategy.Snapshot{Symbol: symbol, Time: time.Now()}
+	if o.marketData == nil {
+		return snap
+	}
+
+	signals, err := o.marketData.GetAdvancedTiDBSignals(symbol)
+	if err != nil {
+		log.Printf("No market analytics for %s: %v", symbol, err)
+	} else {
+		snap.Signals = signals
+	}
+
+	state, err := o.marketData.GetRealTimeMarketState(symbol)
+	if err != nil {
+		log.Printf("No real-time state for %s: %v", symbol, err)
+	} else {
+		snap.RealTime = state
+	}
+
+	readings, err := o.marketData.GetIndicators(symbol, indicatorInterval)
+	if err != nil {
+		log.Printf("No kline indicators for %s: %v", symbol, err)
+	} else {
+		snap.Indicators = readings
+	}
+
+	return snap
+}
+
+// sizingInputs gathers what the configured sizing mode needs: the ATR for
+// the volatility modes, and the prediction's conviction with the bot's hit
+// rate on the symbol for Kelly sizing. Readings that fail to load are left
+// zero for risk.Calculator to reject or do without.
+func (o *Orchestrator) sizingInputs(symbol string, prediction *db.Prediction) risk.SizingInputs {
+	in := risk.SizingInputs{Conviction: float64(prediction.Conv) / 100}
+
+	switch o.riskCalc.SizingMode() {
+	case risk.SizingATR, risk.SizingVolatility:
+		if o.marketData == nil {
+			break
+		}
+		readings, err := o.marketData.GetIndicators(symbol, indicatorInterval)
+		if err != nil {
+			log.Printf("No ATR to size %s with: %v", symbol, err)
+		} else if readings.ATR14 != nil {
+			in.ATR = *readings.ATR14
 		}
 
-		log.Printf("Generated mock prediction for %s (confidence: %d%%)",
-			symbol, prediction.Conv)
+	case risk.SizingKelly:
+		in.HitRate, in.Samples = o.hitRate(symbol)
 	}
+	return in
 }
 
-// runExecution evaluates predictions and executes trades
+// hitRate returns the bot's hit rate on symbol and the predictions it is
+// over, reloading it once hitRateTTL has passed. A failed load is retried
+// on the next call; until then the rate counts as unknown.
+func (o *Orchestrator) hitRate(symbol string) (float64, int) {
+	if cached, ok := o.hitRates[symbol]; ok && time.Since(cached.at) < hitRateTTL {
+		return cached.rate, cached.samples
+	}
+
+	rate, samples, err := predictor.HitRate(o.db, o.botID, symbol)
+	if err != nil {
+		log.Printf("No hit rate to size %s with: %v", symbol, err)
+		return 0, 0
+	}
+	if o.hitRates == nil {
+		o.hitRates = make(map[string]hitRate)
+	}
+	o.hitRates[symbol] = hitRate{rate: rate, samples: samples, at: time.Now()}
+	return rate, samples
+}
+
+// runExecution acts on the latest prediction for each symbol
 func (o *Orchestrator) runExecution() {
-	log.Println("Running trade execution evaluation...")
+	log.Printf("Running trade execution evaluation for bot %s...", o.botID)
+
+	if o.reconciler != nil {
+		if _, err := o.reconciler.Run(); err != nil {
+			log.Printf("Failed to reconcile orders for bot %s: %v", o.botID, err)
+		}
+	}
+
+	if !o.checkGuard() {
+		return
+	}
 
 	for _, symbol := range o.symbols {
-		// Mock execution
-		currentPrice := 50000.0 // Mock price
-		direction := "long"     // Mock direction
+		if err := o.executeSymbol(symbol); err != nil {
+			log.Printf("Execution skipped for %s: %v", symbol, err)
+		}
+	}
+}
+
+// executeSymbol prices and sizes the latest prediction for a symbol and
+// records the resulting trade
+func (o *Orchestrator) executeSymbol(symbol string) error {
+	prediction, err := predictor.GetLatest(o.db, o.botID, symbol)
+	if err != nil {
+		return err
+	}
+
+	if o.alreadyExecuted(symbol, prediction.ID) {
+		return nil
+	}
 
-		// Calculate position size
-		positionSize, err := o.riskCalc.CalculatePositionSize(currentPrice, direction)
+	// Predictions older than two prediction cycles no longer reflect the market
+	if time.Since(prediction.Ts) > 2*o.predictInterval {
+		return fmt.Errorf("latest prediction %d is stale (%s old)", prediction.ID, time.Since(prediction.Ts).Round(time.Second))
+	}
+
+	direction, ok := tradeDirection(prediction.Dir)
+	if !ok {
+		o.markExecuted(symbol, prediction.ID)
+		log.Printf("Prediction %d for %s is %s, no trade", prediction.ID, symbol, prediction.Dir)
+		return nil
+	}
+
+	currentPrice, err := o.currentPrice(symbol)
+	if err != nil {
+		return err
+	}
+
+	step, err := o.quantityStep(symbol)
+	if err != nil {
+		return err
+	}
+	positionSize, err := o.riskCalc.Size(currentPrice, direction, step, o.sizingInputs(symbol, prediction))
+	if errors.Is(err, risk.ErrNoEdge) {
+		o.markExecuted(symbol, prediction.ID)
+		log.Printf("Prediction %d for %s has no edge to size (conviction %d%%), no trade", prediction.ID, symbol, prediction.Conv)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to calculate position size: %w", err)
+	}
+
+	// Validate against the exposure of the bot's open positions
+	exposure, err := o.portfolio.Exposure(o.botID, o.symbols)
+	if err != nil {
+		return fmt.Errorf("failed to load exposure: %w", err)
+	}
+	value := positionSize.Value
+	if direction == "short" {
+		value = -value
+	}
+	if err := o.riskCalc.ValidateTrade(symbol, value, exposure); err != nil {
+		o.markExecuted(symbol, prediction.ID)
+		return fmt.Errorf("risk validation failed: %w", err)
+	}
+
+	trade := db.Trade{
+		BotID:  o.botID,
+		Symbol: symbol,
+		Side:   direction,
+		Qty:    positionSize.Quantity,
+		Price:  currentPrice,
+		Status: "simulated",
+	}
+
+	recorded, err := o.submit(&trade, false)
+	var filterErr *trader.FilterError
+	var rejection *pretrade.Rejection
+	if errors.As(err, &filterErr) || errors.As(err, &rejection) {
+		// The same prediction would be sized the same way next cycle
+		o.markExecuted(symbol, prediction.ID)
+		return fmt.Errorf("order rejected before sending: %w", err)
+	}
+	if err != nil {
+		return err
+	}
+
+	o.markExecuted(symbol, prediction.ID)
+	if recorded {
+		log.Printf("Trade saved for prediction %d: %s %s %.5f @ %.2f",
+			prediction.ID, direction, symbol, trade.Qty, trade.Price)
+	}
+	return nil
+}
+
+// submit passes trade through the pre-trade checks, places it as a market
+// order through the broker, fills in what executed and records it. Without
+// a broker the checked trade is recorded as it is. Reduce-only trades may
+// only close the open position, and skip the checks that bound new risk.
+// It returns false for orders that closed without filling, which are not
+// recorded.
+func (o *Orchestrator) submit(trade *db.Trade, reduce bool) (bool, error) {
+	request := pretrade.Order{Symbol: trade.Symbol, Side: orderSide(trade.Side), Qty: trade.Qty, Price: trade.Price, Reduce: reduce}
+	if o.broker == nil {
+		if err := o.gate.Check(request); err != nil {
+			return false, err
+		}
+	} else {
+		order, err := o.gate.PlaceOrder(o.broker, request)
+		var filterErr *trader.FilterError
+		var rejection *pretrade.Rejection
+		if errors.As(err, &filterErr) || errors.As(err, &rejection) {
+			return false, err
+		}
 		if err != nil {
-			log.Printf("Failed to calculate position size for %s: %v", symbol, err)
-			continue
+			return false, fmt.Errorf("failed to place order: %w", err)
 		}
-
-		// Validate risk limits (assuming no current exposure for simplicity)
-		err = o.riskCalc.ValidateRiskLimits(positionSize.Value, 0)
+		qty, avgPrice, err := order.Executed()
 		if err != nil {
-			log.Printf("Risk validation failed for %s: %v", symbol, err)
-			continue
+			return false, fmt.Errorf("failed to read order %d: %w", order.OrderID, err)
 		}
-
-		// Create mock trade record
-		trade := db.Trade{
-			BotID:  o.botID,
-			Symbol: symbol,
-			Side:   direction,
-			Qty:    positionSize.Quantity,
-			Price:  currentPrice,
-			Status: "simulated",
+		if qty == 0 && !order.IsOpen() {
+			log.Printf("Order %d for %s was not filled (%s)", order.OrderID, trade.Symbol, order.Status)
+			return false, nil
 		}
+		// Open orders are recorded with what has executed so far and
+		// completed by the reconciler
+		trade.Qty, trade.Status = qty, order.Status
+		trade.OrderID = strconv.FormatInt(order.OrderID, 10)
+		if fee, err := order.Fee(); err != nil {
+			// The fill happened either way; only its PnL is overstated
+			log.Printf("Failed to read the fee of order %d: %v", order.OrderID, err)
+		} else {
+			trade.Fee, trade.BaseFee = fee.Quote, fee.Base
+		}
+		if qty > 0 {
+			trade.Price = avgPrice
+		}
+	}
 
-		// Save trade record
-		if o.db != nil && o.db.GetConn() != nil {
-			query := `INSERT INTO trades (bot_id, symbol, side, qty, price, status, ts) VALUES (?, ?, ?, ?, ?, ?, NOW())`
-			_, err = o.db.GetConn().Exec(query, trade.BotID, trade.Symbol, trade.Side, trade.Qty, trade.Price, trade.Status)
-			if err != nil {
-				log.Printf("Failed to save trade record: %v", err)
-			} else {
-				log.Printf("Mock trade saved: %s %s %.5f @ %.2f",
-					direction, symbol, positionSize.Quantity, currentPrice)
-			}
+	if _, err := db.NewTradeStore(o.db).Record(*trade); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// quantityStep returns the market order lot size of symbol when the broker
+// knows it, and zero for the calculator's default otherwise
+func (o *Orchestrator) quantityStep(symbol string) (float64, error) {
+	source, ok := o.broker.(FilterSource)
+	if !ok {
+		return 0, nil
+	}
+	filters, err := source.GetSymbolFilters(symbol)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get filters for %s: %w", symbol, err)
+	}
+	return filters.QuantityStep(true), nil
+}
+
+// currentPrice returns the cached market price for a symbol
+func (o *Orchestrator) currentPrice(symbol string) (float64, error) {
+	if o.marketData == nil {
+		return 0, fmt.Errorf("market data service is not configured")
+	}
+
+	priceData, exists := o.marketData.GetPriceData(symbol)
+	if !exists || priceData.Price <= 0 {
+		return 0, fmt.Errorf("no cached price for %s", symbol)
+	}
+
+	return priceData.Price, nil
+}
+
+// position is the gate's PositionFunc
+func (o *Orchestrator) position(symbol string) (float64, error) {
+	positions, err := o.portfolio.Positions(o.botID)
+	if err != nil {
+		return 0, err
+	}
+	for _, position := range positions {
+		if position.Symbol == symbol {
+			return position.Qty, nil
 		}
 	}
+	return 0, nil
+}
+
+// markPrice is the portfolio's PriceFunc
+func (o *Orchestrator) markPrice(symbol string) (float64, bool) {
+	price, err := o.currentPrice(symbol)
+	return price, err == nil
+}
+
+func (o *Orchestrator) alreadyExecuted(symbol string, predictionID uint) bool {
+	o.executedMu.Lock()
+	defer o.executedMu.Unlock()
+	return o.executed[symbol] == predictionID
+}
+
+func (o *Orchestrator) markExecuted(symbol string, predictionID uint) {
+	o.executedMu.Lock()
+	defer o.executedMu.Unlock()
+	o.executed[symbol] = predictionID
+}
+
+// tradeDirection maps a prediction direction to a risk calculator direction
+func tradeDirection(dir string) (string, bool) {
+	switch dir {
+	case "LONG":
+		return "long", true
+	case "SHORT":
+		return "short", true
+	}
+	return "", false
+}
+
+// orderSide maps a trade direction to an exchange order side
+func orderSide(direction string) string {
+	if direction == "short" {
+		return "SELL"
+	}
+	return "BUY"
 }
 
 // GetStatus returns the current orchestrator status
@@ -285,18 +626,19 @@ func (o *Orchestrator) GetStatus() map[string]interface{} {
 	return map[string]interface{}{
 		"bot_id":  o.botID,
 		"symbols": o.symbols,
-		"enabled": o.enabled,
+		"enabled": o.enabled.Load(),
 		"intervals": map[string]interface{}{
 			"ingest_minutes":  o.ingestInterval.Minutes(),
 			"predict_minutes": o.predictInterval.Minutes(),
 			"execute_minutes": o.executeInterval.Minutes(),
 		},
 		"risk_metrics": o.riskCalc.GetRiskMetrics(),
+		"risk_guard":   o.guardState(),
 	}
 }
 
 // SetEnabled enables or disables the orchestrator
 func (o *Orchestrator) SetEnabled(enabled bool) {
-	o.enabled = enabled
+	o.enabled.Store(enabled)
 	log.Printf("Orchestrator enabled: %v", enabled)
 }
@@ -6,7 +6,9 @@ import (
 	"time"
 
 	"github.com/adeilh/agentic_go_signals/internal/db"
+	"github.com/adeilh/agentic_go_signals/internal/exchange"
 	"github.com/adeilh/agentic_go_signals/internal/risk"
+	"github.com/adeilh/agentic_go_signals/internal/trader"
 )
 
 func TestNewOrchestrator(t *testing.T) {
@@ -25,7 +27,7 @@ func TestNewOrchestrator(t *testing.T) {
 	database := &db.DB{}
 
 	// Note: This test will pass with mock validation
-	orchestrator, err := NewOrchestrator(cfg, database)
+	orchestrator, err := NewOrchestrator(cfg, database, nil, nil)
 	if err == nil {
 		t.Log("Orchestrator created successfully")
 		_ = orchestrator // Use the variable
@@ -36,7 +38,7 @@ func TestNewOrchestrator(t *testing.T) {
 
 	// Test with missing bot ID
 	cfg.BotID = ""
-	_, err = NewOrchestrator(cfg, database)
+	_, err = NewOrchestrator(cfg, database, nil, nil)
 	if err == nil {
 		t.Fatal("expected error with empty bot ID")
 	}
@@ -113,7 +115,6 @@ func TestOrchestratorStatus(t *testing.T) {
 	orchestrator := &Orchestrator{
 		botID:           "test-bot",
 		symbols:         []string{"BTCUSDT", "ETHUSDT"},
-		enabled:         true,
 		ingestInterval:  5 * time.Minute,
 		predictInterval: 10 * time.Minute,
 		executeInterval: 1 * time.Minute,
@@ -121,6 +122,7 @@ func TestOrchestratorStatus(t *testing.T) {
 		ctx:             ctx,
 		cancel:          cancel,
 	}
+	orchestrator.SetEnabled(true)
 
 	// Test status
 	status := orchestrator.GetStatus()
@@ -140,9 +142,129 @@ func TestOrchestratorStatus(t *testing.T) {
 
 	// Test enable/disable
 	orchestrator.SetEnabled(false)
-	if orchestrator.enabled != false {
+	if orchestrator.enabled.Load() {
 		t.Error("expected orchestrator to be disabled")
 	}
 
 	t.Log("Orchestrator status methods work correctly")
 }
+
+func TestTradeDirection(t *testing.T) {
+	cases := map[string]struct {
+		direction string
+		ok        bool
+	}{
+		"LONG":  {"long", true},
+		"SHORT": {"short", true},
+		"FLAT":  {"", false},
+		"":      {"", false},
+	}
+
+	for dir, want := range cases {
+		direction, ok := tradeDirection(dir)
+		if direction != want.direction || ok != want.ok {
+			t.Errorf("tradeDirection(%q) = %q, %v; want %q, %v", dir, direction, ok, want.direction, want.ok)
+		}
+	}
+}
+
+func TestOrderSide(t *testing.T) {
+	if orderSide("long") != "BUY" || orderSide("short") != "SELL" {
+		t.Fatalf("unexpected order sides %s/%s", orderSide("long"), orderSide("short"))
+	}
+}
+
+func TestExecuteSymbolWithoutDatabase(t *testing.T) {
+	riskCalc, err := risk.NewCalculator(risk.RiskParams{
+		AccountBalance:  10000,
+		RiskPerTrade:    0.02,
+		MaxPositionSize: 0.10,
+		StopLossPercent: 0.05,
+	})
+	if err != nil {
+		t.Fatalf("failed to create risk calculator: %v", err)
+	}
+
+	orchestrator := &Orchestrator{
+		db:              &db.DB{},
+		riskCalc:        riskCalc,
+		botID:           "test-bot",
+		predictInterval: 10 * time.Minute,
+		executed:        make(map[string]uint),
+	}
+
+	if err := orchestrator.executeSymbol("BTCUSDT"); err == nil {
+		t.Fatal("expected error without a database connection")
+	}
+
+	if _, err := orchestrator.currentPrice("BTCUSDT"); err == nil {
+		t.Fatal("expected error without a market data service")
+	}
+}
+
+func TestExchangeAsBroker(t *testing.T) {
+	ex := exchange.NewFake()
+	ex.SetPrice("BTCUSDT", 100)
+
+	orchestrator := &Orchestrator{botID: "test-bot", executed: make(map[string]uint)}
+	orchestrator.SetBroker(ex)
+
+	order, err := orchestrator.broker.PlaceOrder("BTCUSDT", orderSide("short"), 0.5)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if qty, price, _ := order.Executed(); order.Side != "SELL" || qty != 0.5 || price != 100 {
+		t.Fatalf("expected 0.5 sold at 100, got %s %v @ %v", order.Side, qty, price)
+	}
+	if len(ex.Orders()) != 1 {
+		t.Fatalf("expected the order to reach the exchange, got %d", len(ex.Orders()))
+	}
+}
+
+func TestQuantityStep(t *testing.T) {
+	orchestrator := &Orchestrator{botID: "test-bot", executed: make(map[string]uint)}
+
+	// Brokers without filters use the calculator's default
+	orchestrator.SetBroker(placeOnly{})
+	if step, err := orchestrator.quantityStep("BTCUSDT"); err != nil || step != 0 {
+		t.Fatalf("expected the default step, got %v, %v", step, err)
+	}
+
+	ex := exchange.NewFake()
+	ex.SetPrice("BTCUSDT", 100)
+	ex.SetSymbolFilters("BTCUSDT", trader.SymbolFilters{StepSize: 0.001, MarketStepSize: 0.01})
+	orchestrator.SetBroker(ex)
+	if step, err := orchestrator.quantityStep("BTCUSDT"); err != nil || step != 0.01 {
+		t.Fatalf("expected the market lot size, got %v, %v", step, err)
+	}
+	if _, err := orchestrator.quantityStep("ETHUSDT"); err == nil {
+		t.Fatal("expected error for a symbol the exchange does not list")
+	}
+}
+
+func TestSizingInputsCachesHitRate(t *testing.T) {
+	calc, err := risk.NewCalculator(risk.RiskParams{
+		AccountBalance:  10000,
+		RiskPerTrade:    0.02,
+		MaxPositionSize: 0.10,
+		StopLossPercent: 0.05,
+		SizingMode:      risk.SizingKelly,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	orchestrator := &Orchestrator{db: &db.DB{}, botID: "test-bot", riskCalc: calc}
+	orchestrator.hitRates = map[string]hitRate{"BTCUSDT": {rate: 0.6, samples: 40, at: time.Now()}}
+
+	// A fresh hit rate is reused without touching the database
+	in := orchestrator.sizingInputs("BTCUSDT", &db.Prediction{Conv: 70})
+	if in.HitRate != 0.6 || in.Samples != 40 || in.Conviction != 0.7 {
+		t.Fatalf("expected the cached hit rate, got %+v", in)
+	}
+
+	// An expired one is reloaded, which fails without a database
+	orchestrator.hitRates["BTCUSDT"] = hitRate{rate: 0.6, samples: 40, at: time.Now().Add(-hitRateTTL)}
+	if in := orchestrator.sizingInputs("BTCUSDT", &db.Prediction{Conv: 70}); in.HitRate != 0 || in.Samples != 0 {
+		t.Fatalf("expected no hit rate once the cache expired, got %+v", in)
+	}
+}
@@ -0,0 +1,129 @@
+package worker
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+
+	"github.com/adeilh/agentic_go_signals/internal/db"
+	"github.com/adeilh/agentic_go_signals/internal/trader"
+)
+
+// OrderTracker looks up the current state of a placed order. paper.Account
+// and the exchanges satisfy it.
+type OrderTracker interface {
+	GetOrder(symbol string, orderID int64) (trader.Order, error)
+}
+
+// tradeLister lists the fills of an order. Exchanges whose order lookups
+// leave out the fills, such as Binance, satisfy it.
+type tradeLister interface {
+	GetMyTrades(symbol string, orderID int64, limit int) ([]trader.AccountTrade, error)
+}
+
+// tradeLedger is the part of db.TradeStore the reconciler needs
+type tradeLedger interface {
+	ListOpenOrders(botID string) ([]db.Trade, error)
+	UpdateExecution(trade db.Trade) error
+}
+
+// Reconciler brings a bot's trade rows in line with their exchange orders.
+// Trades are recorded when the order is accepted; the reconciler moves those
+// still NEW or PARTIALLY_FILLED to what the exchange reports since.
+type Reconciler struct {
+	botID  string
+	trades tradeLedger
+	orders OrderTracker
+}
+
+// NewReconciler creates a reconciler for botID's trades
+func NewReconciler(botID string, trades *db.TradeStore, orders OrderTracker) *Reconciler {
+	return &Reconciler{botID: botID, trades: trades, orders: orders}
+}
+
+// Run reconciles every open trade and returns how many rows changed. A
+// failure on one trade does not stop the others.
+func (r *Reconciler) Run() (int, error) {
+	trades, err := r.trades.ListOpenOrders(r.botID)
+	if err != nil {
+		return 0, err
+	}
+
+	var errs []error
+	updated := 0
+	for _, trade := range trades {
+		changed, err := r.reconcile(trade)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("trade %d: %w", trade.ID, err))
+			continue
+		}
+		if changed {
+			updated++
+		}
+	}
+	return updated, errors.Join(errs...)
+}
+
+func (r *Reconciler) reconcile(trade db.Trade) (bool, error) {
+	orderID, err := strconv.ParseInt(trade.OrderID, 10, 64)
+	if err != nil {
+		return false, fmt.Errorf("invalid order ID %q", trade.OrderID)
+	}
+	order, err := r.orders.GetOrder(trade.Symbol, orderID)
+	if err != nil {
+		return false, fmt.Errorf("failed to get order %d: %w", orderID, err)
+	}
+
+	next, changed, err := applyOrder(trade, order)
+	if err != nil || !changed {
+		return false, err
+	}
+	if next.Qty != trade.Qty {
+		fee, err := r.fee(order)
+		if err != nil {
+			return false, fmt.Errorf("failed to get commission of order %d: %w", orderID, err)
+		}
+		next.Fee, next.BaseFee = fee.Quote, fee.Base
+	}
+	if err := r.trades.UpdateExecution(next); err != nil {
+		return false, err
+	}
+	log.Printf("Trade %d for bot %s: order %d %s -> %s, %.8f @ %.2f, fee %.8f",
+		trade.ID, r.botID, orderID, trade.Status, next.Status, next.Qty, next.Price, next.Fee)
+	return true, nil
+}
+
+// fee returns the commission charged on everything the order has executed,
+// from its fills or, when the lookup left them out, the account's trades
+func (r *Reconciler) fee(order trader.Order) (trader.Fee, error) {
+	lister, ok := r.orders.(tradeLister)
+	if len(order.Fills) > 0 || !ok {
+		return order.Fee()
+	}
+	trades, err := lister.GetMyTrades(order.Symbol, order.OrderID, 0)
+	if err != nil {
+		return trader.Fee{}, err
+	}
+	return trader.TradesFee(order.Symbol, trades)
+}
+
+// applyOrder updates a trade from its order. The trade's quantity is what
+// has executed; its price stays the order's reference price until something
+// fills and is the average fill price after.
+func applyOrder(trade db.Trade, order trader.Order) (db.Trade, bool, error) {
+	qty, avgPrice, err := order.Executed()
+	if err != nil {
+		return trade, false, err
+	}
+	if order.Status == trade.Status && qty == trade.Qty {
+		return trade, false, nil
+	}
+
+	trade.Status = order.Status
+	trade.Qty = qty
+	if qty > 0 {
+		trade.Price = avgPrice
+	}
+	return trade, true, nil
+}
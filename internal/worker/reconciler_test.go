@@ -0,0 +1,162 @@
+package worker
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/adeilh/agentic_go_signals/internal/db"
+	"github.com/adeilh/agentic_go_signals/internal/exchange"
+	"github.com/adeilh/agentic_go_signals/internal/trader"
+)
+
+// memoryLedger keeps trades in memory in place of db.TradeStore
+type memoryLedger struct {
+	trades []db.Trade
+}
+
+func (m *memoryLedger) ListOpenOrders(botID string) ([]db.Trade, error) {
+	var open []db.Trade
+	for _, trade := range m.trades {
+		if trade.BotID == botID && trade.OrderID != "" && (trade.Status == "NEW" || trade.Status == "PARTIALLY_FILLED") {
+			open = append(open, trade)
+		}
+	}
+	return open, nil
+}
+
+func (m *memoryLedger) UpdateExecution(trade db.Trade) error {
+	for i := range m.trades {
+		if m.trades[i].BotID == trade.BotID && m.trades[i].ID == trade.ID {
+			m.trades[i] = trade
+		}
+	}
+	return nil
+}
+
+// noFillsTracker reports orders without their fills, the way Binance's
+// order lookup does, and lists the fills as account trades
+type noFillsTracker struct {
+	order  trader.Order
+	trades []trader.AccountTrade
+}
+
+func (f *noFillsTracker) GetOrder(symbol string, orderID int64) (trader.Order, error) {
+	return f.order, nil
+}
+
+func (f *noFillsTracker) GetMyTrades(symbol string, orderID int64, limit int) ([]trader.AccountTrade, error) {
+	return f.trades, nil
+}
+
+func TestReconciler(t *testing.T) {
+	ex := exchange.NewFake()
+	ex.SetPrice("BTCUSDT", 100)
+
+	var ledger memoryLedger
+	for i, price := range []float64{95, 96, 97} {
+		order, err := ex.NewOrder(trader.OrderRequest{Symbol: "BTCUSDT", Side: trader.SideBuy, Type: trader.OrderTypeLimit, Quantity: 2, Price: price})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		ledger.trades = append(ledger.trades, db.Trade{
+			ID: uint(i + 1), BotID: "bot_1", Symbol: "BTCUSDT", Side: "long",
+			Price: price, Status: order.Status, OrderID: strconv.FormatInt(order.OrderID, 10),
+		})
+	}
+	ledger.trades = append(ledger.trades, db.Trade{ID: 4, BotID: "bot_1", Symbol: "BTCUSDT", Qty: 1, Price: 100, Status: "simulated"})
+
+	ex.FillOrder(1, 2)
+	ex.FillOrder(2, 0.5)
+	ex.CancelOrder("BTCUSDT", 3)
+
+	reconciler := &Reconciler{botID: "bot_1", trades: &ledger, orders: ex}
+	updated, err := reconciler.Run()
+	if err != nil || updated != 3 {
+		t.Fatalf("expected 3 trades updated, got %d, %v", updated, err)
+	}
+
+	want := []db.Trade{
+		{Status: "FILLED", Qty: 2, Price: 95},
+		{Status: "PARTIALLY_FILLED", Qty: 0.5, Price: 96},
+		{Status: "CANCELED", Qty: 0, Price: 97},
+		{Status: "simulated", Qty: 1, Price: 100},
+	}
+	for i, w := range want {
+		got := ledger.trades[i]
+		if got.Status != w.Status || got.Qty != w.Qty || got.Price != w.Price {
+			t.Errorf("trade %d: expected %s %v @ %v, got %s %v @ %v", i+1, w.Status, w.Qty, w.Price, got.Status, got.Qty, got.Price)
+		}
+	}
+
+	// Nothing changed on the exchange, so nothing to update
+	if updated, err := reconciler.Run(); err != nil || updated != 0 {
+		t.Fatalf("expected no updates, got %d, %v", updated, err)
+	}
+
+	ex.FillOrder(2, 1.5)
+	if updated, _ := reconciler.Run(); updated != 1 || ledger.trades[1].Status != "FILLED" || ledger.trades[1].Qty != 2 {
+		t.Fatalf("expected the partial fill to complete, got %+v", ledger.trades[1])
+	}
+}
+
+func TestReconcilerFee(t *testing.T) {
+	ledger := &memoryLedger{trades: []db.Trade{
+		{ID: 1, BotID: "bot_1", Symbol: "BTCUSDT", Price: 100, Status: "NEW", OrderID: "42", Fee: 0.01},
+	}}
+	tracker := &noFillsTracker{
+		order: trader.Order{Symbol: "BTCUSDT", OrderID: 42, Status: trader.OrderStatusPartiallyFilled,
+			ExecutedQty: "1", CummulativeQuoteQty: "100"},
+		trades: []trader.AccountTrade{
+			{Price: "100", Qty: "0.5", Commission: "0.05", CommissionAsset: "USDT"},
+			{Price: "100", Qty: "0.5", Commission: "0.0005", CommissionAsset: "BTC"},
+		},
+	}
+	reconciler := &Reconciler{botID: "bot_1", trades: ledger, orders: tracker}
+
+	if updated, err := reconciler.Run(); err != nil || updated != 1 {
+		t.Fatalf("expected the trade updated, got %d, %v", updated, err)
+	}
+	// The fee covers every fill, not just those the trade was recorded with
+	if got := ledger.trades[0]; got.Fee < 0.1-1e-12 || got.Fee > 0.1+1e-12 || got.BaseFee != 0.0005 {
+		t.Fatalf("expected fee 0.1 with 0.0005 BTC, got %v and %v", got.Fee, got.BaseFee)
+	}
+
+	// A status change without a new fill leaves the fee alone
+	tracker.order.Status = trader.OrderStatusCanceled
+	tracker.trades = nil
+	if updated, err := reconciler.Run(); err != nil || updated != 1 || ledger.trades[0].Fee < 0.1-1e-12 {
+		t.Fatalf("expected the fee kept, got %+v (%d, %v)", ledger.trades[0], updated, err)
+	}
+}
+
+func TestReconcilerErrors(t *testing.T) {
+	ledger := &memoryLedger{trades: []db.Trade{
+		{ID: 1, BotID: "bot_1", Symbol: "BTCUSDT", Status: "NEW", OrderID: "abc"},
+		{ID: 2, BotID: "bot_1", Symbol: "BTCUSDT", Status: "NEW", OrderID: "42"},
+	}}
+	reconciler := &Reconciler{botID: "bot_1", trades: ledger, orders: exchange.NewFake()}
+
+	if updated, err := reconciler.Run(); err == nil || updated != 0 {
+		t.Fatalf("expected errors for a bad and an unknown order ID, got %d, %v", updated, err)
+	}
+}
+
+func TestSetBrokerReconciles(t *testing.T) {
+	orchestrator := &Orchestrator{db: &db.DB{}, botID: "bot_1"}
+
+	orchestrator.SetBroker(exchange.NewFake())
+	if orchestrator.reconciler == nil {
+		t.Fatal("expected a reconciler for a broker that tracks orders")
+	}
+	orchestrator.SetBroker(placeOnly{})
+	if orchestrator.reconciler != nil {
+		t.Fatal("expected no reconciler for a broker that cannot report orders")
+	}
+}
+
+// placeOnly is a broker without order lookups
+type placeOnly struct{}
+
+func (placeOnly) PlaceOrder(symbol, side string, qty float64) (trader.Order, error) {
+	return trader.Order{}, nil
+}
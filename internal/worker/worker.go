@@ -2,9 +2,205 @@ package worker
 
 import (
 	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/adeilh/agentic_go_signals/internal/db"
+	"github.com/adeilh/agentic_go_signals/internal/kimi"
+	"github.com/adeilh/agentic_go_signals/internal/paper"
+	"github.com/adeilh/agentic_go_signals/internal/services"
+	"github.com/adeilh/agentic_go_signals/internal/strategy"
 )
 
-func Start(ctx context.Context, db interface{}) error {
-	<-ctx.Done()
-	return nil
+// syncInterval controls how often the bot registry is re-read
+const syncInterval = time.Minute
+
+// Manager runs one orchestrator per enabled bot in the registry
+type Manager struct {
+	db         *db.DB
+	bots       *db.BotStore
+	kimiClient *kimi.Client
+	marketData *services.MarketDataService
+	broker     *paper.Broker
+	live       OrderPlacer
+	notifier   HaltNotifier
+
+	mu      sync.Mutex
+	running map[string]*managedBot
+}
+
+type managedBot struct {
+	orchestrator *Orchestrator
+	updatedAt    time.Time
+}
+
+// NewManager creates a manager for the bots stored in the database. When
+// broker is set each bot trades through its own paper account; otherwise
+// bots place their orders with live, and only simulate trades if that is
+// nil too.
+func NewManager(database *db.DB, kimiClient *kimi.Client, marketData *services.MarketDataService, broker *paper.Broker, live OrderPlacer) *Manager {
+	return &Manager{
+		db:         database,
+		bots:       db.NewBotStore(database),
+		kimiClient: kimiClient,
+		marketData: marketData,
+		broker:     broker,
+		live:       live,
+		running:    make(map[string]*managedBot),
+	}
+}
+
+// Start runs orchestrators for all enabled bots until ctx is cancelled
+func Start(ctx context.Context, database *db.DB, kimiClient *kimi.Client, marketData *services.MarketDataService, broker *paper.Broker, live OrderPlacer) error {
+	if database == nil || database.GetConn() == nil {
+		return fmt.Errorf("database connection is nil")
+	}
+
+	return NewManager(database, kimiClient, marketData, broker, live).Run(ctx)
+}
+
+// SetNotifier reports bots stopped by their risk limits or the kill switch
+// to notifier
+func (m *Manager) SetNotifier(notifier HaltNotifier) {
+	m.notifier = notifier
+}
+
+// Run keeps the running orchestrators in line with the bot registry
+func (m *Manager) Run(ctx context.Context) error {
+	defer m.stopAll()
+
+	ticker := time.NewTicker(syncInterval)
+	defer ticker.Stop()
+
+	m.sync()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			m.sync()
+		}
+	}
+}
+
+// sync starts newly enabled bots, restarts bots whose configuration changed
+// and stops bots that were disabled or removed
+func (m *Manager) sync() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	bots, err := m.bots.ListEnabled()
+	if err != nil {
+		log.Printf("Failed to load bots: %v", err)
+		return
+	}
+
+	active := make(map[string]bool, len(bots))
+	for _, bot := range bots {
+		active[bot.ID] = true
+
+		if current, ok := m.running[bot.ID]; ok {
+			if current.updatedAt.Equal(bot.UpdatedAt) {
+				continue
+			}
+			log.Printf("Configuration changed for bot %s, restarting", bot.ID)
+			m.stop(bot.ID)
+		}
+
+		strat, err := strategy.New(bot.Strategy, m.kimiClient)
+		if err != nil {
+			log.Printf("Failed to create strategy for bot %s: %v", bot.ID, err)
+			continue
+		}
+
+		orchestrator, err := NewOrchestrator(configFromBot(bot), m.db, strat, m.marketData)
+		if err != nil {
+			log.Printf("Failed to create orchestrator for bot %s: %v", bot.ID, err)
+			continue
+		}
+		if m.broker != nil {
+			orchestrator.SetBroker(m.broker.Account(bot.ID))
+		} else if m.live != nil {
+			orchestrator.SetBroker(m.live)
+		}
+		if m.notifier != nil {
+			orchestrator.SetNotifier(m.notifier)
+		}
+
+		orchestrator.Start()
+		m.running[bot.ID] = &managedBot{orchestrator: orchestrator, updatedAt: bot.UpdatedAt}
+	}
+
+	for botID := range m.running {
+		if !active[botID] {
+			m.stop(botID)
+		}
+	}
+}
+
+// HaltAll is the kill switch: it stops every running bot at once, closing
+// their positions first when flatten is set, and returns how many it
+// stopped. Bots must also be disabled in the registry, or the next sync
+// starts them again.
+func (m *Manager) HaltAll(reason string, flatten bool) int {
+	// Stopping and flattening wait on the exchange, so they run after the
+	// lock is released, leaving the manager free meanwhile
+	m.mu.Lock()
+	halting := make(map[string]*Orchestrator, len(m.running))
+	for botID, current := range m.running {
+		halting[botID] = current.orchestrator
+		delete(m.running, botID)
+	}
+	m.mu.Unlock()
+
+	for _, orchestrator := range halting {
+		orchestrator.Stop()
+	}
+	if flatten {
+		for botID, orchestrator := range halting {
+			if err := orchestrator.Flatten(); err != nil {
+				log.Printf("Failed to flatten bot %s: %v", botID, err)
+			}
+		}
+	}
+	halted := len(halting)
+
+	log.Printf("Kill switch halted %d bots: %s", halted, reason)
+	if m.notifier != nil {
+		if err := m.notifier.NotifyHalt("all", reason); err != nil {
+			log.Printf("Failed to send halt notification: %v", err)
+		}
+	}
+	return halted
+}
+
+func (m *Manager) stop(botID string) {
+	if current, ok := m.running[botID]; ok {
+		current.orchestrator.Stop()
+		delete(m.running, botID)
+	}
+}
+
+func (m *Manager) stopAll() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for botID := range m.running {
+		m.stop(botID)
+	}
+}
+
+// configFromBot converts a persisted bot into orchestrator configuration
+func configFromBot(bot db.Bot) Config {
+	return Config{
+		BotID:           bot.ID,
+		Symbols:         bot.Symbols,
+		IngestInterval:  time.Duration(bot.IngestIntervalSec) * time.Second,
+		PredictInterval: time.Duration(bot.PredictIntervalSec) * time.Second,
+		ExecuteInterval: time.Duration(bot.ExecuteIntervalSec) * time.Second,
+		RiskParams:      bot.RiskParams,
+	}
 }
@@ -4,14 +4,98 @@ import (
 	"context"
 	"testing"
 	"time"
+
+	"github.com/adeilh/agentic_go_signals/internal/db"
+	"github.com/adeilh/agentic_go_signals/internal/portfolio"
+	"github.com/adeilh/agentic_go_signals/internal/risk"
 )
 
+// slowTrades answers ListFilled only once released, like a database or
+// exchange that has stopped responding
+type slowTrades struct {
+	called  chan struct{}
+	release chan struct{}
+}
+
+func (s slowTrades) ListFilled(botID string) ([]db.Trade, error) {
+	close(s.called)
+	<-s.release
+	return nil, nil
+}
+
 func TestStart(t *testing.T) {
 	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
 	defer cancel()
 
-	err := Start(ctx, nil)
-	if err != nil {
-		t.Fatal(err)
+	// Start needs the bot registry, so it must refuse to run without a database
+	if err := Start(ctx, nil, nil, nil, nil, nil); err == nil {
+		t.Fatal("expected error with nil database")
+	}
+
+	if err := Start(ctx, &db.DB{}, nil, nil, nil, nil); err == nil {
+		t.Fatal("expected error with nil connection")
+	}
+}
+
+func TestConfigFromBot(t *testing.T) {
+	bot := db.Bot{
+		ID:                 "bot_test",
+		Symbols:            []string{"BTCUSDT"},
+		IngestIntervalSec:  300,
+		PredictIntervalSec: 600,
+		ExecuteIntervalSec: 60,
+		RiskParams: risk.RiskParams{
+			AccountBalance:  10000,
+			RiskPerTrade:    0.02,
+			MaxPositionSize: 0.10,
+			StopLossPercent: 0.05,
+		},
+	}
+
+	cfg := configFromBot(bot)
+
+	if cfg.BotID != "bot_test" {
+		t.Errorf("expected bot ID bot_test, got %s", cfg.BotID)
+	}
+	if cfg.IngestInterval != 5*time.Minute {
+		t.Errorf("expected ingest interval 5m, got %v", cfg.IngestInterval)
+	}
+	if cfg.PredictInterval != 10*time.Minute {
+		t.Errorf("expected predict interval 10m, got %v", cfg.PredictInterval)
+	}
+	if cfg.ExecuteInterval != time.Minute {
+		t.Errorf("expected execute interval 1m, got %v", cfg.ExecuteInterval)
+	}
+	if cfg.RiskParams.AccountBalance != 10000 {
+		t.Errorf("expected account balance 10000, got %v", cfg.RiskParams.AccountBalance)
+	}
+}
+
+func TestHaltAllReleasesManagerWhileFlattening(t *testing.T) {
+	trades := slowTrades{called: make(chan struct{}), release: make(chan struct{})}
+	o := &Orchestrator{db: &db.DB{}, botID: "bot_1", portfolio: portfolio.New(trades, nil)}
+	o.ctx, o.cancel = context.WithCancel(context.Background())
+	o.SetEnabled(true)
+	m := &Manager{running: map[string]*managedBot{"bot_1": {orchestrator: o}}}
+
+	halted := make(chan int)
+	go func() { halted <- m.HaltAll("test", true) }()
+	<-trades.called
+
+	// The flatten is stuck, but the manager is not
+	stopped := make(chan struct{})
+	go func() {
+		m.stopAll()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("expected the manager usable while bots are flattened")
+	}
+
+	close(trades.release)
+	if n := <-halted; n != 1 || o.enabled.Load() {
+		t.Fatalf("expected the bot halted, got %d", n)
 	}
 }
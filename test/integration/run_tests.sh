@@ -141,44 +141,44 @@ case "${1:-all}" in
         echo ""
         
         print_status "🗄️  Testing TiDB integration..."
-        go test ./test/integration -v -run TestIntegrationFullSystem/TiDB
+        go test -tags integration ./test/integration -v -run TestIntegrationFullSystem/TiDB
         
         print_status "🤖 Testing Kimi AI integration..."
-        go test ./test/integration -v -run TestIntegrationFullSystem/Kimi
+        go test -tags integration ./test/integration -v -run TestIntegrationFullSystem/Kimi
         
         print_status "📈 Testing Binance integration..."
-        go test ./test/integration -v -run TestIntegrationFullSystem/Binance
+        go test -tags integration ./test/integration -v -run TestIntegrationFullSystem/Binance
         
         print_status "🔄 Testing end-to-end signal generation..."
-        go test ./test/integration -v -run TestIntegrationFullSystem/End_To_End
+        go test -tags integration ./test/integration -v -run TestIntegrationFullSystem/End_To_End
         
         print_status "⚡ Testing TTL features..."
-        go test ./test/integration -v -run TestIntegrationFullSystem/TTL
+        go test -tags integration ./test/integration -v -run TestIntegrationFullSystem/TTL
         
         print_status "🧮 Testing vector storage..."
-        go test ./test/integration -v -run TestIntegrationFullSystem/Vector
+        go test -tags integration ./test/integration -v -run TestIntegrationFullSystem/Vector
         ;;
     
     "tidb")
         print_status "🗄️  Testing TiDB integration only..."
-        go test ./test/integration -v -run TestIntegrationFullSystem/TiDB
-        go test ./test/integration -v -run TestIntegrationFullSystem/TTL
-        go test ./test/integration -v -run TestIntegrationFullSystem/Vector
+        go test -tags integration ./test/integration -v -run TestIntegrationFullSystem/TiDB
+        go test -tags integration ./test/integration -v -run TestIntegrationFullSystem/TTL
+        go test -tags integration ./test/integration -v -run TestIntegrationFullSystem/Vector
         ;;
     
     "ai")
         print_status "🤖 Testing Kimi AI integration only..."
-        go test ./test/integration -v -run TestIntegrationFullSystem/Kimi
+        go test -tags integration ./test/integration -v -run TestIntegrationFullSystem/Kimi
         ;;
     
     "binance")
         print_status "📈 Testing Binance integration only..."
-        go test ./test/integration -v -run TestIntegrationFullSystem/Binance
+        go test -tags integration ./test/integration -v -run TestIntegrationFullSystem/Binance
         ;;
     
     "e2e")
         print_status "🔄 Testing end-to-end flow only..."
-        go test ./test/integration -v -run TestIntegrationFullSystem/End_To_End
+        go test -tags integration ./test/integration -v -run TestIntegrationFullSystem/End_To_End
         ;;
     
     "api")
@@ -206,7 +206,7 @@ case "${1:-all}" in
             fi
         fi
         
-        go test ./test/integration -v -run TestIntegrationAPI
+        go test -tags integration ./test/integration -v -run TestIntegrationAPI
         
         # Stop server if we started it
         if [ ! -z "$SERVER_PID" ]; then
@@ -217,7 +217,7 @@ case "${1:-all}" in
     
     "bench")
         print_status "⚡ Running performance benchmarks..."
-        go test ./test/integration -bench=. -v
+        go test -tags integration ./test/integration -bench=. -v
         ;;
     
     "help"|"-h"|"--help")